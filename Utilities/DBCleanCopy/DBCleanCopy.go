@@ -12,45 +12,35 @@ import (
 
 const level string = "level"
 const bolt string = "bolt"
+const badger string = "badger"
 
 func main() {
 	fmt.Println("Usage:")
-	fmt.Println("DBCleanCopy level/bolt DBFileLocation")
+	fmt.Println("DBCleanCopy level/bolt/badger DBFileLocation [level/bolt/badger CopyFileLocation]")
 	fmt.Println("Database will be copied over block by block to remove some DB inconsistencies")
+	fmt.Println("The copy is of the same type in copied.db unless another type and location are given,")
+	fmt.Println("so `DBCleanCopy level factoid_level.db badger factoid_badger.db` migrates a LevelDB to Badger")
 
-	if len(os.Args) < 3 {
-		fmt.Println("\nNot enough arguments passed")
-		os.Exit(1)
-	}
-	if len(os.Args) > 3 {
-		fmt.Println("\nToo many arguments passed")
+	if len(os.Args) != 3 && len(os.Args) != 5 {
+		fmt.Println("\nWrong number of arguments passed")
 		os.Exit(1)
 	}
 
-	levelBolt := os.Args[1]
+	fromType, fromPath := os.Args[1], os.Args[2]
+	toType, toPath := fromType, "copied.db"
+	if len(os.Args) == 5 {
+		toType, toPath = os.Args[3], os.Args[4]
+	}
 
-	if levelBolt != level && levelBolt != bolt {
-		fmt.Println("\nFirst argument should be `level` or `bolt`")
+	dbase1, err := OpenDB(fromType, fromPath, false)
+	if err != nil {
+		fmt.Printf("\nCould not open %v - %v\n", fromPath, err)
 		os.Exit(1)
 	}
-	path := os.Args[2]
-
-	var dbase1 *hybridDB.HybridDB
-	var dbase2 *hybridDB.HybridDB
-
-	var err error
-	if levelBolt == bolt {
-		dbase1 = hybridDB.NewBoltMapHybridDB(nil, path)
-		dbase2 = hybridDB.NewBoltMapHybridDB(nil, "copied.db")
-	} else {
-		dbase1, err = hybridDB.NewLevelMapHybridDB(path, false)
-		if err != nil {
-			panic(err)
-		}
-		dbase2, err = hybridDB.NewLevelMapHybridDB("copied.db", true)
-		if err != nil {
-			panic(err)
-		}
+	dbase2, err := OpenDB(toType, toPath, true)
+	if err != nil {
+		fmt.Printf("\nCould not create %v - %v\n", toPath, err)
+		os.Exit(1)
 	}
 
 	dbo1 := databaseOverlay.NewOverlay(dbase1)
@@ -62,6 +52,19 @@ func main() {
 	dbo2.Close()
 }
 
+// OpenDB opens a database of the given type, one of level, bolt or badger
+func OpenDB(dbType, path string, create bool) (*hybridDB.HybridDB, error) {
+	switch dbType {
+	case bolt:
+		return hybridDB.NewBoltMapHybridDB(nil, path), nil
+	case level:
+		return hybridDB.NewLevelMapHybridDB(path, create)
+	case badger:
+		return hybridDB.NewBadgerMapHybridDB(path, create)
+	}
+	return nil, fmt.Errorf("Database type should be `level`, `bolt` or `badger`, not `%v`", dbType)
+}
+
 func CopyDB(dbase1, dbase2 interfaces.DBOverlay) {
 	processing := ""
 	defer func() {
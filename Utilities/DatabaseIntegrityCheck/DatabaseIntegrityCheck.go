@@ -57,6 +57,31 @@ func main() {
 	fmt.Println("")
 }
 
+// CheckBlockIndex goes through a height index one record at a time, so large databases don't
+// have to fit in memory, and reports the heights that point to blocks not found in the chain
+func CheckBlockIndex(dbo interfaces.DBOverlay, bucket []byte, blockType string, hashMap map[string]string) {
+	iter, err := dbo.NewIterator(bucket, nil)
+	if err != nil {
+		panic(err)
+	}
+	defer iter.Release()
+
+	for iter.Next() {
+		h := primitives.NewZeroHash()
+		err = h.UnmarshalBinary(iter.Value())
+		if err != nil {
+			fmt.Printf("Invalid %v index at height 0x%x - %v\n", blockType, iter.Key(), err)
+			continue
+		}
+		if hashMap[h.String()] != "OK" {
+			fmt.Printf("Invalid %v indexed at height 0x%x - %v\n", blockType, iter.Key(), h)
+		}
+	}
+	if err = iter.Error(); err != nil {
+		fmt.Printf("Error reading the %v index - %v\n", blockType, err)
+	}
+}
+
 func CheckDatabase(dbo interfaces.DBOverlay) {
 	if dbo == nil {
 		return
@@ -146,37 +171,10 @@ func CheckDatabase(dbo interfaces.DBOverlay) {
 
 	fmt.Printf("\tChecking block indexes\n")
 
-	hashes, keys, err := dbo.GetAll(databaseOverlay.DIRECTORYBLOCK_NUMBER, primitives.NewZeroHash())
-	for i, v := range hashes {
-		h := v.(*primitives.Hash)
-		if hashMap[h.String()] != "OK" {
-			fmt.Printf("Invalid DBlock indexed at height 0x%x - %v\n", keys[i], h)
-		}
-	}
-
-	hashes, keys, err = dbo.GetAll(databaseOverlay.FACTOIDBLOCK_NUMBER, primitives.NewZeroHash())
-	for i, v := range hashes {
-		h := v.(*primitives.Hash)
-		if hashMap[h.String()] != "OK" {
-			fmt.Printf("Invalid FBlock indexed at height 0x%x - %v\n", keys[i], h)
-		}
-	}
-
-	hashes, keys, err = dbo.GetAll(databaseOverlay.ADMINBLOCK_NUMBER, primitives.NewZeroHash())
-	for i, v := range hashes {
-		h := v.(*primitives.Hash)
-		if hashMap[h.String()] != "OK" {
-			fmt.Printf("Invalid ABlock indexed at height 0x%x - %v\n", keys[i], h)
-		}
-	}
-
-	hashes, keys, err = dbo.GetAll(databaseOverlay.ENTRYCREDITBLOCK_NUMBER, primitives.NewZeroHash())
-	for i, v := range hashes {
-		h := v.(*primitives.Hash)
-		if hashMap[h.String()] != "OK" {
-			fmt.Printf("Invalid ECBlock indexed at height 0x%x - %v\n", keys[i], h)
-		}
-	}
+	CheckBlockIndex(dbo, databaseOverlay.DIRECTORYBLOCK_NUMBER, "DBlock", hashMap)
+	CheckBlockIndex(dbo, databaseOverlay.FACTOIDBLOCK_NUMBER, "FBlock", hashMap)
+	CheckBlockIndex(dbo, databaseOverlay.ADMINBLOCK_NUMBER, "ABlock", hashMap)
+	CheckBlockIndex(dbo, databaseOverlay.ENTRYCREDITBLOCK_NUMBER, "ECBlock", hashMap)
 
 	fmt.Printf("\tFinished checking block indexes\n")
 
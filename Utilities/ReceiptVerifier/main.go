@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/receipts"
+)
+
+func main() {
+	var (
+		extraKeys = flag.String("keys", "", "Comma separated anchor signing public keys to accept besides the mainnet ones")
+		onlyKeys  = flag.Bool("onlykeys", false, "Only accept the keys given with -keys")
+		quiet     = flag.Bool("q", false, "Only print the receipts that fail verification")
+	)
+	flag.Parse()
+
+	if len(flag.Args()) < 1 {
+		fmt.Println("Usage:")
+		fmt.Println("ReceiptVerifier [-keys key1,key2] [-onlykeys] [-q] ReceiptFileOrDirectory...")
+		fmt.Println("Every receipt file, and every file in the directories, is verified without a database")
+		os.Exit(1)
+	}
+
+	var keys []interfaces.Verifier
+	if !*onlyKeys {
+		keys = append(keys, databaseOverlay.AnchorSigPublicKeys...)
+	}
+	for _, k := range strings.Split(*extraKeys, ",") {
+		k = strings.TrimSpace(k)
+		if k == "" {
+			continue
+		}
+		pubKey := new(primitives.PublicKey)
+		err := pubKey.UnmarshalText([]byte(k))
+		if err != nil {
+			fmt.Printf("Invalid key %v - %v\n", k, err)
+			os.Exit(1)
+		}
+		keys = append(keys, pubKey)
+	}
+	if len(keys) == 0 {
+		fmt.Println("No anchor signing keys to verify against")
+		os.Exit(1)
+	}
+
+	var files []string
+	for _, path := range flag.Args() {
+		err := filepath.Walk(path, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() {
+				files = append(files, file)
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("Could not read %v - %v\n", path, err)
+			os.Exit(1)
+		}
+	}
+
+	failed := 0
+	for _, file := range files {
+		err := VerifyFile(file, keys)
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL %v - %v\n", file, err)
+		} else if !*quiet {
+			fmt.Printf("OK   %v\n", file)
+		}
+	}
+
+	fmt.Printf("\n%v receipts verified, %v failed\n", len(files)-failed, failed)
+	if failed > 0 {
+		os.Exit(2)
+	}
+}
+
+// VerifyFile verifies the entry or transaction receipt held in a file
+func VerifyFile(file string, keys []interfaces.Verifier) error {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return err
+	}
+	return receipts.VerifyReceiptJSON(data, keys)
+}
@@ -513,6 +513,14 @@ func (b *FBlock) GetBodyMR() (rval interfaces.IHash) {
 		}
 	}()
 
+	b.BodyMR = primitives.ComputeMerkleRoot(b.GetBodyHashes())
+
+	return b.BodyMR
+}
+
+// Returns the leaves of the body Merkle tree, the transaction hashes with a marker
+// for the end of every minute
+func (b *FBlock) GetBodyHashes() []interfaces.IHash {
 	hashes := make([]interfaces.IHash, 0, len(b.Transactions))
 	marker := 0
 	for i, trans := range b.Transactions {
@@ -527,10 +535,7 @@ func (b *FBlock) GetBodyMR() (rval interfaces.IHash) {
 		marker++
 		hashes = append(hashes, primitives.Sha(constants.ZERO))
 	}
-
-	b.BodyMR = primitives.ComputeMerkleRoot(hashes)
-
-	return b.BodyMR
+	return hashes
 }
 
 func (b *FBlock) GetPrevKeyMR() (rval interfaces.IHash) {
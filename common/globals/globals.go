@@ -53,6 +53,13 @@ type FactomParams struct {
 	Fast                     bool
 	FastLocation             string
 	FastSaveRate             int
+	SnapshotDir              string
+	ExportArchive            string
+	ExportFrom               int
+	ExportTo                 int
+	ImportArchive            string
+	DBMigrateOnly            bool
+	DBReindexOnly            bool
 	Loglvl                   string
 	Logjson                  bool
 	Svm                      bool
@@ -41,6 +41,8 @@ type IFBlock interface {
 	GetKeyMR() IHash
 	// Get the MR for the list of transactions
 	GetBodyMR() IHash
+	// Get the leaves of the body MR, the transaction hashes and minute markers
+	GetBodyHashes() []IHash
 	// Get the KeyMR of the previous block.
 	GetPrevKeyMR() IHash
 	SetPrevKeyMR(IHash)
@@ -16,6 +16,35 @@ type IDatabase interface {
 	ListAllBuckets() ([][]byte, error)
 	Trim()
 	DoesKeyExist(bucket, key []byte) (bool, error)
+	// NewIterator walks the records of a bucket in key order without loading the whole bucket
+	NewIterator(bucket []byte, options *IteratorOptions) (IIterator, error)
+}
+
+// IteratorOptions selects the records of a bucket an iterator goes through.  A nil
+// IteratorOptions goes through the whole bucket.
+type IteratorOptions struct {
+	// Only the keys starting with Prefix
+	Prefix []byte
+	// Start at the first key at or after Seek, or at or before it when going in Reverse
+	Seek []byte
+	// Go from the last key to the first
+	Reverse bool
+	// Stop after Limit records, 0 for no limit
+	Limit int
+}
+
+// IIterator goes through the records of a bucket one at a time.  Next has to be called before
+// the first record is available, and Release once the iterator is no longer needed.
+type IIterator interface {
+	// Next moves to the next record, and returns false when there are none left or on an error
+	Next() bool
+	// Key returns the key of the current record, without the bucket
+	Key() []byte
+	// Value returns the marshalled data of the current record
+	Value() []byte
+	// Error returns the error that stopped the iteration, if any
+	Error() error
+	Release()
 }
 
 type Record struct {
@@ -40,3 +69,16 @@ type DatabaseBlockWithEntries interface {
 	GetEntryHashes() []IHash
 	GetEntrySigHashes() []IHash
 }
+
+// ISnapshotter is implemented by the databases that can take a point-in-time copy of themselves
+// while they are in use
+type ISnapshotter interface {
+	Snapshot() (ISnapshot, error)
+}
+
+// ISnapshot is a point-in-time view of a database.  Writes made after it was taken are not in it.
+type ISnapshot interface {
+	// WriteTo copies the snapshot into a new database of the same type at path
+	WriteTo(path string) error
+	Release()
+}
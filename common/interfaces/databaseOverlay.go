@@ -21,6 +21,7 @@ type DBOverlaySimple interface {
 	FetchDBlockHead() (IDirectoryBlock, error)
 	FetchEBlock(IHash) (IEntryBlock, error)
 	FetchEBlockHead(chainID IHash) (IEntryBlock, error)
+	FetchEBlockBySequence(chainID IHash, seq uint32) (IEntryBlock, error)
 	FetchECBlock(IHash) (IEntryCreditBlock, error)
 	FetchECBlockByHeight(blockHeight uint32) (IEntryCreditBlock, error)
 	FetchECTransaction(hash IHash) (IECBlockEntry, error)
@@ -42,6 +43,7 @@ type DBOverlaySimple interface {
 	ProcessECBlockMultiBatch(IEntryCreditBlock, bool) (err error)
 	ProcessFBlockMultiBatch(DatabaseBlockWithEntries) error
 	FetchDirBlockInfoByKeyMR(hash IHash) (IDirBlockInfo, error)
+	FetchAnchorEntryByHeight(dbheight uint32) (IEBEntry, error)
 	SetExportData(path string)
 	StartMultiBatch()
 	Trim()
@@ -50,6 +52,28 @@ type DBOverlaySimple interface {
 	FetchKeyValueStore(key []byte, dst BinaryMarshallable) (BinaryMarshallable, error)
 	SaveDatabaseEntryHeight(height uint32) error
 	FetchDatabaseEntryHeight() (uint32, error)
+	SetAddressIndex(enabled bool)
+	HasAddressIndex() bool
+	BackfillAddressIndex(printFreq uint32) (uint32, error)
+	StartAddressIndexBackfill()
+	FetchAddressTransactions(address IHash, start, limit uint32) ([]AddressTransaction, uint32, error)
+	FetchPrunedHeight() (uint32, error)
+	SavePrunedHeight(height uint32) error
+	PruneEBlock(keyMR IHash) (bool, error)
+	IsPruned(hash IHash) (bool, error)
+	SetBlockVerification(sampleRate uint32, onCorruption func(dbheight uint32))
+	SetBlockCache(size int)
+	FetchQuarantinedHeights() ([]uint32, error)
+	RestoreDBState(dblock IDirectoryBlock, ablock IAdminBlock, fblock IFBlock, ecblock IEntryCreditBlock, eblocks []IEntryBlock, entries []IEBEntry) error
+	FetchSchemaVersion() (uint32, error)
+	Migrate(printFreq uint32) (int, error)
+	ReindexEBlockSequences(printFreq uint32) (int, error)
+}
+
+// AddressTransaction is a factoid transaction touching an address, as kept in the address index
+type AddressTransaction struct {
+	TxID     IHash
+	DBHeight uint32
 }
 
 // Db defines a generic interface that is used to request and insert data into db
@@ -108,6 +132,12 @@ type DBOverlay interface {
 
 	FetchAllEBlockChainIDs() ([]IHash, error)
 
+	// FetchEBlockBySequence gets the entry block with the sequence number in the chain
+	FetchEBlockBySequence(chainID IHash, seq uint32) (IEntryBlock, error)
+	FetchEBlockKeyMRBySequence(chainID IHash, seq uint32) (IHash, error)
+	RebuildEBlockSequenceIndex(chainID IHash) error
+	ReindexEBlockSequences(printFreq uint32) (int, error)
+
 	//**********************************DBlock**********************************//
 
 	// ProcessDBlockBatche inserts the EBlock and update all it's ebentries in DB
@@ -234,6 +264,9 @@ type DBOverlay interface {
 	// FetchDirBlockInfoByKeyMR gets a dirblock info block by keyMR from the database.
 	FetchDirBlockInfoByKeyMR(hash IHash) (IDirBlockInfo, error)
 
+	// FetchAnchorEntryByHeight gets the signed Bitcoin anchor record entry of a directory block
+	FetchAnchorEntryByHeight(dbheight uint32) (IEBEntry, error)
+
 	// FetchAllConfirmedDirBlockInfos gets all of the confirmed dirblock info blocks
 	FetchAllConfirmedDirBlockInfos() ([]IDirBlockInfo, error)
 
@@ -263,6 +296,36 @@ type DBOverlay interface {
 	FetchKeyValueStore(key []byte, dst BinaryMarshallable) (BinaryMarshallable, error)
 	SaveDatabaseEntryHeight(height uint32) error
 	FetchDatabaseEntryHeight() (uint32, error)
+
+	//******************************AddressIndex**********************************//
+	SetAddressIndex(enabled bool)
+	HasAddressIndex() bool
+	BackfillAddressIndex(printFreq uint32) (uint32, error)
+	StartAddressIndexBackfill()
+	FetchAddressIndexHeight() (uint32, error)
+	FetchAddressTransactionCount(address IHash) (uint32, error)
+	FetchAddressTransactions(address IHash, start, limit uint32) ([]AddressTransaction, uint32, error)
+
+	//******************************Pruning**********************************//
+	FetchPrunedHeight() (uint32, error)
+	SavePrunedHeight(height uint32) error
+	PruneEBlock(keyMR IHash) (bool, error)
+	IsPruned(hash IHash) (bool, error)
+
+	//******************************Integrity**********************************//
+	SetBlockVerification(sampleRate uint32, onCorruption func(dbheight uint32))
+	IsQuarantined(bucket []byte, key IHash) (bool, error)
+	FetchQuarantinedHeights() ([]uint32, error)
+	ClearQuarantine(dbheight uint32) error
+	RestoreDBState(dblock IDirectoryBlock, ablock IAdminBlock, fblock IFBlock, ecblock IEntryCreditBlock, eblocks []IEntryBlock, entries []IEBEntry) error
+
+	//******************************Migrations**********************************//
+	FetchSchemaVersion() (uint32, error)
+	SaveSchemaVersion(version uint32) error
+	Migrate(printFreq uint32) (int, error)
+
+	//******************************Cache**********************************//
+	SetBlockCache(size int)
 }
 
 type ISCDatabaseOverlay interface {
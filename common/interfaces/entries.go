@@ -24,4 +24,5 @@ type IPendingEntry struct {
 	EntryHash IHash  `json:"entryhash"`
 	ChainID   IHash  `json:"chainid"`
 	Status    string `json:"status"`
+	Stage     string `json:"stage,omitempty"`
 }
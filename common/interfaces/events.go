@@ -0,0 +1,46 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package interfaces
+
+// Types of StateEvent
+const (
+	EVENT_DBLOCK_SAVED = iota + 1 // A directory block has been saved to the database
+	EVENT_ENTRY                   // An entry was acknowledged or saved
+	EVENT_COMMIT                  // A chain or entry commit was acknowledged or saved
+	EVENT_FACTOID_TX              // A factoid transaction was acknowledged or saved
+)
+
+// StateEvent is pushed by the state to its subscribers as the process list advances
+// and as directory blocks are saved to the database.  Not every field applies to every
+// type of event.
+type StateEvent struct {
+	Type     int
+	DBHeight uint32
+	Hash     IHash // KeyMR of the DBlock, entry hash, commit txid or factoid txid
+	ChainID  IHash // Chain of an entry
+	Status   int   // constants.AckStatus*
+
+	Entry       IEBEntry     // EVENT_ENTRY
+	Transaction ITransaction // EVENT_FACTOID_TX
+}
+
+// Addresses returns the input, output and ec output addresses of the factoid transaction
+// carried by the event, if any.
+func (e *StateEvent) Addresses() []IAddress {
+	if e.Transaction == nil {
+		return nil
+	}
+	var list []IAddress
+	for _, a := range e.Transaction.GetInputs() {
+		list = append(list, a.GetAddress())
+	}
+	for _, a := range e.Transaction.GetOutputs() {
+		list = append(list, a.GetAddress())
+	}
+	for _, a := range e.Transaction.GetECOutputs() {
+		list = append(list, a.GetAddress())
+	}
+	return list
+}
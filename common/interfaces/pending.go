@@ -0,0 +1,24 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package interfaces
+
+// Where a pending entry or transaction is on its way into a block
+const (
+	PendingStageHolding     = "holding"     // Waiting in holding for its ack
+	PendingStageAcked       = "acked"       // Acked, but not yet processed in the process list
+	PendingStageProcessList = "processlist" // Processed in the process list
+)
+
+// PendingFilter selects the pending entries and transactions returned by GetPendingEntries and
+// GetPendingTransactions.  An empty field matches everything.
+type PendingFilter struct {
+	ChainIDs []IHash // Entries of any of these chains
+	ECPubKey []byte  // Entries committed with this entry credit public key
+	Address  string  // Transactions touching this human readable factoid or entry credit address
+
+	// Items acked in this minute or later.  Items in holding have no minute yet, so they are
+	// only returned when this is 0.
+	MinMinute int
+}
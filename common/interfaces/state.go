@@ -5,6 +5,8 @@
 package interfaces
 
 import (
+	"time"
+
 	"github.com/FactomProject/factomd/activations"
 )
 
@@ -188,6 +190,8 @@ type IState interface {
 	// ============
 	SetPort(int)
 	GetPort() int
+	SubscribeStateEvents(chan *StateEvent) // Events are dropped if the channel is full
+	UnsubscribeStateEvents(chan *StateEvent)
 
 	// Factoid State
 	// =============
@@ -320,6 +324,12 @@ type IState interface {
 	SetDelay(int64)
 	GetDropRate() int
 	SetDropRate(int)
+	SnapshotDatabase(dir string) (uint32, error)
+	GetPeerBans() map[string]time.Time
+	UnbanPeer(address string) int
+	GetPruneDepth() uint32
+	IsQuarantined(dbheight uint32) bool
+	IsReadOnlyDB() bool
 	GetBootTime() int64
 	IsSyncing() bool
 	IsSyncingEOMs() bool
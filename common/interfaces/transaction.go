@@ -94,4 +94,5 @@ type IPendingTransaction struct {
 	Outputs       []ITransAddress `json:"outputs"`
 	ECOutputs     []ITransAddress `json:"ecoutputs"`
 	Fees          uint64          `json:"fees"`
+	Stage         string          `json:"stage,omitempty"`
 }
@@ -183,12 +183,15 @@ func (m *DBStateMsg) Validate(state interfaces.IState) int {
 		return 1
 	}
 
-	if dbheight < state.GetDBHeightAtBoot() {
+	// A block we found corrupted is taken again, to heal our database
+	healing := state.IsQuarantined(dbheight)
+
+	if !healing && dbheight < state.GetDBHeightAtBoot() {
 		state.LogMessage("dbstatesloaded", "drop, below dbheight at boot", m)
 		return -1 // already have this one
 	}
 
-	if dbheight < state.GetHighestSavedBlk() {
+	if !healing && dbheight < state.GetHighestSavedBlk() {
 		state.LogMessage("dbstatesloaded", "drop, already in database", m)
 		return -1 // already have this one
 	}
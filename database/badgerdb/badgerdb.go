@@ -0,0 +1,249 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package badgerdb
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/dgraph-io/badger"
+)
+
+// BadgerDB stores the records of all buckets in one Badger keyspace, with the keys prefixed by
+// their bucket the same way as in LevelDB.  Badger keeps the values apart from the LSM tree, so
+// compactions are much lighter than LevelDB's while a follower is catching up.
+type BadgerDB struct {
+	// lock preventing multiple entry
+	dbLock sync.RWMutex
+	bDB    *badger.DB
+}
+
+var _ interfaces.IDatabase = (*BadgerDB)(nil)
+
+// MaxTableSize is the size of Badger's tables and memtables.  Badger caps a transaction at 15% of
+// it, and at one record per 96 bytes of that, so at 256MB a transaction takes about 400,000
+// records.  A DBState is written in one batch, with a few records for each entry, transaction and
+// entry block of the directory block, so this fits one of well over 100,000 entries.  The default
+// of 64MB would cap a batch at about 100,000 records.
+var MaxTableSize int64 = 256 << 20
+
+// NumMemtables is how many memtables of MaxTableSize Badger keeps in memory, down from its
+// default of 5 to make up for the larger tables
+var NumMemtables = 2
+
+func NewBadgerDB(filename string, create bool) (interfaces.IDatabase, error) {
+	if create == true {
+		err := os.MkdirAll(filename, 0750)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		_, err := os.Stat(filename)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	opts := badger.DefaultOptions(filename)
+	opts.Logger = nil
+	opts.MaxTableSize = MaxTableSize
+	opts.NumMemtables = NumMemtables
+
+	tbDB, err := badger.Open(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	db := new(BadgerDB)
+	db.bDB = tbDB
+	return db, nil
+}
+
+func ExtendBucket(bucket []byte) []byte {
+	answer := make([]byte, 0, len(bucket)+1)
+	answer = append(answer, bucket...)
+	return append(answer, ';')
+}
+
+func CombineBucketAndKey(bucket []byte, key []byte) []byte {
+	return append(ExtendBucket(bucket), key...)
+}
+
+func (db *BadgerDB) ListAllBuckets() ([][]byte, error) {
+	return nil, fmt.Errorf("Unable to fetch buckets, as BadgerDB keeps them in the key prefixes like LevelDB")
+}
+
+// Trim lets Badger reclaim the space of overwritten and deleted values
+func (db *BadgerDB) Trim() {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	for db.bDB.RunValueLogGC(0.5) == nil {
+	}
+}
+
+func (db *BadgerDB) Close() error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	return db.bDB.Close()
+}
+
+func (db *BadgerDB) Get(bucket []byte, key []byte, destination interfaces.BinaryMarshallable) (interfaces.BinaryMarshallable, error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	var data []byte
+	err := db.bDB.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(CombineBucketAndKey(bucket, key))
+		if err != nil {
+			return err
+		}
+		data, err = item.ValueCopy(nil)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = destination.UnmarshalBinaryData(data)
+	if err != nil {
+		return nil, err
+	}
+	return destination, nil
+}
+
+func (db *BadgerDB) Put(bucket []byte, key []byte, data interfaces.BinaryMarshallable) error {
+	return db.PutInBatch([]interfaces.Record{{Bucket: bucket, Key: key, Data: data}})
+}
+
+func (db *BadgerDB) PutInBatch(records []interfaces.Record) error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	txn := db.bDB.NewTransaction(true)
+	defer txn.Discard()
+
+	for _, v := range records {
+		hex, err := v.Data.MarshalBinary()
+		if err != nil {
+			return err
+		}
+		// A batch is written all at once or not at all, so one too big for a Badger
+		// transaction fails with badger.ErrTxnTooBig rather than being written in parts.
+		// MaxTableSize makes room for the largest DBState.
+		err = txn.Set(CombineBucketAndKey(v.Bucket, v.Key), hex)
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+func (db *BadgerDB) Delete(bucket []byte, key []byte) error {
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	return db.bDB.Update(func(txn *badger.Txn) error {
+		return txn.Delete(CombineBucketAndKey(bucket, key))
+	})
+}
+
+func (db *BadgerDB) Clear(bucket []byte) error {
+	keys, err := db.ListAllKeys(bucket)
+	if err != nil {
+		return err
+	}
+
+	db.dbLock.Lock()
+	defer db.dbLock.Unlock()
+
+	txn := db.bDB.NewTransaction(true)
+	defer func() { txn.Discard() }()
+
+	for _, key := range keys {
+		bKey := CombineBucketAndKey(bucket, key)
+		err = txn.Delete(bKey)
+		if err == badger.ErrTxnTooBig {
+			// A bucket can hold more keys than one transaction deletes, so it is cleared in
+			// parts.  A Clear cut short leaves the rest of the bucket for the next one.
+			err = txn.Commit()
+			if err != nil {
+				return err
+			}
+			txn = db.bDB.NewTransaction(true)
+			err = txn.Delete(bKey)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+func (db *BadgerDB) ListAllKeys(bucket []byte) ([][]byte, error) {
+	iter, err := db.NewIterator(bucket, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer iter.Release()
+
+	var answer [][]byte
+	for iter.Next() {
+		answer = append(answer, iter.Key())
+	}
+	err = iter.Error()
+	if err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+func (db *BadgerDB) GetAll(bucket []byte, sample interfaces.BinaryMarshallableAndCopyable) ([]interfaces.BinaryMarshallableAndCopyable, [][]byte, error) {
+	iter, err := db.NewIterator(bucket, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer iter.Release()
+
+	answer := []interfaces.BinaryMarshallableAndCopyable{}
+	keys := [][]byte{}
+	for iter.Next() {
+		tmp := sample.New()
+		err := tmp.UnmarshalBinary(iter.Value())
+		if err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, iter.Key())
+		answer = append(answer, tmp)
+	}
+	err = iter.Error()
+	if err != nil {
+		return nil, nil, err
+	}
+	return answer, keys, nil
+}
+
+func (db *BadgerDB) DoesKeyExist(bucket, key []byte) (bool, error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	err := db.bDB.View(func(txn *badger.Txn) error {
+		_, err := txn.Get(CombineBucketAndKey(bucket, key))
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
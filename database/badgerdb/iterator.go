@@ -0,0 +1,144 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package badgerdb
+
+import (
+	"bytes"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/dgraph-io/badger"
+)
+
+// BadgerIterator walks a range of keys in a Badger read transaction, so records written while it
+// is in use are not seen by it
+type BadgerIterator struct {
+	txn     *badger.Txn
+	iter    *badger.Iterator
+	prefix  []byte // Bucket and prefix every key of the range starts with
+	trim    int    // Length of the bucket prefix to cut from the keys
+	seek    []byte
+	reverse bool
+	limit   int
+
+	started bool
+	count   int
+	key     []byte
+	value   []byte
+	err     error
+}
+
+var _ interfaces.IIterator = (*BadgerIterator)(nil)
+
+func (db *BadgerDB) NewIterator(bucket []byte, options *interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	if options == nil {
+		options = new(interfaces.IteratorOptions)
+	}
+
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	it := new(BadgerIterator)
+	it.prefix = CombineBucketAndKey(bucket, options.Prefix)
+	it.trim = len(ExtendBucket(bucket))
+	it.reverse = options.Reverse
+	it.limit = options.Limit
+	if options.Seek != nil {
+		it.seek = CombineBucketAndKey(bucket, options.Seek)
+	}
+
+	bOpts := badger.DefaultIteratorOptions
+	bOpts.Reverse = options.Reverse
+	it.txn = db.bDB.NewTransaction(false)
+	it.iter = it.txn.NewIterator(bOpts)
+	return it, nil
+}
+
+// first positions the underlying iterator on the first record to return
+func (it *BadgerIterator) first() {
+	if !it.reverse {
+		if bytes.Compare(it.seek, it.prefix) > 0 {
+			it.iter.Seek(it.seek)
+		} else {
+			it.iter.Seek(it.prefix)
+		}
+		return
+	}
+
+	// Going backwards, Seek lands on the last key at or before the one given
+	end := prefixEnd(it.prefix)
+	if it.seek != nil && (end == nil || bytes.Compare(it.seek, end) < 0) {
+		end = it.seek
+	}
+	if end == nil {
+		it.iter.Rewind()
+		return
+	}
+	it.iter.Seek(end)
+	if it.iter.Valid() && !bytes.HasPrefix(it.iter.Item().Key(), it.prefix) {
+		it.iter.Next()
+	}
+}
+
+func (it *BadgerIterator) Next() bool {
+	it.key, it.value = nil, nil
+	if it.iter == nil || it.err != nil || (it.limit > 0 && it.count >= it.limit) {
+		return false
+	}
+
+	if !it.started {
+		it.started = true
+		it.first()
+	} else {
+		it.iter.Next()
+	}
+	if !it.iter.ValidForPrefix(it.prefix) {
+		return false
+	}
+
+	item := it.iter.Item()
+	key := item.Key()
+	it.key = make([]byte, len(key)-it.trim)
+	copy(it.key, key[it.trim:])
+	it.value, it.err = item.ValueCopy(nil)
+	if it.err != nil {
+		it.key, it.value = nil, nil
+		return false
+	}
+
+	it.count++
+	return true
+}
+
+func (it *BadgerIterator) Key() []byte {
+	return it.key
+}
+
+func (it *BadgerIterator) Value() []byte {
+	return it.value
+}
+
+func (it *BadgerIterator) Error() error {
+	return it.err
+}
+
+func (it *BadgerIterator) Release() {
+	if it.iter != nil {
+		it.iter.Close()
+		it.txn.Discard()
+		it.iter = nil
+	}
+}
+
+// prefixEnd returns the first key after all the keys starting with prefix, or nil if there is none
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
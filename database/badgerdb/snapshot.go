@@ -0,0 +1,76 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package badgerdb
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/dgraph-io/badger"
+)
+
+// BadgerSnapshot is a Badger read transaction, which sees the records as they were when it began
+type BadgerSnapshot struct {
+	txn *badger.Txn
+}
+
+var _ interfaces.ISnapshotter = (*BadgerDB)(nil)
+var _ interfaces.ISnapshot = (*BadgerSnapshot)(nil)
+
+func (db *BadgerDB) Snapshot() (interfaces.ISnapshot, error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	s := new(BadgerSnapshot)
+	s.txn = db.bDB.NewTransaction(false)
+	return s, nil
+}
+
+// WriteTo copies every record of the snapshot into a new BadgerDB at path, which must not exist yet
+func (s *BadgerSnapshot) WriteTo(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%v already exists", path)
+	}
+
+	tdb, err := NewBadgerDB(path, true)
+	if err != nil {
+		return err
+	}
+	dest := tdb.(*BadgerDB)
+	defer dest.Close()
+
+	iter := s.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer iter.Close()
+
+	txn := dest.bDB.NewTransaction(true)
+	defer func() { txn.Discard() }()
+
+	for iter.Rewind(); iter.Valid(); iter.Next() {
+		item := iter.Item()
+		key := item.KeyCopy(nil)
+		value, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		err = txn.Set(key, value)
+		if err == badger.ErrTxnTooBig {
+			err = txn.Commit()
+			if err != nil {
+				return err
+			}
+			txn = dest.bDB.NewTransaction(true)
+			err = txn.Set(key, value)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return txn.Commit()
+}
+
+func (s *BadgerSnapshot) Release() {
+	s.txn.Discard()
+}
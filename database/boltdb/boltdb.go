@@ -10,6 +10,7 @@ import (
 
 	"os"
 	"path/filepath"
+	"time"
 
 	"github.com/FactomProject/bolt"
 	"github.com/FactomProject/factomd/common/interfaces"
@@ -54,6 +55,27 @@ func NewAndCreateBoltDB(bucketList [][]byte, filename string) *BoltDB {
 	return NewBoltDB(bucketList, filename)
 }
 
+// How long NewReadOnlyBoltDB waits for a process writing to the database to let go of it
+var ReadOnlyLockTimeout = 10 * time.Second
+
+// NewReadOnlyBoltDB opens an existing database without write access.  Any number of processes
+// can open the same database this way, but not while one has it open to write.
+func NewReadOnlyBoltDB(filename string) (*BoltDB, error) {
+	_, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	tdb, err := bolt.Open(filename, 0600, &bolt.Options{ReadOnly: true, Timeout: ReadOnlyLockTimeout})
+	if err != nil {
+		return nil, err
+	}
+
+	db := new(BoltDB)
+	db.db = tdb
+	return db, nil
+}
+
 /***************************************
  *       Methods
  ***************************************/
@@ -226,3 +226,48 @@ func TestGetAll(t *testing.T) {
 		}
 	}
 }
+
+func TestReadOnly(t *testing.T) {
+	_, err := NewReadOnlyBoltDB(dbFilename)
+	if err == nil {
+		t.Errorf("Opened a database that does not exist")
+	}
+
+	m := NewBoltDB(nil, dbFilename)
+	key := []byte("key")
+	bucket := []byte("bucket")
+	test := new(TestData)
+	test.Str = "testtest"
+	err = m.Put(bucket, key, test)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	m.Close()
+
+	// Several readers can share the database
+	r1, err := NewReadOnlyBoltDB(dbFilename)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer CleanupTest(t, r1)
+	r2, err := NewReadOnlyBoltDB(dbFilename)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer r2.Close()
+
+	for _, r := range []*BoltDB{r1, r2} {
+		resp, err := r.Get(bucket, key, new(TestData))
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		if resp == nil || resp.(*TestData).Str != test.Str {
+			t.Errorf("data mismatch")
+		}
+	}
+
+	err = r1.Put(bucket, []byte("other"), test)
+	if err == nil {
+		t.Errorf("Wrote to a read only database")
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package boltdb
+
+import (
+	"bytes"
+
+	"github.com/FactomProject/bolt"
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// How many records a BoltIterator reads in each read transaction.  Reading in chunks keeps
+// writers from waiting on a long read transaction, and keeps memory use bounded.
+var IteratorChunkSize = 1000
+
+// BoltIterator walks a bucket a chunk at a time, picking up after the last key it returned
+type BoltIterator struct {
+	db      *BoltDB
+	bucket  []byte
+	options interfaces.IteratorOptions
+
+	keys    [][]byte
+	values  [][]byte
+	index   int
+	lastKey []byte
+	started bool
+	done    bool
+	count   int
+	err     error
+}
+
+var _ interfaces.IIterator = (*BoltIterator)(nil)
+
+func (db *BoltDB) NewIterator(bucket []byte, options *interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	if options == nil {
+		options = new(interfaces.IteratorOptions)
+	}
+
+	it := new(BoltIterator)
+	it.db = db
+	it.bucket = append([]byte{}, bucket...)
+	it.options = *options
+	return it, nil
+}
+
+func (it *BoltIterator) Next() bool {
+	if it.options.Limit > 0 && it.count >= it.options.Limit {
+		it.Release()
+		return false
+	}
+	if it.index+1 < len(it.keys) {
+		it.index++
+	} else {
+		if it.done {
+			it.Release()
+			return false
+		}
+		it.fill()
+		if len(it.keys) == 0 {
+			return false
+		}
+	}
+
+	it.count++
+	return true
+}
+
+// fill reads the next chunk of records
+func (it *BoltIterator) fill() {
+	it.keys, it.values, it.index = nil, nil, 0
+
+	it.db.Sem.RLock()
+	defer it.db.Sem.RUnlock()
+
+	err := it.db.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(it.bucket)
+		if b == nil {
+			it.done = true
+			return nil
+		}
+		c := b.Cursor()
+
+		k, v := it.position(c)
+		for ; k != nil; k, v = it.step(c) {
+			if !bytes.HasPrefix(k, it.options.Prefix) {
+				// Going backwards, keys after the prefix come before the ones with it
+				if it.options.Reverse && bytes.Compare(k, it.options.Prefix) > 0 {
+					continue
+				}
+				break
+			}
+			it.keys = append(it.keys, append([]byte{}, k...))
+			it.values = append(it.values, append([]byte{}, v...))
+			if len(it.keys) >= IteratorChunkSize {
+				break
+			}
+			if it.options.Limit > 0 && it.count+len(it.keys) >= it.options.Limit {
+				break
+			}
+		}
+		if len(it.keys) < IteratorChunkSize {
+			it.done = true
+		}
+		return nil
+	})
+	if err != nil {
+		it.err = err
+		it.done = true
+		it.keys, it.values = nil, nil
+	}
+	if len(it.keys) > 0 {
+		it.lastKey = it.keys[len(it.keys)-1]
+	}
+}
+
+// position puts the cursor on the first record of the chunk
+func (it *BoltIterator) position(c *bolt.Cursor) ([]byte, []byte) {
+	if it.started {
+		k, v := c.Seek(it.lastKey)
+		if it.options.Reverse {
+			if k == nil {
+				return c.Last()
+			}
+			return c.Prev()
+		}
+		if k != nil && bytes.Equal(k, it.lastKey) {
+			return c.Next()
+		}
+		return k, v
+	}
+	it.started = true
+
+	prefix, seek := it.options.Prefix, it.options.Seek
+	if !it.options.Reverse {
+		if bytes.Compare(seek, prefix) < 0 {
+			seek = prefix
+		}
+		if len(seek) == 0 {
+			return c.First()
+		}
+		return c.Seek(seek)
+	}
+
+	end := prefixEnd(prefix)
+	if seek == nil || (end != nil && bytes.Compare(seek, end) > 0) {
+		seek = end
+	}
+	if seek == nil {
+		return c.Last()
+	}
+	k, v := c.Seek(seek)
+	if k == nil {
+		return c.Last()
+	}
+	if bytes.Equal(k, seek) {
+		return k, v
+	}
+	return c.Prev()
+}
+
+func (it *BoltIterator) step(c *bolt.Cursor) ([]byte, []byte) {
+	if it.options.Reverse {
+		return c.Prev()
+	}
+	return c.Next()
+}
+
+func (it *BoltIterator) Key() []byte {
+	if it.index >= len(it.keys) {
+		return nil
+	}
+	return it.keys[it.index]
+}
+
+func (it *BoltIterator) Value() []byte {
+	if it.index >= len(it.values) {
+		return nil
+	}
+	return it.values[it.index]
+}
+
+func (it *BoltIterator) Error() error {
+	return it.err
+}
+
+func (it *BoltIterator) Release() {
+	it.done = true
+	it.keys, it.values = nil, nil
+}
+
+// prefixEnd returns the first key after all the keys starting with prefix, or nil if there is none
+func prefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package boltdb
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/FactomProject/bolt"
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// BoltSnapshot is an open read transaction.  Bolt cannot grow its file while a read transaction
+// is open, so a snapshot should be written out and released without delay.
+type BoltSnapshot struct {
+	tx *bolt.Tx
+}
+
+var _ interfaces.ISnapshotter = (*BoltDB)(nil)
+var _ interfaces.ISnapshot = (*BoltSnapshot)(nil)
+
+func (db *BoltDB) Snapshot() (interfaces.ISnapshot, error) {
+	db.Sem.RLock()
+	defer db.Sem.RUnlock()
+
+	tx, err := db.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+	s := new(BoltSnapshot)
+	s.tx = tx
+	return s, nil
+}
+
+// WriteTo copies the database file as of the snapshot to path, which must not exist yet
+func (s *BoltSnapshot) WriteTo(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%v already exists", path)
+	}
+	return s.tx.CopyFile(path, 0600)
+}
+
+func (s *BoltSnapshot) Release() {
+	s.tx.Rollback()
+}
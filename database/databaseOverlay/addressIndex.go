@@ -0,0 +1,342 @@
+package databaseOverlay
+
+import (
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// The address index maps every factoid and entry credit address to the factoid transactions
+// that touch it.  For each address ADDRESS_TRANSACTIONS_NUMBER holds the number of transactions
+// indexed, and ADDRESS_TRANSACTIONS holds each transaction under the address followed by its
+// position, so a page of transactions can be fetched without loading the whole history.
+
+// AddressIndexHeightKey holds the height of the next factoid block to add to the address index
+var AddressIndexHeightKey = []byte("AddressIndexHeight")
+
+// addressTransaction is the database form of interfaces.AddressTransaction
+type addressTransaction struct {
+	TxID     interfaces.IHash
+	DBHeight uint32
+}
+
+var _ interfaces.BinaryMarshallable = (*addressTransaction)(nil)
+
+func (a *addressTransaction) MarshalBinary() ([]byte, error) {
+	buf := primitives.NewBuffer(nil)
+	err := buf.PushIHash(a.TxID)
+	if err != nil {
+		return nil, err
+	}
+	err = buf.PushUInt32(a.DBHeight)
+	if err != nil {
+		return nil, err
+	}
+	return buf.DeepCopyBytes(), nil
+}
+
+func (a *addressTransaction) UnmarshalBinaryData(data []byte) ([]byte, error) {
+	buf := primitives.NewBuffer(data)
+	h, err := buf.PopIHash()
+	if err != nil {
+		return nil, err
+	}
+	a.TxID = h
+	a.DBHeight, err = buf.PopUInt32()
+	if err != nil {
+		return nil, err
+	}
+	return buf.DeepCopyBytes(), nil
+}
+
+func (a *addressTransaction) UnmarshalBinary(data []byte) error {
+	_, err := a.UnmarshalBinaryData(data)
+	return err
+}
+
+func addressTransactionKey(address []byte, n uint32) []byte {
+	key := make([]byte, len(address)+4)
+	copy(key, address)
+	binary.BigEndian.PutUint32(key[len(address):], n)
+	return key
+}
+
+func uint32ByteSlice(n uint32) *primitives.ByteSlice {
+	bs := new(primitives.ByteSlice)
+	bs.Bytes = make([]byte, 4)
+	binary.BigEndian.PutUint32(bs.Bytes, n)
+	return bs
+}
+
+func (db *Overlay) fetchUint32(bucket, key []byte) (uint32, error) {
+	bs := new(primitives.ByteSlice)
+	loaded, err := db.DB.Get(bucket, key, bs)
+	if err != nil {
+		return 0, err
+	}
+	if loaded == nil {
+		return 0, nil
+	}
+	if len(bs.Bytes) != 4 {
+		return 0, fmt.Errorf("Invalid value stored in %s", string(bucket))
+	}
+	return binary.BigEndian.Uint32(bs.Bytes), nil
+}
+
+// SetAddressIndex turns the maintenance of the address index on or off
+func (db *Overlay) SetAddressIndex(enabled bool) {
+	db.AddressIndex = enabled
+}
+
+func (db *Overlay) HasAddressIndex() bool {
+	return db.AddressIndex
+}
+
+// FetchAddressIndexHeight returns the height of the next factoid block to be indexed
+func (db *Overlay) FetchAddressIndexHeight() (uint32, error) {
+	return db.fetchUint32(KEY_VALUE_STORE, AddressIndexHeightKey)
+}
+
+// FetchAddressTransactionCount returns the number of transactions indexed for the address
+func (db *Overlay) FetchAddressTransactionCount(address interfaces.IHash) (uint32, error) {
+	return db.fetchUint32(ADDRESS_TRANSACTIONS_NUMBER, address.Bytes())
+}
+
+// FetchAddressTransactions returns up to limit transactions touching the address, oldest first,
+// starting at the start'th one.  The total number of transactions indexed for the address is
+// returned along with them.
+func (db *Overlay) FetchAddressTransactions(address interfaces.IHash, start, limit uint32) ([]interfaces.AddressTransaction, uint32, error) {
+	total, err := db.FetchAddressTransactionCount(address)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	list := []interfaces.AddressTransaction{}
+	for i := start; i < total && uint32(len(list)) < limit; i++ {
+		a := new(addressTransaction)
+		loaded, err := db.DB.Get(ADDRESS_TRANSACTIONS, addressTransactionKey(address.Bytes(), i), a)
+		if err != nil {
+			return nil, 0, err
+		}
+		if loaded == nil {
+			return nil, 0, fmt.Errorf("Address index is missing transaction %d of %x", i, address.Bytes())
+		}
+		list = append(list, interfaces.AddressTransaction{TxID: a.TxID, DBHeight: a.DBHeight})
+	}
+	return list, total, nil
+}
+
+// addressTransactionRecords builds the address index records for a factoid block.  Addresses
+// that already have the block indexed are skipped, so a block can be saved more than once.
+func (db *Overlay) addressTransactionRecords(fblock interfaces.IFBlock) ([]interfaces.Record, error) {
+	height := fblock.GetDatabaseHeight()
+
+	// Addresses are kept in the order they are first seen so the same block always produces
+	// the same records
+	var order [][constants.ADDRESS_LENGTH]byte
+	txids := map[[constants.ADDRESS_LENGTH]byte][]interfaces.IHash{}
+	for _, tx := range fblock.GetTransactions() {
+		var adrs []interfaces.IAddress
+		for _, a := range tx.GetInputs() {
+			adrs = append(adrs, a.GetAddress())
+		}
+		for _, a := range tx.GetOutputs() {
+			adrs = append(adrs, a.GetAddress())
+		}
+		for _, a := range tx.GetECOutputs() {
+			adrs = append(adrs, a.GetAddress())
+		}
+
+		seen := map[[constants.ADDRESS_LENGTH]byte]bool{}
+		for _, a := range adrs {
+			if a == nil {
+				continue
+			}
+			k := a.Fixed()
+			if seen[k] {
+				continue
+			}
+			seen[k] = true
+			if _, ok := txids[k]; !ok {
+				order = append(order, k)
+			}
+			txids[k] = append(txids[k], tx.GetSigHash())
+		}
+	}
+
+	batch := []interfaces.Record{}
+	for _, k := range order {
+		address := make([]byte, len(k))
+		copy(address, k[:])
+
+		count, err := db.fetchUint32(ADDRESS_TRANSACTIONS_NUMBER, address)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			last := new(addressTransaction)
+			loaded, err := db.DB.Get(ADDRESS_TRANSACTIONS, addressTransactionKey(address, count-1), last)
+			if err != nil {
+				return nil, err
+			}
+			if loaded != nil && last.DBHeight >= height {
+				continue
+			}
+		}
+
+		for _, txid := range txids[k] {
+			a := &addressTransaction{TxID: txid, DBHeight: height}
+			batch = append(batch, interfaces.Record{ADDRESS_TRANSACTIONS, addressTransactionKey(address, count), a})
+			count++
+		}
+		batch = append(batch, interfaces.Record{ADDRESS_TRANSACTIONS_NUMBER, address, uint32ByteSlice(count)})
+	}
+
+	next, err := db.FetchAddressIndexHeight()
+	if err != nil {
+		return nil, err
+	}
+	if height+1 > next {
+		batch = append(batch, interfaces.Record{KEY_VALUE_STORE, AddressIndexHeightKey, uint32ByteSlice(height + 1)})
+	}
+	return batch, nil
+}
+
+// liveAddressTransactionRecords builds the address index records for a factoid block as it is
+// saved.  A block past the height the index has reached is left to BackfillAddressIndex, so the
+// transactions of every address stay in order; a backfill is started if none is running.  The
+// caller holds addressIndexMutex.
+func (db *Overlay) liveAddressTransactionRecords(fblock interfaces.IFBlock) ([]interfaces.Record, error) {
+	next, err := db.FetchAddressIndexHeight()
+	if err != nil {
+		return nil, err
+	}
+	height := fblock.GetDatabaseHeight()
+	if height > next {
+		if height+1 > db.addressIndexSkipped {
+			db.addressIndexSkipped = height + 1
+		}
+		if !db.addressIndexBackfilling {
+			db.addressIndexBackfilling = true
+			go db.backfillAddressIndex()
+		}
+		return nil, nil
+	}
+	return db.addressTransactionRecords(fblock)
+}
+
+func (db *Overlay) SaveAddressTransactionsFromBlock(block interfaces.DatabaseBlockWithEntries) error {
+	fblock, ok := block.(interfaces.IFBlock)
+	if !db.AddressIndex || !ok {
+		return nil
+	}
+	db.addressIndexMutex.Lock()
+	defer db.addressIndexMutex.Unlock()
+
+	batch, err := db.liveAddressTransactionRecords(fblock)
+	if err != nil || len(batch) == 0 {
+		return err
+	}
+	return db.DB.PutInBatch(batch)
+}
+
+func (db *Overlay) SaveAddressTransactionsFromBlockMultiBatch(block interfaces.DatabaseBlockWithEntries) error {
+	fblock, ok := block.(interfaces.IFBlock)
+	if !db.AddressIndex || !ok {
+		return nil
+	}
+	db.addressIndexMutex.Lock()
+	defer db.addressIndexMutex.Unlock()
+
+	batch, err := db.liveAddressTransactionRecords(fblock)
+	if err != nil || len(batch) == 0 {
+		return err
+	}
+	db.PutInMultiBatch(batch)
+	return nil
+}
+
+// AddressIndexSaveWait is how long BackfillAddressIndex waits for a factoid block that was
+// left to it as it was saved to be written to the database
+var AddressIndexSaveWait = time.Minute
+
+// BackfillAddressIndex adds the factoid blocks already in the database to the address index,
+// starting where the index left off.  It returns the number of blocks that were indexed.  It
+// is safe to run while blocks are being saved.
+func (db *Overlay) BackfillAddressIndex(printFreq uint32) (uint32, error) {
+	var n uint32
+	var waited time.Duration
+	for {
+		db.addressIndexMutex.Lock()
+		height, err := db.FetchAddressIndexHeight()
+		if err != nil {
+			db.addressIndexMutex.Unlock()
+			return n, err
+		}
+		fblock, err := db.FetchFBlockByHeight(height)
+		if err != nil {
+			db.addressIndexMutex.Unlock()
+			return n, err
+		}
+		if fblock == nil {
+			// A block left to the backfill is in a batch that has not been written yet
+			pending := db.addressIndexSkipped > height
+			db.addressIndexMutex.Unlock()
+			if !pending {
+				return n, nil
+			}
+			if waited >= AddressIndexSaveWait {
+				return n, fmt.Errorf("Factoid block %d was not saved", height)
+			}
+			time.Sleep(100 * time.Millisecond)
+			waited += 100 * time.Millisecond
+			continue
+		}
+		waited = 0
+		batch, err := db.addressTransactionRecords(fblock)
+		if err == nil {
+			err = db.DB.PutInBatch(batch)
+		}
+		db.addressIndexMutex.Unlock()
+		if err != nil {
+			return n, err
+		}
+		n++
+		if printFreq > 0 && n%printFreq == 0 {
+			fmt.Printf("Address index: indexed factoid blocks up to height %d\n", height)
+		}
+	}
+}
+
+// StartAddressIndexBackfill indexes the factoid blocks saved while the address index was off
+// in the background, so a node with a long history does not wait for it to start.  It returns
+// at once; if a backfill is already running it does nothing.
+func (db *Overlay) StartAddressIndexBackfill() {
+	db.addressIndexMutex.Lock()
+	defer db.addressIndexMutex.Unlock()
+	if db.addressIndexBackfilling {
+		return
+	}
+	db.addressIndexBackfilling = true
+	go db.backfillAddressIndex()
+}
+
+func (db *Overlay) backfillAddressIndex() {
+	n, err := db.BackfillAddressIndex(5000)
+
+	db.addressIndexMutex.Lock()
+	db.addressIndexBackfilling = false
+	db.addressIndexMutex.Unlock()
+
+	if err != nil {
+		packageLogger.Errorf("Error building the address index: %v", err)
+		return
+	}
+	if n > 0 {
+		packageLogger.Infof("Address index: indexed %d factoid blocks", n)
+	}
+}
@@ -0,0 +1,262 @@
+package databaseOverlay_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	. "github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/mapdb"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func createAddressIndexTestBlocks(max int) []interfaces.IFBlock {
+	blocks := []interfaces.IFBlock{}
+	var prev interfaces.IFBlock = nil
+	for i := 0; i < max; i++ {
+		prev = testHelper.CreateTestFactoidBlock(prev)
+		blocks = append(blocks, prev)
+	}
+	return blocks
+}
+
+// expectedAddressTransactions lists the transactions touching the address in the blocks, in order
+func expectedAddressTransactions(blocks []interfaces.IFBlock, address interfaces.IAddress) []interfaces.AddressTransaction {
+	list := []interfaces.AddressTransaction{}
+	for _, b := range blocks {
+		for _, tx := range b.GetTransactions() {
+			var adrs []interfaces.IAddress
+			for _, a := range tx.GetInputs() {
+				adrs = append(adrs, a.GetAddress())
+			}
+			for _, a := range tx.GetOutputs() {
+				adrs = append(adrs, a.GetAddress())
+			}
+			for _, a := range tx.GetECOutputs() {
+				adrs = append(adrs, a.GetAddress())
+			}
+			for _, a := range adrs {
+				if a.IsSameAs(address) {
+					list = append(list, interfaces.AddressTransaction{TxID: tx.GetSigHash(), DBHeight: b.GetDatabaseHeight()})
+					break
+				}
+			}
+		}
+	}
+	return list
+}
+
+func checkAddressTransactions(t *testing.T, dbo *Overlay, blocks []interfaces.IFBlock, address interfaces.IAddress) {
+	expected := expectedAddressTransactions(blocks, address)
+	if len(expected) == 0 {
+		t.Fatalf("No transactions found for %x", address.Bytes())
+	}
+
+	list, total, err := dbo.FetchAddressTransactions(address, 0, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if int(total) != len(expected) || len(list) != len(expected) {
+		t.Fatalf("Wrong number of transactions - %v, %v vs %v", total, len(list), len(expected))
+	}
+	for i := range expected {
+		if list[i].TxID.IsSameAs(expected[i].TxID) == false || list[i].DBHeight != expected[i].DBHeight {
+			t.Errorf("Wrong transaction at %v", i)
+		}
+	}
+
+	// Page through the transactions two at a time
+	for start := 0; start < len(expected); start += 2 {
+		page, _, err := dbo.FetchAddressTransactions(address, uint32(start), 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, tx := range page {
+			if tx.TxID.IsSameAs(expected[start+i].TxID) == false {
+				t.Errorf("Wrong transaction at %v", start+i)
+			}
+		}
+	}
+
+	page, _, err := dbo.FetchAddressTransactions(address, uint32(len(expected)), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page) != 0 {
+		t.Errorf("Expected an empty page past the end, got %v", len(page))
+	}
+}
+
+func TestAddressIndex(t *testing.T) {
+	blocks := createAddressIndexTestBlocks(10)
+	dbo := NewOverlay(new(mapdb.MapDB))
+	defer dbo.Close()
+	dbo.SetAddressIndex(true)
+
+	for _, block := range blocks {
+		err := dbo.SaveFactoidBlockHead(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Saving a block again must not index its transactions twice
+	err := dbo.SaveFactoidBlockHead(blocks[len(blocks)-1])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkAddressTransactions(t, dbo, blocks, testHelper.NewFactoidAddress(0))
+	checkAddressTransactions(t, dbo, blocks, testHelper.NewECAddress(0))
+
+	height, err := dbo.FetchAddressIndexHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if height != blocks[len(blocks)-1].GetDatabaseHeight()+1 {
+		t.Errorf("Wrong address index height %v", height)
+	}
+}
+
+func TestAddressIndexDisabled(t *testing.T) {
+	blocks := createAddressIndexTestBlocks(3)
+	dbo := NewOverlay(new(mapdb.MapDB))
+	defer dbo.Close()
+
+	for _, block := range blocks {
+		err := dbo.SaveFactoidBlockHead(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, total, err := dbo.FetchAddressTransactions(testHelper.NewFactoidAddress(0), 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 0 || len(list) != 0 {
+		t.Errorf("Transactions were indexed with the address index disabled")
+	}
+}
+
+func TestBackfillAddressIndex(t *testing.T) {
+	blocks := createAddressIndexTestBlocks(10)
+	dbo := NewOverlay(new(mapdb.MapDB))
+	defer dbo.Close()
+
+	// The first blocks are saved before the index is turned on
+	for _, block := range blocks[:6] {
+		err := dbo.SaveFactoidBlockHead(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dbo.SetAddressIndex(true)
+	n, err := dbo.BackfillAddressIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 6 {
+		t.Errorf("Expected 6 blocks to be backfilled, got %v", n)
+	}
+
+	for _, block := range blocks[6:] {
+		err := dbo.SaveFactoidBlockHead(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Nothing is left to backfill
+	n, err = dbo.BackfillAddressIndex(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("Expected nothing to be backfilled, got %v", n)
+	}
+
+	checkAddressTransactions(t, dbo, blocks, testHelper.NewFactoidAddress(0))
+	checkAddressTransactions(t, dbo, blocks, testHelper.NewECAddress(0))
+}
+
+func TestBackfillAddressIndexInBackground(t *testing.T) {
+	blocks := createAddressIndexTestBlocks(10)
+	dbo := NewOverlay(new(mapdb.MapDB))
+	defer dbo.Close()
+
+	for _, block := range blocks[:6] {
+		err := dbo.SaveFactoidBlockHead(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Blocks saved while the backfill runs are left to it until it reaches them
+	dbo.SetAddressIndex(true)
+	dbo.StartAddressIndexBackfill()
+	for _, block := range blocks[6:] {
+		err := dbo.SaveFactoidBlockHead(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		height, err := dbo.FetchAddressIndexHeight()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if height == uint32(len(blocks)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("The backfill stopped at height %d", height)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	checkAddressTransactions(t, dbo, blocks, testHelper.NewFactoidAddress(0))
+	checkAddressTransactions(t, dbo, blocks, testHelper.NewECAddress(0))
+}
+
+func TestAddressIndexSkipsLaterBlocks(t *testing.T) {
+	blocks := createAddressIndexTestBlocks(10)
+	dbo := NewOverlay(new(mapdb.MapDB))
+	defer dbo.Close()
+
+	for _, block := range blocks[:6] {
+		err := dbo.SaveFactoidBlockHead(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// With no backfill run, saving a later block starts one instead of indexing out of order
+	dbo.SetAddressIndex(true)
+	for _, block := range blocks[6:] {
+		err := dbo.SaveFactoidBlockHead(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		height, err := dbo.FetchAddressIndexHeight()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if height == uint32(len(blocks)) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("The address index stopped at height %d", height)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	checkAddressTransactions(t, dbo, blocks, testHelper.NewFactoidAddress(0))
+	checkAddressTransactions(t, dbo, blocks, testHelper.NewECAddress(0))
+}
@@ -6,6 +6,7 @@ package databaseOverlay
 
 import (
 	//"fmt"
+	"encoding/binary"
 	"sort"
 
 	"github.com/FactomProject/factomd/anchor"
@@ -63,7 +64,11 @@ func (dbo *Overlay) SaveAnchorInfoFromEntry(entry interfaces.IEBEntry) error {
 	if err != nil {
 		return err
 	}
-	return dbo.ProcessDirBlockInfoBatch(dbi)
+	err = dbo.ProcessDirBlockInfoBatch(dbi)
+	if err != nil {
+		return err
+	}
+	return dbo.PutInBatch([]interfaces.Record{anchorEntryRecord(entry, ar)})
 }
 
 func (dbo *Overlay) SaveAnchorInfoFromEntryMultiBatch(entry interfaces.IEBEntry) error {
@@ -84,7 +89,39 @@ func (dbo *Overlay) SaveAnchorInfoFromEntryMultiBatch(entry interfaces.IEBEntry)
 	if err != nil {
 		return err
 	}
-	return dbo.ProcessDirBlockInfoMultiBatch(dbi)
+	err = dbo.ProcessDirBlockInfoMultiBatch(dbi)
+	if err != nil {
+		return err
+	}
+	dbo.PutInMultiBatch([]interfaces.Record{anchorEntryRecord(entry, ar)})
+	return nil
+}
+
+// The anchor entry index maps the height of every directory block anchored in Bitcoin to the
+// entry of its signed anchor record, so a receipt can carry the record without a search of the
+// anchor chain.  A block anchored again is mapped to the latest record.
+
+func anchorEntryKey(dbheight uint32) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, dbheight)
+	return key
+}
+
+func anchorEntryRecord(entry interfaces.IEBEntry, ar *anchor.AnchorRecord) interfaces.Record {
+	return interfaces.Record{Bucket: ANCHOR_ENTRY, Key: anchorEntryKey(ar.DBHeight), Data: entry.GetHash()}
+}
+
+// FetchAnchorEntryByHeight gets the entry of the signed Bitcoin anchor record of the directory
+// block at the height, or nil if the block is not anchored yet
+func (dbo *Overlay) FetchAnchorEntryByHeight(dbheight uint32) (interfaces.IEBEntry, error) {
+	hash, err := dbo.Get(ANCHOR_ENTRY, anchorEntryKey(dbheight), new(primitives.Hash))
+	if err != nil {
+		return nil, err
+	}
+	if hash == nil {
+		return nil, nil
+	}
+	return dbo.FetchEntry(hash.(interfaces.IHash))
 }
 
 func (dbo *Overlay) FetchAllAnchorInfo() ([]*anchor.AnchorRecord, error) {
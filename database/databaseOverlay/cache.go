@@ -0,0 +1,224 @@
+package databaseOverlay
+
+import (
+	"container/list"
+	"reflect"
+	"sync"
+	"unsafe"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// The overlay can keep the blocks and entries it reads most recently, already unmarshalled, so
+// reading them again skips the database.  They are stored under their own hash, so a cached block
+// only goes stale when it is written over or deleted, which drops it from the cache.  Chain heads
+// and the height indexes are never cached, so a new head is seen as soon as it is written.  Each
+// read is given a copy of the cached block, as readers change the blocks they are given (an
+// entry block works out its KeyMR when first asked, for one), and copying a block is much cheaper
+// than unmarshalling it.
+
+// SetBlockCache keeps up to size blocks and entries in memory, or none if size is 0
+func (db *Overlay) SetBlockCache(size int) {
+	if size <= 0 {
+		db.cache = nil
+		return
+	}
+	db.cache = newBlockCache(size)
+}
+
+type cacheKey struct {
+	bucket string
+	key    string
+}
+
+type cacheItem struct {
+	key   cacheKey
+	block interfaces.DatabaseBatchable
+}
+
+// blockCache is a least recently used cache of unmarshalled blocks
+type blockCache struct {
+	mutex sync.Mutex
+	size  int
+	items map[cacheKey]*list.Element
+	order *list.List // The most recently used at the front
+}
+
+func newBlockCache(size int) *blockCache {
+	c := new(blockCache)
+	c.size = size
+	c.items = make(map[cacheKey]*list.Element)
+	c.order = list.New()
+	return c
+}
+
+// get returns a copy of the cached block, if there is one of the same type as dst
+func (c *blockCache) get(bucket, key []byte, dst interfaces.DatabaseBatchable) interfaces.DatabaseBatchable {
+	c.mutex.Lock()
+	e, ok := c.items[cacheKey{string(bucket), string(key)}]
+	var block interfaces.DatabaseBatchable
+	if ok {
+		block = e.Value.(*cacheItem).block
+		c.order.MoveToFront(e)
+	}
+	c.mutex.Unlock()
+
+	if !ok || reflect.TypeOf(block) != reflect.TypeOf(dst) {
+		OverlayDBCacheMisses.Inc()
+		return nil
+	}
+	OverlayDBCacheHits.Inc()
+	// The cached block is never changed once added, so it is copied outside the lock
+	return copyBlock(block)
+}
+
+// add caches a copy of the block, dropping the least recently used one if the cache is full
+func (c *blockCache) add(bucket, key []byte, block interfaces.DatabaseBatchable) {
+	block = copyBlock(block)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	k := cacheKey{string(bucket), string(key)}
+	if e, ok := c.items[k]; ok {
+		e.Value.(*cacheItem).block = block
+		c.order.MoveToFront(e)
+		return
+	}
+	c.items[k] = c.order.PushFront(&cacheItem{key: k, block: block})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheItem).key)
+		OverlayDBCacheEvictions.Inc()
+	}
+}
+
+// remove drops the block stored in the bucket under the key
+func (c *blockCache) remove(bucket, key []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	k := cacheKey{string(bucket), string(key)}
+	if e, ok := c.items[k]; ok {
+		c.order.Remove(e)
+		delete(c.items, k)
+	}
+}
+
+// removeBucket drops every block of the bucket
+func (c *blockCache) removeBucket(bucket []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for k, e := range c.items {
+		if k.bucket == string(bucket) {
+			c.order.Remove(e)
+			delete(c.items, k)
+		}
+	}
+}
+
+// copyBlock returns a deep copy of the block, which shares nothing with it that can be changed.
+// Pointers to the same value in the block point to the same copy in the copy.
+func copyBlock(block interfaces.DatabaseBatchable) interfaces.DatabaseBatchable {
+	return deepCopy(reflect.ValueOf(block), make(map[uintptr]reflect.Value)).Interface().(interfaces.DatabaseBatchable)
+}
+
+func deepCopy(src reflect.Value, copied map[uintptr]reflect.Value) reflect.Value {
+	dst := reflect.New(src.Type()).Elem()
+	copyValue(dst, src, copied)
+	return dst
+}
+
+// copyValue deep copies src into dst, which must be addressable
+func copyValue(dst, src reflect.Value, copied map[uintptr]reflect.Value) {
+	if !hasPointers(src.Type()) {
+		dst.Set(src)
+		return
+	}
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() {
+			return
+		}
+		if c, ok := copied[src.Pointer()]; ok {
+			dst.Set(c)
+			return
+		}
+		c := reflect.New(src.Type().Elem())
+		copied[src.Pointer()] = c
+		copyValue(c.Elem(), src.Elem(), copied)
+		dst.Set(c)
+	case reflect.Interface:
+		if !src.IsNil() {
+			dst.Set(deepCopy(src.Elem(), copied))
+		}
+	case reflect.Slice:
+		if src.IsNil() {
+			return
+		}
+		c := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		if !hasPointers(src.Type().Elem()) {
+			reflect.Copy(c, src)
+		} else {
+			for i := 0; i < src.Len(); i++ {
+				copyValue(c.Index(i), src.Index(i), copied)
+			}
+		}
+		dst.Set(c)
+	case reflect.Map:
+		if src.IsNil() {
+			return
+		}
+		c := reflect.MakeMapWithSize(src.Type(), src.Len())
+		for _, k := range src.MapKeys() {
+			c.SetMapIndex(deepCopy(k, copied), deepCopy(src.MapIndex(k), copied))
+		}
+		dst.Set(c)
+	case reflect.Array:
+		src = addressable(src)
+		for i := 0; i < src.Len(); i++ {
+			copyValue(dst.Index(i), src.Index(i), copied)
+		}
+	case reflect.Struct:
+		// The fields are reached through their addresses, as unexported ones can not be read or
+		// set through reflect otherwise
+		src = addressable(src)
+		for i := 0; i < src.NumField(); i++ {
+			f := src.Type().Field(i).Type
+			copyValue(reflect.NewAt(f, unsafe.Pointer(dst.Field(i).UnsafeAddr())).Elem(),
+				reflect.NewAt(f, unsafe.Pointer(src.Field(i).UnsafeAddr())).Elem(), copied)
+		}
+	default:
+		dst.Set(src)
+	}
+}
+
+// addressable returns v, or an addressable copy of it if it is not
+func addressable(v reflect.Value) reflect.Value {
+	if v.CanAddr() {
+		return v
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
+// hasPointers tells whether values of the type refer to memory that a copy of them would share.
+// Strings can not be changed, and functions and channels are shared by the copy.
+func hasPointers(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map:
+		return true
+	case reflect.Array:
+		return hasPointers(t.Elem())
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			if hasPointers(t.Field(i).Type) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,210 @@
+package databaseOverlay_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	. "github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/testHelper"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestBlockCache(t *testing.T) {
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	defer dbo.Close()
+	dbo.SetBlockCache(4)
+
+	count := func(c prometheus.Counter) float64 {
+		m := new(dto.Metric)
+		err := c.Write(m)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		return m.GetCounter().GetValue()
+	}
+	hits, misses := count(OverlayDBCacheHits), count(OverlayDBCacheMisses)
+
+	dblock, err := dbo.FetchDBlockByHeight(3)
+	if err != nil || dblock == nil {
+		t.Fatalf("Missing directory block - %v", err)
+	}
+	again, err := dbo.FetchDBlockByHeight(3)
+	if err != nil || again == nil || !again.GetKeyMR().IsSameAs(dblock.GetKeyMR()) {
+		t.Fatalf("Read the wrong directory block from the cache - %v", err)
+	}
+	if count(OverlayDBCacheMisses) != misses+1 || count(OverlayDBCacheHits) != hits+1 {
+		t.Errorf("Expected one miss and one hit, got %v and %v", count(OverlayDBCacheMisses)-misses, count(OverlayDBCacheHits)-hits)
+	}
+
+	keyMR := dblock.GetEBlockDBEntries()[0].GetKeyMR()
+	eblock, err := dbo.FetchEBlock(keyMR)
+	if err != nil || eblock == nil {
+		t.Fatalf("Missing entry block - %v", err)
+	}
+	var entry interfaces.IEBEntry
+	for _, h := range eblock.GetEntryHashes() {
+		if h.IsMinuteMarker() {
+			continue
+		}
+		entry, err = dbo.FetchEntry(h)
+		if err != nil || entry == nil {
+			t.Fatalf("Missing entry - %v", err)
+		}
+		hits = count(OverlayDBCacheHits)
+		entry, err = dbo.FetchEntry(h)
+		if err != nil || entry == nil || !entry.GetHash().IsSameAs(h) {
+			t.Fatalf("Read the wrong entry from the cache - %v", err)
+		}
+		if count(OverlayDBCacheHits) != hits+1 {
+			t.Errorf("Entry was not read from the cache")
+		}
+		break
+	}
+
+	// Each read gets a block of its own, so changing one does not change the cache
+	seq := eblock.GetHeader().GetEBSequence()
+	eblock.GetHeader().SetEBSequence(seq + 1)
+	fresh, err := dbo.FetchEBlock(keyMR)
+	if err != nil || fresh == nil || fresh.GetHeader().GetEBSequence() != seq {
+		t.Errorf("A change to a block read from the cache was shared - %v", err)
+	}
+
+	// Reading more blocks than fit drops the oldest ones
+	evictions := count(OverlayDBCacheEvictions)
+	for h := uint32(0); h < uint32(testHelper.BlockCount); h++ {
+		b, err := dbo.FetchDBlockByHeight(h)
+		if err != nil || b == nil {
+			t.Fatalf("Missing directory block %v - %v", h, err)
+		}
+	}
+	if count(OverlayDBCacheEvictions) == evictions {
+		t.Errorf("Nothing was dropped from the full cache")
+	}
+
+	// A pruned entry block is not read from the cache
+	_, err = dbo.FetchEBlock(keyMR)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	pruned, err := dbo.PruneEBlock(keyMR)
+	if err != nil || !pruned {
+		t.Fatalf("Entry block was not pruned - %v", err)
+	}
+	b, err := dbo.FetchEBlock(keyMR)
+	if err != nil || b != nil {
+		t.Errorf("Pruned entry block was read from the cache - %v", err)
+	}
+
+	// A new chain head is seen as soon as it is written
+	head, err := dbo.FetchEBlockHead(testHelper.GetChainID())
+	if err != nil || head == nil {
+		t.Fatalf("Missing entry block head - %v", err)
+	}
+	next, _ := testHelper.CreateTestEntryBlock(head)
+	err = dbo.ProcessEBlockBatch(next, false)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	head, err = dbo.FetchEBlockHead(testHelper.GetChainID())
+	if err != nil || head == nil {
+		t.Fatalf("Missing entry block head - %v", err)
+	}
+	if !head.DatabasePrimaryIndex().IsSameAs(next.DatabasePrimaryIndex()) {
+		t.Errorf("Read an old entry block head")
+	}
+
+	// Without a cache nothing is counted
+	dbo.SetBlockCache(0)
+	hits, misses = count(OverlayDBCacheHits), count(OverlayDBCacheMisses)
+	dbo.FetchDBlockByHeight(3)
+	dbo.FetchDBlockByHeight(3)
+	if count(OverlayDBCacheHits) != hits || count(OverlayDBCacheMisses) != misses {
+		t.Errorf("Blocks were read from a cache that is off")
+	}
+}
+
+func TestBlockCacheCopies(t *testing.T) {
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	defer dbo.Close()
+	dbo.SetBlockCache(16)
+
+	same := func(name string, a, b interfaces.BinaryMarshallable) {
+		if a == b {
+			t.Errorf("%s: two reads returned the same object", name)
+		}
+		ra, err := a.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		rb, err := b.MarshalBinary()
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !bytes.Equal(ra, rb) {
+			t.Errorf("%s: the cached copy differs from the original", name)
+		}
+	}
+
+	dblock, err := dbo.FetchDBlockByHeight(3)
+	if err != nil || dblock == nil {
+		t.Fatalf("Missing directory block - %v", err)
+	}
+	again, _ := dbo.FetchDBlockByHeight(3)
+	same("directory block", dblock, again)
+
+	ablock, err := dbo.FetchABlockByHeight(3)
+	if err != nil || ablock == nil {
+		t.Fatalf("Missing admin block - %v", err)
+	}
+	ablock2, _ := dbo.FetchABlockByHeight(3)
+	same("admin block", ablock, ablock2)
+
+	fblock, err := dbo.FetchFBlockByHeight(3)
+	if err != nil || fblock == nil {
+		t.Fatalf("Missing factoid block - %v", err)
+	}
+	fblock2, _ := dbo.FetchFBlockByHeight(3)
+	same("factoid block", fblock, fblock2)
+
+	ecblock, err := dbo.FetchECBlockByHeight(3)
+	if err != nil || ecblock == nil {
+		t.Fatalf("Missing entry credit block - %v", err)
+	}
+	ecblock2, _ := dbo.FetchECBlockByHeight(3)
+	same("entry credit block", ecblock, ecblock2)
+
+	keyMR := dblock.GetEBlockDBEntries()[0].GetKeyMR()
+	eblock, err := dbo.FetchEBlock(keyMR)
+	if err != nil || eblock == nil {
+		t.Fatalf("Missing entry block - %v", err)
+	}
+	eblock2, _ := dbo.FetchEBlock(keyMR)
+	same("entry block", eblock, eblock2)
+
+	for _, h := range eblock.GetEntryHashes() {
+		if h.IsMinuteMarker() {
+			continue
+		}
+		entry, err := dbo.FetchEntry(h)
+		if err != nil || entry == nil {
+			t.Fatalf("Missing entry - %v", err)
+		}
+		entry2, _ := dbo.FetchEntry(h)
+		same("entry", entry, entry2)
+		break
+	}
+
+	// A reader changing its copy must not change what the next reader gets
+	again.GetHeader().SetDBHeight(1000)
+	again.GetHeader().GetPrevKeyMR().SetBytes(make([]byte, 32))
+	fresh, err := dbo.FetchDBlockByHeight(3)
+	if err != nil || fresh == nil {
+		t.Fatalf("Missing directory block - %v", err)
+	}
+	if fresh.GetDatabaseHeight() != 3 || !fresh.GetHeader().GetPrevKeyMR().IsSameAs(dblock.GetHeader().GetPrevKeyMR()) {
+		t.Errorf("Changing a cached copy changed the cache")
+	}
+	same("directory block", dblock, fresh)
+}
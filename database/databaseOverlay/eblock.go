@@ -1,9 +1,14 @@
 package databaseOverlay
 
 import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
 	"github.com/FactomProject/factomd/common/entryBlock"
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/util"
 	//"github.com/FactomProject/factomd/log"
 	//"github.com/FactomProject/factomd/util"
 	//"sort"
@@ -18,6 +23,10 @@ func (db *Overlay) ProcessEBlockBatch(eblock interfaces.DatabaseBlockWithEntries
 	if err != nil {
 		return err
 	}
+	err = db.PutInBatch(eblockSequenceRecords(eblock))
+	if err != nil {
+		return err
+	}
 	return db.SaveIncludedInMultiFromBlock(eblock, checkForDuplicateEntries)
 }
 
@@ -28,6 +37,10 @@ func (db *Overlay) ProcessEBlockBatchWithoutHead(eblock interfaces.DatabaseBlock
 	if err != nil {
 		return err
 	}
+	err = db.PutInBatch(eblockSequenceRecords(eblock))
+	if err != nil {
+		return err
+	}
 	return db.SaveIncludedInMultiFromBlock(eblock, checkForDuplicateEntries)
 }
 
@@ -38,6 +51,7 @@ func (db *Overlay) ProcessEBlockMultiBatchWithoutHead(eblock interfaces.Database
 	if err != nil {
 		return err
 	}
+	db.PutInMultiBatch(eblockSequenceRecords(eblock))
 	return db.SaveIncludedInMultiFromBlockMultiBatch(eblock, checkForDuplicateEntries)
 }
 
@@ -48,6 +62,7 @@ func (db *Overlay) ProcessEBlockMultiBatch(eblock interfaces.DatabaseBlockWithEn
 	if err != nil {
 		return err
 	}
+	db.PutInMultiBatch(eblockSequenceRecords(eblock))
 	return db.SaveIncludedInMultiFromBlockMultiBatch(eblock, checkForDuplicateEntries)
 }
 
@@ -143,3 +158,102 @@ func (db *Overlay) FetchAllEBlockChainIDs() ([]interfaces.IHash, error) {
 	}
 	return entries, nil
 }
+
+// The chain sequence index maps the sequence number of every entry block of a chain to its
+// KeyMR, so a chain can be read from the start without walking it back from the head.
+
+func eblockSequenceKey(seq uint32) []byte {
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, seq)
+	return key
+}
+
+func eblockSequenceRecords(eblock interfaces.DatabaseBlockWithEntries) []interfaces.Record {
+	block, ok := eblock.(interfaces.IEntryBlock)
+	if !ok {
+		return nil
+	}
+	bucket := append(ENTRYBLOCK_CHAIN_SEQUENCE, block.GetChainID().Bytes()...)
+	key := eblockSequenceKey(block.GetHeader().GetEBSequence())
+	return []interfaces.Record{{bucket, key, block.DatabasePrimaryIndex()}}
+}
+
+// FetchEBlockKeyMRBySequence returns the KeyMR of the entry block with the sequence number in
+// the chain, or nil if the sequence index does not have it.
+func (db *Overlay) FetchEBlockKeyMRBySequence(chainID interfaces.IHash, seq uint32) (interfaces.IHash, error) {
+	bucket := append(ENTRYBLOCK_CHAIN_SEQUENCE, chainID.Bytes()...)
+	keyMR, err := db.Get(bucket, eblockSequenceKey(seq), new(primitives.Hash))
+	if err != nil {
+		return nil, err
+	}
+	if keyMR == nil {
+		return nil, nil
+	}
+	return keyMR.(interfaces.IHash), nil
+}
+
+// FetchEBlockBySequence gets the entry block with the sequence number in the chain.  The index
+// is kept as entry blocks are saved, and built for older databases by a migration, so a block
+// missing from it is not in the database.
+func (db *Overlay) FetchEBlockBySequence(chainID interfaces.IHash, seq uint32) (interfaces.IEntryBlock, error) {
+	keyMR, err := db.FetchEBlockKeyMRBySequence(chainID, seq)
+	if err != nil {
+		return nil, err
+	}
+	if keyMR == nil {
+		return nil, nil
+	}
+	return db.FetchEBlock(keyMR)
+}
+
+// RebuildEBlockSequenceIndex walks the chain back from its head, adding every entry block
+// to the sequence index.  The walk stops early if a block is missing from the database.
+func (db *Overlay) RebuildEBlockSequenceIndex(chainID interfaces.IHash) error {
+	block, err := db.FetchEBlockHead(chainID)
+	if err != nil {
+		return err
+	}
+
+	batch := []interfaces.Record{}
+	for block != nil {
+		batch = append(batch, eblockSequenceRecords(block)...)
+		if block.GetHeader().GetEBSequence() == 0 || block.GetHeader().GetPrevKeyMR().IsZero() {
+			break
+		}
+		block, err = db.FetchEBlock(block.GetHeader().GetPrevKeyMR())
+		if err != nil {
+			return err
+		}
+	}
+	return db.PutInBatch(batch)
+}
+
+// ReindexEBlockSequences drops the sequence index of every chain and builds it again from the
+// chain heads, in the order of the chain IDs, and returns how many chains it indexed.  Progress
+// is printed every printFreq chains, or not at all if printFreq is 0.
+func (db *Overlay) ReindexEBlockSequences(printFreq uint32) (int, error) {
+	chainIDs, err := db.DB.ListAllKeys(CHAIN_HEAD)
+	if err != nil {
+		return 0, err
+	}
+	sort.Sort(util.ByByteArray(chainIDs))
+
+	for i, id := range chainIDs {
+		chainID, err := primitives.NewShaHash(id)
+		if err != nil {
+			return i, err
+		}
+		err = db.Clear(append(append([]byte{}, ENTRYBLOCK_CHAIN_SEQUENCE...), id...))
+		if err != nil {
+			return i, err
+		}
+		err = db.RebuildEBlockSequenceIndex(chainID)
+		if err != nil {
+			return i, err
+		}
+		if printFreq > 0 && uint32(i+1)%printFreq == 0 {
+			fmt.Printf("Sequence index: %d of %d chains\n", i+1, len(chainIDs))
+		}
+	}
+	return len(chainIDs), nil
+}
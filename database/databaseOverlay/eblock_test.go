@@ -212,3 +212,78 @@ func TestFetchAllEBlockChainIDs(t *testing.T) {
 		t.Errorf("Got wrong number of chains - %v", len(chains))
 	}
 }
+
+func TestFetchEBlockBySequence(t *testing.T) {
+	blocks := []*EBlock{}
+	max := 10
+	var prev *EBlock = nil
+	dbo := NewOverlay(new(mapdb.MapDB))
+	defer dbo.Close()
+
+	for i := 0; i < max; i++ {
+		prev, _ = testHelper.CreateTestEntryBlock(prev)
+		// The test blocks all have a sequence of 0, so number them before the next one links to them
+		prev.GetHeader().SetEBSequence(uint32(i))
+		blocks = append(blocks, prev)
+		err := dbo.SaveEBlockHead(prev, false)
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	chainID := blocks[0].GetChainID()
+
+	checkSequences := func() {
+		for _, block := range blocks {
+			loaded, err := dbo.FetchEBlockBySequence(chainID, block.GetHeader().GetEBSequence())
+			if err != nil {
+				t.Error(err)
+			}
+			if loaded == nil {
+				t.Fatalf("Block %v not found", block.GetHeader().GetEBSequence())
+			}
+			if loaded.DatabasePrimaryIndex().IsSameAs(block.DatabasePrimaryIndex()) == false {
+				t.Errorf("Wrong block returned for sequence %v", block.GetHeader().GetEBSequence())
+			}
+		}
+
+		loaded, err := dbo.FetchEBlockBySequence(chainID, uint32(max))
+		if err != nil {
+			t.Error(err)
+		}
+		if loaded != nil {
+			t.Errorf("Found a block past the head of the chain")
+		}
+	}
+	checkSequences()
+
+	// A lost index is not rebuilt by reading it, only by a reindex
+	err := dbo.Clear(append(ENTRYBLOCK_CHAIN_SEQUENCE, chainID.Bytes()...))
+	if err != nil {
+		t.Error(err)
+	}
+	loaded, err := dbo.FetchEBlockBySequence(chainID, 0)
+	if err != nil {
+		t.Error(err)
+	}
+	keyMR, err := dbo.FetchEBlockKeyMRBySequence(chainID, 0)
+	if err != nil {
+		t.Error(err)
+	}
+	if loaded != nil || keyMR != nil {
+		t.Errorf("Sequence index was rebuilt on read")
+	}
+
+	// A stale entry past the head is dropped by the reindex
+	err = dbo.Put(append(ENTRYBLOCK_CHAIN_SEQUENCE, chainID.Bytes()...), []byte{0, 0, 0, byte(max)}, blocks[0].DatabasePrimaryIndex())
+	if err != nil {
+		t.Error(err)
+	}
+	n, err := dbo.ReindexEBlockSequences(0)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 1 {
+		t.Errorf("Reindexed %v chains rather than 1", n)
+	}
+	checkSequences()
+}
@@ -48,11 +48,12 @@ func (db *Overlay) SaveIncludedInMultiMultiBatch(entries []interfaces.IHash, blo
 			continue
 		}
 		if checkForDuplicateEntries == true {
-			exists, err := db.DoesKeyExist(INCLUDED_IN, entry.Bytes())
+			first, err := db.FetchIncludedIn(entry)
 			if err != nil {
 				return err
 			}
-			if exists == true {
+			if first != nil {
+				batch = append(batch, includedAgainRecords(entry, first, block)...)
 				continue
 			}
 		}
@@ -72,11 +73,12 @@ func (db *Overlay) SaveIncludedInMulti(entries []interfaces.IHash, block interfa
 
 	for _, entry := range entries {
 		if checkForDuplicateEntries == true {
-			exists, err := db.DoesKeyExist(INCLUDED_IN, entry.Bytes())
+			first, err := db.FetchIncludedIn(entry)
 			if err != nil {
 				return err
 			}
-			if exists == true {
+			if first != nil {
+				batch = append(batch, includedAgainRecords(entry, first, block)...)
 				continue
 			}
 		}
@@ -101,3 +103,38 @@ func (db *Overlay) FetchIncludedIn(hash interfaces.IHash) (interfaces.IHash, err
 	}
 	return block.(interfaces.IHash), nil
 }
+
+// An entry can be included in more than one block, as when it is revealed again.  INCLUDED_IN
+// keeps the first block, and INCLUDED_AGAIN lists the others under the key of the entry hash
+// followed by the block hash.
+
+func includedAgainRecords(entry, first, block interfaces.IHash) []interfaces.Record {
+	if first.IsSameAs(block) {
+		return nil
+	}
+	key := append(entry.Bytes(), block.Bytes()...)
+	return []interfaces.Record{{Bucket: INCLUDED_AGAIN, Key: key, Data: block}}
+}
+
+// FetchAllIncludedIn returns every block the entry is included in, the first one first
+func (db *Overlay) FetchAllIncludedIn(hash interfaces.IHash) ([]interfaces.IHash, error) {
+	first, err := db.FetchIncludedIn(hash)
+	if err != nil || first == nil {
+		return nil, err
+	}
+	blocks := []interfaces.IHash{first}
+
+	it, err := db.DB.NewIterator(INCLUDED_AGAIN, &interfaces.IteratorOptions{Prefix: hash.Bytes()})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Release()
+	for it.Next() {
+		block, err := primitives.NewShaHash(it.Key()[len(hash.Bytes()):])
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+	return blocks, it.Error()
+}
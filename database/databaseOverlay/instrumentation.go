@@ -119,6 +119,21 @@ var (
 		Name: "factomd_database_overlay_gets_paidfor",
 		Help: "Counts gets from the database",
 	})
+
+	OverlayDBCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "factomd_database_overlay_cache_hits",
+		Help: "Counts blocks and entries read from the block cache",
+	})
+
+	OverlayDBCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "factomd_database_overlay_cache_misses",
+		Help: "Counts blocks and entries not found in the block cache",
+	})
+
+	OverlayDBCacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "factomd_database_overlay_cache_evictions",
+		Help: "Counts blocks and entries dropped from the full block cache",
+	})
 )
 
 var registered = false
@@ -154,6 +169,9 @@ func RegisterPrometheus() {
 	prometheus.MustRegister(OverlayDBGetsDirBlockInfoSecondary)
 	prometheus.MustRegister(OverlayDBGetsInvludeIn)
 	prometheus.MustRegister(OverlayDBGetsPaidFor)
+	prometheus.MustRegister(OverlayDBCacheHits)
+	prometheus.MustRegister(OverlayDBCacheMisses)
+	prometheus.MustRegister(OverlayDBCacheEvictions)
 }
 
 func GetBucket(bucket []byte) {
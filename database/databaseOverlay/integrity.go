@@ -0,0 +1,221 @@
+package databaseOverlay
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Blocks read from the database can be checked against the hash they are stored under.  A block
+// that fails is put in QUARANTINE, under its bucket and key, along with the height of the
+// directory block it belongs to.  Until a good copy is written over it the block reads as
+// missing, rather than handing bad data to the rest of the node.
+
+// SetBlockVerification checks one in every sampleRate blocks read, or none if sampleRate is 0.
+// onCorruption, if set, is called with the directory block height of every corrupted block found.
+func (db *Overlay) SetBlockVerification(sampleRate uint32, onCorruption func(dbheight uint32)) {
+	db.VerifySampleRate = sampleRate
+	db.OnCorruption = onCorruption
+}
+
+// verifiable tells whether blocks of the kind can be checked against their key.  Only the blocks
+// of the chains and the entries are, as they are always stored under their own hash.
+func verifiable(dst interfaces.DatabaseBatchable) bool {
+	switch dst.(type) {
+	case interfaces.IDirectoryBlock, interfaces.IAdminBlock, interfaces.IFBlock, interfaces.IEntryCreditBlock, interfaces.IEntryBlock, interfaces.IEBEntry:
+		return true
+	}
+	return false
+}
+
+// sampleBlock tells whether the block being read is one to verify
+func (db *Overlay) sampleBlock() bool {
+	if db.VerifySampleRate == 0 {
+		return false
+	}
+	return atomic.AddUint32(&db.verifyCount, 1)%db.VerifySampleRate == 0
+}
+
+// fetchVerifiedBlock reads a block like FetchBlock, and quarantines it if it does not unmarshal
+// or does not hash to its key
+func (db *Overlay) fetchVerifiedBlock(bucket []byte, key interfaces.IHash, dst interfaces.DatabaseBatchable) (interfaces.DatabaseBatchable, error) {
+	raw, err := db.Get(bucket, key.Bytes(), new(primitives.ByteSlice))
+	if err != nil {
+		return nil, err
+	}
+	if raw == nil {
+		return nil, nil
+	}
+
+	err = dst.UnmarshalBinary(raw.(*primitives.ByteSlice).Bytes)
+	unmarshalled := err == nil
+	if unmarshalled && dst.DatabasePrimaryIndex().IsSameAs(key) {
+		return dst, nil
+	}
+	if unmarshalled {
+		err = fmt.Errorf("hashes to %x", dst.DatabasePrimaryIndex().Bytes())
+	}
+
+	height, known := db.corruptedHeight(key, dst, unmarshalled)
+	qerr := db.quarantine(bucket, key, height, known)
+	if qerr != nil {
+		return nil, qerr
+	}
+	name, ok := ConstantNamesMap[string(bucket)]
+	if !ok {
+		name = fmt.Sprintf("chain %x", bucket)
+	}
+	fields := log.Fields{"bucket": name, "key": fmt.Sprintf("%x", key.Bytes())}
+	if known {
+		fields["dbheight"] = height
+	}
+	packageLogger.WithFields(fields).Errorf("Corrupted block quarantined: %v", err)
+	if known && db.OnCorruption != nil {
+		db.OnCorruption(height)
+	}
+	return nil, nil
+}
+
+// corruptedHeight works out the directory block height of a corrupted block.  An entry is found
+// through the entry block that includes it.  Any other block has its height in its header,
+// which is only trusted if the block unmarshalled.
+func (db *Overlay) corruptedHeight(key interfaces.IHash, dst interfaces.DatabaseBatchable, unmarshalled bool) (uint32, bool) {
+	if _, ok := dst.(interfaces.IEBEntry); ok {
+		keyMR, err := db.FetchIncludedIn(key)
+		if err != nil || keyMR == nil {
+			return 0, false
+		}
+		eblock, err := db.FetchEBlock(keyMR)
+		if err != nil || eblock == nil {
+			return 0, false
+		}
+		return eblock.GetDatabaseHeight(), true
+	}
+	if !unmarshalled {
+		return 0, false
+	}
+	return dst.GetDatabaseHeight(), true
+}
+
+func quarantineKey(bucket []byte, key interfaces.IHash) []byte {
+	return append(append([]byte{}, bucket...), key.Bytes()...)
+}
+
+// quarantine records a corrupted block, with an empty height if it is not known
+func (db *Overlay) quarantine(bucket []byte, key interfaces.IHash, height uint32, known bool) error {
+	value := new(primitives.ByteSlice)
+	if known {
+		value = uint32ByteSlice(height)
+	}
+	return db.DB.Put(QUARANTINE, quarantineKey(bucket, key), value)
+}
+
+// IsQuarantined tells whether the block stored in the bucket under the key was found corrupted
+func (db *Overlay) IsQuarantined(bucket []byte, key interfaces.IHash) (bool, error) {
+	return db.DB.DoesKeyExist(QUARANTINE, quarantineKey(bucket, key))
+}
+
+// FetchQuarantinedHeights returns the heights of the directory blocks with corrupted blocks, in
+// no particular order
+func (db *Overlay) FetchQuarantinedHeights() ([]uint32, error) {
+	seen := map[uint32]bool{}
+	var heights []uint32
+	err := db.ForEachBlockInBucket(QUARANTINE, new(primitives.ByteSlice), func(key []byte, block interfaces.BinaryMarshallableAndCopyable) error {
+		value := block.(*primitives.ByteSlice).Bytes
+		if len(value) != 4 {
+			return nil
+		}
+		height := binary.BigEndian.Uint32(value)
+		if !seen[height] {
+			seen[height] = true
+			heights = append(heights, height)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return heights, nil
+}
+
+// ClearQuarantine drops the quarantine records of the directory block at the height
+func (db *Overlay) ClearQuarantine(dbheight uint32) error {
+	mark := uint32ByteSlice(dbheight).Bytes
+	var keys [][]byte
+	err := db.ForEachBlockInBucket(QUARANTINE, new(primitives.ByteSlice), func(key []byte, block interfaces.BinaryMarshallableAndCopyable) error {
+		if bytes.Equal(block.(*primitives.ByteSlice).Bytes, mark) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		err = db.DB.Delete(QUARANTINE, k)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RestoreDBState writes a good copy of the blocks of a directory block over the ones in the
+// database, without touching the chain heads, and clears its quarantine.  Entry blocks a
+// pruning node has deleted stay deleted.
+func (db *Overlay) RestoreDBState(dblock interfaces.IDirectoryBlock, ablock interfaces.IAdminBlock, fblock interfaces.IFBlock, ecblock interfaces.IEntryCreditBlock, eblocks []interfaces.IEntryBlock, entries []interfaces.IEBEntry) error {
+	db.BatchSemaphore.Lock()
+	defer db.BatchSemaphore.Unlock()
+
+	err := db.ProcessABlockBatchWithoutHead(ablock)
+	if err != nil {
+		return err
+	}
+	err = db.ProcessFBlockBatchWithoutHead(fblock)
+	if err != nil {
+		return err
+	}
+	err = db.ProcessECBlockBatchWithoutHead(ecblock, true)
+	if err != nil {
+		return err
+	}
+
+	skip := map[[32]byte]bool{}
+	for _, eb := range eblocks {
+		pruned, err := db.IsPruned(eb.DatabasePrimaryIndex())
+		if err != nil {
+			return err
+		}
+		if pruned {
+			for _, h := range eb.GetEntryHashes() {
+				skip[h.Fixed()] = true
+			}
+			continue
+		}
+		err = db.ProcessEBlockBatchWithoutHead(eb, true)
+		if err != nil {
+			return err
+		}
+	}
+	for _, e := range entries {
+		if skip[e.GetHash().Fixed()] {
+			continue
+		}
+		err = db.InsertEntry(e)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = db.ProcessDBlockBatchWithoutHead(dblock)
+	if err != nil {
+		return err
+	}
+	return db.ClearQuarantine(dblock.GetDatabaseHeight())
+}
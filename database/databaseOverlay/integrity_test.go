@@ -0,0 +1,152 @@
+package databaseOverlay_test
+
+import (
+	"testing"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestBlockVerification(t *testing.T) {
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	defer dbo.Close()
+
+	reported := []uint32{}
+	dbo.SetBlockVerification(1, func(dbheight uint32) {
+		reported = append(reported, dbheight)
+	})
+
+	// Every block of a healthy database passes
+	var blocks []interfaces.DatabaseBatchable
+	for h := uint32(0); h < uint32(testHelper.BlockCount); h++ {
+		dblock, err := dbo.FetchDBlockByHeight(h)
+		if err != nil || dblock == nil {
+			t.Fatalf("Missing directory block %v - %v", h, err)
+		}
+		ablock, err := dbo.FetchABlock(dblock.GetDBEntries()[0].GetKeyMR())
+		if err != nil || ablock == nil {
+			t.Fatalf("Missing admin block %v - %v", h, err)
+		}
+		ecblock, err := dbo.FetchECBlock(dblock.GetDBEntries()[1].GetKeyMR())
+		if err != nil || ecblock == nil {
+			t.Fatalf("Missing entry credit block %v - %v", h, err)
+		}
+		fblock, err := dbo.FetchFBlock(dblock.GetDBEntries()[2].GetKeyMR())
+		if err != nil || fblock == nil {
+			t.Fatalf("Missing factoid block %v - %v", h, err)
+		}
+		blocks = append(blocks, dblock, ablock, ecblock, fblock)
+		for _, v := range dblock.GetEBlockDBEntries() {
+			eblock, err := dbo.FetchEBlock(v.GetKeyMR())
+			if err != nil || eblock == nil {
+				t.Fatalf("Missing entry block %v - %v", v.GetKeyMR(), err)
+			}
+			for _, e := range eblock.GetEntryHashes() {
+				if e.IsMinuteMarker() {
+					continue
+				}
+				entry, err := dbo.FetchEntry(e)
+				if err != nil || entry == nil {
+					t.Fatalf("Missing entry %v - %v", e, err)
+				}
+			}
+		}
+	}
+	heights, err := dbo.FetchQuarantinedHeights()
+	if err != nil || len(heights) != 0 || len(reported) != 0 {
+		t.Fatalf("Healthy blocks were quarantined - %v %v %v", heights, reported, err)
+	}
+
+	// A directory block that still unmarshals but no longer hashes to its key
+	dblock, err := dbo.FetchDBlockByHeight(3)
+	if err != nil || dblock == nil {
+		t.Fatalf("Missing directory block - %v", err)
+	}
+	eblock, err := dbo.FetchEBlock(dblock.GetEBlockDBEntries()[0].GetKeyMR())
+	if err != nil || eblock == nil {
+		t.Fatalf("Missing entry block - %v", err)
+	}
+	raw, err := dblock.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	raw[len(raw)-1] ^= 0xff
+	err = dbo.Put(DIRECTORYBLOCK, dblock.DatabasePrimaryIndex().Bytes(), &primitives.ByteSlice{Bytes: raw})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	b, err := dbo.FetchDBlock(dblock.DatabasePrimaryIndex())
+	if err != nil || b != nil {
+		t.Errorf("Corrupted directory block was returned - %v", err)
+	}
+	quarantined, err := dbo.IsQuarantined(DIRECTORYBLOCK, dblock.DatabasePrimaryIndex())
+	if err != nil || !quarantined {
+		t.Errorf("Corrupted directory block was not quarantined - %v", err)
+	}
+	if len(reported) != 1 || reported[0] != 3 {
+		t.Errorf("Expected height 3 to be reported, got %v", reported)
+	}
+
+	// An entry is traced back to its directory block through its entry block
+	entryHash := eblock.GetEntryHashes()[0]
+	entry, err := dbo.FetchEntry(entryHash)
+	if err != nil || entry == nil {
+		t.Fatalf("Missing entry - %v", err)
+	}
+	err = dbo.Put(entry.GetChainID().Bytes(), entryHash.Bytes(), &primitives.ByteSlice{Bytes: []byte{0x00, 0x01}})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	e, err := dbo.FetchEntry(entryHash)
+	if err != nil || e != nil {
+		t.Errorf("Corrupted entry was returned - %v", err)
+	}
+	if len(reported) != 2 || reported[1] != eblock.GetDatabaseHeight() {
+		t.Errorf("Expected height %v to be reported, got %v", eblock.GetDatabaseHeight(), reported)
+	}
+
+	// A block with no height to go by is quarantined, but not reported
+	fblock := blocks[3*4+3]
+	err = dbo.Put(FACTOIDBLOCK, fblock.DatabasePrimaryIndex().Bytes(), &primitives.ByteSlice{Bytes: []byte{0x01}})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	f, err := dbo.FetchFBlock(fblock.DatabasePrimaryIndex())
+	if err != nil || f != nil {
+		t.Errorf("Corrupted factoid block was returned - %v", err)
+	}
+	if len(reported) != 2 {
+		t.Errorf("Expected no more heights to be reported, got %v", reported)
+	}
+
+	heights, err = dbo.FetchQuarantinedHeights()
+	if err != nil || len(heights) != 1 || heights[0] != 3 {
+		t.Errorf("Got quarantined heights %v, expected [3] - %v", heights, err)
+	}
+
+	// A good copy heals the directory block and its entry
+	err = dbo.RestoreDBState(dblock, blocks[3*4+1].(interfaces.IAdminBlock), fblock.(interfaces.IFBlock),
+		blocks[3*4+2].(interfaces.IEntryCreditBlock), []interfaces.IEntryBlock{eblock}, []interfaces.IEBEntry{entry})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	b, err = dbo.FetchDBlock(dblock.DatabasePrimaryIndex())
+	if err != nil || b == nil {
+		t.Errorf("Directory block was not restored - %v", err)
+	}
+	e, err = dbo.FetchEntry(entryHash)
+	if err != nil || e == nil {
+		t.Errorf("Entry was not restored - %v", err)
+	}
+	heights, err = dbo.FetchQuarantinedHeights()
+	if err != nil || len(heights) != 0 {
+		t.Errorf("Quarantine was not cleared - %v %v", heights, err)
+	}
+	head, err := dbo.FetchDBlockHead()
+	if err != nil || head.GetDatabaseHeight() != uint32(testHelper.BlockCount-1) {
+		t.Errorf("Restoring moved the directory block head - %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package databaseOverlay
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/FactomProject/factomd/anchor"
+	"github.com/FactomProject/factomd/common/entryBlock"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/util"
+)
+
+// The schema version of a database is the number of migrations that have been run on it.  A
+// migration changes the bucket layout of databases written before it, such as adding an index
+// over the blocks already saved.  Migrations run in order at boot, and save their progress as
+// they go, so a node stopped part way through carries on where it left off.
+
+// SchemaVersionKey holds the schema version of the database
+var SchemaVersionKey = []byte("SchemaVersion")
+
+// MigrationProgressKey holds how far the migration being run has got
+var MigrationProgressKey = []byte("MigrationProgress")
+
+// Migration brings the database from the schema version before it to Version.  Run is given the
+// progress it last saved, and calls save as it goes with the progress to carry on from.  Every
+// step has to be safe to repeat, as the last one may have run without its progress being saved.
+type Migration struct {
+	Version uint32
+	Name    string
+	Run     func(db *Overlay, progress uint32, save func(progress uint32) error) error
+}
+
+// Migrations lists every migration in order.  New ones go at the end, with the next version.
+var Migrations = []Migration{
+	{Version: 1, Name: "Index entry blocks by chain sequence", Run: migrateChainSequence},
+	{Version: 2, Name: "Index entries included in more than one entry block", Run: migrateIncludedAgain},
+	{Version: 3, Name: "Index anchor records by directory block height", Run: migrateAnchorEntries},
+}
+
+// LatestSchemaVersion is the schema version of a database with every migration run
+func LatestSchemaVersion() uint32 {
+	return Migrations[len(Migrations)-1].Version
+}
+
+func (db *Overlay) FetchSchemaVersion() (uint32, error) {
+	return db.fetchUint32(KEY_VALUE_STORE, SchemaVersionKey)
+}
+
+func (db *Overlay) SaveSchemaVersion(version uint32) error {
+	return db.Put(KEY_VALUE_STORE, SchemaVersionKey, uint32ByteSlice(version))
+}
+
+// PendingMigrations returns the migrations the database still needs, in the order to run them
+func (db *Overlay) PendingMigrations() ([]Migration, error) {
+	version, err := db.FetchSchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version > LatestSchemaVersion() {
+		return nil, fmt.Errorf("Database schema version %d is newer than this factomd supports (%d)", version, LatestSchemaVersion())
+	}
+
+	var pending []Migration
+	for _, m := range Migrations {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate runs the migrations the database still needs, and returns how many it ran.  A new
+// database is already laid out the latest way, so it is only marked with the latest version.
+// Progress is printed every printFreq steps of a migration, or not at all if printFreq is 0.
+func (db *Overlay) Migrate(printFreq uint32) (int, error) {
+	pending, err := db.PendingMigrations()
+	if err != nil || len(pending) == 0 {
+		return 0, err
+	}
+
+	head, err := db.FetchDBlockHead()
+	if err != nil {
+		return 0, err
+	}
+	if head == nil {
+		return 0, db.SaveSchemaVersion(LatestSchemaVersion())
+	}
+
+	for i, m := range pending {
+		progress, err := db.fetchUint32(KEY_VALUE_STORE, MigrationProgressKey)
+		if err != nil {
+			return i, err
+		}
+		if progress > 0 {
+			fmt.Printf("Migration %d (%s): carrying on from step %d\n", m.Version, m.Name, progress)
+		} else {
+			fmt.Printf("Migration %d (%s): starting\n", m.Version, m.Name)
+		}
+
+		save := func(p uint32) error {
+			if printFreq > 0 && p%printFreq == 0 {
+				fmt.Printf("Migration %d (%s): step %d\n", m.Version, m.Name, p)
+			}
+			return db.Put(KEY_VALUE_STORE, MigrationProgressKey, uint32ByteSlice(p))
+		}
+		err = m.Run(db, progress, save)
+		if err != nil {
+			return i, fmt.Errorf("Migration %d (%s) failed: %v", m.Version, m.Name, err)
+		}
+
+		// The version and the cleared progress go in together, so the next migration never
+		// starts from this one's progress
+		batch := []interfaces.Record{
+			{Bucket: KEY_VALUE_STORE, Key: SchemaVersionKey, Data: uint32ByteSlice(m.Version)},
+			{Bucket: KEY_VALUE_STORE, Key: MigrationProgressKey, Data: uint32ByteSlice(0)},
+		}
+		err = db.PutInBatch(batch)
+		if err != nil {
+			return i, err
+		}
+		fmt.Printf("Migration %d (%s): done\n", m.Version, m.Name)
+	}
+	return len(pending), nil
+}
+
+// migrateChainSequence adds the entry blocks of every chain to the sequence index.  The chains
+// are taken in the order of their IDs, and the progress is the number of chains done.
+func migrateChainSequence(db *Overlay, progress uint32, save func(progress uint32) error) error {
+	chainIDs, err := db.DB.ListAllKeys(CHAIN_HEAD)
+	if err != nil {
+		return err
+	}
+	sort.Sort(util.ByByteArray(chainIDs))
+
+	for i := progress; i < uint32(len(chainIDs)); i++ {
+		chainID, err := primitives.NewShaHash(chainIDs[i])
+		if err != nil {
+			return err
+		}
+		err = db.RebuildEBlockSequenceIndex(chainID)
+		if err != nil {
+			return err
+		}
+		err = save(i + 1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// migrateIncludedAgain lists the entries included in more than one entry block of their chain,
+// which were only recorded against the first.  It walks every chain by the sequence index, in
+// the order of the chain IDs, and the progress is the number of chains done.
+func migrateIncludedAgain(db *Overlay, progress uint32, save func(progress uint32) error) error {
+	chainIDs, err := db.DB.ListAllKeys(CHAIN_HEAD)
+	if err != nil {
+		return err
+	}
+	sort.Sort(util.ByByteArray(chainIDs))
+
+	for i := progress; i < uint32(len(chainIDs)); i++ {
+		err = db.indexIncludedAgain(chainIDs[i])
+		if err != nil {
+			return err
+		}
+		err = save(i + 1)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (db *Overlay) indexIncludedAgain(chainID []byte) error {
+	bucket := append(append([]byte{}, ENTRYBLOCK_CHAIN_SEQUENCE...), chainID...)
+	it, err := db.DB.NewIterator(bucket, nil)
+	if err != nil {
+		return err
+	}
+	var keyMRs []interfaces.IHash
+	for it.Next() {
+		keyMR, err := primitives.NewShaHash(it.Value())
+		if err != nil {
+			it.Release()
+			return err
+		}
+		keyMRs = append(keyMRs, keyMR)
+	}
+	err = it.Error()
+	it.Release()
+	if err != nil {
+		return err
+	}
+
+	batch := []interfaces.Record{}
+	for _, keyMR := range keyMRs {
+		eblock, err := db.FetchEBlockByPrimary(keyMR)
+		if err != nil {
+			return err
+		}
+		if eblock == nil {
+			continue
+		}
+		for _, h := range eblock.GetEntryHashes() {
+			if h.IsMinuteMarker() {
+				continue
+			}
+			first, err := db.FetchIncludedIn(h)
+			if err != nil {
+				return err
+			}
+			if first != nil {
+				batch = append(batch, includedAgainRecords(h, first, keyMR)...)
+			}
+		}
+	}
+	return db.PutInBatch(batch)
+}
+
+// migrateAnchorEntries adds the signed Bitcoin anchor records already in the anchor chain to the
+// anchor entry index.  The entries are taken in the order of their hashes, and the progress is
+// the number done, saved every thousand.
+func migrateAnchorEntries(db *Overlay, progress uint32, save func(progress uint32) error) error {
+	chainID, err := primitives.NewShaHashFromStr(AnchorBlockID)
+	if err != nil {
+		return err
+	}
+	it, err := db.DB.NewIterator(chainID.Bytes(), nil)
+	if err != nil {
+		return err
+	}
+	defer it.Release()
+
+	batch := []interfaces.Record{}
+	var done uint32
+	for it.Next() {
+		done++
+		if done <= progress {
+			continue
+		}
+		entry := new(entryBlock.Entry)
+		err = entry.UnmarshalBinary(it.Value())
+		if err != nil {
+			return err
+		}
+		if entry.DatabasePrimaryIndex().String() == "24674e6bc3094eb773297de955ee095a05830e431da13a37382dcdc89d73c7d7" {
+			continue // skipped when anchor entries are saved too
+		}
+		ar, ok, err := anchor.UnmarshalAndValidateAnchorEntryAnyVersion(entry, AnchorSigPublicKeys)
+		if err == nil && ok && ar != nil && ar.Bitcoin != nil {
+			batch = append(batch, anchorEntryRecord(entry, ar))
+		}
+		if done%1000 == 0 {
+			err = db.PutInBatch(batch)
+			if err != nil {
+				return err
+			}
+			batch = batch[:0]
+			err = save(done)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = it.Error()
+	if err != nil {
+		return err
+	}
+	return db.PutInBatch(batch)
+}
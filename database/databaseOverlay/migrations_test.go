@@ -0,0 +1,171 @@
+package databaseOverlay_test
+
+import (
+	"testing"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestMigrate(t *testing.T) {
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	defer dbo.Close()
+
+	// Make the database look like one written before the sequence index
+	chainIDs, err := dbo.DB.ListAllKeys(CHAIN_HEAD)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chainIDs) == 0 {
+		t.Fatal("No chains in the test database")
+	}
+	for _, id := range chainIDs {
+		err = dbo.Clear(append(ENTRYBLOCK_CHAIN_SEQUENCE, id...))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	anchored := countAnchored(t, dbo)
+	if anchored == 0 {
+		t.Fatal("No anchor records in the test database")
+	}
+	err = dbo.Clear(ANCHOR_ENTRY)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dbo.SaveSchemaVersion(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := dbo.Migrate(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(Migrations) {
+		t.Errorf("Ran %v migrations, expected %v", n, len(Migrations))
+	}
+	version, err := dbo.FetchSchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("Schema version is %v, expected %v", version, LatestSchemaVersion())
+	}
+	indexed := 0
+	for _, id := range chainIDs {
+		chainID, err := primitives.NewShaHash(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		head, err := dbo.FetchEBlockHead(chainID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if head == nil {
+			// One of the chains of the other blocks
+			continue
+		}
+		keyMR, err := dbo.FetchEBlockKeyMRBySequence(chainID, head.GetHeader().GetEBSequence())
+		if err != nil {
+			t.Error(err)
+		}
+		if keyMR == nil || !keyMR.IsSameAs(head.DatabasePrimaryIndex()) {
+			t.Errorf("Chain %x was not indexed", id)
+		}
+		indexed++
+	}
+	if indexed == 0 {
+		t.Error("No entry chains were checked")
+	}
+	if n := countAnchored(t, dbo); n != anchored {
+		t.Errorf("%v directory blocks have an anchor record rather than %v", n, anchored)
+	}
+
+	// Nothing is left to run
+	n, err = dbo.Migrate(0)
+	if err != nil {
+		t.Error(err)
+	}
+	if n != 0 {
+		t.Errorf("Ran %v migrations again", n)
+	}
+
+	// A database from a newer factomd is refused
+	err = dbo.SaveSchemaVersion(LatestSchemaVersion() + 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dbo.Migrate(0)
+	if err == nil {
+		t.Error("Migrated a database with a newer schema version")
+	}
+}
+
+func countAnchored(t *testing.T, dbo *Overlay) int {
+	head, err := dbo.FetchDBlockHead()
+	if err != nil || head == nil {
+		t.Fatalf("No directory blocks in the test database - %v", err)
+	}
+	anchored := 0
+	for h := uint32(0); h <= head.GetDatabaseHeight(); h++ {
+		entry, err := dbo.FetchAnchorEntryByHeight(h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if entry != nil {
+			anchored++
+		}
+	}
+	return anchored
+}
+
+func TestMigrateResumes(t *testing.T) {
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	defer dbo.Close()
+
+	err := dbo.SaveSchemaVersion(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dbo.Put(KEY_VALUE_STORE, MigrationProgressKey, &primitives.ByteSlice{Bytes: []byte{0, 0, 0, 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := dbo.Migrate(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(Migrations) {
+		t.Errorf("Ran %v migrations, expected %v", n, len(Migrations))
+	}
+	pending, err := dbo.PendingMigrations()
+	if err != nil {
+		t.Error(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("%v migrations still pending", len(pending))
+	}
+}
+
+func TestMigrateEmptyDatabase(t *testing.T) {
+	dbo := testHelper.CreateEmptyTestDatabaseOverlay()
+	defer dbo.Close()
+
+	n, err := dbo.Migrate(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 0 {
+		t.Errorf("Ran %v migrations on an empty database", n)
+	}
+	version, err := dbo.FetchSchemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != LatestSchemaVersion() {
+		t.Errorf("Schema version is %v, expected %v", version, LatestSchemaVersion())
+	}
+}
@@ -18,8 +18,13 @@ import (
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/primitives"
 	"github.com/FactomProject/factomd/database/blockExtractor"
+
+	log "github.com/sirupsen/logrus"
 )
 
+// packageLogger is the general logger for all database overlay related logs
+var packageLogger = log.WithFields(log.Fields{"package": "databaseOverlay"})
+
 // the "table" prefix
 var (
 	// Directory Block
@@ -52,6 +57,7 @@ var (
 	ENTRYBLOCK                = []byte("EntryBlock")
 	ENTRYBLOCK_CHAIN_NUMBER   = []byte("EntryBlockNumber")
 	ENTRYBLOCK_SECONDARYINDEX = []byte("EntryBlockSecondaryIndex")
+	ENTRYBLOCK_CHAIN_SEQUENCE = []byte("EntryBlockChainSequence")
 
 	//Entry
 	ENTRY = []byte("Entry")
@@ -62,12 +68,26 @@ var (
 	DIRBLOCKINFO_NUMBER         = []byte("DirBlockInfoNumber")
 	DIRBLOCKINFO_SECONDARYINDEX = []byte("DirBlockInfoSecondaryIndex")
 
+	//Signed Bitcoin anchor record entries, by the height of the directory block they anchor
+	ANCHOR_ENTRY = []byte("AnchorEntry")
+
 	//IncludedIn
-	INCLUDED_IN = []byte("IncludedIn")
+	INCLUDED_IN    = []byte("IncludedIn")
+	INCLUDED_AGAIN = []byte("IncludedAgain")
 
 	//Which EC transaction paid for this Entry
 	PAID_FOR = []byte("PaidFor")
 
+	//Factoid transactions by address, only kept when the address index is enabled
+	ADDRESS_TRANSACTIONS        = []byte("AddressTransactions")
+	ADDRESS_TRANSACTIONS_NUMBER = []byte("AddressTransactionsNumber")
+
+	//Entry blocks whose payload was deleted by a pruning node, with their directory block height
+	PRUNED_ENTRYBLOCK = []byte("PrunedEntryBlock")
+
+	//Blocks found corrupted on read, with their directory block height
+	QUARANTINE = []byte("Quarantine")
+
 	KEY_VALUE_STORE = []byte("KeyValueStore")
 )
 
@@ -96,6 +116,7 @@ func init() {
 	ConstantNamesMap[string(ENTRYBLOCK)] = "EntryBlock"
 	ConstantNamesMap[string(ENTRYBLOCK_CHAIN_NUMBER)] = "EntryBlockChainNumber"
 	ConstantNamesMap[string(ENTRYBLOCK_SECONDARYINDEX)] = "EntryBlockSecondaryIndex"
+	ConstantNamesMap[string(ENTRYBLOCK_CHAIN_SEQUENCE)] = "EntryBlockChainSequence"
 
 	ConstantNamesMap[string(ENTRY)] = "Entry"
 
@@ -103,10 +124,16 @@ func init() {
 	ConstantNamesMap[string(DIRBLOCKINFO_UNCONFIRMED)] = "DirBlockInfoUnconfirmed"
 	ConstantNamesMap[string(DIRBLOCKINFO_NUMBER)] = "DirBlockInfoNumber"
 	ConstantNamesMap[string(DIRBLOCKINFO_SECONDARYINDEX)] = "DirBlockInfoSecondaryIndex"
+	ConstantNamesMap[string(ANCHOR_ENTRY)] = "AnchorEntry"
 
 	ConstantNamesMap[string(INCLUDED_IN)] = "IncludedIn"
+	ConstantNamesMap[string(INCLUDED_AGAIN)] = "IncludedAgain"
 
 	ConstantNamesMap[string(PAID_FOR)] = "PaidFor"
+	ConstantNamesMap[string(ADDRESS_TRANSACTIONS)] = "AddressTransactions"
+	ConstantNamesMap[string(ADDRESS_TRANSACTIONS_NUMBER)] = "AddressTransactionsNumber"
+	ConstantNamesMap[string(PRUNED_ENTRYBLOCK)] = "PrunedEntryBlock"
+	ConstantNamesMap[string(QUARANTINE)] = "Quarantine"
 	ConstantNamesMap[string(KEY_VALUE_STORE)] = "KeyValueStore"
 
 	RegisterPrometheus()
@@ -118,9 +145,27 @@ type Overlay struct {
 	ExportData     bool
 	ExportDataPath string
 
+	// Maintain the index of factoid transactions by address as factoid blocks are saved
+	AddressIndex bool
+	// Held while address index records are built, so the backfill and saved blocks take turns
+	addressIndexMutex sync.Mutex
+	// One past the highest factoid block left to the backfill as it was saved
+	addressIndexSkipped uint32
+	// Set while a backfill of the address index runs in the background
+	addressIndexBackfilling bool
+
 	BatchSemaphore sync.Mutex
 	MultiBatch     []interfaces.Record
 	BlockExtractor blockExtractor.BlockExtractor
+
+	// Check the hash of one in every VerifySampleRate blocks read, 0 checks none
+	VerifySampleRate uint32
+	verifyCount      uint32
+	// Told the directory block height of every corrupted block found
+	OnCorruption func(dbheight uint32)
+
+	// The blocks and entries read most recently, nil if none are kept
+	cache *blockCache
 }
 
 var _ interfaces.IDatabase = (*Overlay)(nil)
@@ -130,6 +175,26 @@ func (db *Overlay) ListAllBuckets() ([][]byte, error) {
 	return db.DB.ListAllBuckets()
 }
 
+// FetchAllBuckets lists every bucket the overlay writes to: the named ones, and for each chain
+// the bucket of its entries and those of its entry blocks by number and by sequence.  Unlike
+// ListAllBuckets it works on every kind of database.
+func (db *Overlay) FetchAllBuckets() ([][]byte, error) {
+	var buckets [][]byte
+	for k := range ConstantNamesMap {
+		buckets = append(buckets, []byte(k))
+	}
+	chainIDs, err := db.DB.ListAllKeys(CHAIN_HEAD)
+	if err != nil {
+		return nil, err
+	}
+	for _, chainID := range chainIDs {
+		buckets = append(buckets, chainID)
+		buckets = append(buckets, append(append([]byte{}, ENTRYBLOCK_CHAIN_NUMBER...), chainID...))
+		buckets = append(buckets, append(append([]byte{}, ENTRYBLOCK_CHAIN_SEQUENCE...), chainID...))
+	}
+	return buckets, nil
+}
+
 func (db *Overlay) SetExportData(path string) {
 	db.ExportData = true
 	db.ExportDataPath = path
@@ -154,10 +219,18 @@ func (db *Overlay) ExecuteMultiBatch() error {
 }
 
 func (db *Overlay) PutInBatch(records []interfaces.Record) error {
+	if db.cache != nil {
+		for _, r := range records {
+			db.cache.remove(r.Bucket, r.Key)
+		}
+	}
 	return db.DB.PutInBatch(records)
 }
 
 func (db *Overlay) Put(bucket, key []byte, data interfaces.BinaryMarshallable) error {
+	if db.cache != nil {
+		db.cache.remove(bucket, key)
+	}
 	return db.DB.Put(bucket, key, data)
 }
 
@@ -175,6 +248,9 @@ func (db *Overlay) Get(bucket, key []byte, destination interfaces.BinaryMarshall
 }
 
 func (db *Overlay) Clear(bucket []byte) error {
+	if db.cache != nil {
+		db.cache.removeBucket(bucket)
+	}
 	return db.DB.Clear(bucket)
 }
 
@@ -190,6 +266,9 @@ func (db *Overlay) Trim() {
 }
 
 func (db *Overlay) Delete(bucket, key []byte) error {
+	if db.cache != nil {
+		db.cache.remove(bucket, key)
+	}
 	return db.DB.Delete(bucket, key)
 }
 
@@ -247,6 +326,26 @@ func (db *Overlay) FetchBlockBySecondaryIndex(secondaryIndexBucket, blockBucket
 }
 
 func (db *Overlay) FetchBlock(bucket []byte, key interfaces.IHash, dst interfaces.DatabaseBatchable) (interfaces.DatabaseBatchable, error) {
+	// Only the blocks stored under their own hash are cached, the same ones that can be verified
+	cache := db.cache
+	if cache == nil || !verifiable(dst) {
+		return db.fetchBlock(bucket, key, dst)
+	}
+	if block := cache.get(bucket, key.Bytes(), dst); block != nil {
+		return block, nil
+	}
+	block, err := db.fetchBlock(bucket, key, dst)
+	if err != nil || block == nil {
+		return nil, err
+	}
+	cache.add(bucket, key.Bytes(), block)
+	return block, nil
+}
+
+func (db *Overlay) fetchBlock(bucket []byte, key interfaces.IHash, dst interfaces.DatabaseBatchable) (interfaces.DatabaseBatchable, error) {
+	if verifiable(dst) && db.sampleBlock() {
+		return db.fetchVerifiedBlock(bucket, key, dst)
+	}
 	block, err := db.Get(bucket, key.Bytes(), dst)
 	if err != nil {
 		return nil, err
@@ -257,8 +356,39 @@ func (db *Overlay) FetchBlock(bucket []byte, key interfaces.IHash, dst interface
 	return block.(interfaces.DatabaseBatchable), nil
 }
 
+func (db *Overlay) NewIterator(bucket []byte, options *interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	return db.DB.NewIterator(bucket, options)
+}
+
+// ForEachBlockInBucket unmarshals the records of a bucket one at a time, in key order, and
+// hands each of them to f.  It stops at the first error, from the database or from f.
+func (db *Overlay) ForEachBlockInBucket(bucket []byte, sample interfaces.BinaryMarshallableAndCopyable, f func(key []byte, block interfaces.BinaryMarshallableAndCopyable) error) error {
+	iter, err := db.NewIterator(bucket, nil)
+	if err != nil {
+		return err
+	}
+	defer iter.Release()
+
+	for iter.Next() {
+		tmp := sample.New()
+		err = tmp.UnmarshalBinary(iter.Value())
+		if err != nil {
+			return err
+		}
+		err = f(iter.Key(), tmp)
+		if err != nil {
+			return err
+		}
+	}
+	return iter.Error()
+}
+
 func (db *Overlay) FetchAllBlocksFromBucket(bucket []byte, sample interfaces.BinaryMarshallableAndCopyable) ([]interfaces.BinaryMarshallableAndCopyable, error) {
-	answer, _, err := db.GetAll(bucket, sample)
+	answer := []interfaces.BinaryMarshallableAndCopyable{}
+	err := db.ForEachBlockInBucket(bucket, sample, func(key []byte, block interfaces.BinaryMarshallableAndCopyable) error {
+		answer = append(answer, block)
+		return nil
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -38,6 +38,28 @@ func TestListAllBuckets(t *testing.T) {
 }
 */
 
+func TestFetchAllBuckets(t *testing.T) {
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	fetched, err := dbo.FetchAllBuckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := map[string]bool{}
+	for _, b := range fetched {
+		listed[string(b)] = true
+	}
+
+	buckets, err := dbo.ListAllBuckets()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range buckets {
+		if !listed[string(b)] {
+			t.Errorf("Bucket %x was not fetched", b)
+		}
+	}
+}
+
 func TestGetEntryType(t *testing.T) {
 	blocks := testHelper.CreateFullTestBlockSet()
 	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
@@ -0,0 +1,102 @@
+package databaseOverlay
+
+import (
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// A pruning node deletes the entry blocks and entries of old directory blocks, but keeps every
+// index pointing at them.  Pruned entry blocks are listed in PRUNED_ENTRYBLOCK, and a pruned
+// entry still has its ENTRY index, so the node can tell data it deleted from data it never had.
+
+// PrunedHeightKey holds the height of the next directory block to prune
+var PrunedHeightKey = []byte("PrunedHeight")
+
+// FetchPrunedHeight returns the height of the next directory block to prune, so every directory
+// block below it has been pruned
+func (db *Overlay) FetchPrunedHeight() (uint32, error) {
+	return db.fetchUint32(KEY_VALUE_STORE, PrunedHeightKey)
+}
+
+func (db *Overlay) SavePrunedHeight(height uint32) error {
+	return db.Put(KEY_VALUE_STORE, PrunedHeightKey, uint32ByteSlice(height))
+}
+
+// PruneEBlock deletes an entry block and the entries in it, and reports whether there was
+// anything to delete.  An entry also included in an entry block that has not been pruned is
+// kept.  The entry block is marked as pruned before anything is deleted, so a prune that is cut
+// short is finished the next time.
+func (db *Overlay) PruneEBlock(keyMR interfaces.IHash) (bool, error) {
+	eblock, err := db.FetchEBlockByPrimary(keyMR)
+	if err != nil {
+		return false, err
+	}
+	if eblock == nil {
+		return false, nil
+	}
+
+	err = db.Put(PRUNED_ENTRYBLOCK, keyMR.Bytes(), uint32ByteSlice(eblock.GetDatabaseHeight()))
+	if err != nil {
+		return false, err
+	}
+
+	chainID := eblock.GetChainID().Bytes()
+	for _, h := range eblock.GetEntryHashes() {
+		if h.IsMinuteMarker() {
+			continue
+		}
+		kept, err := db.isIncludedElsewhere(h, keyMR)
+		if err != nil {
+			return false, err
+		}
+		if kept {
+			continue
+		}
+		err = db.Delete(chainID, h.Bytes())
+		if err != nil {
+			return false, err
+		}
+	}
+
+	err = db.Delete(ENTRYBLOCK, keyMR.Bytes())
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// isIncludedElsewhere tells whether the entry is also included in an entry block other than
+// keyMR that is still in the database
+func (db *Overlay) isIncludedElsewhere(entry interfaces.IHash, keyMR interfaces.IHash) (bool, error) {
+	blocks, err := db.FetchAllIncludedIn(entry)
+	if err != nil {
+		return false, err
+	}
+	for _, block := range blocks {
+		if block.IsSameAs(keyMR) {
+			continue
+		}
+		exists, err := db.DoesKeyExist(ENTRYBLOCK, block.Bytes())
+		if err != nil || exists {
+			return exists, err
+		}
+	}
+	return false, nil
+}
+
+// IsPruned tells whether the entry block or entry with the hash was deleted by pruning
+func (db *Overlay) IsPruned(hash interfaces.IHash) (bool, error) {
+	pruned, err := db.DoesKeyExist(PRUNED_ENTRYBLOCK, hash.Bytes())
+	if err != nil || pruned {
+		return pruned, err
+	}
+
+	chainID, err := db.FetchPrimaryIndexBySecondaryIndex(ENTRY, hash)
+	if err != nil || chainID == nil {
+		return false, err
+	}
+	exists, err := db.DoesKeyExist(chainID.Bytes(), hash.Bytes())
+	if err != nil {
+		return false, err
+	}
+	return !exists, nil
+}
@@ -0,0 +1,150 @@
+package databaseOverlay_test
+
+import (
+	"testing"
+
+	"github.com/FactomProject/factomd/common/entryBlock"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestPruneEBlock(t *testing.T) {
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	defer dbo.Close()
+
+	height, err := dbo.FetchPrunedHeight()
+	if err != nil || height != 0 {
+		t.Errorf("Expected no pruned height - %v %v", height, err)
+	}
+	err = dbo.SavePrunedHeight(5)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	height, err = dbo.FetchPrunedHeight()
+	if err != nil || height != 5 {
+		t.Errorf("Got pruned height %v, expected 5 - %v", height, err)
+	}
+
+	dblock, err := dbo.FetchDBlockByHeight(3)
+	if err != nil || dblock == nil {
+		t.Fatalf("Missing directory block - %v", err)
+	}
+	keyMR := dblock.GetEBlockDBEntries()[0].GetKeyMR()
+	eblock, err := dbo.FetchEBlock(keyMR)
+	if err != nil || eblock == nil {
+		t.Fatalf("Missing entry block - %v", err)
+	}
+
+	pruned, err := dbo.PruneEBlock(keyMR)
+	if err != nil || !pruned {
+		t.Fatalf("Entry block was not pruned - %v", err)
+	}
+	pruned, err = dbo.PruneEBlock(keyMR)
+	if err != nil || pruned {
+		t.Errorf("Entry block was pruned twice - %v", err)
+	}
+
+	b, err := dbo.FetchEBlock(keyMR)
+	if err != nil || b != nil {
+		t.Errorf("Entry block is still there - %v", err)
+	}
+	pruned, err = dbo.IsPruned(keyMR)
+	if err != nil || !pruned {
+		t.Errorf("Entry block is not marked as pruned - %v", err)
+	}
+
+	for _, h := range eblock.GetEntryHashes() {
+		if h.IsMinuteMarker() {
+			continue
+		}
+		e, err := dbo.FetchEntry(h)
+		if err != nil || e != nil {
+			t.Errorf("Entry %v is still there - %v", h, err)
+		}
+		pruned, err = dbo.IsPruned(h)
+		if err != nil || !pruned {
+			t.Errorf("Entry %v is not marked as pruned - %v", h, err)
+		}
+	}
+
+	// Data that is still there, or was never there, is not pruned
+	other, err := dbo.FetchEBlock(dblock.GetEBlockDBEntries()[1].GetKeyMR())
+	if err != nil || other == nil {
+		t.Fatalf("Missing entry block - %v", err)
+	}
+	for _, h := range []interfaces.IHash{other.DatabasePrimaryIndex(), other.GetEntryHashes()[0], primitives.RandomHash()} {
+		pruned, err = dbo.IsPruned(h)
+		if err != nil || pruned {
+			t.Errorf("%v should not be pruned - %v", h, err)
+		}
+	}
+}
+
+func TestPruneEBlockKeepsEntriesIncludedAgain(t *testing.T) {
+	dbo := testHelper.CreateAndPopulateTestDatabaseOverlay()
+	defer dbo.Close()
+
+	dblock, err := dbo.FetchDBlockByHeight(3)
+	if err != nil || dblock == nil {
+		t.Fatalf("Missing directory block - %v", err)
+	}
+	old, err := dbo.FetchEBlock(dblock.GetEBlockDBEntries()[0].GetKeyMR())
+	if err != nil || old == nil {
+		t.Fatalf("Missing entry block - %v", err)
+	}
+	entry := old.GetEntryHashes()[0]
+
+	// A later entry block of the chain includes the entry again
+	again := entryBlock.NewEBlock()
+	again.GetHeader().SetChainID(old.GetChainID())
+	again.GetHeader().SetEBSequence(old.GetHeader().GetEBSequence() + 1000)
+	again.GetBody().AddEBEntry(entry)
+	err = again.BuildHeader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dbo.ProcessEBlockBatchWithoutHead(again, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err := dbo.FetchAllIncludedIn(entry)
+	if err != nil || len(blocks) != 2 {
+		t.Errorf("Entry is included in %v blocks rather than 2 - %v", len(blocks), err)
+	}
+
+	// Databases written before the index was kept get it from the migration
+	err = dbo.Clear(INCLUDED_AGAIN)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = dbo.SaveSchemaVersion(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = dbo.Migrate(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blocks, err = dbo.FetchAllIncludedIn(entry)
+	if err != nil || len(blocks) != 2 {
+		t.Errorf("Migration found the entry in %v blocks rather than 2 - %v", len(blocks), err)
+	}
+
+	_, err = dbo.PruneEBlock(old.DatabasePrimaryIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, err := dbo.FetchEntry(entry); err != nil || e == nil {
+		t.Errorf("Entry still included in a later entry block was pruned - %v", err)
+	}
+
+	_, err = dbo.PruneEBlock(again.DatabasePrimaryIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e, err := dbo.FetchEntry(entry); err != nil || e != nil {
+		t.Errorf("Entry is still there once every entry block including it was pruned - %v", err)
+	}
+}
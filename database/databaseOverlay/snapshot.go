@@ -0,0 +1,46 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package databaseOverlay
+
+import (
+	"fmt"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// Snapshot copies the database into a new one at path while it stays in use.  Multi batches,
+// which save the blocks, are held off while the snapshot is taken, so the copy ends on the whole
+// set of blocks of a directory block, and the height of that block is returned.  Entries are
+// written apart from their blocks, so the copy may lack some of those of the last blocks.
+func (db *Overlay) Snapshot(path string) (uint32, error) {
+	snapshotter, ok := db.DB.(interfaces.ISnapshotter)
+	if !ok {
+		return 0, fmt.Errorf("Database does not support snapshots")
+	}
+
+	db.BatchSemaphore.Lock()
+	snap, err := snapshotter.Snapshot()
+	if err != nil {
+		db.BatchSemaphore.Unlock()
+		return 0, err
+	}
+	head, err := db.FetchDBlockHead()
+	db.BatchSemaphore.Unlock()
+	defer snap.Release()
+	if err != nil {
+		return 0, err
+	}
+
+	var height uint32
+	if head != nil {
+		height = head.GetDatabaseHeight()
+	}
+
+	err = snap.WriteTo(path)
+	if err != nil {
+		return 0, err
+	}
+	return height, nil
+}
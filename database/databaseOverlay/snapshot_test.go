@@ -0,0 +1,123 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package databaseOverlay_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/badgerdb"
+	"github.com/FactomProject/factomd/database/boltdb"
+	. "github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/leveldb"
+	"github.com/FactomProject/factomd/database/mapdb"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	open := map[string]func(path string) (interfaces.IDatabase, error){
+		"level": func(path string) (interfaces.IDatabase, error) {
+			return leveldb.NewLevelDB(path, true)
+		},
+		"bolt": func(path string) (interfaces.IDatabase, error) {
+			return boltdb.NewBoltDB(nil, path), nil
+		},
+		"badger": func(path string) (interfaces.IDatabase, error) {
+			return badgerdb.NewBadgerDB(path, true)
+		},
+	}
+
+	for name, f := range open {
+		db, err := f(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		dbo := NewOverlay(db)
+		testHelper.PopulateTestDatabaseOverlay(dbo)
+
+		copyPath := filepath.Join(dir, name+"-copy")
+		height, err := dbo.Snapshot(copyPath)
+		if err != nil {
+			t.Fatalf("%v - %v", name, err)
+		}
+		head, err := dbo.FetchDBlockHead()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if height != head.GetDatabaseHeight() {
+			t.Errorf("%v - snapshot at height %v, expected %v", name, height, head.GetDatabaseHeight())
+		}
+		if _, err := dbo.Snapshot(copyPath); err == nil {
+			t.Errorf("%v - snapshot written over an existing database", name)
+		}
+
+		// Records written after the snapshot is taken are not in it.  Bolt holds off writes that
+		// grow its file until the snapshot is released, so the write can't wait on the copy.
+		snap, err := db.(interfaces.ISnapshotter).Snapshot()
+		if err != nil {
+			t.Fatal(err)
+		}
+		later := primitives.RandomHash()
+		written := make(chan error)
+		go func() {
+			written <- dbo.Put([]byte("later"), later.Bytes(), later)
+		}()
+		laterPath := filepath.Join(dir, name+"-later")
+		err = snap.WriteTo(laterPath)
+		snap.Release()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err = <-written; err != nil {
+			t.Fatal(err)
+		}
+		dbo.Close()
+
+		for _, path := range []string{copyPath, laterPath} {
+			c, err := f(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cdbo := NewOverlay(c)
+
+			cHead, err := cdbo.FetchDBlockHead()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cHead == nil || !cHead.GetKeyMR().IsSameAs(head.GetKeyMR()) {
+				t.Errorf("%v - wrong DBlock head in the snapshot %v", name, path)
+			}
+			keys, err := cdbo.FetchAllDBlockKeys()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != testHelper.BlockCount {
+				t.Errorf("%v - found %v DBlocks in the snapshot, expected %v", name, len(keys), testHelper.BlockCount)
+			}
+			found, err := cdbo.Get([]byte("later"), later.Bytes(), primitives.NewZeroHash())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if found != nil {
+				t.Errorf("%v - found a record written after the snapshot in %v", name, path)
+			}
+			cdbo.Close()
+		}
+	}
+
+	if _, err := NewOverlay(new(mapdb.MapDB)).Snapshot(filepath.Join(dir, "map")); err == nil {
+		t.Errorf("Took a snapshot of a map database")
+	}
+}
@@ -1,10 +1,12 @@
 package hybridDB
 
 import (
+	"fmt"
 	"sync"
 
 	"github.com/FactomProject/factomd/common/interfaces"
 
+	"github.com/FactomProject/factomd/database/badgerdb"
 	"github.com/FactomProject/factomd/database/boltdb"
 	"github.com/FactomProject/factomd/database/leveldb"
 	"github.com/FactomProject/factomd/database/mapdb"
@@ -61,6 +63,22 @@ func NewLevelMapHybridDB(filename string, create bool) (*HybridDB, error) {
 	return answer, nil
 }
 
+func NewBadgerMapHybridDB(filename string, create bool) (*HybridDB, error) {
+	answer := new(HybridDB)
+
+	m := new(mapdb.MapDB)
+	m.Init(nil)
+	answer.temporaryStorage = m
+
+	b, err := badgerdb.NewBadgerDB(filename, create)
+	if err != nil {
+		return nil, err
+	}
+	answer.persistentStorage = b
+
+	return answer, nil
+}
+
 func NewBoltMapHybridDB(bucketList [][]byte, filename string) *HybridDB {
 	answer := new(HybridDB)
 
@@ -183,3 +201,22 @@ func (db *HybridDB) DoesKeyExist(bucket, key []byte) (bool, error) {
 	}
 	return exist, nil
 }
+
+func (db *HybridDB) NewIterator(bucket []byte, options *interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	db.Sem.RLock()
+	defer db.Sem.RUnlock()
+
+	return db.persistentStorage.NewIterator(bucket, options)
+}
+
+// Snapshot takes a snapshot of the persistent storage, which holds every record
+func (db *HybridDB) Snapshot() (interfaces.ISnapshot, error) {
+	db.Sem.RLock()
+	defer db.Sem.RUnlock()
+
+	s, ok := db.persistentStorage.(interfaces.ISnapshotter)
+	if !ok {
+		return nil, fmt.Errorf("Database does not support snapshots")
+	}
+	return s.Snapshot()
+}
@@ -0,0 +1,117 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package leveldb
+
+import (
+	"bytes"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/goleveldb/leveldb/iterator"
+	"github.com/FactomProject/goleveldb/leveldb/util"
+)
+
+// LevelIterator walks a range of keys in a leveldb snapshot, so records written while it is in
+// use are not seen by it
+type LevelIterator struct {
+	iter    iterator.Iterator
+	trim    int // Length of the bucket prefix to cut from the keys
+	options interfaces.IteratorOptions
+	started bool
+	count   int
+	key     []byte
+	value   []byte
+}
+
+var _ interfaces.IIterator = (*LevelIterator)(nil)
+
+func (db *LevelDB) NewIterator(bucket []byte, options *interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	if options == nil {
+		options = new(interfaces.IteratorOptions)
+	}
+
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	it := new(LevelIterator)
+	it.iter = db.lDB.NewIterator(util.BytesPrefix(CombineBucketAndKey(bucket, options.Prefix)), db.ro)
+	it.trim = len(ExtendBucket(bucket))
+	it.options = *options
+	if options.Seek != nil {
+		it.options.Seek = CombineBucketAndKey(bucket, options.Seek)
+	}
+	return it, nil
+}
+
+// first positions the underlying iterator on the first record to return
+func (it *LevelIterator) first() bool {
+	seek := it.options.Seek
+	if !it.options.Reverse {
+		if seek == nil {
+			return it.iter.First()
+		}
+		return it.iter.Seek(seek)
+	}
+
+	if seek == nil {
+		return it.iter.Last()
+	}
+	// Seek lands on the first key at or after seek, which is one too far unless it is seek itself
+	if !it.iter.Seek(seek) {
+		return it.iter.Last()
+	}
+	if bytes.Equal(it.iter.Key(), seek) {
+		return true
+	}
+	return it.iter.Prev()
+}
+
+func (it *LevelIterator) Next() bool {
+	if it.options.Limit > 0 && it.count >= it.options.Limit {
+		it.key, it.value = nil, nil
+		return false
+	}
+
+	var ok bool
+	switch {
+	case !it.started:
+		it.started = true
+		ok = it.first()
+	case it.options.Reverse:
+		ok = it.iter.Prev()
+	default:
+		ok = it.iter.Next()
+	}
+	if !ok {
+		it.key, it.value = nil, nil
+		return false
+	}
+
+	// The iterator reuses its buffers, so the record has to be copied out
+	key := it.iter.Key()
+	it.key = make([]byte, len(key)-it.trim)
+	copy(it.key, key[it.trim:])
+	v := it.iter.Value()
+	it.value = make([]byte, len(v))
+	copy(it.value, v)
+
+	it.count++
+	return true
+}
+
+func (it *LevelIterator) Key() []byte {
+	return it.key
+}
+
+func (it *LevelIterator) Value() []byte {
+	return it.value
+}
+
+func (it *LevelIterator) Error() error {
+	return it.iter.Error()
+}
+
+func (it *LevelIterator) Release() {
+	it.iter.Release()
+}
@@ -290,6 +290,31 @@ func NewLevelDB(filename string, create bool) (interfaces.IDatabase, error) {
 	return db, nil
 }
 
+// NewReadOnlyLevelDB opens an existing database without write access.  Any number of processes
+// can open the same database this way, as long as none of them writes to it.
+func NewReadOnlyLevelDB(filename string) (interfaces.IDatabase, error) {
+	db := new(LevelDB)
+
+	_, err := os.Stat(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &opt.Options{
+		OpenFilesCacheCapacity: 50,
+		ReadOnly:               true,
+		ErrorIfMissing:         true,
+	}
+
+	tlDB, err := leveldb.OpenFile(filename, opts)
+	if err != nil {
+		return nil, err
+	}
+	db.lDB = tlDB
+
+	return db, nil
+}
+
 // Internal db use only
 func addOneToByteArray(input []byte) (output []byte) {
 	if input == nil {
@@ -238,3 +238,54 @@ func TestGetAll(t *testing.T) {
 		}
 	}
 }
+
+func TestReadOnly(t *testing.T) {
+	_, err := NewReadOnlyLevelDB(dbFilename)
+	if err == nil {
+		t.Errorf("Opened a database that does not exist")
+	}
+
+	m, err := NewLevelDB(dbFilename, true)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	key := []byte("key")
+	bucket := []byte("bucket")
+	test := new(TestData)
+	test.Str = "testtest"
+	err = m.Put(bucket, key, test)
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+	err = m.Close()
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	// Several readers can share the database
+	r1, err := NewReadOnlyLevelDB(dbFilename)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer CleanupTest(t, r1)
+	r2, err := NewReadOnlyLevelDB(dbFilename)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer r2.Close()
+
+	for _, r := range []interfaces.IDatabase{r1, r2} {
+		resp, err := r.Get(bucket, key, new(TestData))
+		if err != nil {
+			t.Errorf("%v", err)
+		}
+		if resp == nil || resp.(*TestData).Str != test.Str {
+			t.Errorf("data mismatch")
+		}
+	}
+
+	err = r1.Put(bucket, []byte("other"), test)
+	if err == nil {
+		t.Errorf("Wrote to a read only database")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package leveldb
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/goleveldb/leveldb"
+)
+
+// How many records are written to a snapshot copy at a time
+var SnapshotBatchSize = 1000
+
+// LevelSnapshot is a leveldb snapshot, which keeps the records as they were when it was taken
+// until it is released
+type LevelSnapshot struct {
+	snap *leveldb.Snapshot
+	db   *LevelDB
+}
+
+var _ interfaces.ISnapshotter = (*LevelDB)(nil)
+var _ interfaces.ISnapshot = (*LevelSnapshot)(nil)
+
+func (db *LevelDB) Snapshot() (interfaces.ISnapshot, error) {
+	db.dbLock.RLock()
+	defer db.dbLock.RUnlock()
+
+	snap, err := db.lDB.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	s := new(LevelSnapshot)
+	s.snap = snap
+	s.db = db
+	return s, nil
+}
+
+// WriteTo copies every record of the snapshot into a new LevelDB at path, which must not exist yet
+func (s *LevelSnapshot) WriteTo(path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%v already exists", path)
+	}
+
+	tdb, err := NewLevelDB(path, true)
+	if err != nil {
+		return err
+	}
+	dest := tdb.(*LevelDB)
+	defer dest.Close()
+
+	iter := s.snap.NewIterator(nil, s.db.ro)
+	defer iter.Release()
+
+	batch := new(leveldb.Batch)
+	for iter.Next() {
+		// Batch.Put copies the key and value
+		batch.Put(iter.Key(), iter.Value())
+		if batch.Len() >= SnapshotBatchSize {
+			err = dest.lDB.Write(batch, dest.wo)
+			if err != nil {
+				return err
+			}
+			batch.Reset()
+		}
+	}
+	err = iter.Error()
+	if err != nil {
+		return err
+	}
+	return dest.lDB.Write(batch, dest.wo)
+}
+
+func (s *LevelSnapshot) Release() {
+	s.snap.Release()
+}
@@ -0,0 +1,102 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package mapdb
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/util"
+)
+
+// MapIterator goes through the keys of a bucket as they were when it was created, and
+// reads each value as it gets to it.  Records deleted in the meantime are skipped.
+type MapIterator struct {
+	db     *MapDB
+	bucket string
+	keys   [][]byte
+	index  int
+	value  []byte
+}
+
+var _ interfaces.IIterator = (*MapIterator)(nil)
+
+func (db *MapDB) NewIterator(bucket []byte, options *interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	if options == nil {
+		options = new(interfaces.IteratorOptions)
+	}
+
+	db.Sem.RLock()
+	defer db.Sem.RUnlock()
+
+	keys := [][]byte{}
+	for k := range db.Cache[string(bucket)] {
+		key := []byte(k)
+		if !bytes.HasPrefix(key, options.Prefix) {
+			continue
+		}
+		if options.Seek != nil {
+			c := bytes.Compare(key, options.Seek)
+			if (!options.Reverse && c < 0) || (options.Reverse && c > 0) {
+				continue
+			}
+		}
+		keys = append(keys, key)
+	}
+
+	if options.Reverse {
+		sort.Sort(sort.Reverse(util.ByByteArray(keys)))
+	} else {
+		sort.Sort(util.ByByteArray(keys))
+	}
+	if options.Limit > 0 && len(keys) > options.Limit {
+		keys = keys[:options.Limit]
+	}
+
+	it := new(MapIterator)
+	it.db = db
+	it.bucket = string(bucket)
+	it.keys = keys
+	it.index = -1
+	return it, nil
+}
+
+func (it *MapIterator) Next() bool {
+	it.db.Sem.RLock()
+	defer it.db.Sem.RUnlock()
+
+	for it.index+1 < len(it.keys) {
+		it.index++
+		data, ok := it.db.Cache[it.bucket][string(it.keys[it.index])]
+		if ok && data != nil {
+			it.value = data
+			return true
+		}
+	}
+	it.index = len(it.keys)
+	it.value = nil
+	return false
+}
+
+func (it *MapIterator) Key() []byte {
+	if it.index < 0 || it.index >= len(it.keys) {
+		return nil
+	}
+	return it.keys[it.index]
+}
+
+func (it *MapIterator) Value() []byte {
+	return it.value
+}
+
+func (it *MapIterator) Error() error {
+	return nil
+}
+
+func (it *MapIterator) Release() {
+	it.keys = nil
+	it.value = nil
+}
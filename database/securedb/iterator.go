@@ -0,0 +1,81 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package securedb
+
+import (
+	"fmt"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// EncryptedIterator decrypts the values of the records the underlying iterator goes through
+type EncryptedIterator struct {
+	iter          interfaces.IIterator
+	encryptionkey []byte
+	value         []byte
+	err           error
+}
+
+var _ interfaces.IIterator = (*EncryptedIterator)(nil)
+
+func (db *EncryptedDB) NewIterator(bucket []byte, options *interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	if db.isLocked() {
+		return nil, lockedError
+	}
+
+	iter, err := db.db.NewIterator(bucket, options)
+	if err != nil {
+		return nil, err
+	}
+
+	it := new(EncryptedIterator)
+	it.iter = iter
+	it.encryptionkey = db.encryptionkey
+	return it, nil
+}
+
+func (it *EncryptedIterator) Next() bool {
+	it.value = nil
+	if it.err != nil || !it.iter.Next() {
+		return false
+	}
+
+	it.value, it.err = decryptValue(it.iter.Value(), it.encryptionkey)
+	return it.err == nil
+}
+
+func (it *EncryptedIterator) Key() []byte {
+	return it.iter.Key()
+}
+
+func (it *EncryptedIterator) Value() []byte {
+	return it.value
+}
+
+func (it *EncryptedIterator) Error() error {
+	if it.err != nil {
+		return it.err
+	}
+	return it.iter.Error()
+}
+
+func (it *EncryptedIterator) Release() {
+	it.iter.Release()
+}
+
+// decryptValue undoes EncryptedMarshaler.MarshalBinary
+func decryptValue(cipherData []byte, key []byte) ([]byte, error) {
+	if len(cipherData) < 4 {
+		return nil, fmt.Errorf("Encrypted value is too short")
+	}
+	l, err := bytesToUint32(cipherData[:4])
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(cipherData)) < uint64(l)+4 {
+		return nil, fmt.Errorf("Encrypted value is too short")
+	}
+	return Decrypt(cipherData[4:l+4], key)
+}
@@ -14,6 +14,10 @@ var _ = fmt.Sprint
 type SecureDBMetaData struct {
 	Salt      primitives.ByteSlice
 	Challenge primitives.ByteSlice
+
+	// The salt and challenge of the new password while a password change is under way
+	NewSalt      primitives.ByteSlice
+	NewChallenge primitives.ByteSlice
 }
 
 func NewSecureDBMetaData() *SecureDBMetaData {
@@ -30,6 +34,14 @@ func (m *SecureDBMetaData) IsSameAs(b *SecureDBMetaData) bool {
 		return false
 	}
 
+	if !m.NewSalt.IsSameAs(&b.NewSalt) {
+		return false
+	}
+
+	if !m.NewChallenge.IsSameAs(&b.NewChallenge) {
+		return false
+	}
+
 	return true
 }
 
@@ -63,6 +75,27 @@ func (m *SecureDBMetaData) UnmarshalBinaryData(data []byte) (newData []byte, err
 	copy(m.Challenge.Bytes, newData[4:clen+4])
 	newData = newData[clen+4:]
 
+	// Metadata written before password changes were supported ends here
+	if len(newData) == 0 {
+		return
+	}
+
+	nslen, err := bytesToUint32(newData[:4])
+	if err != nil {
+		return nil, err
+	}
+	m.NewSalt.Bytes = make([]byte, nslen)
+	copy(m.NewSalt.Bytes, newData[4:nslen+4])
+	newData = newData[nslen+4:]
+
+	nclen, err := bytesToUint32(newData[:4])
+	if err != nil {
+		return nil, err
+	}
+	m.NewChallenge.Bytes = make([]byte, nclen)
+	copy(m.NewChallenge.Bytes, newData[4:nclen+4])
+	newData = newData[nclen+4:]
+
 	return
 }
 
@@ -88,6 +121,20 @@ func (m *SecureDBMetaData) MarshalBinary() (rval []byte, err error) {
 	}
 	buf.Write(data)
 
+	buf.Write(intToBytes(len(m.NewSalt.Bytes)))
+	data, err = m.NewSalt.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(data)
+
+	buf.Write(intToBytes(len(m.NewChallenge.Bytes)))
+	data, err = m.NewChallenge.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(data)
+
 	return buf.DeepCopyBytes(), nil
 }
 
@@ -38,3 +38,29 @@ func TestSecureDBMetaData(t *testing.T) {
 		}
 	}
 }
+
+func TestSecureDBMetaDataWithoutPasswordChange(t *testing.T) {
+	// Metadata written before password changes were supported only has the salt and challenge
+	salt := random.RandByteSliceOfLen(30)
+	challenge := random.RandByteSliceOfLen(60)
+	var data []byte
+	data = append(data, 0, 0, 0, byte(len(salt)))
+	data = append(data, salt...)
+	data = append(data, 0, 0, 0, byte(len(challenge)))
+	data = append(data, challenge...)
+
+	m := new(SecureDBMetaData)
+	nd, err := m.UnmarshalBinaryData(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nd) != 0 {
+		t.Errorf("Should have 0 bytes left, found %d", len(nd))
+	}
+	if string(m.Salt.Bytes) != string(salt) || string(m.Challenge.Bytes) != string(challenge) {
+		t.Error("Salt or challenge not read")
+	}
+	if len(m.NewSalt.Bytes) != 0 || len(m.NewChallenge.Bytes) != 0 {
+		t.Error("Should have no password change under way")
+	}
+}
@@ -0,0 +1,153 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package securedb
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"fmt"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// How many records ChangePassword re-encrypts in each batch
+var ReencryptBatchSize = 1000
+
+// IsChangingPassword tells whether a password change was started and not finished.  Records are
+// then encrypted under either password, and the change has to be finished before the database
+// can be used.
+func (db *EncryptedDB) IsChangingPassword() bool {
+	return len(db.metadata.NewSalt.Bytes) > 0
+}
+
+// ChangePassword re-encrypts every record of the buckets under a new password.  The buckets
+// have to be all of those in the database.  The salt and challenge of the new password are kept
+// in the metadata until every record is done, so a change that is cut short is finished by calling
+// ChangePassword again with the same password.
+func (db *EncryptedDB) ChangePassword(newPassword string, buckets [][]byte) error {
+	if db.isLocked() {
+		return lockedError
+	}
+
+	newKey, err := db.startPasswordChange(newPassword)
+	if err != nil {
+		return err
+	}
+
+	for _, bucket := range buckets {
+		err = db.reencryptBucket(bucket, newKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	db.metadata.Salt = db.metadata.NewSalt
+	db.metadata.Challenge = db.metadata.NewChallenge
+	db.metadata.NewSalt = primitives.ByteSlice{}
+	db.metadata.NewChallenge = primitives.ByteSlice{}
+	err = db.db.Put(EncyptedMetaData, EncyptedMetaData, db.metadata)
+	if err != nil {
+		return err
+	}
+
+	db.encryptionkey = newKey
+	return nil
+}
+
+// startPasswordChange records the salt and challenge of the new password, or checks the new
+// password against those of a change already under way, and returns the new key
+func (db *EncryptedDB) startPasswordChange(newPassword string) ([]byte, error) {
+	if db.IsChangingPassword() {
+		key, err := GetKey(newPassword, db.metadata.NewSalt.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		plainText, err := Decrypt(db.metadata.NewChallenge.Bytes, key)
+		if err != nil || subtle.ConstantTimeCompare(plainText, challenge) == 0 {
+			return nil, fmt.Errorf("a change to a different password is under way")
+		}
+		return key, nil
+	}
+
+	salt := make([]byte, 30)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return nil, err
+	}
+	key, err := GetKey(newPassword, salt)
+	if err != nil {
+		return nil, err
+	}
+	cipherText, err := Encrypt(challenge, key)
+	if err != nil {
+		return nil, err
+	}
+
+	db.metadata.NewSalt.Bytes = salt
+	db.metadata.NewChallenge.Bytes = cipherText
+	err = db.db.Put(EncyptedMetaData, EncyptedMetaData, db.metadata)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// reencryptBucket re-encrypts the records of a bucket under the new key, a batch at a time.
+// Records already under the new key are left alone.
+func (db *EncryptedDB) reencryptBucket(bucket []byte, newKey []byte) error {
+	var last []byte
+	for {
+		options := &interfaces.IteratorOptions{Seek: last, Limit: ReencryptBatchSize + 1}
+		iter, err := db.db.NewIterator(bucket, options)
+		if err != nil {
+			return err
+		}
+
+		var records []interfaces.Record
+		count := 0
+		for iter.Next() {
+			key := append([]byte{}, iter.Key()...)
+			if last != nil && bytes.Equal(key, last) {
+				continue
+			}
+			count++
+			last = key
+
+			_, err = decryptValue(iter.Value(), newKey)
+			if err == nil {
+				continue
+			}
+			plainText, err := decryptValue(iter.Value(), db.encryptionkey)
+			if err != nil {
+				iter.Release()
+				return fmt.Errorf("record %x in bucket %s can not be decrypted: %v", key, bucket, err)
+			}
+			cipherText, err := Encrypt(plainText, newKey)
+			if err != nil {
+				iter.Release()
+				return err
+			}
+			value := &primitives.ByteSlice{Bytes: append(intToBytes(len(cipherText)), cipherText...)}
+			records = append(records, interfaces.Record{Bucket: bucket, Key: key, Data: value})
+		}
+		err = iter.Error()
+		iter.Release()
+		if err != nil {
+			return err
+		}
+
+		if len(records) > 0 {
+			err = db.db.PutInBatch(records)
+			if err != nil {
+				return err
+			}
+		}
+		if count < ReencryptBatchSize {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package securedb_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/database/mapdb"
+	. "github.com/FactomProject/factomd/database/securedb"
+)
+
+func TestChangePassword(t *testing.T) {
+	ReencryptBatchSize = 7
+	defer func() { ReencryptBatchSize = 1000 }()
+
+	m := new(MapDB)
+	m.Init(nil)
+	db, err := NewEncryptedDBFrom(m, "oldPassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buckets := [][]byte{[]byte("one"), []byte("two"), []byte("empty")}
+	for i := 0; i < 50; i++ {
+		for _, b := range buckets[:2] {
+			err = db.Put(b, []byte(fmt.Sprintf("key%03d", i)), &primitives.ByteSlice{Bytes: []byte(fmt.Sprintf("value%d", i))})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// A record no password decrypts cuts the change short
+	err = m.Put(buckets[1], []byte("key025x"), &primitives.ByteSlice{Bytes: []byte("plain")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.ChangePassword("newPassword", buckets)
+	if err == nil {
+		t.Fatal("Should error")
+	}
+	if !db.IsChangingPassword() {
+		t.Error("Password change should be under way")
+	}
+
+	db, err = NewEncryptedDBFrom(m, "oldPassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !db.IsChangingPassword() {
+		t.Error("Password change should be under way after reopening")
+	}
+	err = db.ChangePassword("otherPassword", buckets)
+	if err == nil {
+		t.Error("Should not change to a different password while a change is under way")
+	}
+
+	m.Delete(buckets[1], []byte("key025x"))
+	err = db.ChangePassword("newPassword", buckets)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db.IsChangingPassword() {
+		t.Error("Password change should be finished")
+	}
+
+	_, err = NewEncryptedDBFrom(m, "oldPassword")
+	if err == nil {
+		t.Error("Old password should no longer open the database")
+	}
+	db, err = NewEncryptedDBFrom(m, "newPassword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 50; i++ {
+		for _, b := range buckets[:2] {
+			v, err := db.Get(b, []byte(fmt.Sprintf("key%03d", i)), new(primitives.ByteSlice))
+			if err != nil || v == nil {
+				t.Fatalf("Record %d of %s can not be read - %v", i, b, err)
+			}
+			if string(v.(*primitives.ByteSlice).Bytes) != fmt.Sprintf("value%d", i) {
+				t.Errorf("Record %d of %s is %s", i, b, v.(*primitives.ByteSlice).Bytes)
+			}
+		}
+	}
+}
@@ -13,6 +13,7 @@ import (
 
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/badgerdb"
 	"github.com/FactomProject/factomd/database/boltdb"
 	"github.com/FactomProject/factomd/database/leveldb"
 	"github.com/FactomProject/factomd/database/mapdb"
@@ -49,6 +50,7 @@ type EncryptedDB struct {
 //			Map
 //			Bolt
 //			LevelDB
+//			Badger
 func NewEncryptedDB(filename, dbtype, password string) (*EncryptedDB, error) {
 	e := new(EncryptedDB)
 	e.Init(filename, dbtype)
@@ -62,6 +64,20 @@ func NewEncryptedDB(filename, dbtype, password string) (*EncryptedDB, error) {
 	return e, nil
 }
 
+// NewEncryptedDBFrom encrypts the records of a database that is already open, such as the main
+// database of the node.  Closing the EncryptedDB closes the database.
+func NewEncryptedDBFrom(db interfaces.IDatabase, password string) (*EncryptedDB, error) {
+	e := new(EncryptedDB)
+	e.db = db
+
+	err := e.initSecureDB(password)
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
 // InitSecureDB will init the Salt and metadata
 func (db *EncryptedDB) initSecureDB(password string) error {
 	m := new(SecureDBMetaData)
@@ -281,9 +297,28 @@ func (db *EncryptedDB) Init(filename string, dbtype string) {
 		}
 	case "Bolt":
 		db.db = boltdb.NewBoltDB(nil, filename)
+	case "Badger":
+		db.db, err = badgerdb.NewBadgerDB(filename, true)
+		if err != nil {
+			panic(err)
+		}
 	default:
-		panic(fmt.Sprintf("%s is not a valid option. Expect 'Map', 'LDB', or 'Bolt'", dbtype))
+		panic(fmt.Sprintf("%s is not a valid option. Expect 'Map', 'LDB', 'Bolt', or 'Badger'", dbtype))
+	}
+}
+
+// Snapshot takes a snapshot of the encrypted records.  The copy it writes opens with the same
+// password as this database.
+func (db *EncryptedDB) Snapshot() (interfaces.ISnapshot, error) {
+	if db.isLocked() {
+		return nil, lockedError
+	}
+
+	s, ok := db.db.(interfaces.ISnapshotter)
+	if !ok {
+		return nil, fmt.Errorf("Database does not support snapshots")
 	}
+	return s.Snapshot()
 }
 
 func (db *EncryptedDB) DoesKeyExist(bucket, key []byte) (bool, error) {
@@ -1,11 +1,15 @@
 package securedb_test
 
 import (
+	"fmt"
+	"io/ioutil"
 	"os"
 	"testing"
 
-	//	"github.com/FactomProject/factomd/common/primitives"
-	//"github.com/FactomProject/factomd/common/primitives/random"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/common/primitives/random"
+	"github.com/FactomProject/factomd/database/leveldb"
 	. "github.com/FactomProject/factomd/database/securedb"
 )
 
@@ -25,3 +29,67 @@ func TestSecureDB(t *testing.T) {
 
 	os.Remove("test.db")
 }
+
+// The benchmarks compare an encrypted LevelDB with a plain one
+
+func benchmarkDB(b *testing.B, encrypted bool) (interfaces.IDatabase, func()) {
+	dir, err := ioutil.TempDir("", "securedb-bench")
+	if err != nil {
+		b.Fatal(err)
+	}
+	ldb, err := leveldb.NewLevelDB(dir, true)
+	if err != nil {
+		b.Fatal(err)
+	}
+	cleanup := func() {
+		ldb.Close()
+		os.RemoveAll(dir)
+	}
+	if !encrypted {
+		return ldb, cleanup
+	}
+	db, err := NewEncryptedDBFrom(ldb, "password")
+	if err != nil {
+		b.Fatal(err)
+	}
+	return db, cleanup
+}
+
+func benchmarkPut(b *testing.B, encrypted bool) {
+	db, cleanup := benchmarkDB(b, encrypted)
+	defer cleanup()
+
+	value := &primitives.ByteSlice{Bytes: random.RandByteSliceOfLen(1024)}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		err := db.Put([]byte("bench"), []byte(fmt.Sprintf("key%d", i)), value)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkGet(b *testing.B, encrypted bool) {
+	db, cleanup := benchmarkDB(b, encrypted)
+	defer cleanup()
+
+	value := &primitives.ByteSlice{Bytes: random.RandByteSliceOfLen(1024)}
+	for i := 0; i < 1000; i++ {
+		err := db.Put([]byte("bench"), []byte(fmt.Sprintf("key%d", i)), value)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := db.Get([]byte("bench"), []byte(fmt.Sprintf("key%d", i%1000)), new(primitives.ByteSlice))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLevelDBPut(b *testing.B)          { benchmarkPut(b, false) }
+func BenchmarkEncryptedLevelDBPut(b *testing.B) { benchmarkPut(b, true) }
+func BenchmarkLevelDBGet(b *testing.B)          { benchmarkGet(b, false) }
+func BenchmarkEncryptedLevelDBGet(b *testing.B) { benchmarkGet(b, true) }
@@ -5,6 +5,7 @@
 package database_test
 
 import (
+	"bytes"
 	"fmt"
 	"os"
 	"testing"
@@ -12,6 +13,7 @@ import (
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/primitives"
 	"github.com/FactomProject/factomd/common/primitives/random"
+	"github.com/FactomProject/factomd/database/badgerdb"
 	"github.com/FactomProject/factomd/database/boltdb"
 	"github.com/FactomProject/factomd/database/databaseOverlay"
 	"github.com/FactomProject/factomd/database/leveldb"
@@ -82,6 +84,16 @@ func TestAllDatabases(t *testing.T) {
 		CleanupTest(t, m)
 	}
 
+	// Secure Badger
+	for i := 0; i < 5; i++ {
+		m, err := securedb.NewEncryptedDB(dbFilename, "Badger", random.RandomString())
+		if err != nil {
+			t.Error(err)
+		}
+		testDB(t, m, i)
+		CleanupTest(t, m)
+	}
+
 	// Bolt
 	for i := 0; i < 5; i++ {
 		m := boltdb.NewBoltDB(nil, dbFilename)
@@ -99,6 +111,16 @@ func TestAllDatabases(t *testing.T) {
 		CleanupTest(t, m)
 	}
 
+	// Badger
+	for i := 0; i < 5; i++ {
+		m, err := badgerdb.NewBadgerDB(dbFilename, true)
+		if err != nil {
+			t.Error(err)
+		}
+		testDB(t, m, i)
+		CleanupTest(t, m)
+	}
+
 	// Map
 	for i := 0; i < 5; i++ {
 		m := new(mapdb.MapDB)
@@ -117,6 +139,8 @@ func testDB(t *testing.T, m interfaces.IDatabase, i int) {
 		testDoesKeyExist(t, m)
 	case 3:
 		testGetAll(t, m)
+	case 4:
+		testIterator(t, m)
 	}
 }
 
@@ -291,3 +315,116 @@ func testGetAll(t *testing.T, m interfaces.IDatabase) {
 		}
 	}
 }
+
+func testIterator(t *testing.T, m interfaces.IDatabase) {
+	defer CleanupTest(t, m)
+
+	// Make the Bolt iterator go through several read transactions
+	chunk := boltdb.IteratorChunkSize
+	boltdb.IteratorChunkSize = 7
+	defer func() { boltdb.IteratorChunkSize = chunk }()
+
+	bucket := []byte("iter")
+	keys := [][]byte{{0xff, 0x00}, {0xff, 0xff}}
+	for i := byte(0); i < 10; i++ {
+		for j := byte(0); j < 10; j++ {
+			keys = append(keys, []byte{i, j})
+		}
+	}
+	for _, k := range keys {
+		err := m.Put(bucket, k, &TestData{Str: fmt.Sprintf("%x", k)})
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	// Records of neighbouring buckets must not show up
+	m.Put([]byte("iter2"), []byte{0x01, 0x01}, &TestData{Str: "other"})
+	m.Put([]byte("ite"), []byte{0x01, 0x01}, &TestData{Str: "other"})
+
+	tests := []interfaces.IteratorOptions{
+		{},
+		{Prefix: []byte{3}},
+		{Seek: []byte{5, 5}},
+		{Seek: []byte{5, 5, 1}},
+		{Reverse: true},
+		{Reverse: true, Seek: []byte{5, 5}, Prefix: []byte{5}},
+		{Reverse: true, Seek: []byte{5, 5, 1}},
+		{Reverse: true, Seek: []byte{9}, Prefix: []byte{3}},
+		{Reverse: true, Prefix: []byte{0xff}},
+		{Prefix: []byte{0xff}, Limit: 1},
+		{Seek: []byte{2}, Limit: 15},
+		{Reverse: true, Limit: 30},
+		{Prefix: []byte{0x20}},
+	}
+	for _, opts := range tests {
+		expected := expectedIteration(keys, opts)
+
+		o := opts
+		iter, err := m.NewIterator(bucket, &o)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		found := 0
+		for iter.Next() {
+			if found >= len(expected) {
+				t.Errorf("%+v - unexpected key %x", opts, iter.Key())
+				break
+			}
+			if !bytes.Equal(iter.Key(), expected[found]) {
+				t.Errorf("%+v - key %v is %x, expected %x", opts, found, iter.Key(), expected[found])
+			}
+			if string(iter.Value()) != fmt.Sprintf("%x", expected[found]) {
+				t.Errorf("%+v - wrong value %x for key %x", opts, iter.Value(), iter.Key())
+			}
+			found++
+		}
+		if err := iter.Error(); err != nil {
+			t.Errorf("%+v - %v", opts, err)
+		}
+		iter.Release()
+		if found != len(expected) {
+			t.Errorf("%+v - found %v keys, expected %v", opts, found, len(expected))
+		}
+	}
+
+	iter, err := m.NewIterator([]byte("empty"), nil)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if iter.Next() {
+		t.Errorf("Found key %x in an empty bucket", iter.Key())
+	}
+	iter.Release()
+}
+
+// expectedIteration lists the keys an iterator should go through, in order
+func expectedIteration(keys [][]byte, opts interfaces.IteratorOptions) [][]byte {
+	sorted := make([][]byte, len(keys))
+	copy(sorted, keys)
+	for i := range sorted {
+		for j := i + 1; j < len(sorted); j++ {
+			c := bytes.Compare(sorted[i], sorted[j])
+			if (!opts.Reverse && c > 0) || (opts.Reverse && c < 0) {
+				sorted[i], sorted[j] = sorted[j], sorted[i]
+			}
+		}
+	}
+
+	answer := [][]byte{}
+	for _, k := range sorted {
+		if !bytes.HasPrefix(k, opts.Prefix) {
+			continue
+		}
+		if opts.Seek != nil {
+			c := bytes.Compare(k, opts.Seek)
+			if (!opts.Reverse && c < 0) || (opts.Reverse && c > 0) {
+				continue
+			}
+		}
+		if opts.Limit > 0 && len(answer) == opts.Limit {
+			break
+		}
+		answer = append(answer, k)
+	}
+	return answer
+}
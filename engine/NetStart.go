@@ -210,7 +210,9 @@ func NetStart(s *state.State, p *FactomParams, listenToStdin bool) {
 		}
 	}
 	if p.Follower {
-		s.NodeMode = "FULL"
+		if s.NodeMode != "PRUNED" {
+			s.NodeMode = "FULL"
+		}
 		leadID := primitives.Sha([]byte(s.Prefix + "FNode0"))
 		if s.IdentityChainID.IsSameAs(leadID) {
 			s.SetIdentityChainID(primitives.Sha([]byte(time.Now().String()))) // Make sure this node is NOT a leader
@@ -245,6 +247,30 @@ func NetStart(s *state.State, p *FactomParams, listenToStdin bool) {
 	s.AddPrefix(p.Prefix)
 	s.SetOut(false)
 	s.Init()
+	if p.DBMigrateOnly {
+		// Init has run the migrations, there is nothing more to do
+		s.DB.Close()
+		fmt.Println("Database migrations are done")
+		os.Exit(0)
+	}
+	if p.DBReindexOnly {
+		if s.ReadOnlyDB {
+			fmt.Println("Can not reindex a read only database")
+			os.Exit(1)
+		}
+		n, err := s.DB.ReindexEBlockSequences(5000)
+		s.DB.Close()
+		if err != nil {
+			fmt.Printf("Error reindexing the database: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Database: reindexed %d chains\n", n)
+		os.Exit(0)
+	}
+	if s.ReadOnlyDB {
+		// A read only node only serves the API from its database, it takes no part in the network
+		p.EnableNet = false
+	}
 	s.SetDropRate(p.DropRate)
 
 	if p.Sync2 >= 0 {
@@ -383,6 +409,16 @@ func NetStart(s *state.State, p *FactomParams, listenToStdin bool) {
 			networkPort = fmt.Sprintf("%d", p.NetworkPortOverride)
 		}
 
+		var nodeKey *p2p.NodeKey
+		if s.P2PEncryption || s.P2PEncryptSpecialPeers {
+			key, err := p2p.LoadNodeKey(s.P2PKeyFile)
+			if err != nil {
+				panic(fmt.Sprintf("Cannot load the p2p node key from %s: %v", s.P2PKeyFile, err))
+			}
+			nodeKey = key
+			os.Stderr.WriteString(fmt.Sprintf("%20s %s\n", "p2p node key", nodeKey.ID()))
+		}
+
 		ci := p2p.ControllerInit{
 			NodeName:                 nodeName,
 			Port:                     networkPort,
@@ -394,6 +430,16 @@ func NetStart(s *state.State, p *FactomParams, listenToStdin bool) {
 			ConfigPeers:              configPeers,
 			CmdLinePeers:             p.Peers,
 			ConnectionMetricsChannel: connectionMetricsChannel,
+			NodeKey:                  nodeKey,
+			EncryptSpecialPeers:      s.P2PEncryptSpecialPeers,
+			PlainFallback:            s.P2PPlainFallback,
+			Height:                   fnodes[0].State.GetHighestSavedBlk,
+			BansFile:                 strings.TrimSuffix(s.PeersFile, ".json") + "-bans.json",
+			BanDuration:              s.BanDuration,
+			PeerUploadRate:           s.P2PPeerUploadRate,
+			PeerDownloadRate:         s.P2PPeerDownloadRate,
+			TotalUploadRate:          s.P2PTotalUploadRate,
+			TotalDownloadRate:        s.P2PTotalDownloadRate,
 		}
 		p2pNetwork = new(p2p.Controller).Init(ci)
 		fnodes[0].State.NetworkController = p2pNetwork
@@ -564,6 +610,63 @@ func NetStart(s *state.State, p *FactomParams, listenToStdin bool) {
 
 	go SimControl(p.ListenTo, listenToStdin)
 
+	if p.SnapshotDir != "" {
+		go snapshotWhenLoaded(fnodes[0].State, p.SnapshotDir)
+	}
+
+	if p.ImportArchive != "" {
+		go importWhenLoaded(fnodes[0].State, p.ImportArchive)
+	}
+
+	if p.ExportArchive != "" {
+		go exportWhenLoaded(fnodes[0].State, p.ExportArchive, p.ExportFrom, p.ExportTo)
+	}
+}
+
+// importWhenLoaded waits for the node to finish loading its database, and then hands it the
+// directory blocks of an archive file that follow on from it
+func importWhenLoaded(s *state.State, path string) {
+	for !s.DBFinished {
+		time.Sleep(time.Second)
+	}
+	fmt.Fprintf(os.Stderr, "%20s Importing archive %v\n", s.FactomNodeName, path)
+	n, err := s.ImportArchive(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%20s Import of %v failed after %d directory blocks: %v\n", s.FactomNodeName, path, n, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%20s Imported %d directory blocks from %v\n", s.FactomNodeName, n, path)
+}
+
+// exportWhenLoaded waits for the node to finish loading its database, and then exports the
+// directory blocks from one height to another into an archive file
+func exportWhenLoaded(s *state.State, path string, from int, to int) {
+	for !s.DBFinished {
+		time.Sleep(time.Second)
+	}
+	if to < 0 {
+		to = int(s.GetHighestSavedBlk())
+	}
+	n, err := s.ExportArchive(path, uint32(from), uint32(to))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%20s Export into %v failed after %d directory blocks: %v\n", s.FactomNodeName, path, n, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%20s Exported %d directory blocks into %v\n", s.FactomNodeName, n, path)
+}
+
+// snapshotWhenLoaded waits for the node to finish loading its database, and then takes a snapshot
+// of it into dir
+func snapshotWhenLoaded(s *state.State, dir string) {
+	for !s.DBFinished {
+		time.Sleep(time.Second)
+	}
+	height, err := s.SnapshotDatabase(dir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%20s Database snapshot into %v failed: %v\n", s.FactomNodeName, dir, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%20s Database snapshot into %v done at directory block %d\n", s.FactomNodeName, dir, height)
 }
 
 func printGraphData(filename string, period int) {
@@ -612,7 +715,15 @@ func startServers(load bool) {
 		if load {
 			go state.LoadDatabase(fnode.State)
 		}
+		if fnode.State.ReadOnlyDB {
+			// Nothing but the loading of the database, and of the newer snapshots copied over it
+			go fnode.State.GoReopenDB()
+			go fnode.State.ValidatorLoop()
+			continue
+		}
 		go fnode.State.GoSyncEntries()
+		go fnode.State.GoPruneEntries()
+		go fnode.State.GoHealDBStates()
 		go Timer(fnode.State)
 		go elections.Run(fnode.State)
 		go fnode.State.ValidatorLoop()
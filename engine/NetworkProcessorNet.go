@@ -15,6 +15,7 @@ import (
 	"github.com/FactomProject/factomd/common/messages"
 	"github.com/FactomProject/factomd/common/primitives"
 	"github.com/FactomProject/factomd/log"
+	"github.com/FactomProject/factomd/p2p"
 )
 
 var _ = log.Printf
@@ -237,6 +238,7 @@ func Peers(fnode *FactomNode) {
 
 				if msg.GetHash().IsHashNil() {
 					fnode.State.LogMessage("badMsgs", "Nil hash from Peer", msg)
+					penalize(msg.GetNetworkOrigin(), p2p.OffenseInvalid)
 					continue
 				}
 
@@ -258,6 +260,7 @@ func Peers(fnode *FactomNode) {
 				tsv := fnode.State.Replay.IsTSValidAndUpdateState(constants.TIME_TEST, hash, timestamp, now)
 				if !tsv {
 					fnode.State.LogMessage("NetworkInputs", fromPeer+" Drop, TS invalid", msg)
+					penalize(msg.GetNetworkOrigin(), p2p.OffenseReplayed)
 					continue
 				}
 
@@ -279,6 +282,7 @@ func Peers(fnode *FactomNode) {
 				if !rv {
 					fnode.State.LogMessage("NetworkInputs", fromPeer+" Drop, NETWORK_REPLAY", msg)
 					RepeatMsgs.Inc()
+					penalize(msg.GetNetworkOrigin(), p2p.OffenseDuplicate)
 					//fnode.MLog.add2(fnode, false, peer.GetNameTo(), "PeerIn", false, msg)
 					continue
 				}
@@ -427,14 +431,13 @@ func NetworkOutputs(fnode *FactomNode) {
 func InvalidOutputs(fnode *FactomNode) {
 	for {
 		time.Sleep(1 * time.Millisecond)
-		_ = <-fnode.State.NetworkInvalidMsgQueue()
+		invalidMsg := <-fnode.State.NetworkInvalidMsgQueue()
 		//fmt.Println(invalidMsg)
 
-		// The following code was giving a demerit for each instance of a message in the NetworkInvalidMsgQueue.
-		// However the consensus system is not properly limiting the messages going into this queue to be ones
-		//  indicating an attack.  So the demerits are turned off for now.
-		// if len(invalidMsg.GetNetworkOrigin()) > 0 {
-		// 	p2pNetwork.AdjustPeerQuality(invalidMsg.GetNetworkOrigin(), -2)
-		// }
+		// The consensus system does not limit the messages going into this queue to ones indicating an
+		// attack, so they carry a small penalty, and only a peer sending a stream of them is banned.
+		if invalidMsg != nil {
+			penalize(invalidMsg.GetNetworkOrigin(), p2p.OffenseRejected)
+		}
 	}
 }
@@ -48,7 +48,7 @@ func init() {
 	flag.BoolVar(&p.Journaling, "journaling", false, "Write a journal of all messages received. Default is off.")
 	flag.BoolVar(&p.Follower, "follower", false, "If true, force node to be a follower.  Only used when replaying a journal.")
 	flag.BoolVar(&p.Leader, "leader", true, "If true, force node to be a leader.  Only used when replaying a journal.")
-	flag.StringVar(&p.Db, "db", "", "Override the Database in the Config file and use this Database implementation. Options Map, LDB, or Bolt")
+	flag.StringVar(&p.Db, "db", "", "Override the Database in the Config file and use this Database implementation. Options Map, LDB, Bolt, or Badger")
 	flag.StringVar(&p.CloneDB, "clonedb", "", "Override the main node and use this database for the clones in a Network.")
 	flag.StringVar(&p.NetworkName, "network", "", "Network to join: MAIN, TEST or LOCAL")
 	flag.StringVar(&p.Peers, "peers", "", "Array of peer addresses. ")
@@ -75,6 +75,13 @@ func init() {
 	flag.BoolVar(&p.Fast, "fast", true, "If true, Factomd will fast-boot from a file.")
 	flag.IntVar(&p.FastSaveRate, "fastsaverate", 1000, "Save a fastboot file every so many blocks. Should be > 1000 for live systems.")
 	flag.StringVar(&p.FastLocation, "fastlocation", "", "Directory to put the Fast-boot file in.")
+	flag.StringVar(&p.SnapshotDir, "snapshot", "", "Once the database is loaded, copy it into this directory while the node keeps running.")
+	flag.StringVar(&p.ExportArchive, "exportarchive", "", "Once the database is loaded, export the directory blocks from -exportfrom to -exportto into this archive file.")
+	flag.IntVar(&p.ExportFrom, "exportfrom", 0, "The first directory block to export with -exportarchive")
+	flag.IntVar(&p.ExportTo, "exportto", -1, "The last directory block to export with -exportarchive, -1 for the highest saved block")
+	flag.StringVar(&p.ImportArchive, "importarchive", "", "Once the database is loaded, import the directory blocks of this archive file that follow on from it. The archive can also be a pipe, or - for standard input, in which case it is read front to back.")
+	flag.BoolVar(&p.DBMigrateOnly, "db-migrate-only", false, "Run any pending database migrations, then exit without starting the node.")
+	flag.BoolVar(&p.DBReindexOnly, "db-reindex-only", false, "Rebuild the entry block sequence index of every chain, then exit without starting the node.")
 	flag.StringVar(&p.Loglvl, "loglvl", "none", "Set log level to either: none, debug, info, warning, error, fatal or panic")
 	flag.BoolVar(&p.Logjson, "logjson", false, "Use to set logging to use a json formatting")
 	flag.BoolVar(&p.Sim_Stdin, "sim_stdin", true, "If true, sim control reads from stdin.")
@@ -45,11 +45,53 @@ type P2PProxy struct {
 	logger *log.Entry
 }
 
+// MaxMessageSize is the largest message a peer may send, other than the responses that carry
+// whole blocks.  A peer that sends a larger one is penalized, see p2p.Offense.
+const MaxMessageSize = 64 * 1024
+
+// oversized tells whether the marshaled message is larger than any of its type can be
+func oversized(data []byte) bool {
+	if len(data) <= MaxMessageSize {
+		return false
+	}
+	switch data[0] {
+	case constants.DBSTATE_MSG, constants.DATA_RESPONSE, constants.ENTRY_BLOCK_RESPONSE:
+		return false
+	}
+	return true
+}
+
+// sendPriority is how urgently a message of the type is sent to peers, see p2p.Priority
+func sendPriority(msgType byte) p2p.Priority {
+	switch msgType {
+	case constants.EOM_MSG, constants.DIRECTORY_BLOCK_SIGNATURE_MSG, constants.FULL_SERVER_FAULT_MSG,
+		constants.EOM_TIMEOUT_MSG, constants.SIGNATURE_TIMEOUT_MSG, constants.HEARTBEAT_MSG,
+		constants.ADDSERVER_MSG, constants.CHANGESERVER_KEY_MSG, constants.REMOVESERVER_MSG:
+		return p2p.PriorityConsensus
+	case constants.ACK_MSG:
+		return p2p.PriorityAck
+	case constants.MISSING_MSG, constants.MISSING_MSG_RESPONSE, constants.MISSING_DATA, constants.DATA_RESPONSE,
+		constants.DBSTATE_MISSING_MSG, constants.MISSING_ENTRY_BLOCKS, constants.ENTRY_BLOCK_RESPONSE:
+		return p2p.PriorityMissing
+	case constants.DBSTATE_MSG:
+		return p2p.PriorityDBState
+	}
+	return p2p.PriorityGossip
+}
+
+// penalize tells the network that the peer sent a message it should not have
+func penalize(peerHash string, offense p2p.Offense) {
+	if p2pNetwork != nil && peerHash != "" {
+		p2pNetwork.Penalize(peerHash, offense)
+	}
+}
+
 type FactomMessage struct {
 	Message  []byte
 	PeerHash string
 	AppHash  string
 	AppType  string
+	Priority p2p.Priority
 }
 
 func (e *FactomMessage) JSONByte() ([]byte, error) {
@@ -119,7 +161,7 @@ func (f *P2PProxy) Send(msg interfaces.IMsg) error {
 	} else {
 		hash := fmt.Sprintf("%x", msg.GetMsgHash().Bytes())
 		appType := fmt.Sprintf("%d", msg.Type())
-		message := FactomMessage{Message: data, PeerHash: msg.GetNetworkOrigin(), AppHash: hash, AppType: appType}
+		message := FactomMessage{Message: data, PeerHash: msg.GetNetworkOrigin(), AppHash: hash, AppType: appType, Priority: sendPriority(msg.Type())}
 		switch {
 		case !msg.IsPeer2Peer() && msg.IsFullBroadcast():
 			msgLogger.Debug("Sending full broadcast message")
@@ -127,6 +169,9 @@ func (f *P2PProxy) Send(msg interfaces.IMsg) error {
 		case !msg.IsPeer2Peer() && !msg.IsFullBroadcast():
 			msgLogger.Debug("Sending broadcast message")
 			message.PeerHash = p2p.BroadcastFlag
+		case msg.IsPeer2Peer() && 0 == len(message.PeerHash) && constants.DBSTATE_MISSING_MSG == msg.Type(): // only peers with the blocks can answer
+			msgLogger.Debug("Sending directed message to an up to date peer")
+			message.PeerHash = p2p.UpToDatePeerFlag
 		case msg.IsPeer2Peer() && 0 == len(message.PeerHash): // directed, with no direction of who to send it to
 			msgLogger.Debug("Sending directed message to a random peer")
 			message.PeerHash = p2p.RandomPeerFlag
@@ -149,10 +194,16 @@ func (f *P2PProxy) Receive() (interfaces.IMsg, error) {
 			switch data.(type) {
 			case FactomMessage:
 				fmessage := data.(FactomMessage)
+				f.bytesIn += len(fmessage.Message)
+				if oversized(fmessage.Message) {
+					penalize(fmessage.PeerHash, p2p.OffenseOversized)
+					return nil, fmt.Errorf("message of %d bytes from %s is oversized", len(fmessage.Message), fmessage.PeerHash)
+				}
 				msg, err := msgsupport.UnmarshalMessage(fmessage.Message)
 
 				if err != nil {
 					proxyLogger.WithField("receive-error", err).Error()
+					penalize(fmessage.PeerHash, p2p.OffenseInvalid)
 				} else {
 					proxyLogger.WithFields(msg.LogFields()).Debug("Received Message")
 				}
@@ -160,7 +211,6 @@ func (f *P2PProxy) Receive() (interfaces.IMsg, error) {
 				if nil == err {
 					msg.SetNetworkOrigin(fmessage.PeerHash)
 				}
-				f.bytesIn += len(fmessage.Message)
 				return msg, err
 			default:
 				f.logger.Errorf("Garbage on f.BroadcastIn. %+v", data)
@@ -221,6 +271,7 @@ func (f *P2PProxy) ManageOutChannel() {
 				parcel.Header.TargetPeer = fmessage.PeerHash
 				parcel.Header.AppHash = fmessage.AppHash
 				parcel.Header.AppType = fmessage.AppType
+				parcel.SetPriority(fmessage.Priority)
 				p2p.BlockFreeChannelSend(f.ToNetwork, parcel)
 			}
 		default:
@@ -0,0 +1,82 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"sync"
+	"time"
+)
+
+// The bytes sent to and read from each peer are capped at PeerUploadRate and PeerDownloadRate,
+// and those of all peers together at TotalUploadRate and TotalDownloadRate.  A connection over a
+// cap waits before it sends its next parcel, or before it reads the next one off the network, so
+// a peer sending too much is slowed down by TCP.
+
+// Limiter caps the rate bytes go through at.  Up to one second of bytes go through at once, and
+// past that each caller waits its turn.  A nil Limiter, or one with a rate of 0, does not limit.
+type Limiter struct {
+	mutex     sync.Mutex
+	rate      float64   // bytes per second
+	allowance float64   // bytes that can go through now, negative when callers are waiting
+	last      time.Time // when the allowance was last updated
+}
+
+// NewLimiter returns a limiter of rate bytes per second, or nil for no limit if rate is 0
+func NewLimiter(rate int) *Limiter {
+	if rate <= 0 {
+		return nil
+	}
+	l := new(Limiter)
+	l.rate = float64(rate)
+	l.allowance = l.rate
+	l.last = time.Now()
+	return l
+}
+
+// delay takes n bytes out of the allowance, and returns how long to wait before they go through
+func (l *Limiter) delay(n int, now time.Time) time.Duration {
+	if l == nil || l.rate <= 0 {
+		return 0
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if elapsed := now.Sub(l.last).Seconds(); elapsed > 0 {
+		l.allowance += elapsed * l.rate
+		l.last = now
+	}
+	if l.allowance > l.rate {
+		l.allowance = l.rate
+	}
+	l.allowance -= float64(n)
+	if l.allowance >= 0 {
+		return 0
+	}
+	return time.Duration(-l.allowance / l.rate * float64(time.Second))
+}
+
+// Wait blocks until n bytes can go through, and returns how long it waited
+func (l *Limiter) Wait(n int) time.Duration {
+	d := l.delay(n, time.Now())
+	if d > 0 {
+		time.Sleep(d)
+	}
+	return d
+}
+
+// Limiters shared by all connections, set up by the controller
+var (
+	totalUpload   *Limiter
+	totalDownload *Limiter
+)
+
+// throttle waits until n bytes can go through both the connection's limiter and the total one,
+// and counts the time waited under direction
+func throttle(connection, total *Limiter, n int, direction string) {
+	waited := connection.Wait(n) + total.Wait(n)
+	if waited > 0 {
+		p2pBandwidthWaitSeconds.WithLabelValues(direction).Add(waited.Seconds())
+	}
+}
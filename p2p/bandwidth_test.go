@@ -0,0 +1,34 @@
+package p2p
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterDelay(t *testing.T) {
+	var none *Limiter
+	if none.delay(1000000, time.Now()) != 0 || NewLimiter(0) != nil {
+		t.Error("A limiter without a rate delays")
+	}
+
+	l := NewLimiter(1000)
+	now := l.last
+	// A second's worth goes through at once
+	if d := l.delay(1000, now); d != 0 {
+		t.Errorf("First second of bytes delayed %v", d)
+	}
+	if d := l.delay(500, now); d != 500*time.Millisecond {
+		t.Errorf("Bytes over the rate delayed %v rather than 500ms", d)
+	}
+	// The next caller waits behind the first
+	if d := l.delay(500, now); d != time.Second {
+		t.Errorf("Second caller over the rate delayed %v rather than 1s", d)
+	}
+	// Once the time has passed the allowance is back, but never more than a second's worth
+	if d := l.delay(1000, now.Add(time.Hour)); d != 0 {
+		t.Errorf("Bytes after an idle hour delayed %v", d)
+	}
+	if d := l.delay(1, now.Add(time.Hour)); d == 0 {
+		t.Error("Allowance grew past a second's worth of bytes")
+	}
+}
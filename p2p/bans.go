@@ -0,0 +1,194 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"sync"
+	"time"
+)
+
+// The application tells the controller about peers that misbehave with Penalize.  Each offense
+// adds its penalty to the misbehavior score of the peer's address, and the score halves every
+// ScoreHalfLife, so a peer is only banned for misbehaving often.  Once the score reaches
+// BanScore the address is banned for BanDuration: its connections are shut down, it is not
+// dialed and its incoming connections are refused.  Bans are kept in the bans file, so they
+// outlast a restart.  Special peers are penalized in quality but never banned.
+
+// Offense is a way a peer misbehaved
+type Offense uint8
+
+// Offenses a peer can be penalized for
+const (
+	OffenseInvalid   Offense = iota // a message that could not be read
+	OffenseDuplicate                // a message we were already sent
+	OffenseOversized                // a message larger than any of its type can be
+	OffenseReplayed                 // a message with a timestamp outside the window we take
+	OffenseRejected                 // a message the application found invalid
+)
+
+// OffenseStrings is a map of offenses to strings for easy printing
+var OffenseStrings = map[Offense]string{
+	OffenseInvalid:   "Invalid",
+	OffenseDuplicate: "Duplicate",
+	OffenseOversized: "Oversized",
+	OffenseReplayed:  "Replayed",
+	OffenseRejected:  "Rejected",
+}
+
+// OffensePenalties is how much each offense adds to the misbehavior score.  Duplicate, late and
+// rejected messages are normal in a gossip network, as a peer passes on what others sent it, so
+// they weigh little enough that the score of a peer sending them at the rate of gossip decays
+// well below BanScore, and only a flood of them gets a peer banned.
+var OffensePenalties = map[Offense]float64{
+	OffenseInvalid:   10,
+	OffenseDuplicate: 0.001,
+	OffenseOversized: 50,
+	OffenseReplayed:  0.01,
+	OffenseRejected:  0.05,
+}
+
+func (o Offense) String() string {
+	if s, ok := OffenseStrings[o]; ok {
+		return s
+	}
+	return "Unknown"
+}
+
+// misbehavior is the decaying misbehavior score of a peer address
+type misbehavior struct {
+	score   float64
+	updated time.Time
+}
+
+// decayed returns the score as of now
+func (m *misbehavior) decayed(now time.Time) float64 {
+	elapsed := now.Sub(m.updated)
+	if elapsed <= 0 {
+		return m.score
+	}
+	return m.score * math.Exp2(-elapsed.Seconds()/ScoreHalfLife.Seconds())
+}
+
+// add decays the score to now, adds the penalty and returns the new score
+func (m *misbehavior) add(penalty float64, now time.Time) float64 {
+	m.score = m.decayed(now) + penalty
+	m.updated = now
+	return m.score
+}
+
+// BanList is the set of banned peer addresses, each with the time its ban ends.  It is saved
+// to its file whenever it changes, unless the file name is empty.
+type BanList struct {
+	mutex    sync.Mutex
+	filename string
+	bans     map[string]time.Time
+}
+
+// NewBanList returns the bans saved in the file that have not ended yet
+func NewBanList(filename string) *BanList {
+	b := new(BanList)
+	b.filename = filename
+	b.bans = make(map[string]time.Time)
+	if filename == "" {
+		return b
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			controllerLogger.Errorf("NewBanList() could not read %s: %v", filename, err)
+		}
+		return b
+	}
+	saved := make(map[string]time.Time)
+	err = json.Unmarshal(data, &saved)
+	if err != nil {
+		controllerLogger.Errorf("NewBanList() could not read bans from %s: %v", filename, err)
+		return b
+	}
+	now := time.Now()
+	for address, until := range saved {
+		if until.After(now) {
+			b.bans[address] = until
+		}
+	}
+	return b
+}
+
+// Ban bans the address until the time given
+func (b *BanList) Ban(address string, until time.Time) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.bans[address] = until
+	b.save()
+}
+
+// IsBanned tells whether the address is banned now
+func (b *BanList) IsBanned(address string) bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	until, ok := b.bans[address]
+	if !ok {
+		return false
+	}
+	if time.Now().Before(until) {
+		return true
+	}
+	delete(b.bans, address)
+	b.save()
+	return false
+}
+
+// Unban lifts the ban on the address, or on every address if it is "", and returns how many
+// bans were lifted
+func (b *BanList) Unban(address string) int {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	lifted := 0
+	for banned := range b.bans {
+		if address == "" || address == banned {
+			delete(b.bans, banned)
+			lifted++
+		}
+	}
+	if lifted > 0 {
+		b.save()
+	}
+	return lifted
+}
+
+// All returns the bans that have not ended yet, with the time each ends
+func (b *BanList) All() map[string]time.Time {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	now := time.Now()
+	all := make(map[string]time.Time)
+	for address, until := range b.bans {
+		if until.After(now) {
+			all[address] = until
+		}
+	}
+	return all
+}
+
+// save writes the bans to the file, the caller holds the mutex
+func (b *BanList) save() {
+	if b.filename == "" {
+		return
+	}
+	data, err := json.Marshal(b.bans)
+	if err != nil {
+		controllerLogger.Errorf("BanList.save() could not encode bans: %v", err)
+		return
+	}
+	err = ioutil.WriteFile(b.filename, data, 0644)
+	if err != nil {
+		controllerLogger.Errorf("BanList.save() could not write %s: %v", b.filename, err)
+	}
+}
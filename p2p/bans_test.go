@@ -0,0 +1,144 @@
+package p2p
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestMisbehaviorDecay(t *testing.T) {
+	start := time.Now()
+	m := new(misbehavior)
+	if score := m.add(40, start); score != 40 {
+		t.Errorf("First offense scored %f", score)
+	}
+	if score := m.decayed(start.Add(ScoreHalfLife)); math.Abs(score-20) > 0.001 {
+		t.Errorf("Score after a half life is %f rather than 20", score)
+	}
+	if score := m.add(10, start.Add(2*ScoreHalfLife)); math.Abs(score-20) > 0.001 {
+		t.Errorf("Score after two half lives and another offense is %f rather than 20", score)
+	}
+}
+
+func TestGossipOffenses(t *testing.T) {
+	start := time.Now()
+
+	// A peer passing on gossip for an hour, with the duplicate, late and rejected messages that
+	// come with it, is never near a ban
+	gossip := new(misbehavior)
+	for second := 0; second < 3600; second++ {
+		now := start.Add(time.Duration(second) * time.Second)
+		for i := 0; i < 50; i++ {
+			gossip.add(OffensePenalties[OffenseDuplicate], now)
+		}
+		for i := 0; i < 5; i++ {
+			gossip.add(OffensePenalties[OffenseReplayed], now)
+		}
+		for i := 0; i < 2; i++ {
+			gossip.add(OffensePenalties[OffenseRejected], now)
+		}
+	}
+	if score := gossip.decayed(start.Add(time.Hour)); score >= BanScore/2 {
+		t.Errorf("Gossip scored %f", score)
+	}
+
+	// A peer replaying a flood of old messages for a minute is banned
+	flood := new(misbehavior)
+	score := 0.0
+	for second := 0; second < 60; second++ {
+		for i := 0; i < 2000; i++ {
+			score = flood.add(OffensePenalties[OffenseReplayed], start.Add(time.Duration(second)*time.Second))
+		}
+	}
+	if score < BanScore {
+		t.Errorf("A flood of replayed messages scored %f", score)
+	}
+}
+
+func TestBanListPersists(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "bans.json")
+
+	bans := NewBanList(filename)
+	bans.Ban("1.2.3.4", time.Now().Add(time.Hour))
+	bans.Ban("5.6.7.8", time.Now().Add(time.Hour))
+	bans.Ban("9.9.9.9", time.Now().Add(-time.Second))
+	if !bans.IsBanned("1.2.3.4") || bans.IsBanned("9.9.9.9") || bans.IsBanned("4.3.2.1") {
+		t.Errorf("Wrong bans: %v", bans.All())
+	}
+
+	loaded := NewBanList(filename)
+	if all := loaded.All(); len(all) != 2 || !loaded.IsBanned("5.6.7.8") {
+		t.Errorf("Bans were not kept over a restart: %v", all)
+	}
+
+	if lifted := loaded.Unban("1.2.3.4"); lifted != 1 || loaded.IsBanned("1.2.3.4") {
+		t.Errorf("Unban lifted %d bans", lifted)
+	}
+	if lifted := loaded.Unban(""); lifted != 1 || len(NewBanList(filename).All()) != 0 {
+		t.Errorf("Unban of all lifted %d bans", lifted)
+	}
+}
+
+func newBanningController() *Controller {
+	c := new(Controller)
+	c.logger = controllerLogger
+	c.connections = new(ConnectionManager).Init()
+	c.specialPeers = make(map[string]*Peer)
+	c.bans = NewBanList("")
+	c.misbehavior = make(map[string]*misbehavior)
+	return c
+}
+
+func TestControllerPenalize(t *testing.T) {
+	c := newBanningController()
+	regular := newIncomingActiveConnection(newPeer("1.2.3.4", "8108", RegularPeer))
+	special := newIncomingActiveConnection(newPeer("5.6.7.8", "8108", SpecialPeerConfig))
+	c.connections.Add(regular)
+	c.connections.Add(special)
+
+	// A message that can not be read now and then is forgiven
+	for i := 0; i < 5; i++ {
+		c.handleCommand(CommandPenalize{PeerHash: regular.peer.Hash, Offense: OffenseInvalid})
+	}
+	if c.bans.IsBanned("1.2.3.4") {
+		t.Fatal("Peer was banned for a few invalid messages")
+	}
+
+	for i := 0; i < 2; i++ {
+		c.handleCommand(CommandPenalize{PeerHash: regular.peer.Hash, Offense: OffenseOversized})
+		c.handleCommand(CommandPenalize{PeerHash: special.peer.Hash, Offense: OffenseOversized})
+	}
+	if !c.bans.IsBanned("1.2.3.4") {
+		t.Error("Peer that sent oversized messages was not banned")
+	}
+	if c.bans.IsBanned("5.6.7.8") {
+		t.Error("Special peer was banned")
+	}
+	if _, scored := c.misbehavior["1.2.3.4"]; scored {
+		t.Error("Banned peer kept its misbehavior score")
+	}
+	if until := c.Bans()["1.2.3.4"]; until.Before(time.Now().Add(BanDuration - time.Minute)) {
+		t.Errorf("Peer is banned until %v, not for the ban duration", until)
+	}
+}
+
+func TestControllerRefusesBannedPeers(t *testing.T) {
+	c := newBanningController()
+	c.bans.Ban("1.2.3.4", time.Now().Add(time.Hour))
+
+	c.handleCommand(CommandDialPeer{peer: *newPeer("1.2.3.4", "8108", RegularPeer)})
+	if c.connections.Count() != 0 {
+		t.Error("Dialed a banned peer")
+	}
+	if c.Unban("1.2.3.4") != 1 || c.bans.IsBanned("1.2.3.4") {
+		t.Error("Ban was not lifted")
+	}
+}
@@ -5,12 +5,12 @@
 package p2p
 
 import (
-	"encoding/gob"
 	"fmt"
 	"hash/crc32"
 	"io"
 	"net"
 	"os"
+	"sync/atomic"
 	"time"
 
 	"github.com/FactomProject/factomd/common/primitives"
@@ -33,21 +33,29 @@ type Connection struct {
 	ReceiveChannel chan interface{}        // Receive means "from the network" Channel receives Parcels and ConnectionCommands
 	ReceiveParcel  chan *Parcel            // Parcels to be handled.
 	// and as "address" for sending messages to specific nodes.
-	encoder         *gob.Encoder      // Wire format is gobs in this version, may switch to binary
-	decoder         *gob.Decoder      // Wire format is gobs in this version, may switch to binary
+	encoder         *ParcelEncoder    // Writes gobs until the peer shows it reads binary frames, see encoding.go
+	decoder         *ParcelDecoder    // Reads both gobs and binary frames
 	peer            Peer              // the data structure representing the peer we are talking to. defined in peer.go
 	attempts        int               // reconnection attempts
 	TimeLastpacket  time.Time         // Time we last successfully received a packet or command.
 	timeLastAttempt time.Time         // time of last attempt to connect via dial
 	timeLastPing    time.Time         // time of last ping sent
 	timeLastUpdate  time.Time         // time of last peer update sent
+	timeLastHello   time.Time         // time of last handshake sent
 	timeLastStatus  time.Time         // last time we printed our status for debugging.
 	timeLastMetrics time.Time         // last time we updated metrics
 	state           uint8             // Current state of the connection. Private. Only communication
 	isOutGoing      bool              // We keep track of outgoing dial() vs incoming accept() connections
 	isPersistent    bool              // Persistent connections we always redail.
 	notes           string            // Notes about the connection, for debugging (eg: error)
+	peerKey         string            // ID of the key the peer encrypts the connection under, "" if not encrypted
+	peerHeight      uint32            // Directory block height from the peer's last handshake, read by the controller
+	handshaken      int32             // Set to 1 once the peer has sent a handshake, read by the controller
+	peerVersion     uint16            // Protocol version of the last valid parcel from the peer
 	metrics         ConnectionMetrics // Metrics about this connection
+	sendQueue       sendQueue         // Parcels waiting to be sent, by priority, see sendqueue.go
+	upload          *Limiter          // Caps the bytes sent to the peer, nil for no cap, see bandwidth.go
+	download        *Limiter          // Caps the bytes read from the peer, nil for no cap
 
 	// logging
 	logger *log.Entry
@@ -154,6 +162,7 @@ const (
 func (c *Connection) InitWithConn(conn net.Conn, peer Peer) *Connection {
 	c.conn = conn
 	c.isOutGoing = false // InitWithConn is called by controller's accept() loop
+	c.peerKey = PeerKeyID(conn)
 	c.commonInit(peer)
 	c.isPersistent = false
 	c.goOnline()
@@ -189,6 +198,22 @@ func (c *Connection) Notes() string {
 	return c.notes
 }
 
+// PeerKeyID returns the ID of the key the peer encrypts the connection under, or "" if the
+// connection is not encrypted
+func (c *Connection) PeerKeyID() string {
+	return c.peerKey
+}
+
+// HasHandshake tells whether the peer has sent a handshake on this connection
+func (c *Connection) HasHandshake() bool {
+	return atomic.LoadInt32(&c.handshaken) == 1
+}
+
+// PeerHeight returns the directory block height the peer told in its last handshake
+func (c *Connection) PeerHeight() uint32 {
+	return atomic.LoadUint32(&c.peerHeight)
+}
+
 //////////////////////////////
 //
 // Private API
@@ -206,6 +231,8 @@ func (c *Connection) commonInit(peer Peer) {
 	c.SendChannel = make(chan interface{}, StandardChannelSize)
 	c.ReceiveChannel = make(chan interface{}, StandardChannelSize)
 	c.ReceiveParcel = make(chan *Parcel, StandardChannelSize)
+	c.upload = NewLimiter(PeerUploadRate)
+	c.download = NewLimiter(PeerDownloadRate)
 	c.metrics = ConnectionMetrics{MomentConnected: time.Now()}
 	c.timeLastMetrics = time.Now()
 	c.timeLastAttempt = time.Now()
@@ -266,6 +293,9 @@ func (c *Connection) runLoop() {
 		case ConnectionOnline:
 			p2pConnectionRunLoopOnline.Inc()
 			c.pingPeer() // sends a ping periodically if things have been quiet
+			if c.handshakeDue() {
+				c.sendHandshake() // keeps the peer told of our height
+			}
 			if PeerSaveInterval < time.Since(c.timeLastUpdate) {
 				c.updatePeer() // every PeerSaveInterval * 0.90 we send an update peer to the controller.
 			}
@@ -332,11 +362,56 @@ func (c *Connection) dial() bool {
 	address := c.peer.AddressPort()
 	// conn, err := net.Dial("tcp", c.peer.Address)
 	conn, err := net.DialTimeout("tcp", address, time.Second*10)
-	if nil == err {
+	if nil != err {
+		return false
+	}
+	if nil == LocalNodeKey {
 		c.conn = conn
+		c.peerKey = ""
+		return true
+	}
+
+	secured, err := SecureOutgoing(conn, LocalNodeKey, c.peer.key)
+	if nil == err {
+		c.conn = secured
+		c.peerKey = PeerKeyID(secured)
+		c.logger.WithField("peer_key", c.peerKey).Debug("Encrypted the connection")
 		return true
 	}
-	return false
+	conn.Close()
+	if !c.mayFallBack(err) {
+		c.notes = fmt.Sprintf("Handshake failed, and the peer may not fall back to plain: %v", err)
+		c.logger.Warnf("Connection.dial() %s", c.notes)
+		return false
+	}
+
+	// Most likely a peer that does not encrypt, dial it again without a handshake.  The next dial
+	// tries the handshake first again.
+	c.logger.Debugf("Connection.dial() handshake failed, falling back to a plain connection: %v", err)
+	conn, err = net.DialTimeout("tcp", address, time.Second*10)
+	if nil != err {
+		return false
+	}
+	c.conn = conn
+	c.peerKey = ""
+	return true
+}
+
+// mustEncrypt tells whether the connection may not fall back to plain
+func (c *Connection) mustEncrypt() bool {
+	if RegularPeer == c.peer.Type {
+		return false
+	}
+	return RequireEncryptedSpecialPeers || "" != c.peer.key
+}
+
+// mayFallBack tells whether the connection may be dialed again without encryption after the
+// handshake failed with err
+func (c *Connection) mayFallBack(err error) bool {
+	if _, wrongKey := err.(*KeyMismatchError); wrongKey {
+		return false
+	}
+	return AllowPlainFallback && !c.mustEncrypt()
 }
 
 // Called when we are online and connected to the peer.
@@ -344,8 +419,10 @@ func (c *Connection) goOnline() {
 	c.logger.Info("Connected to a remote peer")
 	p2pConnectionOnlineCall.Inc()
 	now := time.Now()
-	c.encoder = gob.NewEncoder(c.conn)
-	c.decoder = gob.NewDecoder(c.conn)
+	c.encoder = NewParcelEncoder(c.conn)
+	c.decoder = NewParcelDecoder(c.conn)
+	atomic.StoreInt32(&c.handshaken, 0)
+	c.peerVersion = 0
 	c.attempts = 0
 	c.timeLastPing = now
 	c.timeLastAttempt = now
@@ -358,9 +435,11 @@ func (c *Connection) goOnline() {
 	c.handleNetErrors(true)
 	// Probably shouldn't reset metrics when we go online. (Eg: say after a temp network problem)
 	// c.metrics = ConnectionMetrics{MomentConnected: now} // Reset metrics
-	// Now ask the other side for the peers they know about.
+	// Tell the other side who we are before anything else, then ask it for the peers it knows about.
+	c.sendHandshake()
 	parcel := NewParcel(CurrentNetwork, []byte("Peer Request"))
 	parcel.Header.Type = TypePeerRequest
+	parcel.SetPriority(PriorityGossip)
 	BlockFreeChannelSend(c.SendChannel, ConnectionParcel{Parcel: *parcel})
 }
 
@@ -389,10 +468,12 @@ func (c *Connection) goShutdown() {
 	c.state = ConnectionShuttingDown
 }
 
-// processSends gets all the messages from the application and sends them out over the network
+// processSends gets all the messages from the application and sends them out over the network,
+// the most urgent first
 func (c *Connection) processSends() {
 	p2pProcessSendsGauge.Inc()
 	defer p2pProcessSendsGauge.Dec()
+	defer c.sendQueue.clear()
 
 	defer func() {
 		if r := recover(); r != nil {
@@ -405,29 +486,49 @@ func (c *Connection) processSends() {
 	for ConnectionClosed != c.state && c.state != ConnectionShuttingDown {
 		// note(c.peer.PeerIdent(), "Connection.processSends() called. Items in send channel: %d State: %s", len(c.SendChannel), c.ConnectionState())
 	conloop:
-		for ConnectionOnline == c.state && len(c.SendChannel) > 0 {
+		for ConnectionOnline == c.state {
 			// This was blocking. By checking the length of the channel before entering, this does not block.
 			// The problem was this routine was blocked on a closed connection. Idealling we do want to block
 			// on a 0 length channel, and this is still possible if use a select and close the channel when we
 			// close the connection.
-			message := <-c.SendChannel
-			switch message.(type) {
-			case ConnectionParcel:
-				if nil == c.decoder || nil == c.conn {
-					break conloop
-				}
-				parameters := message.(ConnectionParcel)
-				c.sendParcel(parameters.Parcel)
-			case ConnectionCommand:
-				parameters := message.(ConnectionCommand)
-				c.Commands <- &parameters
-			default:
+			// Everything waiting is queued before each send, so an urgent parcel that just came in goes
+			// ahead of the less urgent ones queued before it.
+			c.queueSends()
+			if nil == c.decoder || nil == c.conn {
+				break conloop
 			}
+			next, ok := c.sendQueue.peek()
+			if !ok {
+				break conloop
+			}
+			// The wait for the upload rate comes before the parcel is taken off the queue, and
+			// whatever came in meanwhile is queued after it, so an urgent parcel is not held up
+			// behind the one that was next when the wait began.
+			throttle(c.upload, totalUpload, int(next.Header.Length), "upload")
+			c.queueSends()
+			parcel, _ := c.sendQueue.pop()
+			c.sendParcel(parcel)
 		}
 		time.Sleep(100 * time.Millisecond)
 	}
 }
 
+// queueSends moves the parcels waiting on the SendChannel into the send queue
+func (c *Connection) queueSends() {
+	for len(c.SendChannel) > 0 {
+		message := <-c.SendChannel
+		switch message.(type) {
+		case ConnectionParcel:
+			parameters := message.(ConnectionParcel)
+			c.sendQueue.push(parameters.Parcel)
+		case ConnectionCommand:
+			parameters := message.(ConnectionCommand)
+			c.Commands <- &parameters
+		default:
+		}
+	}
+}
+
 func (c *Connection) handleCommand() {
 	select {
 	case command := <-c.Commands:
@@ -513,6 +614,7 @@ func (c *Connection) processReceives() {
 				time.Sleep(500 * time.Millisecond)
 				continue
 			case nil: // successfully decoded
+				throttle(c.download, totalDownload, int(message.Header.Length), "download")
 				c.metrics.BytesReceived += message.Header.Length
 				c.metrics.MessagesReceived += 1
 				message.Header.PeerAddress = c.peer.Address
@@ -585,6 +687,11 @@ func (c *Connection) handleParcel(parcel Parcel) {
 		c.peer.LastContact = time.Now() // We only update for valid messages (incluidng pings and heartbeats)
 		c.attempts = 0                  // reset since we are clearly in touch now.
 		c.peer.merit()                  // Increase peer quality score.
+		c.peerVersion = parcel.Header.Version
+		if parcel.Header.Version >= ProtocolVersionBinary && nil != c.encoder && !c.encoder.IsBinary() {
+			c.logger.Debugf("Connection.handleParcel() peer runs version %d, switching to binary frames", parcel.Header.Version)
+			c.encoder.UseBinary()
+		}
 		c.logger.Debugf("Connection.handleParcel() got ParcelValid %s", parcel.MessageType())
 		c.handleParcelTypes(parcel) // handles both network commands and application messages
 		return
@@ -643,6 +750,8 @@ func (c *Connection) handleParcelTypes(parcel Parcel) {
 		BlockFreeChannelSend(c.SendChannel, ConnectionParcel{Parcel: *pong})
 	case TypePong: // all we need is the timestamp which is set already
 		return
+	case TypeHandshake:
+		c.handleHandshake(parcel)
 	case TypePeerRequest:
 		BlockFreeChannelSend(c.ReceiveChannel, ConnectionParcel{Parcel: parcel}) // Controller handles these.
 	case TypePeerResponse:
@@ -664,6 +773,55 @@ func (c *Connection) handleParcelTypes(parcel Parcel) {
 	}
 }
 
+// handshakeDue tells if the handshake should be sent again.  Peers older than
+// ProtocolVersionHandshake do not know the parcel type, so they only get the one sent as the
+// connection went online, before we knew their version.
+func (c *Connection) handshakeDue() bool {
+	return c.peerVersion >= ProtocolVersionHandshake && HandshakeInterval < time.Since(c.timeLastHello)
+}
+
+func (c *Connection) sendHandshake() {
+	c.timeLastHello = time.Now()
+	parcel, err := NewHandshakeParcel()
+	if err != nil {
+		c.logger.Errorf("sendHandshake() %v", err)
+		return
+	}
+	BlockFreeChannelSend(c.SendChannel, ConnectionParcel{Parcel: *parcel})
+}
+
+// handleHandshake takes in what the peer tells about itself, and drops the peer if we can not
+// talk to it
+func (c *Connection) handleHandshake(parcel Parcel) {
+	hello := new(Handshake)
+	err := hello.UnmarshalBinary(parcel.Payload)
+	if err != nil {
+		c.logger.Warnf("Connection.handleHandshake() invalid handshake: %v", err)
+		c.peer.demerit()
+		return
+	}
+	err = hello.Compatible()
+	if err != nil {
+		if hello.NodeID == NodeID {
+			c.peer.QualityScore = MinumumQualityScore - 50 // Ban ourselves, as for loopback parcels
+		}
+		c.notes = fmt.Sprintf("Incompatible handshake: %v", err)
+		c.logger.Infof("Connection(%s) shutting down: %s", c.peer.AddressPort(), c.notes)
+		c.attempts = MaxNumberOfRedialAttempts + 50 // so we don't redial an incompatible peer
+		c.goShutdown()
+		return
+	}
+
+	c.peer.NodeID = hello.NodeID
+	c.peer.Port = hello.ListenPort
+	atomic.StoreUint32(&c.peerHeight, hello.Height)
+	atomic.StoreInt32(&c.handshaken, 1)
+	if hello.Has(CapabilityBinaryWire) && nil != c.encoder && !c.encoder.IsBinary() {
+		c.encoder.UseBinary()
+	}
+	c.logger.Debugf("Connection.handleHandshake() peer %s is at height %d", c.peer.PeerIdent(), hello.Height)
+}
+
 func (c *Connection) pingPeer() {
 	durationLastContact := time.Since(c.peer.LastContact)
 	durationLastPing := time.Since(c.timeLastPing)
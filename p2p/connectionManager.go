@@ -107,6 +107,24 @@ func (cm *ConnectionManager) GetRandom() *Connection {
 	return onlineActive[rand.Intn(len(onlineActive))]
 }
 
+// Get a random connection among the online, active peers that have told us in their handshake a
+// height at or above our own, so the requests are spread over them rather than all going to the
+// one claiming the highest.  Returns nil if no peer has.
+func (cm *ConnectionManager) GetUpToDate() *Connection {
+	var height uint32
+	if nil != LocalHeight {
+		height = LocalHeight()
+	}
+	upToDate := cm.getMatching(func(c *Connection) bool {
+		return c.IsOnline() && c.metrics.BytesReceived > 0 && c.HasHandshake() && c.PeerHeight() >= height
+	})
+	if len(upToDate) == 0 {
+		return nil
+	}
+
+	return upToDate[rand.Intn(len(upToDate))]
+}
+
 // Get connections for all online, active regular peers, but in random order.
 func (cm *ConnectionManager) GetAllRegular() []*Connection {
 
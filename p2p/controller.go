@@ -55,9 +55,11 @@ type Controller struct {
 	lastDiscoveryRequest time.Time
 	NodeID               uint64
 	lastStatusReport     time.Time
-	lastPeerRequest      time.Time        // Last time we asked peers about the peers they know about.
-	specialPeers         map[string]*Peer // special peers (from config file and from the command line params) by peer address
-	partsAssembler       *PartsAssembler  // a data structure that assembles full messages from received message parts
+	lastPeerRequest      time.Time               // Last time we asked peers about the peers they know about.
+	specialPeers         map[string]*Peer        // special peers (from config file and from the command line params) by peer address
+	partsAssembler       *PartsAssembler         // a data structure that assembles full messages from received message parts
+	bans                 *BanList                // banned peer addresses, see bans.go
+	misbehavior          map[string]*misbehavior // misbehavior scores by peer address
 
 	// logging
 	logger *log.Entry
@@ -76,6 +78,16 @@ type ControllerInit struct {
 	ConnectionMetricsChannel chan interface{} // Channel on which we put the connection metrics map, periodically.
 	LogPath                  string           // Path for logs
 	LogLevel                 string           // Logging level
+	NodeKey                  *NodeKey         // Key pair to encrypt connections under, nil to not encrypt them
+	EncryptSpecialPeers      bool             // flag to indicate connections with special peers must be encrypted
+	PlainFallback            bool             // flag to indicate peers that can not take the TLS handshake are dialed again without it
+	Height                   func() uint32    // Tells the height of our highest saved directory block
+	BansFile                 string           // Path to file to find / save banned peers
+	BanDuration              time.Duration    // How long misbehaving peers are banned for, 0 for the default
+	PeerUploadRate           int              // Bytes per second sent to each peer, 0 for no cap
+	PeerDownloadRate         int              // Bytes per second read from each peer, 0 for no cap
+	TotalUploadRate          int              // Bytes per second sent to all peers together, 0 for no cap
+	TotalDownloadRate        int              // Bytes per second read from all peers together, 0 for no cap
 }
 
 // CommandDialPeer is used to instruct the Controller to dial a peer address
@@ -132,6 +144,25 @@ func (e *CommandBan) String() string {
 	return str
 }
 
+// CommandPenalize is used to instruct the Controller to add an offense to a peer's misbehavior score
+type CommandPenalize struct {
+	PeerHash string
+	Offense  Offense
+}
+
+func (e *CommandPenalize) JSONByte() ([]byte, error) {
+	return primitives.EncodeJSON(e)
+}
+
+func (e *CommandPenalize) JSONString() (string, error) {
+	return primitives.EncodeJSONString(e)
+}
+
+func (e *CommandPenalize) String() string {
+	str, _ := e.JSONString()
+	return str
+}
+
 // CommandDisconnect is used to instruct the Controller to disconnect from a peer
 type CommandDisconnect struct {
 	PeerHash string
@@ -167,6 +198,7 @@ func (c *Controller) Init(ci ControllerInit) *Controller {
 	c.logger.WithField("controller_init", ci).Debugf("Initializing network controller")
 	RandomGenerator = rand.New(rand.NewSource(time.Now().UnixNano()))
 	NodeID = uint64(RandomGenerator.Int63()) // This is a global used by all connections
+	LocalHeight = ci.Height
 	c.keepRunning = true
 	c.commandChannel = make(chan interface{}, StandardChannelSize) // Commands from App
 	c.FromNetwork = make(chan interface{}, StandardChannelSize)    // Channel to the app for network data
@@ -182,10 +214,25 @@ func (c *Controller) Init(ci ControllerInit) *Controller {
 	CurrentNetwork = ci.Network
 	OnlySpecialPeers = ci.Exclusive || ci.ExclusiveIn
 	AllowUnknownIncomingPeers = !ci.ExclusiveIn
+	LocalNodeKey = ci.NodeKey
+	RequireEncryptedSpecialPeers = ci.EncryptSpecialPeers && ci.NodeKey != nil
+	AllowPlainFallback = ci.PlainFallback
+	if LocalNodeKey != nil {
+		c.logger.WithField("node_key", LocalNodeKey.ID()).Info("Encrypting peer connections")
+	}
 	c.initSpecialPeers(ci)
 	c.lastDiscoveryRequest = time.Now() // Discovery does its own on startup.
 	c.lastConnectionMetricsUpdate = time.Now()
 	c.partsAssembler = new(PartsAssembler).Init()
+	if ci.BanDuration > 0 {
+		BanDuration = ci.BanDuration
+	}
+	c.bans = NewBanList(ci.BansFile)
+	PeerUploadRate = ci.PeerUploadRate
+	PeerDownloadRate = ci.PeerDownloadRate
+	totalUpload = NewLimiter(ci.TotalUploadRate)
+	totalDownload = NewLimiter(ci.TotalDownloadRate)
+	c.misbehavior = make(map[string]*misbehavior)
 	discovery := new(Discovery).Init(ci.PeersFile, ci.SeedURL)
 	c.discovery = *discovery
 	return c
@@ -225,6 +272,23 @@ func (c *Controller) Ban(peerHash string) {
 	BlockFreeChannelSend(c.commandChannel, CommandBan{PeerHash: peerHash})
 }
 
+// Penalize adds the offense to the misbehavior score of the peer, which is banned once the score
+// gets too high
+func (c *Controller) Penalize(peerHash string, offense Offense) {
+	BlockFreeChannelSend(c.commandChannel, CommandPenalize{PeerHash: peerHash, Offense: offense})
+}
+
+// Bans returns the banned peer addresses, with the time each ban ends
+func (c *Controller) Bans() map[string]time.Time {
+	return c.bans.All()
+}
+
+// Unban lifts the ban on the peer address, or on every address if it is "", and returns how
+// many bans were lifted
+func (c *Controller) Unban(address string) int {
+	return c.bans.Unban(address)
+}
+
 func (c *Controller) Disconnect(peerHash string) {
 	BlockFreeChannelSend(c.commandChannel, CommandDisconnect{PeerHash: peerHash})
 }
@@ -328,11 +392,51 @@ func (c *Controller) acceptLoop(listener net.Listener) {
 			continue
 		}
 
+		if LocalNodeKey != nil {
+			go c.acceptSecured(conn, connLogger) // The handshake must not hold up accepting other peers
+			continue
+		}
+
 		c.AddPeer(conn) // Sends command to add the peer to the peers list
 		connLogger.Infof("Accepting new incoming connection")
 	}
 }
 
+// acceptSecured adds an incoming connection once it has been through the TLS handshake, or has
+// been found to be plain
+func (c *Controller) acceptSecured(conn net.Conn, connLogger *log.Entry) {
+	secured, err := SecureIncoming(conn, LocalNodeKey)
+	if err != nil {
+		connLogger.Infof("Rejecting new connection request: handshake failed: %v", err)
+		_ = conn.Close()
+		return
+	}
+	if ok, reason := c.canAcceptSecured(conn, PeerKeyID(secured)); !ok {
+		connLogger.Infof("Rejecting new connection request: %s", reason)
+		_ = conn.Close()
+		return
+	}
+
+	c.AddPeer(secured) // Sends command to add the peer to the peers list
+	connLogger.WithField("peer_key", PeerKeyID(secured)).Infof("Accepting new incoming connection")
+}
+
+// canAcceptSecured checks the key an incoming special peer encrypts under, "" if it does not
+func (c *Controller) canAcceptSecured(conn net.Conn, key string) (bool, string) {
+	for _, peer := range c.specialPeers {
+		if !peer.IsSamePeerAs(conn.RemoteAddr()) {
+			continue
+		}
+		if peer.key != "" && key != peer.key {
+			return false, "special peer did not present the key it is pinned to"
+		}
+		if RequireEncryptedSpecialPeers && key == "" {
+			return false, "special peers must be encrypted"
+		}
+	}
+	return true, ""
+}
+
 func (c *Controller) canConnectTo(conn net.Conn) (bool, string) {
 	if c.connections.incomingCount >= MaxNumberIncomingConnections {
 		return false, "too many incoming connections"
@@ -342,6 +446,10 @@ func (c *Controller) canConnectTo(conn net.Conn) (bool, string) {
 		return false, "not a special peer and unknown incoming connections are not allowed"
 	}
 
+	if address, _, err := net.SplitHostPort(conn.RemoteAddr().String()); err == nil && c.bans.IsBanned(address) {
+		return false, "the peer is banned"
+	}
+
 	return true, ""
 }
 
@@ -375,12 +483,23 @@ func (c *Controller) parseSpecialPeers(peersString string, peerType uint8) []*Pe
 	peerAddresses := strings.FieldsFunc(peersString, parseFunc)
 	peers := make([]*Peer, 0, len(peerAddresses))
 	for _, peerAddress := range peerAddresses {
+		key := ""
+		if at := strings.LastIndex(peerAddress, "@"); at >= 0 {
+			key, peerAddress = strings.ToLower(peerAddress[:at]), peerAddress[at+1:]
+		}
 		address, port, err := net.SplitHostPort(peerAddress)
+		if err == nil && key != "" && !isKeyID(key) {
+			err = fmt.Errorf("%s is not a node key ID", key)
+		}
 		if err != nil {
-			c.logger.Errorf("%s is not a valid peer (%v), use format: 127.0.0.1:8999", peersString, err)
+			c.logger.Errorf("%s is not a valid peer (%v), use format: 127.0.0.1:8999, or <node key ID>@127.0.0.1:8999 to pin its key", peersString, err)
 		} else {
 			peer := new(Peer).Init(address, port, 0, peerType, 0)
 			peer.Source["Local-Configuration"] = time.Now()
+			peer.key = key
+			if key != "" && LocalNodeKey == nil {
+				c.logger.Warnf("Special peer %s is pinned to a key, but connections are not encrypted", peerAddress)
+			}
 			peers = append(peers, peer)
 		}
 	}
@@ -456,6 +575,8 @@ func (c *Controller) route() {
 
 		case RandomPeerFlag: // Find a random peer, send to that peer.
 			c.sendToRandomPeer(parcel)
+		case UpToDatePeerFlag: // Find a random peer with the highest blocks, send to that peer.
+			c.sendToUpToDatePeer(parcel)
 		default: // Check if we're connected to the peer, if not drop message.
 			c.logger.Debugf("Controller.route() Directed Neither Random nor Broadcast: %s Type: %s ", parcel.Header.TargetPeer, parcel.Header.AppType)
 			c.doDirectedSend(parcel)
@@ -490,6 +611,7 @@ func (c *Controller) handleParcelReceive(message interface{}, peerHash string, c
 		// Get selection of peers from discovery
 		response := NewParcel(CurrentNetwork, c.discovery.SharePeers())
 		response.Header.Type = TypePeerResponse
+		response.SetPriority(PriorityGossip)
 		// Send them out to the network - on the connection that requested it!
 		BlockFreeChannelSend(connection.SendChannel, ConnectionParcel{Parcel: *response})
 	case TypePeerResponse:
@@ -520,6 +642,10 @@ func (c *Controller) handleCommand(command interface{}) {
 	switch commandType := command.(type) {
 	case CommandDialPeer: // parameter is the peer address
 		parameters := command.(CommandDialPeer)
+		if c.bans.IsBanned(parameters.peer.Address) {
+			c.logger.Debugf("Not dialing banned peer %s", parameters.peer.AddressPort())
+			return
+		}
 		conn := new(Connection).Init(parameters.peer, parameters.persistent)
 		c.handleNewConnection(conn)
 	case CommandAddPeer: // parameter is a Connection. This message is sent by the accept loop which is in a different goroutine
@@ -542,6 +668,12 @@ func (c *Controller) handleCommand(command interface{}) {
 		parameters := command.(CommandBan)
 		peerHash := parameters.PeerHash
 		c.applicationPeerUpdate(BannedQualityScore, peerHash)
+		if connection, present := c.connections.GetByHash(peerHash); present {
+			c.ban(connection.peer, "banned by the application")
+		}
+	case CommandPenalize:
+		parameters := command.(CommandPenalize)
+		c.penalize(parameters.PeerHash, parameters.Offense)
 	case CommandDisconnect:
 		parameters := command.(CommandDisconnect)
 		connection, present := c.connections.GetByHash(parameters.PeerHash)
@@ -576,10 +708,61 @@ func (c *Controller) applicationPeerUpdate(qualityDelta int32, peerHash string)
 	}
 }
 
+// penalize adds the offense to the misbehavior score of the peer's address, and bans the
+// address if the score reaches BanScore
+func (c *Controller) penalize(peerHash string, offense Offense) {
+	connection, present := c.connections.GetByHash(peerHash)
+	if !present {
+		return
+	}
+	peer := connection.peer
+	penalty := OffensePenalties[offense]
+	m, ok := c.misbehavior[peer.Address]
+	if !ok {
+		m = new(misbehavior)
+		c.misbehavior[peer.Address] = m
+	}
+	score := m.add(penalty, time.Now())
+	c.logger.WithFields(log.Fields{"peer": peer.AddressPort(), "offense": offense.String(), "score": score}).Debug("Penalizing peer")
+
+	if score < BanScore {
+		return
+	}
+	if peer.IsSpecial() {
+		// Special peers are trusted by configuration, so they only lose quality
+		c.applicationPeerUpdate(-int32(penalty), peerHash)
+		return
+	}
+	c.applicationPeerUpdate(BannedQualityScore, peerHash)
+	c.ban(peer, fmt.Sprintf("misbehavior score %.1f after a %s message", score, offense))
+}
+
+// ban bans the peer's address for BanDuration
+func (c *Controller) ban(peer Peer, reason string) {
+	if peer.IsSpecial() {
+		return
+	}
+	until := time.Now().Add(BanDuration)
+	c.bans.Ban(peer.Address, until)
+	delete(c.misbehavior, peer.Address) // A peer starts over once its ban ends
+	c.logger.WithFields(log.Fields{"peer": peer.AddressPort(), "until": until}).Warnf("Banning peer: %s", reason)
+}
+
+// pruneMisbehavior forgets the scores that have decayed to nothing
+func (c *Controller) pruneMisbehavior() {
+	now := time.Now()
+	for address, m := range c.misbehavior {
+		if m.decayed(now) < 1 {
+			delete(c.misbehavior, address)
+		}
+	}
+}
+
 func (c *Controller) managePeers() {
 	managementDuration := time.Since(c.lastPeerManagement)
 	if PeerSaveInterval < managementDuration {
 		c.lastPeerManagement = time.Now()
+		c.pruneMisbehavior()
 		c.logger.Debugf("managePeers() time since last peer management: %s", managementDuration.String())
 		// If it's been awhile, update peers from the DNS seed.
 		discoveryDuration := time.Since(c.lastDiscoveryRequest)
@@ -606,6 +789,7 @@ func (c *Controller) managePeers() {
 			parcelp := NewParcel(CurrentNetwork, []byte("Peer Request"))
 			parcel := *parcelp
 			parcel.Header.Type = TypePeerRequest
+			parcel.SetPriority(PriorityGossip)
 			c.connections.SendToAll(ConnectionParcel{Parcel: parcel})
 		}
 	}
@@ -617,7 +801,7 @@ func (c *Controller) fillOutgoingSlots(openSlots int) {
 	// To avoid dialing "too many" peers, we are keeping a count and only dialing the number of peers we need to add.
 	newPeers := 0
 	for _, peer := range peers {
-		if !c.connections.ConnectedTo(peer.Address) && newPeers < openSlots {
+		if !c.connections.ConnectedTo(peer.Address) && !c.bans.IsBanned(peer.Address) && newPeers < openSlots {
 			c.logger.Debugf("newPeers: %d < openSlots: %d We think we are not already connected to: %s so dialing.", newPeers, openSlots, peer.AddressPort())
 			newPeers = newPeers + 1
 			c.DialPeer(peer, false)
@@ -704,3 +888,15 @@ func (c *Controller) sendToRandomPeer(parcel Parcel) {
 	parcel.Header.TargetPeer = randomConn.peer.Hash
 	c.doDirectedSend(parcel)
 }
+
+func (c *Controller) sendToUpToDatePeer(parcel Parcel) {
+	upToDateConn := c.connections.GetUpToDate()
+	if upToDateConn == nil {
+		c.sendToRandomPeer(parcel) // No peer has told us its height yet
+		return
+	}
+
+	c.logger.Debugf("Controller.route() Directed to up to date peer at height %d Type: %s", upToDateConn.PeerHeight(), parcel.Header.AppType)
+	parcel.Header.TargetPeer = upToDateConn.peer.Hash
+	c.doDirectedSend(parcel)
+}
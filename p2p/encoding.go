@@ -0,0 +1,226 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// Parcels go over the wire in one of two formats.  Every connection starts out writing gobs, which
+// is all that peers before ProtocolVersionBinary can read.  Once a peer sends a valid parcel with
+// a Version of at least ProtocolVersionBinary, the connection writes it binary frames instead.  The
+// reading side takes either format at any point of the stream, so neither peer needs to know when
+// the other one switches.
+//
+// A binary frame is laid out as follows, with every integer big endian:
+//
+//	Marker      1 byte   BinaryFrameMarker, a byte no gob message starts with
+//	Format      1 byte   BinaryFormatVersion, the layout of the rest of the frame
+//	HeaderSize  2 bytes  the number of header bytes that follow
+//	Header      HeaderSize bytes
+//	  Network     4 bytes
+//	  Version     2 bytes
+//	  Type        2 bytes
+//	  Length      4 bytes  the number of payload bytes after the header
+//	  Crc32       4 bytes
+//	  PartNo      2 bytes
+//	  PartsTotal  2 bytes
+//	  NodeID      8 bytes
+//	  TargetPeer, PeerAddress, PeerPort, AppHash, AppType
+//	              each a 2 byte length and that many bytes of UTF-8
+//	Payload     Length bytes
+//
+// A reader skips any header bytes past the fields it knows about, so later formats can add fields
+// at the end of the header and still be read.
+
+const (
+	// BinaryFrameMarker starts every binary frame.  A gob message starts with its length, which is
+	// either a byte below 0x80 or the negated byte count of the length, 0xF8 to 0xFF.
+	BinaryFrameMarker byte = 0xF0
+	// BinaryFormatVersion is the layout of the binary frames written by this package
+	BinaryFormatVersion byte = 1
+)
+
+// binaryHeaderFixedSize is the number of bytes in the fixed size fields of a binary header
+const binaryHeaderFixedSize = 28
+
+// MarshalBinaryParcel returns the parcel as a binary frame
+func MarshalBinaryParcel(p *Parcel) ([]byte, error) {
+	h := &p.Header
+	if h.Length != uint32(len(p.Payload)) {
+		return nil, fmt.Errorf("Parcel header length %d does not match its payload of %d bytes", h.Length, len(p.Payload))
+	}
+	if len(p.Payload) > MaxPayloadSize {
+		return nil, fmt.Errorf("Parcel payload of %d bytes is over the maximum of %d", len(p.Payload), MaxPayloadSize)
+	}
+
+	strs := []string{h.TargetPeer, h.PeerAddress, h.PeerPort, h.AppHash, h.AppType}
+	headerSize := binaryHeaderFixedSize
+	for _, s := range strs {
+		if len(s) > 0xFFFF {
+			return nil, fmt.Errorf("Parcel header field of %d bytes is too long", len(s))
+		}
+		headerSize += 2 + len(s)
+	}
+	if headerSize > 0xFFFF {
+		return nil, fmt.Errorf("Parcel header of %d bytes is too long", headerSize)
+	}
+
+	data := make([]byte, 4+headerSize+len(p.Payload))
+	data[0] = BinaryFrameMarker
+	data[1] = BinaryFormatVersion
+	binary.BigEndian.PutUint16(data[2:], uint16(headerSize))
+	b := data[4:]
+	binary.BigEndian.PutUint32(b[0:], uint32(h.Network))
+	binary.BigEndian.PutUint16(b[4:], h.Version)
+	binary.BigEndian.PutUint16(b[6:], uint16(h.Type))
+	binary.BigEndian.PutUint32(b[8:], h.Length)
+	binary.BigEndian.PutUint32(b[12:], h.Crc32)
+	binary.BigEndian.PutUint16(b[16:], h.PartNo)
+	binary.BigEndian.PutUint16(b[18:], h.PartsTotal)
+	binary.BigEndian.PutUint64(b[20:], h.NodeID)
+	b = b[binaryHeaderFixedSize:]
+	for _, s := range strs {
+		binary.BigEndian.PutUint16(b, uint16(len(s)))
+		copy(b[2:], s)
+		b = b[2+len(s):]
+	}
+	copy(b, p.Payload)
+	return data, nil
+}
+
+// ReadBinaryParcel reads one binary frame from r into p
+func ReadBinaryParcel(r io.Reader, p *Parcel) error {
+	var prefix [4]byte
+	_, err := io.ReadFull(r, prefix[:])
+	if err != nil {
+		return err
+	}
+	if prefix[0] != BinaryFrameMarker {
+		return fmt.Errorf("Binary parcel starts with %#x rather than the frame marker", prefix[0])
+	}
+	if prefix[1] < 1 {
+		return fmt.Errorf("Binary parcel format %d is not supported", prefix[1])
+	}
+
+	header := make([]byte, binary.BigEndian.Uint16(prefix[2:]))
+	_, err = io.ReadFull(r, header)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	if len(header) < binaryHeaderFixedSize {
+		return fmt.Errorf("Binary parcel header of %d bytes is too short", len(header))
+	}
+
+	h := ParcelHeader{}
+	h.Network = NetworkID(binary.BigEndian.Uint32(header[0:]))
+	h.Version = binary.BigEndian.Uint16(header[4:])
+	h.Type = ParcelCommandType(binary.BigEndian.Uint16(header[6:]))
+	h.Length = binary.BigEndian.Uint32(header[8:])
+	h.Crc32 = binary.BigEndian.Uint32(header[12:])
+	h.PartNo = binary.BigEndian.Uint16(header[16:])
+	h.PartsTotal = binary.BigEndian.Uint16(header[18:])
+	h.NodeID = binary.BigEndian.Uint64(header[20:])
+	rest := header[binaryHeaderFixedSize:]
+	for _, s := range []*string{&h.TargetPeer, &h.PeerAddress, &h.PeerPort, &h.AppHash, &h.AppType} {
+		if len(rest) < 2 {
+			return fmt.Errorf("Binary parcel header is missing fields")
+		}
+		n := int(binary.BigEndian.Uint16(rest))
+		if len(rest) < 2+n {
+			return fmt.Errorf("Binary parcel header field of %d bytes overruns the header", n)
+		}
+		*s = string(rest[2 : 2+n])
+		rest = rest[2+n:]
+	}
+	// Whatever is left are fields of a later format
+
+	if h.Length > MaxPayloadSize {
+		return fmt.Errorf("Binary parcel payload of %d bytes is over the maximum of %d", h.Length, MaxPayloadSize)
+	}
+	payload := make([]byte, h.Length)
+	_, err = io.ReadFull(r, payload)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	p.Header = h
+	p.Payload = payload
+	return nil
+}
+
+// unexpectedEOF reports a frame cut off part way as such, rather than as the end of the stream
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// ParcelEncoder writes parcels to a stream as gobs, or as binary frames once UseBinary is called
+type ParcelEncoder struct {
+	writer io.Writer
+	gob    *gob.Encoder
+	binary int32 // Set to 1 by UseBinary, which may be called while another goroutine encodes
+}
+
+func NewParcelEncoder(w io.Writer) *ParcelEncoder {
+	e := new(ParcelEncoder)
+	e.writer = w
+	e.gob = gob.NewEncoder(w)
+	return e
+}
+
+// UseBinary switches the encoder to binary frames, for a peer that has shown it can read them
+func (e *ParcelEncoder) UseBinary() {
+	atomic.StoreInt32(&e.binary, 1)
+}
+
+// IsBinary tells whether the encoder writes binary frames
+func (e *ParcelEncoder) IsBinary() bool {
+	return atomic.LoadInt32(&e.binary) == 1
+}
+
+func (e *ParcelEncoder) Encode(p Parcel) error {
+	if !e.IsBinary() {
+		return e.gob.Encode(p)
+	}
+	data, err := MarshalBinaryParcel(&p)
+	if err != nil {
+		return err
+	}
+	_, err = e.writer.Write(data)
+	return err
+}
+
+// ParcelDecoder reads parcels from a stream holding gobs, binary frames, or a mix of the two
+type ParcelDecoder struct {
+	reader *bufio.Reader
+	gob    *gob.Decoder
+}
+
+func NewParcelDecoder(r io.Reader) *ParcelDecoder {
+	d := new(ParcelDecoder)
+	d.reader = bufio.NewReader(r)
+	// The gob decoder reads from the same buffer, as it only wraps readers without one
+	d.gob = gob.NewDecoder(d.reader)
+	return d
+}
+
+func (d *ParcelDecoder) Decode(p *Parcel) error {
+	first, err := d.reader.Peek(1)
+	if err != nil {
+		return err
+	}
+	if first[0] == BinaryFrameMarker {
+		return ReadBinaryParcel(d.reader, p)
+	}
+	return d.gob.Decode(p)
+}
@@ -0,0 +1,144 @@
+package p2p_test
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"reflect"
+	"testing"
+
+	. "github.com/FactomProject/factomd/p2p"
+)
+
+func testParcels() []Parcel {
+	p1 := NewParcel(MainNet, []byte("a message payload"))
+	p1.Header.TargetPeer = "some-peer-hash"
+	p1.Header.NodeID = 12345
+	p1.Header.PeerAddress = "10.0.0.1"
+	p1.Header.AppHash = "0123456789abcdef"
+	p1.Header.AppType = "Ack"
+
+	p2 := NewParcel(TestNet, []byte("Ping"))
+	p2.Header.Type = TypePing
+
+	parts := ParcelsForPayload(LocalNet, bytes.Repeat([]byte{0xF0}, 1000))
+	return []Parcel{*p1, *p2, parts[0]}
+}
+
+func TestBinaryParcelRoundTrip(t *testing.T) {
+	for i, p := range testParcels() {
+		data, err := MarshalBinaryParcel(&p)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if data[0] != BinaryFrameMarker || data[1] != BinaryFormatVersion {
+			t.Errorf("Parcel %d does not start with the frame marker and format", i)
+		}
+
+		var read Parcel
+		err = ReadBinaryParcel(bytes.NewReader(data), &read)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !reflect.DeepEqual(read, p) {
+			t.Errorf("Parcel %d changed over the wire\n%+v\n%+v", i, p, read)
+		}
+
+		// Every cut short frame is an error
+		for n := 1; n < len(data); n++ {
+			err = ReadBinaryParcel(bytes.NewReader(data[:n]), &read)
+			if err == nil || err == io.EOF {
+				t.Fatalf("Parcel %d cut to %d bytes read with %v", i, n, err)
+			}
+		}
+	}
+
+	p := NewParcel(MainNet, []byte("payload"))
+	p.Header.Length++
+	_, err := MarshalBinaryParcel(p)
+	if err == nil {
+		t.Error("Marshalled a parcel with the wrong length")
+	}
+}
+
+func TestBinaryParcelLaterFormat(t *testing.T) {
+	p := testParcels()[0]
+	data, err := MarshalBinaryParcel(&p)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	// A later format with an extra header field is still read
+	headerSize := int(data[2])<<8 | int(data[3])
+	later := append([]byte{}, data[:4+headerSize]...)
+	later = append(later, 0xAA, 0xBB)
+	later = append(later, data[4+headerSize:]...)
+	later[1] = BinaryFormatVersion + 1
+	later[2], later[3] = byte((headerSize+2)>>8), byte(headerSize+2)
+
+	var read Parcel
+	err = ReadBinaryParcel(bytes.NewReader(later), &read)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(read, p) {
+		t.Errorf("Parcel changed over the wire\n%+v\n%+v", p, read)
+	}
+}
+
+func TestParcelEncodingCompatibility(t *testing.T) {
+	parcels := testParcels()
+
+	// Until switched, the encoder writes gobs an old peer reads
+	var buf bytes.Buffer
+	e := NewParcelEncoder(&buf)
+	for _, p := range parcels {
+		err := e.Encode(p)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	old := gob.NewDecoder(&buf)
+	for i, p := range parcels {
+		var read Parcel
+		err := old.Decode(&read)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		if !reflect.DeepEqual(read, p) {
+			t.Errorf("Gob parcel %d changed over the wire", i)
+		}
+	}
+
+	// The decoder reads a stream switching to binary part way
+	buf.Reset()
+	e = NewParcelEncoder(&buf)
+	e.Encode(parcels[0])
+	e.Encode(parcels[1])
+	e.UseBinary()
+	if !e.IsBinary() {
+		t.Fatal("Encoder did not switch to binary")
+	}
+	for _, p := range parcels {
+		err := e.Encode(p)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+
+	d := NewParcelDecoder(&buf)
+	for i, p := range append(parcels[:2:2], parcels...) {
+		var read Parcel
+		err := d.Decode(&read)
+		if err != nil {
+			t.Fatalf("Parcel %d - %v", i, err)
+		}
+		if !reflect.DeepEqual(read, p) {
+			t.Errorf("Parcel %d changed over the wire\n%+v\n%+v", i, p, read)
+		}
+	}
+	var read Parcel
+	if d.Decode(&read) != io.EOF {
+		t.Error("Read past the end of the stream")
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+)
+
+// Peers from ProtocolVersionHandshake on send a TypeHandshake parcel as soon as a connection goes
+// online, and again every HandshakeInterval to peers whose parcels carry ProtocolVersionHandshake
+// or later.  It tells the network and version the peer runs, so an incompatible peer is dropped
+// before any application message, and the height of its directory blocks, so requests for
+// missing blocks go to peers that have them.
+//
+// The payload is laid out as follows, with every integer big endian:
+//
+//	Network       4 bytes
+//	Version       2 bytes
+//	NodeID        8 bytes
+//	Height        4 bytes  the height of the peer's highest saved directory block
+//	Capabilities  4 bytes  the Capability flags of the peer
+//	ListenPort    a 2 byte length and that many bytes
+//
+// A reader ignores any bytes after these, so later versions can add fields at the end.
+
+// Capability flags of a peer
+const (
+	CapabilityBinaryWire uint32 = 1 << iota // reads binary frames, see encoding.go
+	CapabilityEncryption                    // takes TLS handshakes, see security.go
+)
+
+// handshakeFixedSize is the number of bytes in the fixed size fields of a handshake
+const handshakeFixedSize = 22
+
+// Handshake is what a peer tells about itself when a connection goes online
+type Handshake struct {
+	Network      NetworkID
+	Version      uint16
+	NodeID       uint64
+	Height       uint32
+	Capabilities uint32
+	ListenPort   string
+}
+
+// NewHandshake returns the handshake of this node
+func NewHandshake() *Handshake {
+	h := new(Handshake)
+	h.Network = CurrentNetwork
+	h.Version = ProtocolVersion
+	h.NodeID = NodeID
+	if nil != LocalHeight {
+		h.Height = LocalHeight()
+	}
+	h.Capabilities = CapabilityBinaryWire
+	if nil != LocalNodeKey {
+		h.Capabilities |= CapabilityEncryption
+	}
+	h.ListenPort = NetworkListenPort
+	return h
+}
+
+func (h *Handshake) MarshalBinary() ([]byte, error) {
+	if len(h.ListenPort) > 0xFFFF {
+		return nil, fmt.Errorf("Handshake listen port of %d bytes is too long", len(h.ListenPort))
+	}
+	data := make([]byte, handshakeFixedSize+2+len(h.ListenPort))
+	binary.BigEndian.PutUint32(data[0:], uint32(h.Network))
+	binary.BigEndian.PutUint16(data[4:], h.Version)
+	binary.BigEndian.PutUint64(data[6:], h.NodeID)
+	binary.BigEndian.PutUint32(data[14:], h.Height)
+	binary.BigEndian.PutUint32(data[18:], h.Capabilities)
+	binary.BigEndian.PutUint16(data[22:], uint16(len(h.ListenPort)))
+	copy(data[24:], h.ListenPort)
+	return data, nil
+}
+
+func (h *Handshake) UnmarshalBinary(data []byte) error {
+	if len(data) < handshakeFixedSize+2 {
+		return fmt.Errorf("Handshake of %d bytes is too short", len(data))
+	}
+	n := int(binary.BigEndian.Uint16(data[22:]))
+	if len(data) < handshakeFixedSize+2+n {
+		return fmt.Errorf("Handshake listen port of %d bytes overruns the handshake", n)
+	}
+	h.Network = NetworkID(binary.BigEndian.Uint32(data[0:]))
+	h.Version = binary.BigEndian.Uint16(data[4:])
+	h.NodeID = binary.BigEndian.Uint64(data[6:])
+	h.Height = binary.BigEndian.Uint32(data[14:])
+	h.Capabilities = binary.BigEndian.Uint32(data[18:])
+	h.ListenPort = string(data[24 : 24+n])
+	port, err := strconv.Atoi(h.ListenPort)
+	if err != nil || port < 1 || port > 0xFFFF {
+		return fmt.Errorf("Handshake listen port %q is not a port number", h.ListenPort)
+	}
+	return nil
+}
+
+// Has tells whether the peer has all the capabilities
+func (h *Handshake) Has(capabilities uint32) bool {
+	return h.Capabilities&capabilities == capabilities
+}
+
+// NewHandshakeParcel returns a parcel carrying the handshake of this node
+func NewHandshakeParcel() (*Parcel, error) {
+	payload, err := NewHandshake().MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	parcel := NewParcel(CurrentNetwork, payload)
+	parcel.Header.Type = TypeHandshake
+	return parcel, nil
+}
+
+// Compatible returns why a peer with the handshake can not be talked to, or nil if it can
+func (h *Handshake) Compatible() error {
+	switch {
+	case h.NodeID == NodeID:
+		return fmt.Errorf("loopback, the peer has our node ID")
+	case h.Network != CurrentNetwork:
+		return fmt.Errorf("the peer is on network %#x rather than %#x", h.Network, CurrentNetwork)
+	case h.Version < ProtocolVersionMinimum:
+		return fmt.Errorf("the peer runs protocol version %d, below the minimum of %d", h.Version, ProtocolVersionMinimum)
+	}
+	return nil
+}
@@ -0,0 +1,241 @@
+package p2p
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandshakeMarshal(t *testing.T) {
+	defer func(height func() uint32) { LocalHeight = height }(LocalHeight)
+	LocalHeight = func() uint32 { return 1234 }
+
+	h := NewHandshake()
+	if h.Height != 1234 || h.Network != CurrentNetwork || h.Version != ProtocolVersion || !h.Has(CapabilityBinaryWire) {
+		t.Errorf("Wrong handshake for this node: %+v", h)
+	}
+	data, err := h.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read := new(Handshake)
+	err = read.UnmarshalBinary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *read != *h {
+		t.Errorf("Handshake changed over the wire\n%+v\n%+v", h, read)
+	}
+
+	// A later version with more fields is still read
+	err = read.UnmarshalBinary(append(data, 1, 2, 3))
+	if err != nil || *read != *h {
+		t.Errorf("Handshake with more fields was not read - %v", err)
+	}
+	for n := 0; n < len(data); n++ {
+		if read.UnmarshalBinary(data[:n]) == nil {
+			t.Errorf("Handshake cut to %d bytes was read", n)
+		}
+	}
+}
+
+func TestHandshakeCompatible(t *testing.T) {
+	h := NewHandshake()
+	h.NodeID = NodeID + 1
+	if err := h.Compatible(); err != nil {
+		t.Errorf("Our own handshake is incompatible - %v", err)
+	}
+
+	other := *h
+	other.Network = CurrentNetwork + 1
+	if other.Compatible() == nil {
+		t.Error("Handshake from another network is compatible")
+	}
+	other = *h
+	other.Version = ProtocolVersionMinimum - 1
+	if other.Compatible() == nil {
+		t.Error("Handshake from an old version is compatible")
+	}
+	other = *h
+	other.NodeID = NodeID
+	if other.Compatible() == nil {
+		t.Error("Handshake from ourselves is compatible")
+	}
+}
+
+func handshakeParcel(h *Handshake) Parcel {
+	payload, _ := h.MarshalBinary()
+	parcel := NewParcel(CurrentNetwork, payload)
+	parcel.Header.Type = TypeHandshake
+	return *parcel
+}
+
+func TestConnectionHandshake(t *testing.T) {
+	connection := newIncomingActiveConnection(newPeer("1.2.3.4", "8888", RegularPeer))
+	if connection.HasHandshake() {
+		t.Error("Connection has a handshake before the peer sent one")
+	}
+
+	h := NewHandshake()
+	h.NodeID = NodeID + 1
+	h.Height = 42
+	h.ListenPort = "9999"
+	connection.handleParcelTypes(handshakeParcel(h))
+	if !connection.HasHandshake() || connection.PeerHeight() != 42 {
+		t.Errorf("Handshake was not taken in, height %d", connection.PeerHeight())
+	}
+	if connection.peer.Port != "9999" || connection.peer.NodeID != h.NodeID {
+		t.Errorf("Peer was not updated from the handshake: %+v", connection.peer)
+	}
+	if !connection.encoder.IsBinary() {
+		t.Error("Connection did not switch to binary frames")
+	}
+
+	// A peer on another network is dropped as soon as it tells
+	h.Network = CurrentNetwork + 1
+	connection.handleParcelTypes(handshakeParcel(h))
+	if connection.state != ConnectionShuttingDown {
+		t.Errorf("Connection to a peer on another network is %s", connection.ConnectionState())
+	}
+}
+
+func TestConnectionHandshakeDue(t *testing.T) {
+	connection := newIncomingActiveConnection(newPeer("1.2.3.4", "8888", RegularPeer))
+	connection.timeLastHello = time.Now().Add(-2 * HandshakeInterval)
+
+	pong := func(version uint16) Parcel {
+		parcel := NewParcel(CurrentNetwork, []byte("Pong"))
+		parcel.Header.Type = TypePong
+		parcel.Header.NodeID = NodeID + 1
+		parcel.Header.Version = version
+		return *parcel
+	}
+
+	// A peer older than the handshake only gets the one sent as the connection went online
+	if connection.handshakeDue() {
+		t.Error("Handshake is due before the version of the peer is known")
+	}
+	connection.handleParcel(pong(ProtocolVersionHandshake - 1))
+	if connection.handshakeDue() {
+		t.Error("Handshake is due to a peer that does not know it")
+	}
+
+	connection.handleParcel(pong(ProtocolVersionHandshake))
+	if !connection.handshakeDue() {
+		t.Error("Handshake is not due to a peer that knows it")
+	}
+	connection.timeLastHello = time.Now()
+	if connection.handshakeDue() {
+		t.Error("Handshake is due before HandshakeInterval has passed")
+	}
+}
+
+func TestConnectionManagerGetUpToDate(t *testing.T) {
+	cm := new(ConnectionManager).Init()
+	if cm.GetUpToDate() != nil {
+		t.Error("GetUpToDate should return nil if there is nothing in the manager")
+	}
+
+	conn1 := newIncomingActiveConnection(newPeer("1", "1", RegularPeer))
+	conn2 := newIncomingActiveConnection(newPeer("2", "1", RegularPeer))
+	conn3 := newIncomingActiveConnection(newPeer("3", "1", SpecialPeerCmdLine))
+	cm.Add(conn1)
+	cm.Add(conn2)
+	cm.Add(conn3)
+	if cm.GetUpToDate() != nil {
+		t.Error("GetUpToDate should return nil if no peer sent a handshake")
+	}
+
+	for height, connection := range map[uint32]*Connection{10: conn1, 30: conn2, 20: conn3} {
+		h := NewHandshake()
+		h.NodeID = NodeID + 1
+		h.Height = height
+		connection.handleParcelTypes(handshakeParcel(h))
+	}
+	defer func(height func() uint32) { LocalHeight = height }(LocalHeight)
+	LocalHeight = func() uint32 { return 20 }
+	picked := make(map[*Connection]int)
+	for i := 0; i < 100; i++ {
+		picked[cm.GetUpToDate()]++
+	}
+	if len(picked) != 2 || picked[conn2] == 0 || picked[conn3] == 0 {
+		t.Errorf("GetUpToDate should pick among the peers at or above our height: %v", picked)
+	}
+
+	LocalHeight = func() uint32 { return 40 }
+	if cm.GetUpToDate() != nil {
+		t.Error("GetUpToDate should return nil if every peer is behind us")
+	}
+}
+
+func TestHandshakeListenPort(t *testing.T) {
+	for port, valid := range map[string]bool{"8108": true, "1": true, "65535": true, "": false, "0": false, "65536": false, "-1": false, "port": false, "81 08": false} {
+		h := NewHandshake()
+		h.ListenPort = port
+		data, err := h.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = new(Handshake).UnmarshalBinary(data)
+		if (err == nil) != valid {
+			t.Errorf("Listen port %q taken in %v, error %v", port, err == nil, err)
+		}
+	}
+
+	// A handshake with a bad port is not taken in
+	connection := newIncomingActiveConnection(newPeer("1.2.3.4", "8888", RegularPeer))
+	h := NewHandshake()
+	h.NodeID = NodeID + 1
+	h.ListenPort = "99999"
+	connection.handleParcelTypes(handshakeParcel(h))
+	if connection.HasHandshake() || connection.peer.Port != "8888" {
+		t.Errorf("Handshake with a bad listen port was taken in, port %q", connection.peer.Port)
+	}
+}
+
+func TestConnectionMayFallBack(t *testing.T) {
+	defer func(fallback, require bool) {
+		AllowPlainFallback, RequireEncryptedSpecialPeers = fallback, require
+	}(AllowPlainFallback, RequireEncryptedSpecialPeers)
+	failed := fmt.Errorf("EOF")
+
+	regular := newOutgoingConnection(newPeer("1.2.3.4", "8108", RegularPeer))
+	special := newOutgoingConnection(newPeer("5.6.7.8", "8108", SpecialPeerConfig))
+	pinned := newOutgoingConnection(newPeer("9.9.9.9", "8108", SpecialPeerConfig))
+	pinned.peer.key = strings.Repeat("ab", 32)
+
+	AllowPlainFallback, RequireEncryptedSpecialPeers = false, false
+	if regular.mayFallBack(failed) {
+		t.Error("Fell back to plain without being configured to")
+	}
+
+	AllowPlainFallback = true
+	if !regular.mayFallBack(failed) || !special.mayFallBack(failed) {
+		t.Error("Did not fall back to plain when configured to")
+	}
+	if regular.mayFallBack(&KeyMismatchError{}) {
+		t.Error("Fell back to plain from a peer that presented the wrong key")
+	}
+	if pinned.mayFallBack(failed) {
+		t.Error("Fell back to plain from a pinned special peer")
+	}
+	RequireEncryptedSpecialPeers = true
+	if special.mayFallBack(failed) {
+		t.Error("Fell back to plain from a special peer that must be encrypted")
+	}
+}
+
+func TestParseSpecialPeersPinned(t *testing.T) {
+	c := new(Controller)
+	c.logger = controllerLogger
+	key := strings.Repeat("AB", 32)
+	peers := c.parseSpecialPeers("1.2.3.4:8108 "+key+"@5.6.7.8:8108 nothex@9.9.9.9:8108", SpecialPeerConfig)
+	if len(peers) != 2 {
+		t.Fatalf("Parsed %d peers rather than 2", len(peers))
+	}
+	if peers[0].key != "" || peers[1].Address != "5.6.7.8" || peers[1].key != strings.ToLower(key) {
+		t.Errorf("Wrong pinned keys: %q %q", peers[0].key, peers[1].key)
+	}
+}
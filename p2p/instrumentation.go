@@ -91,6 +91,23 @@ var (
 		Name: "factomd_p2p_goOffline_total",
 		Help: "Number of times we call goOffline()",
 	})
+
+	//
+	// Send queues and bandwidth
+	p2pSendQueueDepth = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "factomd_p2p_send_queue_depth",
+		Help: "Number of parcels waiting to be sent to peers, by priority",
+	}, []string{"priority"})
+
+	p2pSendQueueDrops = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factomd_p2p_send_queue_drops_total",
+		Help: "Number of parcels dropped from full send queues, by priority",
+	}, []string{"priority"})
+
+	p2pBandwidthWaitSeconds = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factomd_p2p_bandwidth_wait_seconds_total",
+		Help: "Time connections waited on the upload and download rate caps",
+	}, []string{"direction"})
 )
 
 var registered = false
@@ -127,4 +144,9 @@ func RegisterPrometheus() {
 	// Connections
 	prometheus.MustRegister(p2pConnectionCommonInit)
 
+	// Send queues and bandwidth
+	prometheus.MustRegister(p2pSendQueueDepth)
+	prometheus.MustRegister(p2pSendQueueDrops)
+	prometheus.MustRegister(p2pBandwidthWaitSeconds)
+
 }
@@ -17,8 +17,9 @@ var parcelLogger = packageLogger.WithField("subpack", "connection")
 // Parcel is the atomic level of communication for the p2p network.  It contains within it the necessary info for
 // the networking protocol, plus the message that the Application is sending.
 type Parcel struct {
-	Header  ParcelHeader
-	Payload []byte
+	Header   ParcelHeader
+	Payload  []byte
+	priority Priority // how urgently the parcel is sent, not sent itself, see sendqueue.go
 }
 
 // ParcelHeaderSize is the number of bytes in a parcel header
@@ -52,6 +53,7 @@ const ( // iota is reset to 0
 	TypeAlert                                 // network wide alerts (used in bitcoin to indicate criticalities)
 	TypeMessage                               // Application level message
 	TypeMessagePart                           // Application level message that was split into multiple parts
+	TypeHandshake                             // "Here's who I am and how far along" see handshake.go
 )
 
 // CommandStrings is a Map of command ids to strings for easy printing of network comands
@@ -64,6 +66,7 @@ var CommandStrings = map[ParcelCommandType]string{
 	TypeAlert:        "Alert",         // network wide alerts (used in bitcoin to indicate criticalities)
 	TypeMessage:      "Message",       // Application level message
 	TypeMessagePart:  "MessagePart",   // Application level message that was split into multiple parts
+	TypeHandshake:    "Handshake",     // "Here's who I am and how far along"
 }
 
 // MaxPayloadSize is the maximum bytes a message can be at the networking level.
@@ -143,6 +146,16 @@ func (p *Parcel) UpdateHeader() {
 	p.Header.Length = uint32(len(p.Payload))
 }
 
+// Priority tells how urgently the parcel is sent
+func (p *Parcel) Priority() Priority {
+	return p.priority
+}
+
+// SetPriority sets how urgently the parcel is sent
+func (p *Parcel) SetPriority(priority Priority) {
+	p.priority = priority
+}
+
 func (p *Parcel) LogEntry() *log.Entry {
 	return parcelLogger.WithFields(log.Fields{
 		"network":     p.Header.Network.String(),
@@ -29,6 +29,7 @@ type Peer struct {
 	Connections  int                  // Number of successful connections.
 	LastContact  time.Time            // Keep track of how long ago we talked to the peer.
 	Source       map[string]time.Time // source where we heard from the peer.
+	key          string               // ID of the key a special peer is pinned to, "" if any will do
 
 	// logging
 	logger *log.Entry
@@ -49,7 +49,11 @@ var (
 	BroadcastFlag                       = "<BROADCAST>"
 	FullBroadcastFlag                   = "<FULLBORADCAST>"
 	RandomPeerFlag                      = "<RANDOMPEER>"
+	UpToDatePeerFlag                    = "<UPTODATEPEER>" // A random peer among those with the highest directory blocks
 	NodeID                       uint64 = 0           // Random number used for loopback protection
+	LocalNodeKey                 *NodeKey             // Key pair connections are encrypted under, nil to not encrypt them
+	RequireEncryptedSpecialPeers        = false       // refuse plain connections to and from special peers
+	AllowPlainFallback                  = false       // dial peers that can not take the TLS handshake again without it
 	MinumumQualityScore          int32  = -200        // if a peer's score is less than this we ignore them.
 	BannedQualityScore           int32  = -2147000000 // Used to ban a peer
 	MinumumSharingQualityScore   int32  = 20          // if a peer's score is less than this we don't share them.
@@ -68,6 +72,14 @@ var (
 	PeerSaveInterval                    = time.Second * 30
 	PeerRequestInterval                 = time.Second * 180
 	PeerDiscoveryInterval               = time.Hour * 4
+	HandshakeInterval                   = time.Second * 60 // How often the handshake is sent again, to keep peers told of our height
+	LocalHeight                         func() uint32      // Tells the height of our highest saved directory block, for the handshake
+	BanDuration                         = time.Hour * 24   // How long a misbehaving peer is banned for, see bans.go
+	BanScore                            = 100.0            // The misbehavior score at which a peer is banned
+	ScoreHalfLife                       = time.Minute      // How long it takes a misbehavior score to halve
+	SendQueueSize                       = 5000             // How many parcels of each priority wait to be sent to a peer, see sendqueue.go
+	PeerUploadRate                      = 0                // Bytes per second sent to each peer, 0 for no cap, see bandwidth.go
+	PeerDownloadRate                    = 0                // Bytes per second read from each peer, 0 for no cap
 
 	// Testing metrics
 	TotalMessagesReceived       uint64
@@ -81,7 +93,11 @@ var (
 
 const (
 	// ProtocolVersion is the latest version this package supports
-	ProtocolVersion uint16 = 9
+	ProtocolVersion uint16 = 11
+	// ProtocolVersionBinary is the earliest version that reads the binary wire format
+	ProtocolVersionBinary uint16 = 10
+	// ProtocolVersionHandshake is the earliest version that sends a handshake
+	ProtocolVersionHandshake uint16 = 11
 	// ProtocolVersionMinimum is the earliest version this package supports
 	ProtocolVersionMinimum uint16 = 9
 )
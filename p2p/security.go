@@ -0,0 +1,247 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+import (
+	"bufio"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// With LocalNodeKey set, connections are encrypted and authenticated with TLS.  Each node presents a
+// self signed certificate for its persistent key pair, and peers know each other by the hash of
+// that key rather than by a certificate authority.  A special peer can be given the ID of the key
+// it must present, as <key ID>@address:port, which pins it to that key.  The dialing side starts
+// the TLS handshake on every dial.  Only with AllowPlainFallback set does it dial a peer that
+// could not take the handshake again without one, and never a special peer that has to be
+// encrypted or a peer that presented the wrong key.  The accepting side tells an incoming
+// handshake from a plain parcel by its first byte.
+
+// NodeKey is the persistent key pair this node encrypts its connections under
+type NodeKey struct {
+	private *ecdsa.PrivateKey
+	cert    tls.Certificate
+	id      string
+}
+
+// tlsRecordHandshake starts every TLS handshake, and no stream of parcels in either wire format
+const tlsRecordHandshake byte = 0x16
+
+// NewNodeKey returns a new random key pair
+func NewNodeKey() (*NodeKey, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return nodeKeyFrom(private)
+}
+
+// LoadNodeKey reads the key pair saved in the file, or saves a new one there if there is none
+func LoadNodeKey(filename string) (*NodeKey, error) {
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		k, err := NewNodeKey()
+		if err != nil {
+			return nil, err
+		}
+		der, err := x509.MarshalECPrivateKey(k.private)
+		if err != nil {
+			return nil, err
+		}
+		data = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+		err = ioutil.WriteFile(filename, data, 0600)
+		if err != nil {
+			return nil, err
+		}
+		return k, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "EC PRIVATE KEY" {
+		return nil, fmt.Errorf("%s does not hold a node key", filename)
+	}
+	private, err := x509.ParseECPrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return nodeKeyFrom(private)
+}
+
+func nodeKeyFrom(private *ecdsa.PrivateKey) (*NodeKey, error) {
+	k := new(NodeKey)
+	k.private = private
+
+	id, err := KeyID(&private.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	k.id = id
+
+	// The certificate only carries the key, so a new one is made every time the key is loaded
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: id},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &private.PublicKey, private)
+	if err != nil {
+		return nil, err
+	}
+	k.cert = tls.Certificate{Certificate: [][]byte{der}, PrivateKey: private}
+	return k, nil
+}
+
+// ID is the hash of the node's public key, which its peers know it by
+func (k *NodeKey) ID() string {
+	return k.id
+}
+
+// KeyID returns the hex of the sha256 hash of the public key
+func KeyID(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// KeyMismatchError is the error of a handshake with a peer that presented another key than the
+// one it is pinned to
+type KeyMismatchError struct {
+	Expected string
+	Got      string
+}
+
+func (e *KeyMismatchError) Error() string {
+	return fmt.Sprintf("Peer presented key %s rather than %s", e.Got, e.Expected)
+}
+
+// isKeyID tells whether s has the form of a key ID
+func isKeyID(s string) bool {
+	b, err := hex.DecodeString(s)
+	return err == nil && len(b) == sha256.Size
+}
+
+// tlsConfig returns the TLS configuration of a connection, which only takes a peer presenting the
+// key with the ID expected, unless it is ""
+func (k *NodeKey) tlsConfig(expected string) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{k.cert},
+		ClientAuth:   tls.RequireAnyClientCert,
+		// Peer certificates are self signed, the peer is known by its key instead
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS12,
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return fmt.Errorf("Peer sent no certificate")
+			}
+			cert, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+			// The handshake proves the peer holds the key, this only checks the certificate is its own
+			err = cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature)
+			if err != nil || expected == "" {
+				return err
+			}
+			id, err := KeyID(cert.PublicKey)
+			if err != nil {
+				return err
+			}
+			if id != expected {
+				return &KeyMismatchError{Expected: expected, Got: id}
+			}
+			return nil
+		},
+	}
+}
+
+// SecureOutgoing runs the TLS handshake on a connection we dialed.  If expected is not "" the
+// peer has to present the key with that ID.
+func SecureOutgoing(conn net.Conn, key *NodeKey, expected string) (net.Conn, error) {
+	tlsConn := tls.Client(conn, key.tlsConfig(expected))
+	err := handshake(tlsConn)
+	if err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// SecureIncoming runs the TLS handshake on a connection we accepted, if the peer starts one.  A
+// peer that sends a plain parcel instead is given back a plain connection.
+func SecureIncoming(conn net.Conn, key *NodeKey) (net.Conn, error) {
+	peeked := &peekedConn{Conn: conn, reader: bufio.NewReader(conn)}
+	conn.SetReadDeadline(time.Now().Add(NetworkDeadline))
+	first, err := peeked.reader.Peek(1)
+	conn.SetReadDeadline(time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	if first[0] != tlsRecordHandshake {
+		return peeked, nil
+	}
+
+	tlsConn := tls.Server(peeked, key.tlsConfig(""))
+	err = handshake(tlsConn)
+	if err != nil {
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func handshake(conn *tls.Conn) error {
+	conn.SetDeadline(time.Now().Add(NetworkDeadline))
+	defer conn.SetDeadline(time.Time{})
+	return conn.Handshake()
+}
+
+// PeerKeyID returns the ID of the key the peer encrypts the connection under, or "" if the
+// connection is not encrypted
+func PeerKeyID(conn net.Conn) string {
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return ""
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return ""
+	}
+	id, err := KeyID(certs[0].PublicKey)
+	if err != nil {
+		return ""
+	}
+	return id
+}
+
+// peekedConn reads the bytes peeked at before the rest of the connection
+type peekedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *peekedConn) Read(b []byte) (int, error) {
+	return c.reader.Read(b)
+}
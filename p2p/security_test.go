@@ -0,0 +1,204 @@
+package p2p_test
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	. "github.com/FactomProject/factomd/p2p"
+)
+
+func TestLoadNodeKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nodekey")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "p2pkey.pem")
+
+	k1, err := LoadNodeKey(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Node key is saved with mode %v", info.Mode().Perm())
+	}
+
+	k2, err := LoadNodeKey(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if k1.ID() == "" || k1.ID() != k2.ID() {
+		t.Errorf("Loaded a different node key, %s rather than %s", k2.ID(), k1.ID())
+	}
+
+	ioutil.WriteFile(filename, []byte("not a key"), 0600)
+	_, err = LoadNodeKey(filename)
+	if err == nil {
+		t.Error("Loaded a node key from a file without one")
+	}
+}
+
+// loopback returns the two ends of a TCP connection over the loopback interface
+func loopback(t *testing.T) (net.Conn, net.Conn) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	accepted := make(chan net.Conn)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			t.Error(err)
+		}
+		accepted <- conn
+	}()
+	dialed, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dialed, <-accepted
+}
+
+// exchange sends a parcel each way and checks it arrives unchanged
+func exchange(t *testing.T, a, b net.Conn) {
+	parcels := testParcels()
+	done := make(chan error, 2)
+	go func() { done <- NewParcelEncoder(a).Encode(parcels[0]) }()
+	go func() { done <- NewParcelEncoder(b).Encode(parcels[1]) }()
+
+	var read Parcel
+	err := NewParcelDecoder(b).Decode(&read)
+	if err != nil || !reflect.DeepEqual(read, parcels[0]) {
+		t.Errorf("Parcel from the dialing side did not arrive - %v", err)
+	}
+	var back Parcel
+	err = NewParcelDecoder(a).Decode(&back)
+	if err != nil || !reflect.DeepEqual(back, parcels[1]) {
+		t.Errorf("Parcel from the accepting side did not arrive - %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestEncryptedConnection(t *testing.T) {
+	dialKey, err := NewNodeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	acceptKey, err := NewNodeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dialed, accepted := loopback(t)
+	defer dialed.Close()
+	defer accepted.Close()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	incoming := make(chan result)
+	go func() {
+		conn, err := SecureIncoming(accepted, acceptKey)
+		incoming <- result{conn, err}
+	}()
+	outgoing, err := SecureOutgoing(dialed, dialKey, acceptKey.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := <-incoming
+	if r.err != nil {
+		t.Fatal(r.err)
+	}
+
+	// Each side knows the other by its key
+	if PeerKeyID(outgoing) != acceptKey.ID() {
+		t.Errorf("Dialing side sees key %s rather than %s", PeerKeyID(outgoing), acceptKey.ID())
+	}
+	if PeerKeyID(r.conn) != dialKey.ID() {
+		t.Errorf("Accepting side sees key %s rather than %s", PeerKeyID(r.conn), dialKey.ID())
+	}
+	exchange(t, outgoing, r.conn)
+}
+
+func TestPlainConnectionToEncryptingPeer(t *testing.T) {
+	key, err := NewNodeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dialed, accepted := loopback(t)
+	defer dialed.Close()
+	defer accepted.Close()
+
+	// A peer that does not encrypt sends its parcels straight away
+	go NewParcelEncoder(dialed).Encode(testParcels()[0])
+	conn, err := SecureIncoming(accepted, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if PeerKeyID(conn) != "" {
+		t.Error("Plain connection has a peer key")
+	}
+	var read Parcel
+	err = NewParcelDecoder(conn).Decode(&read)
+	if err != nil || !reflect.DeepEqual(read, testParcels()[0]) {
+		t.Errorf("Parcel from a plain peer did not arrive - %v", err)
+	}
+}
+
+func TestEncryptingToPlainPeer(t *testing.T) {
+	key, err := NewNodeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dialed, accepted := loopback(t)
+	defer dialed.Close()
+
+	// A peer that does not encrypt fails to read the handshake as a parcel, and hangs up
+	go func() {
+		var read Parcel
+		NewParcelDecoder(accepted).Decode(&read)
+		accepted.Close()
+	}()
+	_, err = SecureOutgoing(dialed, key, "")
+	if err == nil {
+		t.Error("Handshake with a plain peer succeeded")
+	}
+}
+
+func TestPinnedKey(t *testing.T) {
+	key, err := NewNodeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewNodeKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dialed, accepted := loopback(t)
+	defer dialed.Close()
+	defer accepted.Close()
+
+	go SecureIncoming(accepted, key)
+	_, err = SecureOutgoing(dialed, key, other.ID())
+	if _, wrongKey := err.(*KeyMismatchError); !wrongKey {
+		t.Errorf("Handshake with a peer presenting another key than the pinned one gave %v", err)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package p2p
+
+// Each connection moves the parcels on its SendChannel into a sendQueue, and sends the parcel of
+// the most urgent priority first, so a backlog of directory block states does not hold up the
+// EOMs and acks behind it.  Each priority holds up to SendQueueSize parcels; once a priority is
+// full, its oldest message is dropped for the new one.  A message split into parts is dropped
+// with all of its parts, as the peer can not put it together from only some of them.
+
+// Priority orders the parcels waiting to go out on a connection, the lowest first
+type Priority uint8
+
+// Send priorities, from the most urgent.  The zero value is for the network's own parcels.
+const (
+	PriorityNetwork   Priority = iota // pings, pongs and handshakes, which keep the connection up
+	PriorityConsensus                 // EOMs, directory block signatures, faults and server changes
+	PriorityAck                       // acknowledgements
+	PriorityMissing                   // requests for missing messages and data, and their responses
+	PriorityDBState                   // directory block states
+	PriorityGossip                    // everything else, such as commits, reveals, transactions and peer sharing
+	numberOfPriorities
+)
+
+// PriorityStrings is a map of priorities to strings for easy printing
+var PriorityStrings = map[Priority]string{
+	PriorityNetwork:   "Network",
+	PriorityConsensus: "Consensus",
+	PriorityAck:       "Ack",
+	PriorityMissing:   "Missing",
+	PriorityDBState:   "DBState",
+	PriorityGossip:    "Gossip",
+}
+
+func (p Priority) String() string {
+	if s, ok := PriorityStrings[p]; ok {
+		return s
+	}
+	return "Unknown"
+}
+
+// sendQueue holds the parcels waiting to go out on a connection.  It is only used by the
+// connection's processSends goroutine.
+type sendQueue struct {
+	queues  [numberOfPriorities][]Parcel
+	dropped map[string]bool // app hashes of split messages dropped before all their parts came in
+}
+
+// push adds the parcel behind those of its priority, dropping the oldest message if they are too
+// many.  The parts of a message that was dropped are dropped as they come in.
+func (q *sendQueue) push(parcel Parcel) {
+	priority := parcel.Priority()
+	if priority >= numberOfPriorities {
+		priority = PriorityGossip
+	}
+	label := priority.String()
+	if len(q.queues[priority]) >= SendQueueSize && !q.isDropped(parcel) {
+		q.dropOldest(priority)
+	}
+	if q.isDropped(parcel) {
+		if parcel.Header.PartNo+1 >= parcel.Header.PartsTotal {
+			delete(q.dropped, parcel.Header.AppHash) // the last part
+		}
+		p2pSendQueueDrops.WithLabelValues(label).Inc()
+		return
+	}
+	q.queues[priority] = append(q.queues[priority], parcel)
+	p2pSendQueueDepth.WithLabelValues(label).Inc()
+}
+
+// isDropped tells whether the parcel is a part of a message that was dropped
+func (q *sendQueue) isDropped(parcel Parcel) bool {
+	return parcel.Header.Type == TypeMessagePart && q.dropped[parcel.Header.AppHash]
+}
+
+// dropOldest drops the oldest message of the priority, with all of its parts if it was split
+func (q *sendQueue) dropOldest(priority Priority) {
+	queue := q.queues[priority]
+	oldest := queue[0].Header
+	split := oldest.Type == TypeMessagePart
+
+	kept := queue[:0]
+	last := false
+	for i, parcel := range queue {
+		if i == 0 || split && parcel.Header.Type == TypeMessagePart && parcel.Header.AppHash == oldest.AppHash {
+			last = last || parcel.Header.PartNo+1 >= parcel.Header.PartsTotal
+			continue
+		}
+		kept = append(kept, parcel)
+	}
+	for i := len(kept); i < len(queue); i++ {
+		queue[i] = Parcel{}
+	}
+	q.queues[priority] = kept
+
+	if split && !last {
+		if q.dropped == nil {
+			q.dropped = make(map[string]bool)
+		}
+		q.dropped[oldest.AppHash] = true
+	}
+	n := float64(len(queue) - len(kept))
+	p2pSendQueueDrops.WithLabelValues(priority.String()).Add(n)
+	p2pSendQueueDepth.WithLabelValues(priority.String()).Sub(n)
+}
+
+// peek returns the parcel pop would return, without taking it off the queue
+func (q *sendQueue) peek() (Parcel, bool) {
+	for _, queue := range q.queues {
+		if len(queue) > 0 {
+			return queue[0], true
+		}
+	}
+	return Parcel{}, false
+}
+
+// pop removes and returns the oldest parcel of the most urgent priority, if there is any
+func (q *sendQueue) pop() (Parcel, bool) {
+	for priority := range q.queues {
+		queue := q.queues[priority]
+		if len(queue) == 0 {
+			continue
+		}
+		parcel := queue[0]
+		queue[0] = Parcel{}
+		q.queues[priority] = queue[1:]
+		p2pSendQueueDepth.WithLabelValues(Priority(priority).String()).Dec()
+		return parcel, true
+	}
+	return Parcel{}, false
+}
+
+// Len returns the number of parcels waiting
+func (q *sendQueue) Len() int {
+	n := 0
+	for _, queue := range q.queues {
+		n += len(queue)
+	}
+	return n
+}
+
+// clear drops the parcels waiting, as when the connection shuts down
+func (q *sendQueue) clear() {
+	for priority := range q.queues {
+		p2pSendQueueDepth.WithLabelValues(Priority(priority).String()).Sub(float64(len(q.queues[priority])))
+		q.queues[priority] = nil
+	}
+	q.dropped = nil
+}
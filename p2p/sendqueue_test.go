@@ -0,0 +1,175 @@
+package p2p
+
+import (
+	"fmt"
+	"net"
+	"testing"
+)
+
+func priorityParcel(priority Priority, payload string) Parcel {
+	parcel := NewParcel(CurrentNetwork, []byte(payload))
+	parcel.SetPriority(priority)
+	return *parcel
+}
+
+func TestSendQueueOrder(t *testing.T) {
+	q := new(sendQueue)
+	q.push(priorityParcel(PriorityGossip, "commit"))
+	q.push(priorityParcel(PriorityDBState, "dbstate"))
+	q.push(priorityParcel(PriorityAck, "ack 1"))
+	q.push(priorityParcel(PriorityConsensus, "eom"))
+	q.push(priorityParcel(PriorityAck, "ack 2"))
+	q.push(priorityParcel(Priority(200), "unknown"))
+	if q.Len() != 6 {
+		t.Errorf("Queue holds %d parcels rather than 6", q.Len())
+	}
+
+	for _, expected := range []string{"eom", "ack 1", "ack 2", "dbstate", "commit", "unknown"} {
+		parcel, ok := q.pop()
+		if !ok || string(parcel.Payload) != expected {
+			t.Errorf("Popped %q rather than %q", parcel.Payload, expected)
+		}
+	}
+	if _, ok := q.pop(); ok || q.Len() != 0 {
+		t.Error("Popped a parcel from an empty queue")
+	}
+}
+
+func TestSendQueueDropsOldest(t *testing.T) {
+	defer func(size int) { SendQueueSize = size }(SendQueueSize)
+	SendQueueSize = 2
+
+	q := new(sendQueue)
+	q.push(priorityParcel(PriorityDBState, "1"))
+	q.push(priorityParcel(PriorityDBState, "2"))
+	q.push(priorityParcel(PriorityDBState, "3"))
+	q.push(priorityParcel(PriorityAck, "ack"))
+	if q.Len() != 3 {
+		t.Errorf("Queue holds %d parcels rather than 3", q.Len())
+	}
+	for _, expected := range []string{"ack", "2", "3"} {
+		parcel, _ := q.pop()
+		if string(parcel.Payload) != expected {
+			t.Errorf("Popped %q rather than %q", parcel.Payload, expected)
+		}
+	}
+
+	q.push(priorityParcel(PriorityGossip, "left over"))
+	q.clear()
+	if q.Len() != 0 {
+		t.Error("Cleared queue still holds parcels")
+	}
+}
+
+func partParcel(appHash string, partNo, partsTotal uint16) Parcel {
+	parcel := priorityParcel(PriorityDBState, fmt.Sprintf("%s %d", appHash, partNo))
+	parcel.Header.Type = TypeMessagePart
+	parcel.Header.AppHash = appHash
+	parcel.Header.PartNo = partNo
+	parcel.Header.PartsTotal = partsTotal
+	return parcel
+}
+
+func TestSendQueueDropsWholeMessages(t *testing.T) {
+	defer func(size int) { SendQueueSize = size }(SendQueueSize)
+	SendQueueSize = 3
+
+	q := new(sendQueue)
+	q.push(partParcel("a", 0, 3))
+	q.push(partParcel("a", 1, 3))
+	q.push(priorityParcel(PriorityDBState, "x"))
+	// Making room drops both parts of a, and its last part is dropped as it comes in
+	q.push(priorityParcel(PriorityDBState, "y"))
+	q.push(partParcel("a", 2, 3))
+	if q.Len() != 2 {
+		t.Errorf("Queue holds %d parcels rather than 2", q.Len())
+	}
+	q.push(partParcel("b", 0, 2))
+	q.push(partParcel("b", 1, 2))
+	// A message dropped with all its parts queued is not looked out for
+	q.push(partParcel("a", 0, 1))
+
+	for _, expected := range []string{"b 0", "b 1", "a 0"} {
+		parcel, _ := q.pop()
+		if string(parcel.Payload) != expected {
+			t.Errorf("Popped %q rather than %q", parcel.Payload, expected)
+		}
+	}
+	if q.Len() != 0 || len(q.dropped) != 0 {
+		t.Errorf("Queue holds %d parcels and %d dropped messages", q.Len(), len(q.dropped))
+	}
+}
+
+func TestConnectionSendsUrgentFirst(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	connection := newIncomingActiveConnection(newPeer("1.2.3.4", "8108", RegularPeer))
+	connection.conn = local
+	connection.encoder = NewParcelEncoder(local)
+	connection.decoder = NewParcelDecoder(local)
+	for len(connection.SendChannel) > 0 { // leave out the handshake and peer request of going online
+		<-connection.SendChannel
+	}
+
+	for _, parcel := range []Parcel{
+		priorityParcel(PriorityDBState, "dbstate"),
+		priorityParcel(PriorityGossip, "commit"),
+		priorityParcel(PriorityConsensus, "eom"),
+		priorityParcel(PriorityAck, "ack"),
+	} {
+		connection.SendChannel <- ConnectionParcel{Parcel: parcel}
+	}
+	go connection.processSends()
+	defer func() { connection.state = ConnectionShuttingDown }()
+
+	decoder := NewParcelDecoder(remote)
+	for _, expected := range []string{"eom", "ack", "dbstate", "commit"} {
+		var parcel Parcel
+		err := decoder.Decode(&parcel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(parcel.Payload) != expected {
+			t.Errorf("Sent %q rather than %q", parcel.Payload, expected)
+		}
+	}
+}
+
+func TestConnectionSendsUrgentAfterThrottle(t *testing.T) {
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+
+	connection := newIncomingActiveConnection(newPeer("1.2.3.4", "8108", RegularPeer))
+	connection.conn = local
+	connection.encoder = NewParcelEncoder(local)
+	connection.decoder = NewParcelDecoder(local)
+	for len(connection.SendChannel) > 0 {
+		<-connection.SendChannel
+	}
+	// Room for the first parcel, and a wait before the second
+	connection.upload = NewLimiter(10)
+
+	connection.SendChannel <- ConnectionParcel{Parcel: priorityParcel(PriorityDBState, "dbstate1")}
+	connection.SendChannel <- ConnectionParcel{Parcel: priorityParcel(PriorityDBState, "dbstate2")}
+	go connection.processSends()
+	defer func() { connection.state = ConnectionShuttingDown }()
+
+	decoder := NewParcelDecoder(remote)
+	for i, expected := range []string{"dbstate1", "eom", "dbstate2"} {
+		var parcel Parcel
+		err := decoder.Decode(&parcel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(parcel.Payload) != expected {
+			t.Errorf("Sent %q rather than %q", parcel.Payload, expected)
+		}
+		if i == 0 {
+			// Comes in while the connection waits to send dbstate2
+			connection.SendChannel <- ConnectionParcel{Parcel: priorityParcel(PriorityConsensus, "eom")}
+		}
+	}
+}
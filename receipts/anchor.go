@@ -0,0 +1,123 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package receipts
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/FactomProject/factomd/anchor"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// AnchorJSON is the signed anchor record of the directory block a receipt leads to, as found in
+// the anchor chain.  It lets the anchor be checked from the receipt alone.
+type AnchorJSON struct {
+	Content string   `json:"content"`
+	ExtIDs  []string `json:"extids,omitempty"`
+}
+
+func (e *AnchorJSON) IsSameAs(r *AnchorJSON) bool {
+	if r == nil {
+		return false
+	}
+	if e.Content != r.Content {
+		return false
+	}
+	if len(e.ExtIDs) != len(r.ExtIDs) {
+		return false
+	}
+	for i := range e.ExtIDs {
+		if e.ExtIDs[i] != r.ExtIDs[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks the signature of the anchor record against the given anchor signing keys, and
+// returns the record if it is signed by one of them
+func (e *AnchorJSON) Validate(publicKeys []interfaces.Verifier) (*anchor.AnchorRecord, error) {
+	content, err := hex.DecodeString(e.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	var ar *anchor.AnchorRecord
+	var valid bool
+	if len(e.ExtIDs) > 0 {
+		extIDs := make([][]byte, len(e.ExtIDs))
+		for i, v := range e.ExtIDs {
+			extIDs[i], err = hex.DecodeString(v)
+			if err != nil {
+				return nil, err
+			}
+		}
+		ar, valid, err = anchor.UnmarshalAndValidateAnchorRecordV2(content, extIDs, publicKeys)
+	} else {
+		// Version 1 records carry their signature at the end of the content
+		ar, valid, err = anchor.UnmarshalAndValidateAnchorRecord(content, publicKeys)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !valid {
+		return nil, fmt.Errorf("Anchor record is not signed by a known anchor signing key")
+	}
+	return ar, nil
+}
+
+// checkAnchor tells if the anchor record of a receipt is valid and anchors the directory block
+// and Bitcoin transaction named by the receipt
+func checkAnchor(a *AnchorJSON, dBlockKeyMR, btcTxHash, btcBlockHash *primitives.Hash, publicKeys []interfaces.Verifier) error {
+	if a == nil {
+		return fmt.Errorf("Receipt has no anchor record")
+	}
+	ar, err := a.Validate(publicKeys)
+	if err != nil {
+		return err
+	}
+	if ar.KeyMR != dBlockKeyMR.String() {
+		return fmt.Errorf("Anchor record is for DBlock %v, not %v", ar.KeyMR, dBlockKeyMR)
+	}
+	if btcTxHash != nil || btcBlockHash != nil {
+		if ar.Bitcoin == nil {
+			return fmt.Errorf("Anchor record has no Bitcoin anchor")
+		}
+		if btcTxHash != nil && !sameShaHash(ar.Bitcoin.TXID, btcTxHash) {
+			return fmt.Errorf("Anchor record is for Bitcoin transaction %v, not %v", ar.Bitcoin.TXID, btcTxHash)
+		}
+		if btcBlockHash != nil && !sameShaHash(ar.Bitcoin.BlockHash, btcBlockHash) {
+			return fmt.Errorf("Anchor record is for Bitcoin block %v, not %v", ar.Bitcoin.BlockHash, btcBlockHash)
+		}
+	}
+	return nil
+}
+
+// sameShaHash compares a hash in the byte order of an anchor record to a hash of a receipt
+func sameShaHash(str string, h *primitives.Hash) bool {
+	hash, err := primitives.NewShaHashFromStr(str)
+	if err != nil {
+		return false
+	}
+	return hash.IsSameAs(h)
+}
+
+// fetchAnchorEntry gets the signed Bitcoin anchor record of a directory block, or nil if it is not
+// anchored yet
+func fetchAnchorEntry(dbo interfaces.DBOverlaySimple, dBlock interfaces.IDirectoryBlock) (*AnchorJSON, error) {
+	entry, err := dbo.FetchAnchorEntryByHeight(dBlock.GetDatabaseHeight())
+	if err != nil || entry == nil {
+		return nil, err
+	}
+
+	answer := new(AnchorJSON)
+	answer.Content = hex.EncodeToString(entry.GetContent())
+	for _, extID := range entry.ExternalIDs() {
+		answer.ExtIDs = append(answer.ExtIDs, hex.EncodeToString(extID))
+	}
+	return answer, nil
+}
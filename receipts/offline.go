@@ -0,0 +1,126 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package receipts
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// The functions below verify receipts from their own content and a set of anchor signing keys,
+// so receipts can be audited without a node or its database.
+
+// VerifyReceiptOffline checks that the Merkle branch of an entry receipt leads from the entry
+// through its entry block to the directory block, and that the directory block is anchored by a
+// record signed with one of the given keys
+func VerifyReceiptOffline(receipt *Receipt, publicKeys []interfaces.Verifier) error {
+	err := receipt.Validate()
+	if err != nil {
+		return err
+	}
+
+	entryHash, err := primitives.NewShaHashFromStr(receipt.Entry.EntryHash)
+	if err != nil {
+		return err
+	}
+	tops, err := walkBranch(entryHash, receipt.MerkleBranch)
+	if err != nil {
+		return err
+	}
+	if !containsHash(tops, receipt.EntryBlockKeyMR) {
+		return fmt.Errorf("EntryBlockKeyMR not found in branch")
+	}
+	if !tops[len(tops)-1].IsSameAs(receipt.DirectoryBlockKeyMR) {
+		return fmt.Errorf("DirectoryBlockKeyMR is not the top of the branch")
+	}
+
+	return checkAnchor(receipt.Anchor, receipt.DirectoryBlockKeyMR, receipt.BitcoinTransactionHash, receipt.BitcoinBlockHash, publicKeys)
+}
+
+// VerifyTransactionReceiptOffline is VerifyReceiptOffline for factoid and entry credit transactions
+func VerifyTransactionReceiptOffline(receipt *TransactionReceipt, publicKeys []interfaces.Verifier) error {
+	err := receipt.Validate()
+	if err != nil {
+		return err
+	}
+
+	return checkAnchor(receipt.Anchor, receipt.DirectoryBlockKeyMR, receipt.BitcoinTransactionHash, receipt.BitcoinBlockHash, publicKeys)
+}
+
+// VerifyReceiptJSON verifies an entry or transaction receipt, as returned by the receipt and
+// transaction-receipt API methods or saved by ExportEntryReceipt
+func VerifyReceiptJSON(data []byte, publicKeys []interfaces.Verifier) error {
+	var fields map[string]json.RawMessage
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return err
+	}
+
+	// Receipts fetched from the API are wrapped in the response
+	if r, ok := fields["result"]; ok {
+		return VerifyReceiptJSON(r, publicKeys)
+	}
+	if r, ok := fields["receipt"]; ok {
+		return VerifyReceiptJSON(r, publicKeys)
+	}
+
+	if _, ok := fields["transaction"]; ok {
+		receipt := new(TransactionReceipt)
+		err = json.Unmarshal(data, receipt)
+		if err != nil {
+			return err
+		}
+		return VerifyTransactionReceiptOffline(receipt, publicKeys)
+	}
+
+	receipt := new(Receipt)
+	err = json.Unmarshal(data, receipt)
+	if err != nil {
+		return err
+	}
+	return VerifyReceiptOffline(receipt, publicKeys)
+}
+
+// walkBranch hashes its way up a Merkle branch from start, and returns every hash on the way,
+// from start to the top of the branch
+func walkBranch(start interfaces.IHash, branch []*primitives.MerkleNode) ([]interfaces.IHash, error) {
+	current := start
+	tops := []interfaces.IHash{current}
+	for i, node := range branch {
+		var left, right interfaces.IHash
+		switch {
+		case node.Left == nil && node.Right == nil:
+			return nil, fmt.Errorf("Node %v/%v has two nil sides", i, len(branch))
+		case node.Left == nil:
+			left, right = current, node.Right
+		case node.Right == nil:
+			left, right = node.Left, current
+		default:
+			if !current.IsSameAs(node.Left) && !current.IsSameAs(node.Right) {
+				return nil, fmt.Errorf("Hash %v not found in node %v/%v", current, i, len(branch))
+			}
+			left, right = node.Left, node.Right
+		}
+		top := primitives.HashMerkleBranches(left, right)
+		if node.Top != nil && !top.IsSameAs(node.Top) {
+			return nil, fmt.Errorf("Derived top %v is not the same as saved top in node %v/%v", top, i, len(branch))
+		}
+		current = top
+		tops = append(tops, current)
+	}
+	return tops, nil
+}
+
+func containsHash(hashes []interfaces.IHash, h interfaces.IHash) bool {
+	for _, v := range hashes {
+		if v.IsSameAs(h) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package receipts_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	. "github.com/FactomProject/factomd/receipts"
+	. "github.com/FactomProject/factomd/testHelper"
+)
+
+func TestVerifyReceiptOffline(t *testing.T) {
+	dbo := CreateAndPopulateTestDatabaseOverlay()
+	blocks := CreateFullTestBlockSet()
+	keys := databaseOverlay.AnchorSigPublicKeys
+
+	for _, block := range blocks[:len(blocks)-2] {
+		for _, entry := range block.Entries {
+			receipt, err := CreateFullReceipt(dbo, entry.DatabasePrimaryIndex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if receipt.Anchor == nil {
+				t.Fatalf("No anchor record in receipt for block %v", block.Height)
+			}
+			if err = VerifyReceiptOffline(receipt, keys); err != nil {
+				t.Errorf("Block %v - %v", block.Height, err)
+			}
+
+			receipt.TrimReceipt()
+			if err = VerifyReceiptJSON([]byte(receipt.CustomMarshalString()), keys); err != nil {
+				t.Errorf("Minimal receipt for block %v - %v", block.Height, err)
+			}
+		}
+	}
+
+	receipt, err := CreateTransactionReceipt(dbo, blocks[2].FBlock.GetTransactions()[0].GetSigHash())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 0, "result": map[string]interface{}{"receipt": receipt}})
+	if err = VerifyReceiptJSON(resp, keys); err != nil {
+		t.Errorf("Transaction receipt in an API response - %v", err)
+	}
+}
+
+func TestVerifyReceiptOfflineFailures(t *testing.T) {
+	dbo := CreateAndPopulateTestDatabaseOverlay()
+	blocks := CreateFullTestBlockSet()
+	keys := databaseOverlay.AnchorSigPublicKeys
+
+	str := ""
+	for _, entry := range blocks[3].Entries {
+		receipt, err := CreateFullReceipt(dbo, entry.DatabasePrimaryIndex())
+		if err != nil {
+			t.Fatal(err)
+		}
+		str = receipt.CustomMarshalString()
+		break
+	}
+
+	tampered := map[string]func(r *Receipt){
+		"unknown key": func(r *Receipt) {},
+		"no anchor":   func(r *Receipt) { r.Anchor = nil },
+		"other dblock": func(r *Receipt) {
+			r.Anchor = blockReceiptAnchor(t, dbo, blocks[4])
+		},
+		"other bitcoin transaction": func(r *Receipt) { r.BitcoinTransactionHash = primitives.RandomHash().(*primitives.Hash) },
+		"broken signature":          func(r *Receipt) { r.Anchor.Content = r.Anchor.Content[:len(r.Anchor.Content)-2] + "00" },
+		"unconnected branch":        func(r *Receipt) { r.MerkleBranch[0].Left = primitives.RandomHash().(*primitives.Hash) },
+		"cut branch":                func(r *Receipt) { r.MerkleBranch = r.MerkleBranch[:len(r.MerkleBranch)-1] },
+	}
+	for name, tamper := range tampered {
+		r, err := DecodeReceiptString(str)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tamper(r)
+		k := keys
+		if name == "unknown key" {
+			k = []interfaces.Verifier{NewPrimitivesPrivateKey(1).Pub}
+		}
+		if err := VerifyReceiptOffline(r, k); err == nil {
+			t.Errorf("Receipt with %v passed verification", name)
+		}
+	}
+}
+
+func blockReceiptAnchor(t *testing.T, dbo interfaces.DBOverlaySimple, block *BlockSet) *AnchorJSON {
+	receipt, err := CreateFullReceipt(dbo, block.Entries[0].DatabasePrimaryIndex())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return receipt.Anchor
+}
@@ -20,6 +20,7 @@ type Receipt struct {
 	DirectoryBlockKeyMR    *primitives.Hash         `json:"directoryblockkeymr,omitempty"`
 	BitcoinTransactionHash *primitives.Hash         `json:"bitcointransactionhash,omitempty"`
 	BitcoinBlockHash       *primitives.Hash         `json:"bitcoinblockhash,omitempty"`
+	Anchor                 *AnchorJSON              `json:"anchor,omitempty"`
 }
 
 func (e *Receipt) TrimReceipt() {
@@ -202,6 +203,16 @@ func (e *Receipt) IsSameAs(r *Receipt) bool {
 		}
 	}
 
+	if e.Anchor == nil {
+		if r.Anchor != nil {
+			return false
+		}
+	} else {
+		if e.Anchor.IsSameAs(r.Anchor) == false {
+			return false
+		}
+	}
+
 	return true
 }
 
@@ -334,64 +345,81 @@ func CreateReceipt(dbo interfaces.DBOverlaySimple, entryID interfaces.IHash) (*R
 
 	//DBlock
 
-	hash, err = dbo.FetchIncludedIn(hash)
+	branch, dBlock, err := buildDBlockBranch(dbo, receipt.EntryBlockKeyMR, "EBlock")
 	if err != nil {
 		return nil, err
 	}
+	receipt.MerkleBranch = append(receipt.MerkleBranch, branch...)
 
-	if hash == nil {
-		return nil, fmt.Errorf("Block containing EBlock not found")
-	}
+	//DirBlockInfo
 
-	dBlock, err := dbo.FetchDBlock(hash)
+	hash = dBlock.DatabasePrimaryIndex()
+	receipt.DirectoryBlockKeyMR = hash.(*primitives.Hash)
+
+	receipt.BitcoinTransactionHash, receipt.BitcoinBlockHash, err = fetchAnchor(dbo, hash)
 	if err != nil {
 		return nil, err
 	}
+	if receipt.BitcoinTransactionHash != nil {
+		receipt.Anchor, err = fetchAnchorEntry(dbo, dBlock)
+		if err != nil {
+			return nil, err
+		}
+	}
 
-	if dBlock == nil {
-		return nil, fmt.Errorf("DBlock not found")
+	return receipt, nil
+}
+
+// buildDBlockBranch returns the Merkle branch from the KeyMR of a block to the KeyMR of the
+// directory block holding it, along with that directory block
+func buildDBlockBranch(dbo interfaces.DBOverlaySimple, keyMR interfaces.IHash, blockName string) ([]*primitives.MerkleNode, interfaces.IDirectoryBlock, error) {
+	hash, err := dbo.FetchIncludedIn(keyMR)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	//str, _ = dBlock.JSONString()
-	//fmt.Printf("dBlock - %v\n\n", str)
+	if hash == nil {
+		return nil, nil, fmt.Errorf("Block containing %v not found", blockName)
+	}
 
-	entries = dBlock.GetEntryHashesForBranch()
-	//fmt.Printf("dBlock entries - %v\n\n", entries)
+	dBlock, err := dbo.FetchDBlock(hash)
+	if err != nil {
+		return nil, nil, err
+	}
 
-	//merkleTree := primitives.BuildMerkleTreeStore(entries)
-	//fmt.Printf("dBlock merkleTree - %v\n\n", merkleTree)
+	if dBlock == nil {
+		return nil, nil, fmt.Errorf("DBlock not found")
+	}
 
-	branch = primitives.BuildMerkleBranchForEntryHash(entries, receipt.EntryBlockKeyMR, true)
-	blockNode = new(primitives.MerkleNode)
-	left, err = dBlock.GetHeaderHash()
+	entries := dBlock.GetEntryHashesForBranch()
+	branch := primitives.BuildMerkleBranchForEntryHash(entries, keyMR, true)
+	blockNode := new(primitives.MerkleNode)
+	left, err := dBlock.GetHeaderHash()
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	blockNode.Left = left.(*primitives.Hash)
 	blockNode.Right = dBlock.BodyKeyMR().(*primitives.Hash)
 	blockNode.Top = hash.(*primitives.Hash)
-	//fmt.Printf("dBlock blockNode - %v\n\n", blockNode)
 	branch = append(branch, blockNode)
-	receipt.MerkleBranch = append(receipt.MerkleBranch, branch...)
 
-	//DirBlockInfo
-
-	hash = dBlock.DatabasePrimaryIndex()
-	receipt.DirectoryBlockKeyMR = hash.(*primitives.Hash)
+	return branch, dBlock, nil
+}
 
-	dirBlockInfo, err := dbo.FetchDirBlockInfoByKeyMR(hash)
+// fetchAnchor returns the Bitcoin transaction and block anchoring a directory block, or nils if
+// it is not anchored yet
+func fetchAnchor(dbo interfaces.DBOverlaySimple, dBlockKeyMR interfaces.IHash) (*primitives.Hash, *primitives.Hash, error) {
+	dirBlockInfo, err := dbo.FetchDirBlockInfoByKeyMR(dBlockKeyMR)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	if dirBlockInfo != nil {
-		dbi := dirBlockInfo.(*dbInfo.DirBlockInfo)
-
-		receipt.BitcoinTransactionHash = dbi.BTCTxHash.(*primitives.Hash)
-		receipt.BitcoinBlockHash = dbi.BTCBlockHash.(*primitives.Hash)
+	if dirBlockInfo == nil {
+		return nil, nil, nil
 	}
 
-	return receipt, nil
+	dbi := dirBlockInfo.(*dbInfo.DirBlockInfo)
+	return dbi.BTCTxHash.(*primitives.Hash), dbi.BTCBlockHash.(*primitives.Hash), nil
 }
 
 func VerifyFullReceipt(dbo interfaces.DBOverlaySimple, receiptStr string) error {
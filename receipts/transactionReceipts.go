@@ -0,0 +1,317 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package receipts
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/entryCreditBlock"
+	"github.com/FactomProject/factomd/common/factoid"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// The types of transaction a TransactionReceipt can prove
+const (
+	TransactionTypeFactoid     = "factoid"
+	TransactionTypeEntryCredit = "entrycredit"
+)
+
+// TransactionReceipt proves that a factoid transaction or an entry credit transaction (a chain
+// or entry commit, or a balance increase) is part of a directory block.
+//
+// A factoid transaction is proven with a Merkle branch from its hash through the body MR of its
+// factoid block to the directory block KeyMR.  The body of an entry credit block is hashed as a
+// whole rather than as a Merkle tree, so an entry credit transaction is proven by carrying the
+// whole entry credit block, whose header hash is the start of the branch to the directory block.
+type TransactionReceipt struct {
+	Transaction            *TransactionJSON         `json:"transaction,omitempty"`
+	MerkleBranch           []*primitives.MerkleNode `json:"merklebranch,omitempty"`
+	FactoidBlockKeyMR      *primitives.Hash         `json:"factoidblockkeymr,omitempty"`
+	EntryCreditBlock       string                   `json:"entrycreditblock,omitempty"`
+	EntryCreditBlockKeyMR  *primitives.Hash         `json:"entrycreditblockkeymr,omitempty"`
+	DirectoryBlockKeyMR    *primitives.Hash         `json:"directoryblockkeymr,omitempty"`
+	BitcoinTransactionHash *primitives.Hash         `json:"bitcointransactionhash,omitempty"`
+	BitcoinBlockHash       *primitives.Hash         `json:"bitcoinblockhash,omitempty"`
+	Anchor                 *AnchorJSON              `json:"anchor,omitempty"`
+}
+
+type TransactionJSON struct {
+	Type          string `json:"type"`
+	TransactionID string `json:"txid"`
+	Raw           string `json:"raw"`
+}
+
+func (e *TransactionReceipt) JSONByte() ([]byte, error) {
+	return primitives.EncodeJSON(e)
+}
+
+func (e *TransactionReceipt) JSONString() (string, error) {
+	return primitives.EncodeJSONString(e)
+}
+
+func (e *TransactionReceipt) CustomMarshalString() string {
+	str, _ := e.JSONString()
+	return str
+}
+
+func DecodeTransactionReceiptString(str string) (*TransactionReceipt, error) {
+	receipt := new(TransactionReceipt)
+	err := json.Unmarshal([]byte(str), &receipt)
+	if err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// Validate checks the receipt using nothing but its own content: that the transaction is the one
+// it claims to be, and that the Merkle branch leads from it to the directory block KeyMR
+func (e *TransactionReceipt) Validate() error {
+	if e == nil {
+		return fmt.Errorf("No receipt provided")
+	}
+	if e.Transaction == nil {
+		return fmt.Errorf("Receipt has no transaction")
+	}
+	if len(e.MerkleBranch) == 0 {
+		return fmt.Errorf("Receipt has no MerkleBranch")
+	}
+	if e.DirectoryBlockKeyMR == nil {
+		return fmt.Errorf("Receipt has no DirectoryBlockKeyMR")
+	}
+	txID, err := primitives.HexToHash(e.Transaction.TransactionID)
+	if err != nil {
+		return err
+	}
+	raw, err := hex.DecodeString(e.Transaction.Raw)
+	if err != nil {
+		return err
+	}
+
+	// The hash the branch starts from, and the block the branch has to go through
+	var start, block interfaces.IHash
+	switch e.Transaction.Type {
+	case TransactionTypeFactoid:
+		if e.FactoidBlockKeyMR == nil {
+			return fmt.Errorf("Receipt has no FactoidBlockKeyMR")
+		}
+		tx := new(factoid.Transaction)
+		if err := tx.UnmarshalBinary(raw); err != nil {
+			return err
+		}
+		if !txID.IsSameAs(tx.GetSigHash()) && !txID.IsSameAs(tx.GetHash()) {
+			return fmt.Errorf("Transaction %v does not match its raw data", txID)
+		}
+		start = tx.GetHash()
+		block = e.FactoidBlockKeyMR
+	case TransactionTypeEntryCredit:
+		if e.EntryCreditBlockKeyMR == nil {
+			return fmt.Errorf("Receipt has no EntryCreditBlockKeyMR")
+		}
+		data, err := hex.DecodeString(e.EntryCreditBlock)
+		if err != nil {
+			return err
+		}
+		ecBlock, err := entryCreditBlock.UnmarshalECBlock(data)
+		if err != nil {
+			return err
+		}
+		tx := findECTransaction(ecBlock, txID)
+		if tx == nil {
+			return fmt.Errorf("Transaction %v not found in the EntryCreditBlock", txID)
+		}
+		txRaw, err := marshalECTransaction(tx)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(raw, txRaw) {
+			return fmt.Errorf("Transaction %v does not match its raw data", txID)
+		}
+		start, err = ecBlock.HeaderHash()
+		if err != nil {
+			return err
+		}
+		if !start.IsSameAs(e.EntryCreditBlockKeyMR) {
+			return fmt.Errorf("EntryCreditBlock does not hash to the EntryCreditBlockKeyMR")
+		}
+		block = e.EntryCreditBlockKeyMR
+	default:
+		return fmt.Errorf("Unknown transaction type %q", e.Transaction.Type)
+	}
+
+	tops, err := walkBranch(start, e.MerkleBranch)
+	if err != nil {
+		return err
+	}
+	if !containsHash(tops, block) {
+		return fmt.Errorf("Block KeyMR not found in branch")
+	}
+	if !tops[len(tops)-1].IsSameAs(e.DirectoryBlockKeyMR) {
+		return fmt.Errorf("DirectoryBlockKeyMR is not the top of the branch")
+	}
+
+	return nil
+}
+
+// CreateTransactionReceipt builds the receipt of the factoid or entry credit transaction with the
+// given ID, which can be either its transaction ID or its full hash
+func CreateTransactionReceipt(dbo interfaces.DBOverlaySimple, txID interfaces.IHash) (*TransactionReceipt, error) {
+	hash, err := dbo.FetchIncludedIn(txID)
+	if err != nil {
+		return nil, err
+	}
+
+	if hash == nil {
+		return nil, fmt.Errorf("Block containing transaction not found")
+	}
+
+	receipt := new(TransactionReceipt)
+	receipt.Transaction = new(TransactionJSON)
+	receipt.Transaction.TransactionID = txID.String()
+
+	var blockKeyMR interfaces.IHash
+	var blockName string
+
+	fBlock, err := dbo.FetchFBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if fBlock != nil {
+		tx := fBlock.GetTransactionByHash(txID)
+		if tx == nil {
+			return nil, fmt.Errorf("Transaction not found in FBlock")
+		}
+		raw, err := tx.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		receipt.Transaction.Type = TransactionTypeFactoid
+		receipt.Transaction.Raw = hex.EncodeToString(raw)
+
+		blockKeyMR = fBlock.DatabasePrimaryIndex()
+		receipt.FactoidBlockKeyMR = blockKeyMR.(*primitives.Hash)
+		blockName = "FBlock"
+
+		branch := primitives.BuildMerkleBranchForEntryHash(fBlock.GetBodyHashes(), tx.GetHash(), true)
+		header, err := fBlock.MarshalHeader()
+		if err != nil {
+			return nil, err
+		}
+		blockNode := new(primitives.MerkleNode)
+		blockNode.Left = primitives.Sha(header).(*primitives.Hash)
+		blockNode.Right = fBlock.GetBodyMR().(*primitives.Hash)
+		blockNode.Top = blockKeyMR.(*primitives.Hash)
+		branch = append(branch, blockNode)
+		receipt.MerkleBranch = append(receipt.MerkleBranch, branch...)
+	} else {
+		ecBlock, err := dbo.FetchECBlock(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if ecBlock == nil {
+			return nil, fmt.Errorf("Hash is not a factoid or entry credit transaction")
+		}
+
+		tx := findECTransaction(ecBlock, txID)
+		if tx == nil {
+			return nil, fmt.Errorf("Transaction not found in ECBlock")
+		}
+		raw, err := marshalECTransaction(tx)
+		if err != nil {
+			return nil, err
+		}
+		receipt.Transaction.Type = TransactionTypeEntryCredit
+		receipt.Transaction.Raw = hex.EncodeToString(raw)
+
+		data, err := ecBlock.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		receipt.EntryCreditBlock = hex.EncodeToString(data)
+
+		blockKeyMR = ecBlock.DatabasePrimaryIndex()
+		receipt.EntryCreditBlockKeyMR = blockKeyMR.(*primitives.Hash)
+		blockName = "ECBlock"
+	}
+
+	//DBlock
+
+	branch, dBlock, err := buildDBlockBranch(dbo, blockKeyMR, blockName)
+	if err != nil {
+		return nil, err
+	}
+	receipt.MerkleBranch = append(receipt.MerkleBranch, branch...)
+
+	//DirBlockInfo
+
+	hash = dBlock.DatabasePrimaryIndex()
+	receipt.DirectoryBlockKeyMR = hash.(*primitives.Hash)
+
+	receipt.BitcoinTransactionHash, receipt.BitcoinBlockHash, err = fetchAnchor(dbo, hash)
+	if err != nil {
+		return nil, err
+	}
+	if receipt.BitcoinTransactionHash != nil {
+		receipt.Anchor, err = fetchAnchorEntry(dbo, dBlock)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return receipt, nil
+}
+
+// VerifyFullTransactionReceipt is the counterpart of VerifyFullReceipt for transaction receipts
+func VerifyFullTransactionReceipt(dbo interfaces.DBOverlaySimple, receiptStr string) error {
+	receipt, err := DecodeTransactionReceiptString(receiptStr)
+	if err != nil {
+		return err
+	}
+
+	err = receipt.Validate()
+	if err != nil {
+		return err
+	}
+
+	for i, node := range receipt.MerkleBranch {
+		if node.Left == nil || node.Right == nil {
+			return fmt.Errorf("Node %v/%v has a nil side", i, len(receipt.MerkleBranch))
+		}
+		if node.Top == nil {
+			return fmt.Errorf("Node %v/%v has no top", i, len(receipt.MerkleBranch))
+		}
+	}
+
+	return nil
+}
+
+// findECTransaction returns the transaction of an ECBlock with the given transaction ID or hash
+func findECTransaction(ecBlock interfaces.IEntryCreditBlock, txID interfaces.IHash) interfaces.IECBlockEntry {
+	for _, v := range ecBlock.GetBody().GetEntries() {
+		switch v.ECID() {
+		case constants.ECIDBalanceIncrease, constants.ECIDChainCommit, constants.ECIDEntryCommit:
+			if txID.IsSameAs(v.Hash()) || txID.IsSameAs(v.GetSigHash()) {
+				return v
+			}
+		}
+	}
+	return nil
+}
+
+// marshalECTransaction marshals an entry credit transaction the way the ECBlock body does, that is
+// prefixed with its ECID
+func marshalECTransaction(tx interfaces.IECBlockEntry) ([]byte, error) {
+	data, err := tx.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{tx.ECID()}, data...), nil
+}
@@ -0,0 +1,107 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package receipts_test
+
+import (
+	"testing"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/receipts"
+	. "github.com/FactomProject/factomd/testHelper"
+)
+
+func TestTransactionReceipts(t *testing.T) {
+	dbo := CreateAndPopulateTestDatabaseOverlay()
+	blocks := CreateFullTestBlockSet()
+
+	ids := map[string][]interfaces.IHash{}
+	for _, block := range blocks[:len(blocks)-2] {
+		for _, tx := range block.FBlock.GetTransactions() {
+			ids[TransactionTypeFactoid] = append(ids[TransactionTypeFactoid], tx.GetSigHash(), tx.GetHash())
+		}
+		for _, tx := range block.ECBlock.GetBody().GetEntries() {
+			if tx.ECID() == constants.ECIDEntryCommit || tx.ECID() == constants.ECIDChainCommit {
+				ids[TransactionTypeEntryCredit] = append(ids[TransactionTypeEntryCredit], tx.Hash())
+			}
+		}
+	}
+	if len(ids[TransactionTypeFactoid]) == 0 || len(ids[TransactionTypeEntryCredit]) == 0 {
+		t.Fatalf("Test blocks hold no transactions - %v", ids)
+	}
+
+	for txType, hashes := range ids {
+		for _, h := range hashes {
+			receipt, err := CreateTransactionReceipt(dbo, h)
+			if err != nil {
+				t.Fatalf("%v - %v", h, err)
+			}
+			if receipt.Transaction.Type != txType {
+				t.Errorf("Wrong type %v for %v", receipt.Transaction.Type, h)
+			}
+			if receipt.BitcoinBlockHash == nil {
+				t.Errorf("No Bitcoin Block Hash in receipt!")
+			}
+
+			err = VerifyFullTransactionReceipt(dbo, receipt.CustomMarshalString())
+			if err != nil {
+				t.Errorf("%v - %v", h, err)
+			}
+		}
+	}
+
+	entry := blocks[0].Entries[0].DatabasePrimaryIndex()
+	if _, err := CreateTransactionReceipt(dbo, entry); err == nil {
+		t.Error("Created a transaction receipt for an entry")
+	}
+	if _, err := CreateTransactionReceipt(dbo, primitives.RandomHash()); err == nil {
+		t.Error("Created a transaction receipt for an unknown hash")
+	}
+}
+
+func TestTransactionReceiptTampering(t *testing.T) {
+	dbo := CreateAndPopulateTestDatabaseOverlay()
+	blocks := CreateFullTestBlockSet()
+
+	fctTx := blocks[1].FBlock.GetTransactions()[0].GetSigHash()
+	var ecTx interfaces.IHash
+	for _, tx := range blocks[1].ECBlock.GetBody().GetEntries() {
+		if tx.ECID() == constants.ECIDEntryCommit {
+			ecTx = tx.Hash()
+		}
+	}
+
+	for _, h := range []interfaces.IHash{fctTx, ecTx} {
+		receipt, err := CreateTransactionReceipt(dbo, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		str := receipt.CustomMarshalString()
+
+		tampered := []func(r *TransactionReceipt){
+			func(r *TransactionReceipt) { r.Transaction.TransactionID = primitives.RandomHash().String() },
+			func(r *TransactionReceipt) { r.Transaction.Raw = r.Transaction.Raw[:len(r.Transaction.Raw)-2] + "00" },
+			func(r *TransactionReceipt) { r.DirectoryBlockKeyMR = primitives.RandomHash().(*primitives.Hash) },
+			func(r *TransactionReceipt) { r.MerkleBranch[0].Top = primitives.RandomHash().(*primitives.Hash) },
+			func(r *TransactionReceipt) { r.MerkleBranch = r.MerkleBranch[:len(r.MerkleBranch)-1] },
+		}
+		if receipt.Transaction.Type == TransactionTypeEntryCredit {
+			tampered = append(tampered, func(r *TransactionReceipt) {
+				r.EntryCreditBlock = r.EntryCreditBlock[:len(r.EntryCreditBlock)-2] + "ff"
+			})
+		}
+		for i, tamper := range tampered {
+			r, err := DecodeTransactionReceiptString(str)
+			if err != nil {
+				t.Fatal(err)
+			}
+			tamper(r)
+			if err := r.Validate(); err == nil {
+				t.Errorf("Tampered %v receipt %v passed validation", receipt.Transaction.Type, i)
+			}
+		}
+	}
+}
@@ -0,0 +1,570 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/messages"
+)
+
+// An archive holds a range of directory blocks with everything in them, as WholeBlocks, in a
+// single stream.  It is written front to back, so it can be piped straight into object storage,
+// and read front to back to seed a node.  It is laid out as:
+//
+//	header   ArchiveMagic, then the network ID
+//	blocks   for each directory block, its height, the length of the WholeBlock, the
+//	         WholeBlock and its sha256
+//	end      ArchiveEnd in place of a height
+//	index    the number of blocks, then the height and offset of each
+//	trailer  the sha256 of the index, then the offset of the index
+//
+// All numbers are big endian.  The trailer lets a reader that can seek find any block without
+// reading the ones before it.
+
+var ArchiveMagic = []byte("FCTARCH1")
+
+// ArchiveEnd takes the place of a height after the last block
+const ArchiveEnd = 0xFFFFFFFF
+
+// The largest WholeBlock an archive reader accepts
+const MaxArchiveBlockSize = 1 << 30
+
+const archiveTrailerSize = sha256.Size + 8
+
+// ArchiveIndexEntry is where a directory block is in an archive
+type ArchiveIndexEntry struct {
+	Height uint32
+	Offset uint64
+}
+
+// ArchiveWriter writes WholeBlocks into an archive
+type ArchiveWriter struct {
+	w      *bufio.Writer
+	offset uint64
+	index  []ArchiveIndexEntry
+}
+
+func NewArchiveWriter(w io.Writer, networkID uint32) (*ArchiveWriter, error) {
+	a := new(ArchiveWriter)
+	a.w = bufio.NewWriter(w)
+	err := a.write(ArchiveMagic, archiveUint32(networkID))
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *ArchiveWriter) write(data ...[]byte) error {
+	for _, d := range data {
+		n, err := a.w.Write(d)
+		a.offset += uint64(n)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Write adds a directory block to the archive
+func (a *ArchiveWriter) Write(wb *WholeBlock) error {
+	data, err := wb.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+
+	height := wb.DBlock.GetDatabaseHeight()
+	a.index = append(a.index, ArchiveIndexEntry{Height: height, Offset: a.offset})
+	return a.write(archiveUint32(height), archiveUint32(uint32(len(data))), data, sum[:])
+}
+
+// Close ends the archive with its index, and flushes it.  It does not close the underlying writer.
+func (a *ArchiveWriter) Close() error {
+	err := a.write(archiveUint32(ArchiveEnd))
+	if err != nil {
+		return err
+	}
+
+	indexOffset := a.offset
+	index := new(bytes.Buffer)
+	index.Write(archiveUint32(uint32(len(a.index))))
+	for _, e := range a.index {
+		index.Write(archiveUint32(e.Height))
+		index.Write(archiveUint64(e.Offset))
+	}
+	sum := sha256.Sum256(index.Bytes())
+
+	err = a.write(index.Bytes(), sum[:], archiveUint64(indexOffset))
+	if err != nil {
+		return err
+	}
+	return a.w.Flush()
+}
+
+// ArchiveReader reads the WholeBlocks of an archive in order
+type ArchiveReader struct {
+	r         *bufio.Reader
+	NetworkID uint32
+	done      bool
+}
+
+func NewArchiveReader(r io.Reader) (*ArchiveReader, error) {
+	a := new(ArchiveReader)
+	a.r = bufio.NewReader(r)
+
+	header := make([]byte, len(ArchiveMagic)+4)
+	_, err := io.ReadFull(a.r, header)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(header[:len(ArchiveMagic)], ArchiveMagic) {
+		return nil, fmt.Errorf("Not a block archive")
+	}
+	a.NetworkID = binary.BigEndian.Uint32(header[len(ArchiveMagic):])
+	return a, nil
+}
+
+// NewArchiveReaderAt reads an archive starting at the directory block at the height, or the
+// first one after it, using the index to skip the blocks before it
+func NewArchiveReaderAt(r io.ReadSeeker, height uint32) (*ArchiveReader, error) {
+	_, err := r.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	a, err := NewArchiveReader(r)
+	if err != nil {
+		return nil, err
+	}
+	index, err := ReadArchiveIndex(r)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range index {
+		if e.Height >= height {
+			_, err = r.Seek(int64(e.Offset), io.SeekStart)
+			if err != nil {
+				return nil, err
+			}
+			a.r.Reset(r)
+			return a, nil
+		}
+	}
+	a.done = true
+	return a, nil
+}
+
+// Skip passes over the directory blocks below the height without decoding them.  Unlike
+// NewArchiveReaderAt it reads every block before the height, so it works on a stream.
+func (a *ArchiveReader) Skip(height uint32) error {
+	for !a.done {
+		head, err := a.r.Peek(8)
+		if err != nil {
+			return archiveTruncated(err)
+		}
+		h := binary.BigEndian.Uint32(head)
+		if h == ArchiveEnd || h >= height {
+			return nil
+		}
+		length := binary.BigEndian.Uint32(head[4:])
+		if length > MaxArchiveBlockSize {
+			return fmt.Errorf("Block %d in the archive is too large (%d bytes)", h, length)
+		}
+		_, err = a.r.Discard(8 + int(length) + sha256.Size)
+		if err != nil {
+			return archiveTruncated(err)
+		}
+	}
+	return nil
+}
+
+// Next returns the next directory block in the archive, or io.EOF after the last one
+func (a *ArchiveReader) Next() (*WholeBlock, error) {
+	if a.done {
+		return nil, io.EOF
+	}
+
+	head := make([]byte, 4)
+	_, err := io.ReadFull(a.r, head)
+	if err != nil {
+		return nil, archiveTruncated(err)
+	}
+	height := binary.BigEndian.Uint32(head)
+	if height == ArchiveEnd {
+		a.done = true
+		return nil, io.EOF
+	}
+
+	_, err = io.ReadFull(a.r, head)
+	if err != nil {
+		return nil, archiveTruncated(err)
+	}
+	length := binary.BigEndian.Uint32(head)
+	if length > MaxArchiveBlockSize {
+		return nil, fmt.Errorf("Block %d in the archive is too large (%d bytes)", height, length)
+	}
+
+	data := make([]byte, int(length)+sha256.Size)
+	_, err = io.ReadFull(a.r, data)
+	if err != nil {
+		return nil, archiveTruncated(err)
+	}
+	sum := sha256.Sum256(data[:length])
+	if !bytes.Equal(sum[:], data[length:]) {
+		return nil, fmt.Errorf("Block %d in the archive is corrupted", height)
+	}
+
+	wb := NewWholeBlock()
+	err = wb.UnmarshalBinary(data[:length])
+	if err != nil {
+		return nil, err
+	}
+	if wb.DBlock.GetDatabaseHeight() != height {
+		return nil, fmt.Errorf("Block %d in the archive holds directory block %d", height, wb.DBlock.GetDatabaseHeight())
+	}
+	return wb, nil
+}
+
+// ReadArchiveIndex reads the index at the end of an archive
+func ReadArchiveIndex(r io.ReadSeeker) ([]ArchiveIndexEntry, error) {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if size < int64(len(ArchiveMagic)+4+4+4+archiveTrailerSize) {
+		return nil, fmt.Errorf("Archive is truncated")
+	}
+	_, err = r.Seek(size-archiveTrailerSize, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	trailer := make([]byte, archiveTrailerSize)
+	_, err = io.ReadFull(r, trailer)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := binary.BigEndian.Uint64(trailer[sha256.Size:])
+	if offset+4 > uint64(size-archiveTrailerSize) {
+		return nil, fmt.Errorf("Archive index is corrupted")
+	}
+	_, err = r.Seek(int64(offset), io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+	data := make([]byte, uint64(size-archiveTrailerSize)-offset)
+	_, err = io.ReadFull(r, data)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if !bytes.Equal(sum[:], trailer[:sha256.Size]) {
+		return nil, fmt.Errorf("Archive index is corrupted")
+	}
+
+	count := binary.BigEndian.Uint32(data)
+	data = data[4:]
+	if uint64(len(data)) != uint64(count)*12 {
+		return nil, fmt.Errorf("Archive index is corrupted")
+	}
+	index := make([]ArchiveIndexEntry, count)
+	for i := range index {
+		index[i].Height = binary.BigEndian.Uint32(data)
+		index[i].Offset = binary.BigEndian.Uint64(data[4:])
+		data = data[12:]
+	}
+	return index, nil
+}
+
+func archiveTruncated(err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("Archive is truncated")
+	}
+	return err
+}
+
+func archiveUint32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, v)
+	return b
+}
+
+func archiveUint64(v uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, v)
+	return b
+}
+
+// ExportArchive writes the directory blocks from one height to another into an archive file,
+// and returns how many it wrote.  A pruned directory block can not be exported.
+func (s *State) ExportArchive(path string, from uint32, to uint32) (uint32, error) {
+	if to < from {
+		return 0, fmt.Errorf("Nothing to export from %d to %d", from, to)
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	a, err := NewArchiveWriter(file, s.GetNetworkID())
+	if err != nil {
+		return 0, err
+	}
+
+	var count uint32
+	for h := from; h <= to; h++ {
+		wb, err := s.wholeBlock(h)
+		if err != nil {
+			return count, err
+		}
+		err = a.Write(wb)
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	err = a.Close()
+	if err != nil {
+		return count, err
+	}
+	return count, file.Sync()
+}
+
+// wholeBlock gathers the directory block at the height with its signatures, entry blocks and
+// entries
+func (s *State) wholeBlock(dbheight uint32) (*WholeBlock, error) {
+	msg, err := s.LoadDBState(dbheight)
+	if err != nil {
+		return nil, err
+	}
+	if msg == nil {
+		return nil, fmt.Errorf("Directory block %d is not in the database", dbheight)
+	}
+	d := msg.(*messages.DBStateMsg)
+
+	wb := NewWholeBlock()
+	wb.DBlock = d.DirectoryBlock
+	wb.ABlock = d.AdminBlock
+	wb.FBlock = d.FactoidBlock
+	wb.ECBlock = d.EntryCreditBlock
+	wb.SigList = d.SignatureList.List
+
+	// LoadDBState leaves out most entries, so they are all fetched here
+	for _, v := range d.DirectoryBlock.GetEBlockDBEntries() {
+		eblock, err := s.DB.FetchEBlock(v.GetKeyMR())
+		if err != nil {
+			return nil, err
+		}
+		if eblock == nil {
+			return nil, fmt.Errorf("Entry block %x of directory block %d is missing or pruned", v.GetKeyMR().Bytes(), dbheight)
+		}
+		wb.AddEblock(eblock)
+		for _, e := range eblock.GetEntryHashes() {
+			if e.IsMinuteMarker() {
+				continue
+			}
+			entry, err := s.DB.FetchEntry(e)
+			if err != nil {
+				return nil, err
+			}
+			if entry == nil {
+				return nil, fmt.Errorf("Entry %x of directory block %d is missing or pruned", e.Bytes(), dbheight)
+			}
+			wb.AddIEBEntry(entry)
+		}
+	}
+	return wb, nil
+}
+
+// ArchiveImportAhead is how many blocks of an archive are handed to the node ahead of those it
+// has saved, as it holds the blocks it can not save yet in memory
+var ArchiveImportAhead uint32 = 50
+
+// ArchiveImportTimeout is how long an import waits for the node to save the next block it was
+// handed before it gives up on the archive
+var ArchiveImportTimeout = 5 * time.Minute
+
+// ImportArchive hands the directory blocks of an archive file that follow on from the database to
+// the node, and returns how many of them were saved.  It is meant to run once the database is
+// loaded.  Each block is checked against the one before it and the data with it, and then goes
+// through the node as a DBState, so its signatures are checked against the authority set before
+// it is saved, as those of a DBState from a peer are.  Entries left out of the archive are picked
+// up by the entry sync as usual.
+//
+// A regular file is read from the first block the database is missing, found through the index.
+// Anything else, such as a pipe, or standard input when the path is "-", is read as a stream
+// with ImportArchiveStream.
+func (s *State) ImportArchive(path string) (uint32, error) {
+	if path == "-" {
+		return s.ImportArchiveStream(os.Stdin)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return 0, err
+	}
+	if !info.Mode().IsRegular() {
+		return s.ImportArchiveStream(file)
+	}
+
+	start, prevKeyMR, err := s.archiveStart()
+	if err != nil {
+		return 0, err
+	}
+	a, err := NewArchiveReaderAt(file, start)
+	if err != nil {
+		return 0, err
+	}
+	return s.importArchive(a, start, prevKeyMR)
+}
+
+// ImportArchiveStream does what ImportArchive does for an archive read front to back, such as
+// one piped in from object storage.  The blocks the database already has are read and skipped,
+// as the index at the end of the archive can not be reached first.
+func (s *State) ImportArchiveStream(r io.Reader) (uint32, error) {
+	start, prevKeyMR, err := s.archiveStart()
+	if err != nil {
+		return 0, err
+	}
+	a, err := NewArchiveReader(r)
+	if err != nil {
+		return 0, err
+	}
+	if a.NetworkID == s.GetNetworkID() {
+		err = a.Skip(start)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return s.importArchive(a, start, prevKeyMR)
+}
+
+// archiveStart returns the height of the first directory block an import needs, and the key
+// of the one before it
+func (s *State) archiveStart() (uint32, []byte, error) {
+	head, err := s.DB.FetchDBlockHead()
+	if err != nil {
+		return 0, nil, err
+	}
+	if head == nil {
+		return 0, nil, fmt.Errorf("The database has to be loaded before an archive is imported")
+	}
+	return head.GetDatabaseHeight() + 1, head.GetKeyMR().Bytes(), nil
+}
+
+// importArchive hands the node the blocks of an archive from start on
+func (s *State) importArchive(a *ArchiveReader, start uint32, prevKeyMR []byte) (uint32, error) {
+	if a.NetworkID != s.GetNetworkID() {
+		return 0, fmt.Errorf("Archive is for network %x, not %x", a.NetworkID, s.GetNetworkID())
+	}
+
+	next := start
+	for ; ; next++ {
+		wb, err := a.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return s.archiveBlocksSaved(start), err
+		}
+
+		err = s.checkArchivedBlock(wb, next, prevKeyMR)
+		if err != nil {
+			return s.archiveBlocksSaved(start), err
+		}
+		msg := wb.BlockToDBStateMsg()
+		msg.SetLocal(true)
+		s.LogMessage("InMsgQueue", "enqueue_ImportArchive", msg)
+		s.InMsgQueue().Enqueue(msg)
+		prevKeyMR = wb.DBlock.GetKeyMR().Bytes()
+
+		if next >= start+ArchiveImportAhead {
+			err = s.waitForSavedBlock(next - ArchiveImportAhead)
+			if err != nil {
+				return s.archiveBlocksSaved(start), err
+			}
+		}
+	}
+	if next > start {
+		err := s.waitForSavedBlock(next - 1)
+		return s.archiveBlocksSaved(start), err
+	}
+	return s.archiveBlocksSaved(start), nil
+}
+
+// waitForSavedBlock waits for the node to save the directory block at height.  A block the node
+// turns down, such as one without the signatures of the authority set, is never saved, so the
+// wait gives up once no block has been saved for ArchiveImportTimeout.
+func (s *State) waitForSavedBlock(height uint32) error {
+	saved := s.GetHighestSavedBlk()
+	since := time.Now()
+	for saved < height {
+		if time.Since(since) > ArchiveImportTimeout {
+			return fmt.Errorf("Directory block %d from the archive was not saved, it may not be signed by the authority set", saved+1)
+		}
+		time.Sleep(100 * time.Millisecond)
+		if highest := s.GetHighestSavedBlk(); highest > saved {
+			saved = highest
+			since = time.Now()
+		}
+	}
+	return nil
+}
+
+// archiveBlocksSaved returns how many blocks the node has saved from start on
+func (s *State) archiveBlocksSaved(start uint32) uint32 {
+	saved := s.GetHighestSavedBlk()
+	if saved < start {
+		return 0
+	}
+	return saved - start + 1
+}
+
+// checkArchivedBlock makes sure a directory block from an archive is the one expected at the
+// height, and that the blocks and entries with it are those it lists
+func (s *State) checkArchivedBlock(wb *WholeBlock, height uint32, prevKeyMR []byte) error {
+	dblock := wb.DBlock
+	if dblock.GetDatabaseHeight() != height {
+		return fmt.Errorf("Archive has directory block %d where %d was expected", dblock.GetDatabaseHeight(), height)
+	}
+	if dblock.GetHeader().GetNetworkID() != s.GetNetworkID() {
+		return fmt.Errorf("Directory block %d in the archive is for another network", height)
+	}
+	if prevKeyMR != nil && !bytes.Equal(dblock.GetHeader().GetPrevKeyMR().Bytes(), prevKeyMR) {
+		return fmt.Errorf("Directory block %d in the archive does not follow on from the one before it", height)
+	}
+	if s.GetNetworkID() == constants.MAIN_NETWORK_ID {
+		key := constants.CheckPoints[height]
+		if key != "" && key != dblock.DatabasePrimaryIndex().String() {
+			return fmt.Errorf("Directory block %d in the archive fails its checkpoint", height)
+		}
+	}
+
+	msg := wb.BlockToDBStateMsg().(*messages.DBStateMsg)
+	if msg.ValidateData(s) != 1 {
+		return fmt.Errorf("Directory block %d in the archive does not match the blocks and entries with it", height)
+	}
+	if len(wb.EBlocks) != len(dblock.GetEBlockDBEntries()) {
+		return fmt.Errorf("Directory block %d in the archive is missing entry blocks", height)
+	}
+	return nil
+}
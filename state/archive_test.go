@@ -0,0 +1,188 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/messages"
+	. "github.com/FactomProject/factomd/state"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := testHelper.CreateAndPopulateTestState()
+	last := uint32(testHelper.BlockCount - 2)
+
+	first := filepath.Join(dir, "first.archive")
+	n, err := s.ExportArchive(first, 0, 4)
+	if err != nil || n != 5 {
+		t.Fatalf("Exported %d blocks, expected 5 - %v", n, err)
+	}
+	second := filepath.Join(dir, "second.archive")
+	n, err = s.ExportArchive(second, 3, last)
+	if err != nil || n != last-2 {
+		t.Fatalf("Exported %d blocks, expected %d - %v", n, last-2, err)
+	}
+
+	// Read front to back
+	file, err := os.Open(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	a, err := NewArchiveReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.NetworkID != constants.LOCAL_NETWORK_ID {
+		t.Errorf("Archive network is %x", a.NetworkID)
+	}
+	for h := uint32(3); h <= last; h++ {
+		wb, err := a.Next()
+		if err != nil {
+			t.Fatalf("Error reading block %d - %v", h, err)
+		}
+		dblock, err := s.DB.FetchDBlockByHeight(h)
+		if err != nil || dblock == nil {
+			t.Fatalf("Missing directory block %d - %v", h, err)
+		}
+		if !wb.DBlock.GetKeyMR().IsSameAs(dblock.GetKeyMR()) {
+			t.Errorf("Archived directory block %d differs", h)
+		}
+		if len(wb.EBlocks) != len(dblock.GetEBlockDBEntries()) {
+			t.Errorf("Archived directory block %d has %d entry blocks, expected %d", h, len(wb.EBlocks), len(dblock.GetEBlockDBEntries()))
+		}
+	}
+	if _, err = a.Next(); err != io.EOF {
+		t.Errorf("Expected the end of the archive, got %v", err)
+	}
+
+	// Skip ahead with the index
+	index, err := ReadArchiveIndex(file)
+	if err != nil || len(index) != int(last-2) || index[0].Height != 3 {
+		t.Fatalf("Bad index %v - %v", index, err)
+	}
+	a, err = NewArchiveReaderAt(file, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wb, err := a.Next()
+	if err != nil || wb.DBlock.GetDatabaseHeight() != 6 {
+		t.Errorf("Expected directory block 6 - %v", err)
+	}
+
+	// The blocks of an archive go to the node as DBStates rather than straight into the database,
+	// so they are only saved once the node has checked their signatures.  This node is not
+	// processing its messages, so none are saved and the import gives up on them.
+	defer func(count int, timeout time.Duration) {
+		testHelper.BlockCount = count
+		ArchiveImportTimeout = timeout
+	}(testHelper.BlockCount, ArchiveImportTimeout)
+	testHelper.BlockCount = 5
+	ArchiveImportTimeout = time.Second
+	seeded := testHelper.CreateAndPopulateTestState()
+
+	n, err = seeded.ImportArchive(first)
+	if err != nil || n != 0 {
+		t.Errorf("Imported %d blocks the database already has - %v", n, err)
+	}
+	n, err = seeded.ImportArchive(second)
+	if err == nil || n != 0 {
+		t.Errorf("Imported %d blocks the node did not save - %v", n, err)
+	}
+	head, err := seeded.DB.FetchDBlockHead()
+	if err != nil || head == nil || head.GetDatabaseHeight() != 4 {
+		t.Errorf("Archived blocks were written into the database - %v", err)
+	}
+	next := uint32(5)
+	for seeded.InMsgQueue().Length() > 0 {
+		msg, ok := seeded.InMsgQueue().Dequeue().(*messages.DBStateMsg)
+		if !ok || msg.DirectoryBlock.GetDatabaseHeight() < 5 {
+			continue
+		}
+		if msg.DirectoryBlock.GetDatabaseHeight() != next || msg.IsInDB || msg.IgnoreSigs {
+			t.Errorf("Directory block %d was handed over without its signatures to check", msg.DirectoryBlock.GetDatabaseHeight())
+		}
+		next++
+	}
+	if next != last+1 {
+		t.Errorf("Handed over the blocks up to %d rather than %d", next-1, last)
+	}
+
+	// A stream, which can not seek to the index, skips the blocks the database has by reading them
+	stream, err := ioutil.ReadFile(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err = NewArchiveReader(bytes.NewReader(stream))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = a.Skip(6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wb, err = a.Next()
+	if err != nil || wb.DBlock.GetDatabaseHeight() != 6 {
+		t.Errorf("Expected directory block 6 after skipping - %v", err)
+	}
+
+	n, err = seeded.ImportArchiveStream(io.MultiReader(bytes.NewReader(stream)))
+	if err == nil || n != 0 {
+		t.Errorf("Imported %d streamed blocks the node did not save - %v", n, err)
+	}
+	next = uint32(5)
+	for seeded.InMsgQueue().Length() > 0 {
+		msg, ok := seeded.InMsgQueue().Dequeue().(*messages.DBStateMsg)
+		if !ok || msg.DirectoryBlock.GetDatabaseHeight() < 5 {
+			continue
+		}
+		if msg.DirectoryBlock.GetDatabaseHeight() != next {
+			t.Errorf("Streamed directory block %d out of order", msg.DirectoryBlock.GetDatabaseHeight())
+		}
+		next++
+	}
+	if next != last+1 {
+		t.Errorf("Streamed the blocks up to %d rather than %d", next-1, last)
+	}
+	firstData, err := ioutil.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = seeded.ImportArchiveStream(io.MultiReader(bytes.NewReader(firstData)))
+	if err != nil || n != 0 {
+		t.Errorf("Imported %d streamed blocks the database already has - %v", n, err)
+	}
+
+	// A damaged block is caught by its checksum before it is handed to the node
+	data, err := ioutil.ReadFile(second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data[index[2].Offset+8+100] ^= 0xff
+	damaged := filepath.Join(dir, "damaged.archive")
+	err = ioutil.WriteFile(damaged, data, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n, err = seeded.ImportArchive(damaged)
+	if err == nil || n != 0 {
+		t.Errorf("A damaged archive should not import")
+	}
+}
@@ -1473,9 +1473,11 @@ func (list *DBStateList) SaveDBStateToDB(d *DBState) (progress bool) {
 			list.State.LogPrintf("dbstateprocess", "Error saving eblock from dbstate, eblock not allowed")
 		}
 	}
+	savedEntries := make([]interfaces.IEBEntry, 0, len(d.Entries))
 	for _, e := range d.Entries {
 		// If it's in the DBlock
 		list.State.WriteEntry <- e
+		savedEntries = append(savedEntries, e)
 	}
 	list.State.NumEntries += len(d.Entries)
 	list.State.NumEntryBlocks += len(d.EntryBlocks)
@@ -1515,7 +1517,11 @@ func (list *DBStateList) SaveDBStateToDB(d *DBState) (progress bool) {
 			}
 			if _, ok := allowedEBlocks[keymr.Fixed()]; ok {
 				for _, e := range eb.GetBody().GetEBEntries() {
-					pl.State.WriteEntry <- pl.GetNewEntry(e.Fixed())
+					entry := pl.GetNewEntry(e.Fixed())
+					pl.State.WriteEntry <- entry
+					if entry != nil {
+						savedEntries = append(savedEntries, entry)
+					}
 				}
 			} else {
 				list.State.LogPrintf("dbstateprocess", "Error saving eblock from process list, eblock not allowed")
@@ -1594,6 +1600,9 @@ func (list *DBStateList) SaveDBStateToDB(d *DBState) (progress bool) {
 	list.State.ECBalancesPapi = nil
 	list.State.ECBalancesPMutex.Unlock()
 
+	// Let the API subscribers know the block and everything in it is confirmed
+	list.State.EmitSavedBlockEvents(d, savedEntries)
+
 	return
 }
 
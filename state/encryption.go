@@ -0,0 +1,134 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/securedb"
+)
+
+// Environment variables holding the database passwords.  They take precedence over the key files,
+// so a password never has to be written to disk.
+const (
+	DBPasswordEnv    = "FACTOMD_DB_PASSWORD"
+	DBNewPasswordEnv = "FACTOMD_DB_NEW_PASSWORD"
+)
+
+// setDB puts the overlay over the database just opened.  A read only database goes behind a
+// reopenableDB, so ReopenDB can put a newer copy in its place.
+func (s *State) setDB(dbase interfaces.IDatabase) error {
+	db, err := s.encryptDB(dbase)
+	if err != nil {
+		return err
+	}
+	if s.ReadOnlyDB {
+		db = newReopenableDB(db)
+	}
+	s.DB = databaseOverlay.NewOverlay(db)
+	return nil
+}
+
+// encryptDB returns the database just opened, ready for the overlay.  With EncryptDB set, the
+// records are encrypted through securedb, and if a new password is given the whole database is
+// re-encrypted with it before the node starts.  The database is closed on an error.
+func (s *State) encryptDB(dbase interfaces.IDatabase) (interfaces.IDatabase, error) {
+	encrypted, err := dbase.DoesKeyExist(securedb.EncyptedMetaData, securedb.EncyptedMetaData)
+	if err != nil {
+		dbase.Close()
+		return nil, err
+	}
+	if !s.EncryptDB {
+		if encrypted {
+			dbase.Close()
+			return nil, fmt.Errorf("The database is encrypted, set EncryptDB and give its password to open it")
+		}
+		return dbase, nil
+	}
+	if !encrypted {
+		// securedb can not read records written in plaintext, so an existing database is not
+		// encrypted in place
+		blocks, err := hasBlocks(dbase)
+		if err == nil && blocks {
+			err = fmt.Errorf("EncryptDB is set, but the database holds blocks that are not encrypted; start from a new database to encrypt it")
+		}
+		if err != nil {
+			dbase.Close()
+			return nil, err
+		}
+	}
+
+	password, err := dbPassword(DBPasswordEnv, s.DBKeyFile)
+	if err == nil && password == "" {
+		err = fmt.Errorf("EncryptDB is set, but no password is given in %s or DBKeyFile", DBPasswordEnv)
+	}
+	if err != nil {
+		dbase.Close()
+		return nil, err
+	}
+	edb, err := securedb.NewEncryptedDBFrom(dbase, password)
+	if err != nil {
+		dbase.Close()
+		return nil, err
+	}
+	overlay := databaseOverlay.NewOverlay(edb)
+
+	newPassword, err := dbPassword(DBNewPasswordEnv, s.DBNewKeyFile)
+	if err != nil {
+		overlay.Close()
+		return nil, err
+	}
+	if newPassword != "" && newPassword != password {
+		if s.ReadOnlyDB {
+			overlay.Close()
+			return nil, fmt.Errorf("A read only database can not be re-encrypted with a new password")
+		}
+		buckets, err := overlay.FetchAllBuckets()
+		if err == nil {
+			fmt.Fprintln(os.Stderr, "Database: re-encrypting with the new password")
+			err = edb.ChangePassword(newPassword, buckets)
+		}
+		if err != nil {
+			overlay.Close()
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Database: re-encrypted, the new password is needed from now on\n")
+	} else if edb.IsChangingPassword() {
+		overlay.Close()
+		return nil, fmt.Errorf("A change of the database password was cut short, give the new password in %s or DBNewKeyFile to finish it", DBNewPasswordEnv)
+	}
+
+	return edb, nil
+}
+
+// hasBlocks tells whether the database holds any directory block
+func hasBlocks(dbase interfaces.IDatabase) (bool, error) {
+	it, err := dbase.NewIterator(databaseOverlay.DIRECTORYBLOCK_NUMBER, &interfaces.IteratorOptions{Limit: 1})
+	if err != nil {
+		return false, err
+	}
+	defer it.Release()
+	found := it.Next()
+	return found, it.Error()
+}
+
+// dbPassword reads a password from the environment variable, or else from the key file
+func dbPassword(env string, keyFile string) (string, error) {
+	password := os.Getenv(env)
+	if password != "" || keyFile == "" {
+		return password, nil
+	}
+
+	data, err := ioutil.ReadFile(keyFile)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -0,0 +1,83 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/securedb"
+	. "github.com/FactomProject/factomd/state"
+)
+
+func TestEncryptDB(t *testing.T) {
+	os.Unsetenv(DBPasswordEnv)
+
+	s := new(State)
+	s.EncryptDB = true
+	if s.InitMapDB() == nil {
+		t.Error("Should error without a password")
+	}
+
+	os.Setenv(DBPasswordEnv, "password")
+	defer os.Unsetenv(DBPasswordEnv)
+	err := s.InitMapDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.DB.(*databaseOverlay.Overlay).DB.(*securedb.EncryptedDB); !ok {
+		t.Error("Database should be encrypted")
+	}
+
+	s = new(State)
+	err = s.InitMapDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.DB.(*databaseOverlay.Overlay).DB.(*securedb.EncryptedDB); ok {
+		t.Error("Database should not be encrypted")
+	}
+}
+
+func TestEncryptDBRefusesMismatch(t *testing.T) {
+	os.Setenv(DBPasswordEnv, "password")
+	defer os.Unsetenv(DBPasswordEnv)
+	dir, err := ioutil.TempDir("", "encryptdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	open := func(encrypt bool) (*State, error) {
+		s := new(State)
+		s.LdbPath = dir
+		s.Network = "LOCAL"
+		s.EncryptDB = encrypt
+		return s, s.InitLevelDB()
+	}
+
+	s, err := open(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.DB.(*databaseOverlay.Overlay).Put(databaseOverlay.DIRECTORYBLOCK_NUMBER, []byte{0, 0, 0, 0}, primitives.NewZeroHash())
+	s.DB.Close()
+	if _, err = open(true); err == nil {
+		t.Error("Encrypted a database holding plaintext blocks")
+	}
+
+	os.RemoveAll(dir)
+	s, err = open(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.DB.Close()
+	if _, err = open(false); err == nil {
+		t.Error("Opened an encrypted database without EncryptDB")
+	}
+}
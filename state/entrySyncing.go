@@ -295,6 +295,13 @@ func (s *State) GoSyncEntries() {
 					panic(err)
 				}
 
+				// A pruning node deleted it along with its entries, so there is nothing to sync
+				if eBlock == nil {
+					if pruned, _ := s.DB.IsPruned(ebKeyMR); pruned {
+						continue
+					}
+				}
+
 				// Don't have an eBlock?  Huh. We can go on, but we can't advance.  We just wait until it
 				// does show up.
 				for eBlock == nil {
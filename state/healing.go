@@ -0,0 +1,111 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"time"
+
+	"github.com/FactomProject/factomd/common/messages"
+)
+
+// How long to wait for a peer to send a corrupted directory block before asking again
+const healAskInterval = 30 * time.Second
+
+// QuarantineDBState is called by the database when a block of the directory block at the height
+// is found corrupted, so it is asked for again from our peers
+func (s *State) QuarantineDBState(dbheight uint32) {
+	s.QuarantinedMutex.Lock()
+	defer s.QuarantinedMutex.Unlock()
+
+	if s.Quarantined == nil {
+		s.Quarantined = map[uint32]time.Time{}
+	}
+	if _, ok := s.Quarantined[dbheight]; !ok {
+		s.LogPrintf("healing", "Directory block %d is corrupted", dbheight)
+		s.Quarantined[dbheight] = time.Time{}
+	}
+}
+
+// IsQuarantined tells whether the directory block at the height has corrupted blocks waiting on
+// a good copy from our peers
+func (s *State) IsQuarantined(dbheight uint32) bool {
+	s.QuarantinedMutex.Lock()
+	defer s.QuarantinedMutex.Unlock()
+
+	_, ok := s.Quarantined[dbheight]
+	return ok
+}
+
+// GoHealDBStates asks our peers for the directory blocks found corrupted, including those left
+// over from before a restart.  It does nothing unless blocks are verified.
+func (s *State) GoHealDBStates() {
+	if s.VerifyBlocks == 0 {
+		return
+	}
+
+	heights, err := s.DB.FetchQuarantinedHeights()
+	if err != nil {
+		s.LogPrintf("healing", "Error reading the quarantined heights: %v", err)
+	}
+	for _, h := range heights {
+		s.QuarantineDBState(h)
+	}
+
+	for {
+		time.Sleep(5 * time.Second)
+
+		now := time.Now()
+		var ask []uint32
+		s.QuarantinedMutex.Lock()
+		for h, asked := range s.Quarantined {
+			if now.Sub(asked) > healAskInterval {
+				s.Quarantined[h] = now
+				ask = append(ask, h)
+			}
+		}
+		s.QuarantinedMutex.Unlock()
+
+		for _, h := range ask {
+			s.LogPrintf("healing", "Asking for directory block %d", h)
+			msg := messages.NewDBStateMissing(s, h, h)
+			msg.SendOut(s, msg)
+		}
+	}
+}
+
+// HealDBState writes the blocks of a DBState over those of a quarantined directory block, and
+// reports whether the DBState was used for that.  The DBState has to match the directory block
+// key we already have at the height, so a peer can only send us back what we once had.
+func (s *State) HealDBState(msg *messages.DBStateMsg) bool {
+	if msg.IsInDB {
+		return false
+	}
+	dbheight := msg.DirectoryBlock.GetDatabaseHeight()
+	if !s.IsQuarantined(dbheight) {
+		return false
+	}
+
+	keyMR, err := s.DB.FetchDBKeyMRByHeight(dbheight)
+	if err != nil || keyMR == nil || !keyMR.IsSameAs(msg.DirectoryBlock.GetKeyMR()) {
+		s.LogPrintf("healing", "Directory block %d from a peer does not match ours", dbheight)
+		return true
+	}
+	if msg.ValidateData(s) != 1 {
+		s.LogPrintf("healing", "Directory block %d from a peer is missing blocks or entries", dbheight)
+		return true
+	}
+
+	err = s.DB.RestoreDBState(msg.DirectoryBlock, msg.AdminBlock, msg.FactoidBlock, msg.EntryCreditBlock, msg.EBlocks, msg.Entries)
+	if err != nil {
+		s.LogPrintf("healing", "Error restoring directory block %d: %v", dbheight, err)
+		return true
+	}
+
+	s.QuarantinedMutex.Lock()
+	delete(s.Quarantined, dbheight)
+	s.QuarantinedMutex.Unlock()
+	s.LogPrintf("healing", "Directory block %d is healed", dbheight)
+	return true
+}
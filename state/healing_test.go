@@ -0,0 +1,69 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state_test
+
+import (
+	"testing"
+
+	"github.com/FactomProject/factomd/common/messages"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestHealDBState(t *testing.T) {
+	s := testHelper.CreateAndPopulateTestState()
+	s.DB.SetBlockVerification(1, s.QuarantineDBState)
+
+	// What a peer would send us
+	m, err := s.LoadDBState(3)
+	if err != nil || m == nil {
+		t.Fatalf("Could not load DBState 3 - %v", err)
+	}
+	msg := m.(*messages.DBStateMsg)
+	msg.IsInDB = false
+
+	if s.HealDBState(msg) {
+		t.Errorf("A DBState that is not quarantined should not be used to heal")
+	}
+
+	dblock := msg.DirectoryBlock
+	raw, err := dblock.MarshalBinary()
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	raw[len(raw)-1] ^= 0xff
+	dbo := s.DB.(*databaseOverlay.Overlay)
+	err = dbo.Put(databaseOverlay.DIRECTORYBLOCK, dblock.DatabasePrimaryIndex().Bytes(), &primitives.ByteSlice{Bytes: raw})
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	b, err := s.DB.FetchDBlock(dblock.DatabasePrimaryIndex())
+	if err != nil || b != nil {
+		t.Errorf("Corrupted directory block was returned - %v", err)
+	}
+	if !s.IsQuarantined(3) {
+		t.Fatalf("Corrupted directory block was not quarantined")
+	}
+	if msg.Validate(s) != 1 {
+		t.Errorf("A DBState for a quarantined height should be valid")
+	}
+
+	if !s.HealDBState(msg) {
+		t.Errorf("A DBState for a quarantined height should be used to heal")
+	}
+	if s.IsQuarantined(3) {
+		t.Errorf("Directory block is still quarantined")
+	}
+	b, err = s.DB.FetchDBlock(dblock.DatabasePrimaryIndex())
+	if err != nil || b == nil {
+		t.Errorf("Directory block was not healed - %v", err)
+	}
+	heights, err := s.DB.FetchQuarantinedHeights()
+	if err != nil || len(heights) != 0 {
+		t.Errorf("Quarantine was not cleared - %v %v", heights, err)
+	}
+}
@@ -261,6 +261,16 @@ var (
 		Name: "factomd_state_execute_msg_time",
 		Help: "Time spent in executeMsg",
 	})
+
+	// API Events
+	TotalStateEventsEmitted = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "factomd_state_events_emitted_total",
+		Help: "Events pushed to API subscribers",
+	})
+	TotalStateEventsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "factomd_state_events_dropped_total",
+		Help: "Events dropped because a subscriber's channel was full",
+	})
 )
 
 var registered bool = false
@@ -352,4 +362,8 @@ func RegisterPrometheus() {
 	prometheus.MustRegister(TotalEmptyLoopTime)
 	prometheus.MustRegister(TotalAckLoopTime)
 	prometheus.MustRegister(TotalExecuteMsgTime)
+
+	// API Events
+	prometheus.MustRegister(TotalStateEventsEmitted)
+	prometheus.MustRegister(TotalStateEventsDropped)
 }
@@ -40,13 +40,23 @@ func PrintState(state *State) {
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "LogPath", state.LogPath)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "LdbPath", state.LdbPath)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "BoltDBPath", state.BoltDBPath)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "BadgerDBPath", state.BadgerDBPath)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "LogLevel", state.LogLevel)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "ConsoleLogLevel", state.ConsoleLogLevel)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "NodeMode", state.NodeMode)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "PruneDepth", state.PruneDepth)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "DBType", state.DBType)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "CloneDBType", state.CloneDBType)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "ExportData", state.ExportData)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "ExportDataSubpath", state.ExportDataSubpath)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "AddressIndex", state.AddressIndex)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "VerifyBlocks", state.VerifyBlocks)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "BlockCacheSize", state.BlockCacheSize)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "EncryptDB", state.EncryptDB)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "DBKeyFile", state.DBKeyFile)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "DBNewKeyFile", state.DBNewKeyFile)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "ReadOnlyDB", state.ReadOnlyDB)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "ReopenDBSeconds", state.ReopenDBSeconds)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "LocalServerPrivKey", state.LocalServerPrivKey)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "DirectoryBlockInSeconds", state.DirectoryBlockInSeconds)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "PortNumber", state.PortNumber)
@@ -70,6 +80,15 @@ func PrintState(state *State) {
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "CustomNetworkPort", state.CustomNetworkPort)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "CustomSeedURL", state.CustomSeedURL)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "CustomSpecialPeers", state.CustomSpecialPeers)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "P2PEncryption", state.P2PEncryption)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "P2PKeyFile", state.P2PKeyFile)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "P2PEncryptSpecialPeers", state.P2PEncryptSpecialPeers)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "P2PPlainFallback", state.P2PPlainFallback)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "BanDuration", state.BanDuration)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "P2PPeerUploadRate", state.P2PPeerUploadRate)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "P2PPeerDownloadRate", state.P2PPeerDownloadRate)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "P2PTotalUploadRate", state.P2PTotalUploadRate)
+	str = fmt.Sprintf("%s %35s = %+v\n", str, "P2PTotalDownloadRate", state.P2PTotalDownloadRate)
 	str = fmt.Sprintf("%s %35s = %+v(%s)\n", str, "CustomNetworkID", state.CustomNetworkID, globals.Params.CustomNetName)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "IdentityChainID", state.IdentityChainID)
 	str = fmt.Sprintf("%s %35s = %+v\n", str, "Identities", state.IdentityControl.GetIdentities())
@@ -0,0 +1,153 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+)
+
+// The smallest PruneDepth a PRUNED node accepts.  The entries of the last few blocks are needed
+// to follow the network, whatever the operator asks for.
+const MinimumPruneDepth = 10
+
+// GetPruneDepth returns how many of the latest directory blocks keep their entry blocks and
+// entries, or 0 if this node keeps them all
+func (s *State) GetPruneDepth() uint32 {
+	if s.NodeMode != "PRUNED" {
+		return 0
+	}
+	return s.PruneDepth
+}
+
+// GoPruneEntries deletes the entry blocks and entries of the directory blocks that fall more than
+// PruneDepth blocks behind the highest saved block.  It does nothing unless the node is PRUNED.
+func (s *State) GoPruneEntries() {
+	if s.GetPruneDepth() == 0 {
+		return
+	}
+
+	next, err := s.DB.FetchPrunedHeight()
+	if err != nil {
+		s.LogPrintf("pruning", "Error reading the pruned height: %v", err)
+		return
+	}
+
+	for {
+		time.Sleep(time.Second)
+
+		// Blocks the entry sync has not finished with are left alone, so it never waits on an
+		// entry block we deleted
+		highest := s.GetHighestSavedBlk()
+		if s.EntryDBHeightComplete < highest {
+			highest = s.EntryDBHeightComplete
+		}
+		next, err = s.PruneEntries(next, highest)
+		if err != nil {
+			s.LogPrintf("pruning", "Error pruning directory block %d: %v", next, err)
+			time.Sleep(10 * time.Second)
+		}
+	}
+}
+
+// PruneEntries prunes the directory blocks from next up to PruneDepth blocks below highest, and
+// returns the height of the next directory block to prune.
+func (s *State) PruneEntries(next uint32, highest uint32) (uint32, error) {
+	depth := s.GetPruneDepth()
+	if depth == 0 || highest < depth {
+		return next, nil
+	}
+
+	start := next
+	for ; next <= highest-depth; next++ {
+		err := s.pruneDBlock(next, depth)
+		if err != nil {
+			return next, err
+		}
+		err = s.DB.SavePrunedHeight(next + 1)
+		if err != nil {
+			return next, err
+		}
+	}
+	if next > start {
+		s.LogPrintf("pruning", "Pruned directory blocks %d to %d", start, next-1)
+	}
+	return next, nil
+}
+
+// pruneDBlock prunes the entry blocks of the directory block at height.  A chain head is kept,
+// as new entry blocks of the chain are built on it; once a newer entry block has taken its place
+// it is pruned along with the directory block holding the newer one, depth blocks later.
+func (s *State) pruneDBlock(height uint32, depth uint32) error {
+	dblock, err := s.DB.FetchDBlockByHeight(height)
+	if err != nil {
+		return err
+	}
+	if dblock == nil {
+		return fmt.Errorf("Directory block %d is missing", height)
+	}
+
+	for _, v := range dblock.GetEBlockDBEntries() {
+		eblock, err := s.DB.FetchEBlock(v.GetKeyMR())
+		if err != nil {
+			return err
+		}
+		if eblock == nil || s.keepWhenPruning(eblock) {
+			continue
+		}
+		head, err := s.DB.FetchHeadIndexByChainID(eblock.GetChainID())
+		if err != nil {
+			return err
+		}
+		if head != nil && head.IsSameAs(v.GetKeyMR()) {
+			continue
+		}
+		_, err = s.DB.PruneEBlock(v.GetKeyMR())
+		if err != nil {
+			return err
+		}
+	}
+
+	newer, err := s.DB.FetchDBlockByHeight(height + depth)
+	if err != nil || newer == nil {
+		return err
+	}
+	for _, v := range newer.GetEBlockDBEntries() {
+		eblock, err := s.DB.FetchEBlock(v.GetKeyMR())
+		if err != nil {
+			return err
+		}
+		if eblock == nil || eblock.GetHeader().GetPrevKeyMR().IsZero() {
+			continue
+		}
+		prev, err := s.DB.FetchEBlock(eblock.GetHeader().GetPrevKeyMR())
+		if err != nil {
+			return err
+		}
+		if prev == nil || prev.GetDatabaseHeight() >= height || s.keepWhenPruning(prev) {
+			continue
+		}
+		_, err = s.DB.PruneEBlock(eblock.GetHeader().GetPrevKeyMR())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// keepWhenPruning tells whether the entry block is needed to rebuild the state, in which case
+// it is never pruned.  That is the first blocks, the identity chains and the exchange rate chain.
+func (s *State) keepWhenPruning(eblock interfaces.IEntryBlock) bool {
+	if eblock.GetDatabaseHeight() < 2 {
+		return true
+	}
+	if bytes.HasPrefix(eblock.GetChainID().Bytes(), []byte{0x88, 0x88, 0x88}) {
+		return true
+	}
+	return eblock.GetChainID().String() == s.FERChainId
+}
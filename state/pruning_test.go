@@ -0,0 +1,80 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state_test
+
+import (
+	"testing"
+
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestPruneEntries(t *testing.T) {
+	s := testHelper.CreateAndPopulateTestState()
+
+	highest := uint32(testHelper.BlockCount - 1)
+
+	next, err := s.PruneEntries(0, highest)
+	if err != nil || next != 0 {
+		t.Errorf("A node that is not PRUNED should not prune - %v %v", next, err)
+	}
+
+	s.NodeMode = "PRUNED"
+	s.PruneDepth = 3
+
+	next, err = s.PruneEntries(0, highest)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if next != highest-2 {
+		t.Errorf("Pruned up to %v, expected %v", next, highest-2)
+	}
+	saved, err := s.DB.FetchPrunedHeight()
+	if err != nil || saved != next {
+		t.Errorf("Saved pruned height %v, expected %v - %v", saved, next, err)
+	}
+
+	prunedCount := 0
+	for h := uint32(0); h <= highest; h++ {
+		dblock, err := s.DB.FetchDBlockByHeight(h)
+		if err != nil || dblock == nil {
+			t.Fatalf("Missing directory block %v - %v", h, err)
+		}
+		for _, v := range dblock.GetEBlockDBEntries() {
+			head, err := s.DB.FetchHeadIndexByChainID(v.GetChainID())
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			// The first blocks, the chain heads and the blocks within the depth are kept
+			keep := h < 2 || h >= next || head.IsSameAs(v.GetKeyMR())
+
+			eblock, err := s.DB.FetchEBlock(v.GetKeyMR())
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			pruned, err := s.DB.IsPruned(v.GetKeyMR())
+			if err != nil {
+				t.Fatalf("%v", err)
+			}
+			if keep && (eblock == nil || pruned) {
+				t.Errorf("Entry block %v at height %v should have been kept", v.GetKeyMR(), h)
+			}
+			if !keep && (eblock != nil || !pruned) {
+				t.Errorf("Entry block %v at height %v should have been pruned", v.GetKeyMR(), h)
+			}
+			if pruned {
+				prunedCount++
+			}
+		}
+	}
+	if prunedCount == 0 {
+		t.Errorf("Nothing was pruned")
+	}
+
+	// Nothing more to do until more blocks are saved
+	again, err := s.PruneEntries(next, highest)
+	if err != nil || again != next {
+		t.Errorf("Pruned again up to %v, expected %v - %v", again, next, err)
+	}
+}
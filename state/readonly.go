@@ -0,0 +1,276 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/database/boltdb"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/leveldb"
+)
+
+// A node with ReadOnlyDB set opens its database without write access, so any number of them can
+// share one copy, such as a snapshot taken by another node.  It takes no part in the network: it
+// loads the directory blocks of the database, and serves the API and control panel from them.
+
+// IsReadOnlyDB tells whether the node only reads its database, and can not take in new data
+func (s *State) IsReadOnlyDB() bool {
+	return s.ReadOnlyDB
+}
+
+// initReadOnlyDB opens the database of the node's DBType without write access
+func (s *State) initReadOnlyDB() error {
+	dbase, err := s.openReadOnlyDB()
+	if err != nil {
+		return err
+	}
+	return s.setDB(dbase)
+}
+
+func (s *State) openReadOnlyDB() (interfaces.IDatabase, error) {
+	var path string
+	switch s.DBType {
+	case "LDB":
+		path = s.LdbPath + "/" + s.Network + "/" + "factoid_level.db"
+	case "Bolt":
+		path = s.BoltDBPath + "/" + s.Network + "/" + "FactomBolt.db"
+	default:
+		return nil, fmt.Errorf("A %s database can not be opened read only", s.DBType)
+	}
+
+	s.Println("Database (read only):", path)
+	fmt.Fprintln(os.Stderr, "Database (read only):", path)
+
+	if s.DBType == "LDB" {
+		return leveldb.NewReadOnlyLevelDB(path)
+	}
+	return boltdb.NewReadOnlyBoltDB(path)
+}
+
+// checkReadOnlySchema makes sure a read only database needs no migrations, as they would have
+// to write to it
+func checkReadOnlySchema(db interfaces.DBOverlaySimple) error {
+	version, err := db.FetchSchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version != databaseOverlay.LatestSchemaVersion() {
+		return fmt.Errorf("The database is at schema version %d rather than %d, run factomd with -db-migrate-only on it first", version, databaseOverlay.LatestSchemaVersion())
+	}
+	return nil
+}
+
+// ReopenDB opens the database again, to pick up a newer snapshot copied over it, and puts it in
+// place of the one open now.  The one replaced is closed once the reads under way finish.
+func (s *State) ReopenDB() error {
+	overlay, ok := s.DB.(*databaseOverlay.Overlay)
+	if !ok {
+		return fmt.Errorf("The database can not be reopened")
+	}
+	current, ok := overlay.DB.(*reopenableDB)
+	if !ok {
+		return fmt.Errorf("Only a read only database can be reopened")
+	}
+
+	dbase, err := s.openReadOnlyDB()
+	if err != nil {
+		return err
+	}
+	db, err := s.encryptDB(dbase)
+	if err != nil {
+		return err
+	}
+	err = checkReadOnlySchema(databaseOverlay.NewOverlay(db))
+	if err != nil {
+		db.Close()
+		return err
+	}
+	current.swap(db)
+	return nil
+}
+
+// GoReopenDB reopens the database every ReopenDBSeconds, once the node has loaded it, and loads
+// the directory blocks a newer snapshot adds.  It does nothing unless the database is read only.
+func (s *State) GoReopenDB() {
+	if !s.ReadOnlyDB || s.ReopenDBSeconds <= 0 {
+		return
+	}
+
+	for {
+		time.Sleep(time.Duration(s.ReopenDBSeconds) * time.Second)
+		if !s.DBFinished {
+			continue
+		}
+
+		err := s.ReopenDB()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%20s Reopening the database failed, keeping the one open: %v\n", s.FactomNodeName, err)
+			continue
+		}
+		LoadDatabase(s)
+	}
+}
+
+// reopenableDB lets ReopenDB put a newer database in place of the one in use, without the
+// overlay and its readers noticing.  Each call holds the database it started on until it returns,
+// and an iterator until it is released, and a database replaced is closed once nothing holds it.
+type reopenableDB struct {
+	mutex   sync.Mutex
+	current *heldDB
+}
+
+// heldDB is a database with the number of calls and iterators still using it
+type heldDB struct {
+	db       interfaces.IDatabase
+	users    int
+	replaced bool // Closed once users drops to 0
+}
+
+var _ interfaces.IDatabase = (*reopenableDB)(nil)
+
+func newReopenableDB(db interfaces.IDatabase) *reopenableDB {
+	r := new(reopenableDB)
+	r.current = &heldDB{db: db}
+	return r
+}
+
+func (r *reopenableDB) hold() *heldDB {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.current.users++
+	return r.current
+}
+
+func (r *reopenableDB) release(h *heldDB) {
+	r.mutex.Lock()
+	h.users--
+	done := h.replaced && h.users == 0
+	r.mutex.Unlock()
+	if done {
+		h.db.Close()
+	}
+}
+
+// retire marks the database as replaced, and closes it if nothing holds it
+func (r *reopenableDB) retire(h *heldDB) error {
+	r.mutex.Lock()
+	if h.replaced {
+		r.mutex.Unlock()
+		return nil
+	}
+	h.replaced = true
+	done := h.users == 0
+	r.mutex.Unlock()
+	if done {
+		return h.db.Close()
+	}
+	return nil
+}
+
+// swap puts db in place of the database in use
+func (r *reopenableDB) swap(db interfaces.IDatabase) {
+	r.mutex.Lock()
+	old := r.current
+	r.current = &heldDB{db: db}
+	r.mutex.Unlock()
+	r.retire(old)
+}
+
+func (r *reopenableDB) Close() error {
+	r.mutex.Lock()
+	h := r.current
+	r.mutex.Unlock()
+	return r.retire(h)
+}
+
+func (r *reopenableDB) Put(bucket, key []byte, data interfaces.BinaryMarshallable) error {
+	h := r.hold()
+	defer r.release(h)
+	return h.db.Put(bucket, key, data)
+}
+
+func (r *reopenableDB) Get(bucket, key []byte, destination interfaces.BinaryMarshallable) (interfaces.BinaryMarshallable, error) {
+	h := r.hold()
+	defer r.release(h)
+	return h.db.Get(bucket, key, destination)
+}
+
+func (r *reopenableDB) Delete(bucket, key []byte) error {
+	h := r.hold()
+	defer r.release(h)
+	return h.db.Delete(bucket, key)
+}
+
+func (r *reopenableDB) ListAllKeys(bucket []byte) ([][]byte, error) {
+	h := r.hold()
+	defer r.release(h)
+	return h.db.ListAllKeys(bucket)
+}
+
+func (r *reopenableDB) GetAll(bucket []byte, sample interfaces.BinaryMarshallableAndCopyable) ([]interfaces.BinaryMarshallableAndCopyable, [][]byte, error) {
+	h := r.hold()
+	defer r.release(h)
+	return h.db.GetAll(bucket, sample)
+}
+
+func (r *reopenableDB) Clear(bucket []byte) error {
+	h := r.hold()
+	defer r.release(h)
+	return h.db.Clear(bucket)
+}
+
+func (r *reopenableDB) PutInBatch(records []interfaces.Record) error {
+	h := r.hold()
+	defer r.release(h)
+	return h.db.PutInBatch(records)
+}
+
+func (r *reopenableDB) ListAllBuckets() ([][]byte, error) {
+	h := r.hold()
+	defer r.release(h)
+	return h.db.ListAllBuckets()
+}
+
+func (r *reopenableDB) Trim() {
+	h := r.hold()
+	defer r.release(h)
+	h.db.Trim()
+}
+
+func (r *reopenableDB) DoesKeyExist(bucket, key []byte) (bool, error) {
+	h := r.hold()
+	defer r.release(h)
+	return h.db.DoesKeyExist(bucket, key)
+}
+
+func (r *reopenableDB) NewIterator(bucket []byte, options *interfaces.IteratorOptions) (interfaces.IIterator, error) {
+	h := r.hold()
+	it, err := h.db.NewIterator(bucket, options)
+	if err != nil {
+		r.release(h)
+		return nil, err
+	}
+	return &heldIterator{IIterator: it, db: r, held: h}, nil
+}
+
+// heldIterator holds the database it goes through until it is released
+type heldIterator struct {
+	interfaces.IIterator
+	db   *reopenableDB
+	held *heldDB
+}
+
+func (it *heldIterator) Release() {
+	it.IIterator.Release()
+	if it.held != nil {
+		it.db.release(it.held)
+		it.held = nil
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	. "github.com/FactomProject/factomd/state"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestReadOnlyDB(t *testing.T) {
+	dir, err := ioutil.TempDir("", "readonly")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	newState := func(readOnly bool) *State {
+		s := new(State)
+		s.DBType = "LDB"
+		s.LdbPath = dir
+		s.Network = "LOCAL"
+		s.ReadOnlyDB = readOnly
+		return s
+	}
+
+	// Nothing to open yet
+	if newState(true).InitLevelDB() == nil {
+		t.Error("Opened a database that does not exist")
+	}
+
+	w := newState(false)
+	err = w.InitLevelDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testHelper.PopulateTestDatabaseOverlay(w.DB.(*databaseOverlay.Overlay))
+	_, err = w.DB.Migrate(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	head, err := w.DB.FetchDBlockHead()
+	if err != nil || head == nil {
+		t.Fatalf("Missing directory block head - %v", err)
+	}
+	w.DB.Close()
+
+	r := newState(true)
+	err = r.InitLevelDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := r.DB.FetchDBlockHead()
+	if err != nil || loaded == nil || !loaded.GetKeyMR().IsSameAs(head.GetKeyMR()) {
+		t.Errorf("Read the wrong directory block head - %v", err)
+	}
+	err = r.DB.(*databaseOverlay.Overlay).SaveSchemaVersion(0)
+	if err == nil {
+		t.Error("Wrote to a read only database")
+	}
+
+	// A read under way keeps the database it started on until it is done
+	it, err := r.DB.(*databaseOverlay.Overlay).DB.NewIterator(databaseOverlay.DIRECTORYBLOCK_NUMBER, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = r.ReopenDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !it.Next() || it.Error() != nil {
+		t.Errorf("The replaced database was closed under an iterator - %v", it.Error())
+	}
+	it.Release()
+	loaded, err = r.DB.FetchDBlockHead()
+	if err != nil || loaded == nil || !loaded.GetKeyMR().IsSameAs(head.GetKeyMR()) {
+		t.Errorf("Read the wrong directory block head after reopening - %v", err)
+	}
+	r.DB.Close()
+
+	// A database needing migrations is not reopened
+	w = newState(false)
+	err = w.InitLevelDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = w.DB.(*databaseOverlay.Overlay).Put(databaseOverlay.KEY_VALUE_STORE, databaseOverlay.SchemaVersionKey, &primitives.ByteSlice{Bytes: []byte{0, 0, 0, 0}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.DB.Close()
+
+	r = newState(true)
+	err = r.InitLevelDB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.ReopenDB() == nil {
+		t.Error("Reopened a database that needs migrating")
+	}
+	if _, err = r.DB.FetchDBlockHead(); err != nil {
+		t.Errorf("The database in use was closed - %v", err)
+	}
+	r.DB.Close()
+
+	// Only LevelDB and Bolt can be opened read only
+	m := newState(true)
+	m.DBType = "Map"
+	if m.InitMapDB() == nil {
+		t.Error("Opened a Map database read only")
+	}
+}
@@ -0,0 +1,91 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type snapshotter interface {
+	Snapshot(path string) (uint32, error)
+}
+
+// SnapshotEntryWait is how long a snapshot waits for the entries of the saved directory blocks to
+// be written before it is taken
+var SnapshotEntryWait = time.Minute
+
+// SnapshotDatabase copies the database into dir while the node keeps running, and returns the
+// height of the last directory block in the copy with all of its entries.  The copy is laid out
+// like LdbPath, BoltDBPath or BadgerDBPath, so a node pointed at dir starts from it as-is.
+func (s *State) SnapshotDatabase(dir string) (uint32, error) {
+	if dir == "" {
+		return 0, fmt.Errorf("No snapshot directory given")
+	}
+
+	var file string
+	switch s.DBType {
+	case "LDB":
+		file = "factoid_level.db"
+	case "Bolt":
+		file = "FactomBolt.db"
+	case "Badger":
+		file = "factoid_badger.db"
+	default:
+		return 0, fmt.Errorf("Snapshots are not supported for the %v database", s.DBType)
+	}
+
+	db, ok := s.DB.(snapshotter)
+	if !ok {
+		return 0, fmt.Errorf("Database does not support snapshots")
+	}
+
+	s.SnapshotMutex.Lock()
+	defer s.SnapshotMutex.Unlock()
+
+	path := filepath.Join(dir, s.Network)
+	err := os.MkdirAll(path, 0750)
+	if err != nil {
+		return 0, err
+	}
+	path = filepath.Join(path, file)
+
+	// Entries are written by WriteEntries after their blocks are saved, so the snapshot waits for
+	// them to catch up.  The entries of blocks up to EntryDBHeightComplete are all written before
+	// the copy is taken, so if they have not caught up that is the height the copy is good for.
+	s.waitForEntries(SnapshotEntryWait)
+	entryHeight := s.EntryDBHeightComplete
+
+	s.LogPrintf("database", "Taking a snapshot of the database into %v", path)
+	height, err := db.Snapshot(path)
+	if err != nil {
+		s.LogPrintf("database", "Snapshot into %v failed: %v", path, err)
+		return 0, err
+	}
+	if entryHeight < height {
+		s.LogPrintf("database", "Snapshot into %v has the blocks up to %d, but their entries only up to %d", path, height, entryHeight)
+		height = entryHeight
+	}
+	s.LogPrintf("database", "Snapshot into %v done at directory block %d", path, height)
+	return height, nil
+}
+
+// waitForEntries waits up to wait for the entries waiting to be written to be written, and for
+// the entries of the saved directory blocks to be complete
+func (s *State) waitForEntries(wait time.Duration) {
+	deadline := time.Now().Add(wait)
+	for time.Now().Before(deadline) {
+		head, err := s.DB.FetchDBlockHead()
+		if err != nil || head == nil {
+			return
+		}
+		if len(s.WriteEntry) == 0 && s.EntryDBHeightComplete >= head.GetDatabaseHeight() {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+}
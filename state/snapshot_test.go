@@ -0,0 +1,78 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/FactomProject/factomd/database/databaseOverlay"
+	"github.com/FactomProject/factomd/database/leveldb"
+	. "github.com/FactomProject/factomd/state"
+	"github.com/FactomProject/factomd/testHelper"
+)
+
+func TestSnapshotDatabase(t *testing.T) {
+	dir, err := ioutil.TempDir("", "statesnapshot")
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := leveldb.NewLevelDB(filepath.Join(dir, "source"), true)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	dbo := databaseOverlay.NewOverlay(db)
+	defer dbo.Close()
+	testHelper.PopulateTestDatabaseOverlay(dbo)
+
+	s := new(State)
+	s.DB = dbo
+	s.Network = "LOCAL"
+	s.EntryDBHeightComplete = uint32(testHelper.BlockCount - 1)
+
+	s.DBType = "Map"
+	_, err = s.SnapshotDatabase(filepath.Join(dir, "map"))
+	if err == nil {
+		t.Errorf("Snapshot of a map database should have failed")
+	}
+
+	s.DBType = "LDB"
+	_, err = s.SnapshotDatabase("")
+	if err == nil {
+		t.Errorf("Snapshot without a directory should have failed")
+	}
+
+	height, err := s.SnapshotDatabase(filepath.Join(dir, "backup"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if int(height) != testHelper.BlockCount-1 {
+		t.Errorf("Got height %v, expected %v", height, testHelper.BlockCount-1)
+	}
+
+	// The copy is laid out the way the node looks for its database
+	_, err = os.Stat(filepath.Join(dir, "backup", "LOCAL", "factoid_level.db"))
+	if err != nil {
+		t.Errorf("%v", err)
+	}
+
+	// A copy taken while the entries of the last blocks are still being written is only good up
+	// to the blocks with all of their entries
+	defer func(wait time.Duration) { SnapshotEntryWait = wait }(SnapshotEntryWait)
+	SnapshotEntryWait = 200 * time.Millisecond
+	s.EntryDBHeightComplete = 3
+	height, err = s.SnapshotDatabase(filepath.Join(dir, "behind"))
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	if height != 3 {
+		t.Errorf("Got height %v, expected 3", height)
+	}
+}
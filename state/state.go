@@ -27,6 +27,7 @@ import (
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/messages"
 	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/badgerdb"
 	"github.com/FactomProject/factomd/database/boltdb"
 	"github.com/FactomProject/factomd/database/databaseOverlay"
 	"github.com/FactomProject/factomd/database/leveldb"
@@ -61,9 +62,11 @@ type State struct {
 	LogPath         string
 	LdbPath         string
 	BoltDBPath      string
+	BadgerDBPath    string
 	LogLevel        string
 	ConsoleLogLevel string
 	NodeMode        string
+	PruneDepth      uint32 // In PRUNED mode, how many of the latest directory blocks keep their entries
 	DBType          string
 	CheckChainHeads struct {
 		CheckChainHeads bool
@@ -72,6 +75,14 @@ type State struct {
 	CloneDBType       string
 	ExportData        bool
 	ExportDataSubpath string
+	AddressIndex      bool   // Index factoid transactions by address
+	VerifyBlocks      uint32 // Verify one in every VerifyBlocks blocks read from the database, 0 for none
+	BlockCacheSize    int    // How many blocks and entries read from the database are kept in memory
+	EncryptDB         bool   // Encrypt the database at rest
+	DBKeyFile         string // File holding the database password, if not in the environment
+	DBNewKeyFile      string // File holding a new database password to re-encrypt with
+	ReadOnlyDB        bool   // Only read the database, and serve the API from it
+	ReopenDBSeconds   int    // How often a read only database is reopened, 0 for never
 
 	LogBits int64 // Bit zero is for logging the Directory Block on DBSig [5]
 
@@ -94,6 +105,10 @@ type State struct {
 	ControlPanelChannel     chan DisplayState
 	ControlPanelDataRequest bool // If true, update Display state
 
+	// Subscribers to the events pushed to the API as blocks are saved and the process list advances
+	StateEventsMutex      sync.RWMutex
+	StateEventSubscribers []chan *interfaces.StateEvent
+
 	// Network Configuration
 	Network                 string
 	MainNetworkPort         string
@@ -112,6 +127,15 @@ type State struct {
 	CustomNetworkID         []byte
 	CustomBootstrapIdentity string
 	CustomBootstrapKey      string
+	P2PEncryption           bool          // Encrypt peer connections under the node key
+	P2PKeyFile              string        // File holding the node key, created if missing
+	P2PEncryptSpecialPeers  bool          // Refuse plain connections to and from special peers
+	P2PPlainFallback        bool          // Dial peers that can not take the TLS handshake again without it
+	BanDuration             time.Duration // How long misbehaving peers are banned for
+	P2PPeerUploadRate       int           // Bytes per second sent to each peer, 0 for no cap
+	P2PPeerDownloadRate     int           // Bytes per second read from each peer, 0 for no cap
+	P2PTotalUploadRate      int           // Bytes per second sent to all peers together, 0 for no cap
+	P2PTotalDownloadRate    int           // Bytes per second read from all peers together, 0 for no cap
 
 	IdentityChainID interfaces.IHash // If this node has an identity, this is it
 	//Identities      []*Identity      // Identities of all servers in management chain
@@ -289,6 +313,11 @@ type State struct {
 	// Database
 	DB     interfaces.DBOverlaySimple
 	Anchor interfaces.IAnchor
+	// Only one database snapshot is taken at a time
+	SnapshotMutex sync.Mutex
+	// Heights of the directory blocks found corrupted, and when we last asked peers for them
+	Quarantined      map[uint32]time.Time
+	QuarantinedMutex sync.Mutex
 
 	// Directory Block State
 	DBStates *DBStateList // Holds all DBStates not yet processed.
@@ -469,14 +498,24 @@ func (s *State) Clone(cloneNumber int) interfaces.IState {
 	newState.JournalFile = s.LogPath + "/journal" + number + ".log"
 	newState.Journaling = s.Journaling
 	newState.BoltDBPath = s.BoltDBPath + "/Sim" + number
+	newState.BadgerDBPath = s.BadgerDBPath + "/Sim" + number
 	newState.LogLevel = s.LogLevel
 	newState.ConsoleLogLevel = s.ConsoleLogLevel
 	newState.NodeMode = "FULL"
+	newState.PruneDepth = s.PruneDepth
 	newState.CloneDBType = s.CloneDBType
 	newState.DBType = s.CloneDBType
 	newState.CheckChainHeads = s.CheckChainHeads
 	newState.ExportData = s.ExportData
 	newState.ExportDataSubpath = s.ExportDataSubpath + "sim-" + number
+	newState.AddressIndex = s.AddressIndex
+	newState.VerifyBlocks = s.VerifyBlocks
+	newState.BlockCacheSize = s.BlockCacheSize
+	newState.EncryptDB = s.EncryptDB
+	newState.DBKeyFile = s.DBKeyFile
+	newState.DBNewKeyFile = s.DBNewKeyFile
+	newState.ReadOnlyDB = s.ReadOnlyDB
+	newState.ReopenDBSeconds = s.ReopenDBSeconds
 	newState.Network = s.Network
 	newState.MainNetworkPort = s.MainNetworkPort
 	newState.PeersFile = s.PeersFile
@@ -495,6 +534,15 @@ func (s *State) Clone(cloneNumber int) interfaces.IState {
 	newState.CustomNetworkID = s.CustomNetworkID
 	newState.CustomBootstrapIdentity = s.CustomBootstrapIdentity
 	newState.CustomBootstrapKey = s.CustomBootstrapKey
+	newState.P2PEncryption = s.P2PEncryption
+	newState.P2PKeyFile = s.P2PKeyFile
+	newState.P2PEncryptSpecialPeers = s.P2PEncryptSpecialPeers
+	newState.P2PPlainFallback = s.P2PPlainFallback
+	newState.BanDuration = s.BanDuration
+	newState.P2PPeerUploadRate = s.P2PPeerUploadRate
+	newState.P2PPeerDownloadRate = s.P2PPeerDownloadRate
+	newState.P2PTotalUploadRate = s.P2PTotalUploadRate
+	newState.P2PTotalDownloadRate = s.P2PTotalDownloadRate
 
 	newState.DirectoryBlockInSeconds = s.DirectoryBlockInSeconds
 	newState.PortNumber = s.PortNumber
@@ -554,6 +602,10 @@ func (s *State) Clone(cloneNumber int) interfaces.IState {
 		newState.StateSaverStruct.FastBoot = s.StateSaverStruct.FastBoot
 		newState.StateSaverStruct.FastBootLocation = newState.BoltDBPath
 		break
+	case "Badger":
+		newState.StateSaverStruct.FastBoot = s.StateSaverStruct.FastBoot
+		newState.StateSaverStruct.FastBootLocation = newState.BadgerDBPath
+		break
 	}
 	if globals.Params.WriteProcessedDBStates {
 		path := filepath.Join(newState.LdbPath, newState.Network, "dbstates")
@@ -740,21 +792,33 @@ func (s *State) LoadConfig(filename string, networkFlag string) {
 		// TODO: improve the paths after milestone 1
 		cfg.App.LdbPath = cfg.App.HomeDir + networkName + cfg.App.LdbPath
 		cfg.App.BoltDBPath = cfg.App.HomeDir + networkName + cfg.App.BoltDBPath
+		cfg.App.BadgerDBPath = cfg.App.HomeDir + networkName + cfg.App.BadgerDBPath
 		cfg.App.DataStorePath = cfg.App.HomeDir + networkName + cfg.App.DataStorePath
 		cfg.Log.LogPath = cfg.App.HomeDir + networkName + cfg.Log.LogPath
 		cfg.App.ExportDataSubpath = cfg.App.HomeDir + networkName + cfg.App.ExportDataSubpath
 		cfg.App.PeersFile = cfg.App.HomeDir + networkName + cfg.App.PeersFile
+		cfg.App.P2PKeyFile = cfg.App.HomeDir + networkName + cfg.App.P2PKeyFile
 		cfg.App.ControlPanelFilesPath = cfg.App.HomeDir + cfg.App.ControlPanelFilesPath
 
 		s.LogPath = cfg.Log.LogPath + s.Prefix
 		s.LdbPath = cfg.App.LdbPath + s.Prefix
 		s.BoltDBPath = cfg.App.BoltDBPath + s.Prefix
+		s.BadgerDBPath = cfg.App.BadgerDBPath + s.Prefix
 		s.LogLevel = cfg.Log.LogLevel
 		s.ConsoleLogLevel = cfg.Log.ConsoleLogLevel
 		s.NodeMode = cfg.App.NodeMode
+		s.PruneDepth = cfg.App.PruneDepth
 		s.DBType = cfg.App.DBType
 		s.ExportData = cfg.App.ExportData // bool
 		s.ExportDataSubpath = cfg.App.ExportDataSubpath
+		s.AddressIndex = cfg.App.AddressIndex
+		s.VerifyBlocks = cfg.App.VerifyBlocks
+		s.BlockCacheSize = cfg.App.BlockCacheSize
+		s.EncryptDB = cfg.App.EncryptDB
+		s.DBKeyFile = cfg.App.DBKeyFile
+		s.DBNewKeyFile = cfg.App.DBNewKeyFile
+		s.ReadOnlyDB = cfg.App.ReadOnlyDB
+		s.ReopenDBSeconds = cfg.App.ReopenDBSeconds
 		s.MainNetworkPort = cfg.App.MainNetworkPort
 		s.PeersFile = cfg.App.PeersFile
 		s.MainSeedURL = cfg.App.MainSeedURL
@@ -764,6 +828,19 @@ func (s *State) LoadConfig(filename string, networkFlag string) {
 		s.TestSpecialPeers = cfg.App.TestSpecialPeers
 		s.CustomBootstrapIdentity = cfg.App.CustomBootstrapIdentity
 		s.CustomBootstrapKey = cfg.App.CustomBootstrapKey
+		s.P2PEncryption = cfg.App.P2PEncryption
+		s.P2PKeyFile = cfg.App.P2PKeyFile
+		s.P2PEncryptSpecialPeers = cfg.App.P2PEncryptSpecialPeers
+		s.P2PPlainFallback = cfg.App.P2PPlainFallback
+		s.P2PPeerUploadRate = cfg.App.P2PPeerUploadRate
+		s.P2PPeerDownloadRate = cfg.App.P2PPeerDownloadRate
+		s.P2PTotalUploadRate = cfg.App.P2PTotalUploadRate
+		s.P2PTotalDownloadRate = cfg.App.P2PTotalDownloadRate
+		if cfg.Peer.BanDuration >= time.Second {
+			s.BanDuration = cfg.Peer.BanDuration
+		} else {
+			s.BanDuration = 24 * time.Hour
+		}
 		s.LocalNetworkPort = cfg.App.LocalNetworkPort
 		s.LocalSeedURL = cfg.App.LocalSeedURL
 		s.LocalSpecialPeers = cfg.App.LocalSpecialPeers
@@ -834,12 +911,22 @@ func (s *State) LoadConfig(filename string, networkFlag string) {
 		s.LogPath = "database/"
 		s.LdbPath = "database/ldb"
 		s.BoltDBPath = "database/bolt"
+		s.BadgerDBPath = "database/badger"
 		s.LogLevel = "none"
 		s.ConsoleLogLevel = "standard"
 		s.NodeMode = "SERVER"
+		s.PruneDepth = 1000
 		s.DBType = "Map"
 		s.ExportData = false
 		s.ExportDataSubpath = "data/export"
+		s.AddressIndex = false
+		s.VerifyBlocks = 0
+		s.BlockCacheSize = 0
+		s.EncryptDB = false
+		s.DBKeyFile = ""
+		s.DBNewKeyFile = ""
+		s.ReadOnlyDB = false
+		s.ReopenDBSeconds = 0
 		s.Network = "TEST"
 		s.MainNetworkPort = "8108"
 		s.PeersFile = "peers.json"
@@ -851,6 +938,15 @@ func (s *State) LoadConfig(filename string, networkFlag string) {
 		s.LocalNetworkPort = "8110"
 		s.LocalSeedURL = "https://raw.githubusercontent.com/FactomProject/factomproject.github.io/master/seed/localseed.txt"
 		s.LocalSpecialPeers = ""
+		s.P2PEncryption = false
+		s.P2PKeyFile = "p2pkey.pem"
+		s.P2PEncryptSpecialPeers = false
+		s.P2PPlainFallback = false
+		s.BanDuration = 24 * time.Hour
+		s.P2PPeerUploadRate = 0
+		s.P2PPeerDownloadRate = 0
+		s.P2PTotalUploadRate = 0
+		s.P2PTotalDownloadRate = 0
 
 		s.LocalServerPrivKey = "4c38c72fc5cdad68f13b74674d3ffb1f3d63a112710868c9b08946553448d26d"
 		s.FactoshisPerEC = 006666
@@ -992,8 +1088,16 @@ func (s *State) Init() {
 		s.Println("\n   +-------------------------+")
 		s.Println("   |       Leader Node       |")
 		s.Print("   +-------------------------+\n\n")
+	case "PRUNED":
+		if s.PruneDepth < MinimumPruneDepth {
+			panic(fmt.Sprintf("PruneDepth must be at least %d in PRUNED mode", MinimumPruneDepth))
+		}
+		s.Leader = false
+		s.Println("\n   +---------------------------+")
+		s.Println("   +----- Pruned Follower -----+")
+		s.Print("   +---------------------------+\n\n")
 	default:
-		panic("Bad Node Mode (must be FULL or SERVER)")
+		panic("Bad Node Mode (must be FULL, SERVER or PRUNED)")
 	}
 
 	//Database
@@ -1006,6 +1110,10 @@ func (s *State) Init() {
 		if err := s.InitBoltDB(); err != nil {
 			panic(fmt.Sprintf("Error initializing the database: %v", err))
 		}
+	case "Badger":
+		if err := s.InitBadgerDB(); err != nil {
+			panic(fmt.Sprintf("Error initializing the database: %v", err))
+		}
 	case "Map":
 		if err := s.InitMapDB(); err != nil {
 			panic(fmt.Sprintf("Error initializing the database: %v", err))
@@ -1040,9 +1148,33 @@ func (s *State) Init() {
 			Fix:       s.CheckChainHeads.Fix,
 		})
 	}
+	// Bring the layout of a database written by an older factomd up to date.  Only a node
+	// writing to the database can do that.
+	if s.ReadOnlyDB {
+		if err := checkReadOnlySchema(s.DB); err != nil {
+			panic(fmt.Errorf("Error opening the database: %s\n", err.Error()))
+		}
+	} else if n, err := s.DB.Migrate(5000); err != nil {
+		panic(fmt.Errorf("Error migrating the database: %s\n", err.Error()))
+	} else if n > 0 {
+		fmt.Printf("Database: ran %d migrations\n", n)
+	}
 	if s.ExportData {
 		s.DB.SetExportData(s.ExportDataSubpath)
 	}
+	if s.AddressIndex {
+		// Catch the index up with any blocks saved while it was turned off, without holding
+		// up the boot
+		s.DB.SetAddressIndex(true)
+		if !s.ReadOnlyDB {
+			s.DB.StartAddressIndexBackfill()
+		}
+	}
+	// Corrupted blocks are quarantined in the database, so a read only node can not verify them
+	if s.VerifyBlocks > 0 && !s.ReadOnlyDB {
+		s.DB.SetBlockVerification(s.VerifyBlocks, s.QuarantineDBState)
+	}
+	s.DB.SetBlockCache(s.BlockCacheSize)
 
 	// Cross Boot Replay
 	switch s.DBType {
@@ -1496,224 +1628,262 @@ func (s *State) fillAcksMap() {
 	}
 }
 
-func (s *State) GetPendingEntries(params interface{}) []interfaces.IPendingEntry {
-	resp := make([]interfaces.IPendingEntry, 0)
-	repeatmap := make(map[[32]byte]interfaces.IPendingEntry)
-	pls := s.ProcessLists.Lists
-	var cc messages.CommitChainMsg
-	var ce messages.CommitEntryMsg
-	var re messages.RevealEntryMsg
-	var tmp interfaces.IPendingEntry
-	LastComplete := s.GetDBHeightComplete()
-	// check all existing processlists/VMs
-	for _, pl := range pls {
-		if pl != nil {
-			if pl.DBHeight > LastComplete {
-				for _, v := range pl.VMs {
-					for _, plmsg := range v.List {
-						if plmsg.Type() == constants.COMMIT_CHAIN_MSG { //5
-							enb, err := plmsg.MarshalBinary()
-							if err != nil {
-								return nil
-							}
-							err = cc.UnmarshalBinary(enb)
-							if err != nil {
-								return nil
-							}
-							tmp.EntryHash = cc.CommitChain.EntryHash
+// pendingEntryFilter reads the filter passed to GetPendingEntries.  A string is taken as the
+// chain to list the entries of.
+func pendingEntryFilter(params interface{}) *interfaces.PendingFilter {
+	switch p := params.(type) {
+	case *interfaces.PendingFilter:
+		if p != nil {
+			return p
+		}
+	case string:
+		if chainID, err := primitives.HexToHash(p); err == nil {
+			return &interfaces.PendingFilter{ChainIDs: []interfaces.IHash{chainID}}
+		}
+	}
+	return new(interfaces.PendingFilter)
+}
 
-							tmp.ChainID = cc.CommitChain.ChainIDHash
-							if pl.DBHeight > s.GetDBHeightComplete() {
-								tmp.Status = constants.AckStatusACKString
-							} else {
-								tmp.Status = constants.AckStatusDBlockConfirmedString
-							}
-							if _, ok := repeatmap[tmp.EntryHash.Fixed()]; !ok {
-								resp = append(resp, tmp)
-								repeatmap[tmp.EntryHash.Fixed()] = tmp
-							}
-						} else if plmsg.Type() == constants.COMMIT_ENTRY_MSG { //6
-							enb, err := plmsg.MarshalBinary()
-							if err != nil {
-								return nil
-							}
-							err = ce.UnmarshalBinary(enb)
-							if err != nil {
-								return nil
-							}
-							tmp.EntryHash = ce.CommitEntry.EntryHash
+// pendingTransactionFilter reads the filter passed to GetPendingTransactions.  A string is
+// taken as the address to list the transactions of.
+func pendingTransactionFilter(params interface{}) *interfaces.PendingFilter {
+	switch p := params.(type) {
+	case *interfaces.PendingFilter:
+		if p != nil {
+			return p
+		}
+	case string:
+		return &interfaces.PendingFilter{Address: p}
+	}
+	return new(interfaces.PendingFilter)
+}
 
-							tmp.ChainID = nil
-							if pl.DBHeight > s.GetDBHeightComplete() {
-								tmp.Status = constants.AckStatusACKString
-							} else {
-								tmp.Status = constants.AckStatusDBlockConfirmedString
-							}
+// pendingEntry is a pending entry along with what it can be filtered on
+type pendingEntry struct {
+	interfaces.IPendingEntry
+	ecPubKey []byte
+	minute   int
+}
 
-							if _, ok := repeatmap[tmp.EntryHash.Fixed()]; !ok {
-								resp = append(resp, tmp)
-								repeatmap[tmp.EntryHash.Fixed()] = tmp
-							}
-						} else if plmsg.Type() == constants.REVEAL_ENTRY_MSG { //13
-							enb, err := plmsg.MarshalBinary()
-							if err != nil {
-								return nil
-							}
-							err = re.UnmarshalBinary(enb)
-							if err != nil {
-								return nil
-							}
-							tmp.EntryHash = re.Entry.GetHash()
-							tmp.ChainID = re.Entry.GetChainID()
-							if pl.DBHeight > s.GetDBHeightComplete() {
-								tmp.Status = constants.AckStatusACKString
-							} else {
-								tmp.Status = constants.AckStatusDBlockConfirmedString
-							}
-							if _, ok := repeatmap[tmp.EntryHash.Fixed()]; !ok {
-								resp = append(resp, tmp)
-								repeatmap[tmp.EntryHash.Fixed()] = tmp
-							} else {
-								//If it is in there, it may not know the chainid because it was from a commit
-								if repeatmap[tmp.EntryHash.Fixed()].ChainID == nil {
-									repeatmap[tmp.EntryHash.Fixed()] = tmp
-									// now update your response entry
-									for k, _ := range resp {
-										if resp[k].EntryHash.IsSameAs(tmp.EntryHash) {
-											if tmp.ChainID != nil {
-
-												resp[k].ChainID = tmp.ChainID
-											}
-										}
-									}
-								}
-							}
-						}
-					}
-				}
+func (p *pendingEntry) matches(filter *interfaces.PendingFilter) bool {
+	if filter.MinMinute > 0 && p.minute < filter.MinMinute {
+		return false
+	}
+	if filter.ECPubKey != nil && !bytes.Equal(p.ecPubKey, filter.ECPubKey) {
+		return false
+	}
+	if len(filter.ChainIDs) > 0 {
+		if p.ChainID == nil {
+			return false
+		}
+		for _, c := range filter.ChainIDs {
+			if c.IsSameAs(p.ChainID) {
+				return true
 			}
 		}
+		return false
 	}
+	return true
+}
 
-	// check holding queue
-	q := s.LoadHoldingMap()
-	for _, h := range q {
-		if h.Type() == constants.REVEAL_ENTRY_MSG {
-			enb, err := h.MarshalBinary()
-			if err != nil {
-				return nil
+// GetPendingEntries lists the entries in the process lists and holding that are not yet in a
+// block.  params is a *interfaces.PendingFilter, or a chain ID string.  An entry only known from
+// its commit has no chain ID, and one only known from its reveal has no EC key, so these are
+// left out when filtering on them.
+func (s *State) GetPendingEntries(params interface{}) []interfaces.IPendingEntry {
+	filter := pendingEntryFilter(params)
+	var list []*pendingEntry
+	found := make(map[[32]byte]*pendingEntry)
+
+	// add records an entry the first time one of its messages is seen, and fills in what was
+	// missing from the earlier messages after that
+	add := func(msg interfaces.IMsg, status string, stage string, minute int) {
+		var hash, chainID interfaces.IHash
+		var ecPubKey []byte
+		switch m := msg.(type) {
+		case *messages.CommitChainMsg:
+			hash = m.CommitChain.EntryHash
+			ecPubKey = m.CommitChain.ECPubKey[:]
+		case *messages.CommitEntryMsg:
+			hash = m.CommitEntry.EntryHash
+			ecPubKey = m.CommitEntry.ECPubKey[:]
+		case *messages.RevealEntryMsg:
+			hash = m.Entry.GetHash()
+			chainID = m.Entry.GetChainID()
+		default:
+			return
+		}
+
+		if p, ok := found[hash.Fixed()]; ok {
+			if p.ChainID == nil {
+				p.ChainID = chainID
 			}
-			err = re.UnmarshalBinary(enb)
-			if err != nil {
-				return nil
+			if p.ecPubKey == nil {
+				p.ecPubKey = ecPubKey
 			}
-			tmp.EntryHash = re.Entry.GetHash()
-
-			tmp.ChainID = re.Entry.GetChainID()
-
-			tmp.Status = constants.AckStatusNotConfirmedString
-
-			if _, ok := repeatmap[tmp.EntryHash.Fixed()]; !ok {
+			return
+		}
+		p := new(pendingEntry)
+		p.EntryHash = hash
+		p.ChainID = chainID
+		p.Status = status
+		p.Stage = stage
+		p.ecPubKey = ecPubKey
+		p.minute = minute
+		found[hash.Fixed()] = p
+		list = append(list, p)
+	}
 
-				resp = append(resp, tmp)
-				repeatmap[tmp.EntryHash.Fixed()] = tmp
+	// check all existing processlists/VMs
+	LastComplete := s.GetDBHeightComplete()
+	for _, pl := range s.ProcessLists.Lists {
+		if pl == nil || pl.DBHeight <= LastComplete {
+			continue
+		}
+		for _, v := range pl.VMs {
+			for i, plmsg := range v.List {
+				if plmsg == nil {
+					continue
+				}
+				stage, minute := vmMessageStage(v, i)
+				add(plmsg, constants.AckStatusACKString, stage, minute)
 			}
-
 		}
 	}
 
+	// check holding queue
+	for _, h := range s.LoadHoldingMap() {
+		add(h, constants.AckStatusNotConfirmedString, interfaces.PendingStageHolding, -1)
+	}
+
+	resp := make([]interfaces.IPendingEntry, 0)
+	for _, p := range list {
+		if p.matches(filter) {
+			resp = append(resp, p.IPendingEntry)
+		}
+	}
 	return resp
 }
 
+// vmMessageStage tells if the i'th message of a VM has been processed yet, and in what minute
+// it was acked
+func vmMessageStage(v *VM, i int) (string, int) {
+	stage := interfaces.PendingStageProcessList
+	if i >= v.Height {
+		stage = interfaces.PendingStageAcked
+	}
+	minute := 0
+	if i < len(v.ListAck) && v.ListAck[i] != nil {
+		minute = int(v.ListAck[i].Minute)
+	}
+	return stage, minute
+}
+
+// pendingTransaction is a pending transaction along with what it can be filtered on
+type pendingTransaction struct {
+	interfaces.IPendingTransaction
+	tran   interfaces.ITransaction
+	minute int
+}
+
+func (p *pendingTransaction) matches(filter *interfaces.PendingFilter) bool {
+	if filter.MinMinute > 0 && p.minute < filter.MinMinute {
+		return false
+	}
+	if filter.Address != "" && !p.tran.HasUserAddress(filter.Address) {
+		return false
+	}
+	return true
+}
+
+// GetPendingTransactions lists the factoid transactions in the process lists and holding that
+// are not yet in a block.  params is a *interfaces.PendingFilter, or an address string.
 func (s *State) GetPendingTransactions(params interface{}) []interfaces.IPendingTransaction {
-	var flgFound bool
+	filter := pendingTransactionFilter(params)
+	var list []*pendingTransaction
+	found := make(map[[32]byte]*pendingTransaction)
 
+	add := func(tran interfaces.ITransaction, status string, stage string, minute int) {
+		if _, ok := found[tran.GetSigHash().Fixed()]; ok {
+			return
+		}
+		p := new(pendingTransaction)
+		p.TransactionID = tran.GetSigHash()
+		p.Status = status
+		p.Stage = stage
+		p.Inputs = tran.GetInputs()
+		p.Outputs = tran.GetOutputs()
+		p.ECOutputs = tran.GetECOutputs()
+		p.Fees, _ = tran.CalculateFee(s.GetPredictiveFER())
+		p.tran = tran
+		p.minute = minute
+		found[tran.GetSigHash().Fixed()] = p
+		list = append(list, p)
+	}
+
+	// Find where each acked transaction is in the process lists
+	type vmTransaction struct {
+		tran   interfaces.ITransaction
+		stage  string
+		minute int
+	}
+	var acked []vmTransaction
+	ackedAt := make(map[[32]byte]vmTransaction)
 	var currentHeightComplete = s.GetDBHeightComplete()
-	resp := make([]interfaces.IPendingTransaction, 0)
-	pls := s.ProcessLists.Lists
-	for _, pl := range pls {
-		if pl != nil {
-			// ignore old process lists
-			if pl.DBHeight > currentHeightComplete {
-				cb := pl.State.FactoidState.GetCurrentBlock()
-				ct := cb.GetTransactions()
-				for _, tran := range ct {
-					var tmp interfaces.IPendingTransaction
-					tmp.TransactionID = tran.GetSigHash()
-					if tran.GetBlockHeight() > 0 {
-						tmp.Status = constants.AckStatusDBlockConfirmedString
-					} else {
-						tmp.Status = constants.AckStatusACKString
-					}
-
-					tmp.Inputs = tran.GetInputs()
-					tmp.Outputs = tran.GetOutputs()
-					tmp.ECOutputs = tran.GetECOutputs()
-					ecrate := s.GetPredictiveFER()
-					ecrate, _ = tran.CalculateFee(ecrate)
-					tmp.Fees = ecrate
-
-					if params.(string) == "" {
-						flgFound = true
-					} else {
-						flgFound = tran.HasUserAddress(params.(string))
-					}
-					if flgFound == true {
-						//working through multiple process lists.  Is this transaction already in the list?
-						for _, pt := range resp {
-							if pt.TransactionID.String() == tmp.TransactionID.String() {
-								flgFound = false
-							}
-						}
-						//  flag was true to be added to the list and not already in the list
-						if flgFound == true {
-							resp = append(resp, tmp)
-						}
-					}
+	for _, pl := range s.ProcessLists.Lists {
+		// ignore old process lists
+		if pl == nil || pl.DBHeight <= currentHeightComplete {
+			continue
+		}
+		for _, v := range pl.VMs {
+			for i, plmsg := range v.List {
+				m, ok := plmsg.(*messages.FactoidTransaction)
+				if !ok || m == nil {
+					continue
 				}
+				stage, minute := vmMessageStage(v, i)
+				t := vmTransaction{tran: m.GetTransaction(), stage: stage, minute: minute}
+				acked = append(acked, t)
+				ackedAt[t.tran.GetSigHash().Fixed()] = t
 			}
 		}
 	}
 
-	q := s.LoadHoldingMap()
-	for _, h := range q {
-		if h.Type() == constants.FACTOID_TRANSACTION_MSG {
-			var rm messages.FactoidTransaction
-			enb, err := h.MarshalBinary()
-			if err != nil {
-				return nil
+	// The transactions processed into the factoid block being built, the coinbase among them
+	for _, pl := range s.ProcessLists.Lists {
+		if pl == nil || pl.DBHeight <= currentHeightComplete {
+			continue
+		}
+		for _, tran := range pl.State.FactoidState.GetCurrentBlock().GetTransactions() {
+			status := constants.AckStatusACKString
+			if tran.GetBlockHeight() > 0 {
+				status = constants.AckStatusDBlockConfirmedString
 			}
-			err = rm.UnmarshalBinary(enb)
-			if err != nil {
-				return nil
-			}
-			tempTran := rm.GetTransaction()
-			var tmp interfaces.IPendingTransaction
-			tmp.TransactionID = tempTran.GetSigHash()
-			tmp.Status = constants.AckStatusNotConfirmedString
-			flgFound = tempTran.HasUserAddress(params.(string))
-			tmp.Inputs = tempTran.GetInputs()
-			tmp.Outputs = tempTran.GetOutputs()
-			tmp.ECOutputs = tempTran.GetECOutputs()
-			ecrate := s.GetPredictiveFER()
-			ecrate, _ = tempTran.CalculateFee(ecrate)
-			tmp.Fees = ecrate
-			if flgFound == true {
-				//working through multiple process lists.  Is this transaction already in the list?
-				for _, pt := range resp {
-					if pt.TransactionID.IsSameAs(tmp.TransactionID) {
-						flgFound = false
-					}
-				}
-				//  flag was true to be added to the list and not already in the list
-				if flgFound == true {
-					resp = append(resp, tmp)
-				}
+			t, ok := ackedAt[tran.GetSigHash().Fixed()]
+			if !ok {
+				t.stage = interfaces.PendingStageProcessList
 			}
+			add(tran, status, t.stage, t.minute)
+		}
+	}
+
+	// Then the ones acked but not processed yet
+	for _, t := range acked {
+		add(t.tran, constants.AckStatusACKString, t.stage, t.minute)
+	}
+
+	for _, h := range s.LoadHoldingMap() {
+		if m, ok := h.(*messages.FactoidTransaction); ok {
+			add(m.GetTransaction(), constants.AckStatusNotConfirmedString, interfaces.PendingStageHolding, -1)
 		}
 	}
 
-	//b, _ := json.Marshal(resp)
+	resp := make([]interfaces.IPendingTransaction, 0)
+	for _, p := range list {
+		if p.matches(filter) {
+			resp = append(resp, p.IPendingTransaction)
+		}
+	}
 	return resp
 }
 
@@ -2458,6 +2628,9 @@ func (s *State) InitLevelDB() error {
 	if s.DB != nil {
 		return nil
 	}
+	if s.ReadOnlyDB {
+		return s.initReadOnlyDB()
+	}
 
 	path := s.LdbPath + "/" + s.Network + "/" + "factoid_level.db"
 
@@ -2473,14 +2646,16 @@ func (s *State) InitLevelDB() error {
 		}
 	}
 
-	s.DB = databaseOverlay.NewOverlay(dbase)
-	return nil
+	return s.setDB(dbase)
 }
 
 func (s *State) InitBoltDB() error {
 	if s.DB != nil {
 		return nil
 	}
+	if s.ReadOnlyDB {
+		return s.initReadOnlyDB()
+	}
 
 	path := s.BoltDBPath + "/" + s.Network + "/"
 
@@ -2489,19 +2664,41 @@ func (s *State) InitBoltDB() error {
 
 	dbase := new(boltdb.BoltDB)
 	dbase.Init(nil, path+"FactomBolt.db")
-	s.DB = databaseOverlay.NewOverlay(dbase)
-	return nil
+	return s.setDB(dbase)
+}
+
+func (s *State) InitBadgerDB() error {
+	if s.DB != nil {
+		return nil
+	}
+	if s.ReadOnlyDB {
+		return s.initReadOnlyDB()
+	}
+
+	path := s.BadgerDBPath + "/" + s.Network + "/" + "factoid_badger.db"
+
+	s.Println("Database:", path)
+	fmt.Fprintln(os.Stderr, "Database:", path)
+
+	dbase, err := badgerdb.NewBadgerDB(path, true)
+	if err != nil {
+		return err
+	}
+
+	return s.setDB(dbase)
 }
 
 func (s *State) InitMapDB() error {
 	if s.DB != nil {
 		return nil
 	}
+	if s.ReadOnlyDB {
+		return s.initReadOnlyDB()
+	}
 
 	dbase := new(mapdb.MapDB)
 	dbase.Init(nil)
-	s.DB = databaseOverlay.NewOverlay(dbase)
-	return nil
+	return s.setDB(dbase)
 }
 
 func (s *State) String() string {
@@ -2930,6 +3127,23 @@ func (s *State) updateNetworkControllerConfig() {
 	s.NetworkController.ReloadSpecialPeers(newPeersConfig)
 }
 
+// GetPeerBans returns the banned peer addresses, with the time each ban ends
+func (s *State) GetPeerBans() map[string]time.Time {
+	if s.NetworkController == nil {
+		return map[string]time.Time{}
+	}
+	return s.NetworkController.Bans()
+}
+
+// UnbanPeer lifts the ban on the peer address, or on every address if it is "", and returns how
+// many bans were lifted
+func (s *State) UnbanPeer(address string) int {
+	if s.NetworkController == nil {
+		return 0
+	}
+	return s.NetworkController.Unban(address)
+}
+
 // Check and Add a hash to the network replay filter
 func (s *State) AddToReplayFilter(mask int, hash [32]byte, timestamp interfaces.Timestamp, systemtime interfaces.Timestamp) (rval bool) {
 	return s.Replay.IsTSValidAndUpdateState(constants.NETWORK_REPLAY, hash, timestamp, systemtime)
@@ -1102,6 +1102,10 @@ func (s *State) ExecuteEntriesInDBState(dbmsg *messages.DBStateMsg) {
 func (s *State) FollowerExecuteDBState(msg interfaces.IMsg) {
 	dbstatemsg, _ := msg.(*messages.DBStateMsg)
 
+	if s.HealDBState(dbstatemsg) {
+		return
+	}
+
 	cntFail := func() {
 		if !dbstatemsg.IsInDB {
 			s.DBStateIgnoreCnt++
@@ -0,0 +1,152 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package state
+
+import (
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/messages"
+)
+
+// SubscribeStateEvents registers a channel that will receive every event emitted by this
+// state.  Events are never blocked on; if the channel is full the event is dropped.
+func (s *State) SubscribeStateEvents(c chan *interfaces.StateEvent) {
+	s.StateEventsMutex.Lock()
+	defer s.StateEventsMutex.Unlock()
+	s.StateEventSubscribers = append(s.StateEventSubscribers, c)
+}
+
+// UnsubscribeStateEvents removes a channel added with SubscribeStateEvents
+func (s *State) UnsubscribeStateEvents(c chan *interfaces.StateEvent) {
+	s.StateEventsMutex.Lock()
+	defer s.StateEventsMutex.Unlock()
+	for i, sub := range s.StateEventSubscribers {
+		if sub == c {
+			s.StateEventSubscribers = append(s.StateEventSubscribers[:i], s.StateEventSubscribers[i+1:]...)
+			return
+		}
+	}
+}
+
+// EmitStateEvent pushes the event to all subscribers without blocking
+func (s *State) EmitStateEvent(e *interfaces.StateEvent) {
+	s.StateEventsMutex.RLock()
+	defer s.StateEventsMutex.RUnlock()
+	for _, c := range s.StateEventSubscribers {
+		select {
+		case c <- e:
+			TotalStateEventsEmitted.Inc()
+		default:
+			TotalStateEventsDropped.Inc()
+		}
+	}
+}
+
+// HasStateEventSubscribers is used to avoid building events nobody is listening for
+func (s *State) HasStateEventSubscribers() bool {
+	s.StateEventsMutex.RLock()
+	defer s.StateEventsMutex.RUnlock()
+	return len(s.StateEventSubscribers) > 0
+}
+
+// EmitProcessListEvent emits the event for a message that was just added to the process list.
+// Only the messages a client can track through the API produce events.
+func (s *State) EmitProcessListEvent(dbheight uint32, m interfaces.IMsg) {
+	if !s.HasStateEventSubscribers() {
+		return
+	}
+
+	e := new(interfaces.StateEvent)
+	e.DBHeight = dbheight
+	e.Status = constants.AckStatusACK
+
+	switch m.Type() {
+	case constants.REVEAL_ENTRY_MSG:
+		re, ok := m.(*messages.RevealEntryMsg)
+		if !ok || re.Entry == nil {
+			return
+		}
+		e.Type = interfaces.EVENT_ENTRY
+		e.Hash = re.Entry.GetHash()
+		e.ChainID = re.Entry.GetChainIDHash()
+		e.Entry = re.Entry
+	case constants.COMMIT_CHAIN_MSG:
+		cc, ok := m.(*messages.CommitChainMsg)
+		if !ok || cc.CommitChain == nil {
+			return
+		}
+		e.Type = interfaces.EVENT_COMMIT
+		e.Hash = cc.CommitChain.GetSigHash()
+	case constants.COMMIT_ENTRY_MSG:
+		ce, ok := m.(*messages.CommitEntryMsg)
+		if !ok || ce.CommitEntry == nil {
+			return
+		}
+		e.Type = interfaces.EVENT_COMMIT
+		e.Hash = ce.CommitEntry.GetSigHash()
+	case constants.FACTOID_TRANSACTION_MSG:
+		ft, ok := m.(*messages.FactoidTransaction)
+		if !ok || ft.Transaction == nil {
+			return
+		}
+		e.Type = interfaces.EVENT_FACTOID_TX
+		e.Hash = ft.Transaction.GetSigHash()
+		e.Transaction = ft.Transaction
+	default:
+		return
+	}
+
+	s.EmitStateEvent(e)
+}
+
+// EmitSavedBlockEvents emits the events for a directory block that has just been saved to the
+// database, followed by the confirmation of every entry, commit and transaction it contains.
+func (s *State) EmitSavedBlockEvents(d *DBState, entries []interfaces.IEBEntry) {
+	if !s.HasStateEventSubscribers() {
+		return
+	}
+
+	dbheight := d.DirectoryBlock.GetHeader().GetDBHeight()
+
+	e := new(interfaces.StateEvent)
+	e.Type = interfaces.EVENT_DBLOCK_SAVED
+	e.DBHeight = dbheight
+	e.Hash = d.DirectoryBlock.GetKeyMR()
+	e.Status = constants.AckStatusDBlockConfirmed
+	s.EmitStateEvent(e)
+
+	for _, en := range entries {
+		e := new(interfaces.StateEvent)
+		e.Type = interfaces.EVENT_ENTRY
+		e.DBHeight = dbheight
+		e.Hash = en.GetHash()
+		e.ChainID = en.GetChainIDHash()
+		e.Status = constants.AckStatusDBlockConfirmed
+		e.Entry = en
+		s.EmitStateEvent(e)
+	}
+
+	for _, en := range d.EntryCreditBlock.GetEntries() {
+		switch en.ECID() {
+		case constants.ECIDChainCommit, constants.ECIDEntryCommit:
+			e := new(interfaces.StateEvent)
+			e.Type = interfaces.EVENT_COMMIT
+			e.DBHeight = dbheight
+			e.Hash = en.GetSigHash()
+			e.Status = constants.AckStatusDBlockConfirmed
+			s.EmitStateEvent(e)
+		}
+	}
+
+	for _, tx := range d.FactoidBlock.GetTransactions() {
+		e := new(interfaces.StateEvent)
+		e.Type = interfaces.EVENT_FACTOID_TX
+		e.DBHeight = dbheight
+		e.Hash = tx.GetSigHash()
+		e.Status = constants.AckStatusDBlockConfirmed
+		e.Transaction = tx
+		s.EmitStateEvent(e)
+	}
+}
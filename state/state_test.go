@@ -11,7 +11,9 @@ import (
 	"testing"
 	"time"
 
-	//"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/messages"
 	"github.com/FactomProject/factomd/common/primitives"
 	"github.com/FactomProject/factomd/state"
 	. "github.com/FactomProject/factomd/state"
@@ -290,3 +292,96 @@ func TestIsStalled(t *testing.T) {
 	}
 
 }
+
+func TestGetPendingEntriesAndTransactions(t *testing.T) {
+	s := testHelper.CreateEmptyTestState()
+
+	eblock, _ := testHelper.CreateTestEntryBlock(nil)
+	holdingEntry := testHelper.CreateTestEntry(1)
+	ackedEntry := testHelper.CreateTestEntry(2)
+	ackedEntry.ChainID = primitives.NewHash(primitives.Sha([]byte("other chain")).Bytes())
+
+	reveal := messages.NewRevealEntryMsg()
+	reveal.Entry = holdingEntry
+	commit := messages.NewCommitEntryMsg()
+	commit.CommitEntry = testHelper.NewCommitEntry(eblock)
+
+	fblock := testHelper.CreateTestFactoidBlock(nil)
+	tx := fblock.GetTransactions()[1]
+	ftx := new(messages.FactoidTransaction)
+	ftx.Transaction = tx
+
+	s.HoldingMap = map[[32]byte]interfaces.IMsg{
+		reveal.GetMsgHash().Fixed():          reveal,
+		commit.CommitEntry.EntryHash.Fixed(): commit,
+		tx.GetSigHash().Fixed():              ftx,
+	}
+
+	// An entry acked in minute 4 that is not processed yet
+	pl := s.ProcessLists.Get(s.GetDBHeightComplete() + 1)
+	if pl == nil || len(pl.VMs) == 0 {
+		t.Fatal("No process list to add to")
+	}
+	ackedReveal := messages.NewRevealEntryMsg()
+	ackedReveal.Entry = ackedEntry
+	ack := new(messages.Ack)
+	ack.Minute = 4
+	pl.VMs[0].List = append(pl.VMs[0].List, ackedReveal)
+	pl.VMs[0].ListAck = append(pl.VMs[0].ListAck, ack)
+	pl.VMs[0].Height = 0
+
+	stages := map[[32]byte]string{}
+	for _, p := range s.GetPendingEntries(new(interfaces.PendingFilter)) {
+		stages[p.EntryHash.Fixed()] = p.Stage
+	}
+	if len(stages) != 3 {
+		t.Fatalf("Expected 3 pending entries, got %v", len(stages))
+	}
+	if stages[holdingEntry.GetHash().Fixed()] != interfaces.PendingStageHolding || stages[commit.CommitEntry.EntryHash.Fixed()] != interfaces.PendingStageHolding {
+		t.Errorf("Entries in holding have the wrong stage - %v", stages)
+	}
+	if stages[ackedEntry.GetHash().Fixed()] != interfaces.PendingStageAcked {
+		t.Errorf("Acked entry has the wrong stage - %v", stages)
+	}
+
+	checkEntries := func(filter interface{}, expected ...interfaces.IHash) {
+		list := s.GetPendingEntries(filter)
+		if len(list) != len(expected) {
+			t.Errorf("Expected %v entries for %v, got %v", len(expected), filter, len(list))
+			return
+		}
+		for i := range list {
+			if !list[i].EntryHash.IsSameAs(expected[i]) {
+				t.Errorf("Wrong entry for %v - %v", filter, list[i].EntryHash)
+			}
+		}
+	}
+	checkEntries(&interfaces.PendingFilter{ChainIDs: []interfaces.IHash{ackedEntry.ChainID}}, ackedEntry.GetHash())
+	checkEntries(ackedEntry.ChainID.String(), ackedEntry.GetHash())
+	checkEntries(&interfaces.PendingFilter{ECPubKey: commit.CommitEntry.ECPubKey[:]}, commit.CommitEntry.EntryHash)
+	checkEntries(&interfaces.PendingFilter{MinMinute: 4}, ackedEntry.GetHash())
+	checkEntries(&interfaces.PendingFilter{MinMinute: 5})
+
+	found := false
+	for _, p := range s.GetPendingTransactions(new(interfaces.PendingFilter)) {
+		if p.TransactionID.IsSameAs(tx.GetSigHash()) {
+			found = true
+			if p.Stage != interfaces.PendingStageHolding || p.Status != constants.AckStatusNotConfirmedString {
+				t.Errorf("Transaction in holding has the wrong stage or status - %v %v", p.Stage, p.Status)
+			}
+		}
+	}
+	if !found {
+		t.Error("Transaction in holding was not listed")
+	}
+
+	address := primitives.ConvertFctAddressToUserStr(tx.GetInputs()[0].GetAddress())
+	list := s.GetPendingTransactions(&interfaces.PendingFilter{Address: address})
+	if len(list) != 1 || !list[0].TransactionID.IsSameAs(tx.GetSigHash()) {
+		t.Errorf("Wrong transactions for %v - %v", address, list)
+	}
+	list = s.GetPendingTransactions(&interfaces.PendingFilter{Address: address, MinMinute: 1})
+	if len(list) != 0 {
+		t.Errorf("Transactions in holding should not have a minute - %v", list)
+	}
+}
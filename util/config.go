@@ -24,13 +24,23 @@ type FactomdConfig struct {
 		DBType                                 string
 		LdbPath                                string
 		BoltDBPath                             string
+		BadgerDBPath                           string
 		DataStorePath                          string
 		DirectoryBlockInSeconds                int
 		ExportData                             bool
 		ExportDataSubpath                      string
+		AddressIndex                           bool
+		VerifyBlocks                           uint32
+		BlockCacheSize                         int
+		EncryptDB                              bool
+		DBKeyFile                              string
+		DBNewKeyFile                           string
+		ReadOnlyDB                             bool
+		ReopenDBSeconds                        int
 		FastBoot                               bool
 		FastBootLocation                       string
 		NodeMode                               string
+		PruneDepth                             uint32
 		IdentityChainID                        string
 		LocalServerPrivKey                     string
 		LocalServerPublicKey                   string
@@ -58,12 +68,22 @@ type FactomdConfig struct {
 		CustomSpecialPeers      string
 		CustomBootstrapIdentity string
 		CustomBootstrapKey      string
+		P2PEncryption           bool
+		P2PKeyFile              string
+		P2PEncryptSpecialPeers  bool
+		P2PPlainFallback        bool
+		P2PPeerUploadRate       int
+		P2PPeerDownloadRate     int
+		P2PTotalUploadRate      int
+		P2PTotalDownloadRate    int
 		FactomdTlsEnabled       bool
 		FactomdTlsPrivateKey    string
 		FactomdTlsPublicCert    string
 		FactomdRpcUser          string
 		FactomdRpcPass          string
 		CorsDomains             string
+		GrpcEnabled             bool
+		GrpcPort                int
 
 		ChangeAcksHeight uint32
 	}
@@ -100,6 +120,24 @@ type FactomdConfig struct {
 		WalletdLocation     string
 		WalletEncrypted     bool
 	}
+	Api struct {
+		RequireApiKey  bool
+		AnonymousRate  float64
+		AnonymousBurst int
+		MethodCosts    string
+		MaxBatchSize   int
+		BatchWorkers   int
+	}
+	ApiKey map[string]*ApiKeyConfig
+}
+
+// ApiKeyConfig is one [ApiKey "name"] section of the config file
+type ApiKeyConfig struct {
+	Key      string
+	Rate     float64
+	Burst    int
+	Methods  string
+	ReadOnly bool
 }
 
 // defaultConfig
@@ -113,14 +151,30 @@ HomeDir                               = ""
 ; --------------- ControlPanel disabled | readonly | readwrite
 ControlPanelSetting                   = readonly
 ControlPanelPort                      = 8090
-; --------------- DBType: LDB | Bolt | Map
+; --------------- DBType: LDB | Bolt | Badger | Map
 DBType                                = "LDB"
 LdbPath                               = "database/ldb"
 BoltDBPath                            = "database/bolt"
+BadgerDBPath                          = "database/badger"
 DataStorePath                         = "data/export"
 DirectoryBlockInSeconds               = 6
 ExportData                            = false
 ExportDataSubpath                     = "database/export/"
+; --------------- AddressIndex: keep an index of factoid transactions by address for the address-transactions API
+AddressIndex                          = false
+; --------------- VerifyBlocks: check one in every VerifyBlocks blocks read against its hash and heal corrupted ones from peers, 0 for none
+VerifyBlocks                          = 0
+; --------------- BlockCacheSize: keep this many of the blocks and entries read most recently in memory, 0 for none
+BlockCacheSize                        = 0
+; --------------- EncryptDB: encrypt the database at rest, with the password in FACTOMD_DB_PASSWORD or else in DBKeyFile
+EncryptDB                             = false
+DBKeyFile                             = ""
+; --------------- DBNewKeyFile: re-encrypt the database at boot with the password in FACTOMD_DB_NEW_PASSWORD or else in DBNewKeyFile
+DBNewKeyFile                          = ""
+; --------------- ReadOnlyDB: open the database without writing to it and only serve the API and control panel from it, with no network or consensus
+ReadOnlyDB                            = false
+; --------------- ReopenDBSeconds: with ReadOnlyDB, reopen the database this often to pick up a newer snapshot copied over it, 0 for never
+ReopenDBSeconds                       = 0
 FastBoot                              = true
 FastBootLocation                      = ""
 ; --------------- Network: MAIN | TEST | LOCAL
@@ -140,8 +194,23 @@ CustomSeedURL        = ""
 CustomSpecialPeers   = ""
 CustomBootstrapIdentity     = 38bab1455b7bd7e5efd15c53c777c79d0c988e9210f1da49a99d95b3a6417be9
 CustomBootstrapKey          = cc1985cdfae4e32b5a454dfda8ce5e1361558482684f3367649c3ad852c8e31a
-; --------------- NodeMode: FULL | SERVER ----------------
+; --------------- P2PEncryption: encrypt peer connections under the node key kept in P2PKeyFile
+P2PEncryption               = false
+P2PKeyFile                  = "p2pkey.pem"
+; --------------- P2PEncryptSpecialPeers: refuse plain connections to and from special peers
+P2PEncryptSpecialPeers      = false
+; --------------- P2PPlainFallback: dial peers that can not take the TLS handshake again without encryption, except special peers that must be encrypted or are pinned to a key
+P2PPlainFallback            = false
+; --------------- P2PPeerUploadRate, P2PPeerDownloadRate: cap the bytes per second sent to and read from each peer, 0 for no cap
+P2PPeerUploadRate           = 0
+P2PPeerDownloadRate         = 0
+; --------------- P2PTotalUploadRate, P2PTotalDownloadRate: cap the bytes per second sent to and read from all peers together, 0 for no cap
+P2PTotalUploadRate          = 0
+P2PTotalDownloadRate        = 0
+; --------------- NodeMode: FULL | SERVER | PRUNED ----------------
 NodeMode                                = FULL
+; --------------- PruneDepth: in PRUNED mode, how many of the latest directory blocks keep their entries
+PruneDepth                              = 1000
 LocalServerPrivKey                      = 4c38c72fc5cdad68f13b74674d3ffb1f3d63a112710868c9b08946553448d26d
 LocalServerPublicKey                    = cc1985cdfae4e32b5a454dfda8ce5e1361558482684f3367649c3ad852c8e31a
 ExchangeRateChainId                     = 111111118d918a8be684e0dac725493a75862ef96d2d3f43f84b26969329bf03
@@ -166,6 +235,10 @@ FactomdRpcPass                        = ""
 ; Example paramaters are "http://www.example.com, http://anotherexample.com, *"
 CorsDomains                           = ""
 
+; The gRPC API serves the read methods of the V2 API, using the same TLS settings, logins and API keys
+GrpcEnabled                           = false
+GrpcPort                              = 8091
+
 ; Specifying when to change ACKs for switching leader servers
 ChangeAcksHeight                      = 0
 
@@ -178,6 +251,31 @@ logLevel                              = error
 LogPath                               = "database/Log"
 ConsoleLogLevel                       = standard
 
+; ------------------------------------------------------------------------------
+; Access to the V2 API.  Each client is given a bucket of tokens that refills at its rate (tokens
+; per second) up to its burst, and every request takes the cost of its method out of it.
+; A rate of 0 means no limit.  Clients without an API key are limited by their IP address.
+; MethodCosts lists the methods that cost more (or less) than 1 token as method:cost pairs.
+; ------------------------------------------------------------------------------
+[Api]
+RequireApiKey                         = false
+AnonymousRate                         = 0
+AnonymousBurst                        = 0
+MethodCosts                           = "raw-data:10, receipt:5, transaction-receipt:5, chain-entries:5, address-transactions:5"
+; --------------- Largest JSON-RPC batch accepted, and how many of its requests are handled at once
+MaxBatchSize                          = 100
+BatchWorkers                          = 4
+
+; API keys are sent in the X-API-Key header, one section per key.  Methods is a comma separated
+; list of the only methods the key may call (all of them if empty), and a ReadOnly key may not
+; call the methods that submit commits, reveals, transactions or messages.
+; [ApiKey "explorer"]
+; Key                                 = "a long random string"
+; Rate                                = 20
+; Burst                               = 100
+; Methods                             = ""
+; ReadOnly                            = true
+
 ; ------------------------------------------------------------------------------
 ; Configurations for factom-walletd
 ; ------------------------------------------------------------------------------
@@ -220,10 +318,19 @@ func (s *FactomdConfig) String() string {
 	out.WriteString(fmt.Sprintf("\n    DBType                  %v", s.App.DBType))
 	out.WriteString(fmt.Sprintf("\n    LdbPath                 %v", s.App.LdbPath))
 	out.WriteString(fmt.Sprintf("\n    BoltDBPath              %v", s.App.BoltDBPath))
+	out.WriteString(fmt.Sprintf("\n    BadgerDBPath            %v", s.App.BadgerDBPath))
 	out.WriteString(fmt.Sprintf("\n    DataStorePath           %v", s.App.DataStorePath))
 	out.WriteString(fmt.Sprintf("\n    DirectoryBlockInSeconds %v", s.App.DirectoryBlockInSeconds))
 	out.WriteString(fmt.Sprintf("\n    ExportData              %v", s.App.ExportData))
 	out.WriteString(fmt.Sprintf("\n    ExportDataSubpath       %v", s.App.ExportDataSubpath))
+	out.WriteString(fmt.Sprintf("\n    AddressIndex            %v", s.App.AddressIndex))
+	out.WriteString(fmt.Sprintf("\n    VerifyBlocks            %v", s.App.VerifyBlocks))
+	out.WriteString(fmt.Sprintf("\n    BlockCacheSize          %v", s.App.BlockCacheSize))
+	out.WriteString(fmt.Sprintf("\n    EncryptDB               %v", s.App.EncryptDB))
+	out.WriteString(fmt.Sprintf("\n    DBKeyFile               %v", s.App.DBKeyFile))
+	out.WriteString(fmt.Sprintf("\n    DBNewKeyFile            %v", s.App.DBNewKeyFile))
+	out.WriteString(fmt.Sprintf("\n    ReadOnlyDB              %v", s.App.ReadOnlyDB))
+	out.WriteString(fmt.Sprintf("\n    ReopenDBSeconds         %v", s.App.ReopenDBSeconds))
 	out.WriteString(fmt.Sprintf("\n    Network                 %v", s.App.Network))
 	out.WriteString(fmt.Sprintf("\n    MainNetworkPort         %v", s.App.MainNetworkPort))
 	out.WriteString(fmt.Sprintf("\n    PeersFile               %v", s.App.PeersFile))
@@ -240,7 +347,16 @@ func (s *FactomdConfig) String() string {
 	out.WriteString(fmt.Sprintf("\n    CustomSpecialPeers      %v", s.App.CustomSpecialPeers))
 	out.WriteString(fmt.Sprintf("\n    CustomBootstrapIdentity %v", s.App.CustomBootstrapIdentity))
 	out.WriteString(fmt.Sprintf("\n    CustomBootstrapKey      %v", s.App.CustomBootstrapKey))
+	out.WriteString(fmt.Sprintf("\n    P2PEncryption           %v", s.App.P2PEncryption))
+	out.WriteString(fmt.Sprintf("\n    P2PKeyFile              %v", s.App.P2PKeyFile))
+	out.WriteString(fmt.Sprintf("\n    P2PEncryptSpecialPeers  %v", s.App.P2PEncryptSpecialPeers))
+	out.WriteString(fmt.Sprintf("\n    P2PPlainFallback        %v", s.App.P2PPlainFallback))
+	out.WriteString(fmt.Sprintf("\n    P2PPeerUploadRate       %v", s.App.P2PPeerUploadRate))
+	out.WriteString(fmt.Sprintf("\n    P2PPeerDownloadRate     %v", s.App.P2PPeerDownloadRate))
+	out.WriteString(fmt.Sprintf("\n    P2PTotalUploadRate      %v", s.App.P2PTotalUploadRate))
+	out.WriteString(fmt.Sprintf("\n    P2PTotalDownloadRate    %v", s.App.P2PTotalDownloadRate))
 	out.WriteString(fmt.Sprintf("\n    NodeMode                %v", s.App.NodeMode))
+	out.WriteString(fmt.Sprintf("\n    PruneDepth              %v", s.App.PruneDepth))
 	out.WriteString(fmt.Sprintf("\n    IdentityChainID         %v", s.App.IdentityChainID))
 	out.WriteString(fmt.Sprintf("\n    LocalServerPrivKey      %v", s.App.LocalServerPrivKey))
 	out.WriteString(fmt.Sprintf("\n    LocalServerPublicKey    %v", s.App.LocalServerPublicKey))
@@ -252,6 +368,8 @@ func (s *FactomdConfig) String() string {
 	out.WriteString(fmt.Sprintf("\n    FactomdTlsPublicCert     %v", s.App.FactomdTlsPublicCert))
 	out.WriteString(fmt.Sprintf("\n    FactomdRpcUser          	%v", s.App.FactomdRpcUser))
 	out.WriteString(fmt.Sprintf("\n    FactomdRpcPass          	%v", s.App.FactomdRpcPass))
+	out.WriteString(fmt.Sprintf("\n    GrpcEnabled             %v", s.App.GrpcEnabled))
+	out.WriteString(fmt.Sprintf("\n    GrpcPort                %v", s.App.GrpcPort))
 	out.WriteString(fmt.Sprintf("\n    ChangeAcksHeight         %v", s.App.ChangeAcksHeight))
 
 	out.WriteString(fmt.Sprintf("\n  Log"))
@@ -259,6 +377,24 @@ func (s *FactomdConfig) String() string {
 	out.WriteString(fmt.Sprintf("\n    LogLevel                %v", s.Log.LogLevel))
 	out.WriteString(fmt.Sprintf("\n    ConsoleLogLevel         %v", s.Log.ConsoleLogLevel))
 
+	out.WriteString(fmt.Sprintf("\n  Api"))
+	out.WriteString(fmt.Sprintf("\n    RequireApiKey           %v", s.Api.RequireApiKey))
+	out.WriteString(fmt.Sprintf("\n    AnonymousRate           %v", s.Api.AnonymousRate))
+	out.WriteString(fmt.Sprintf("\n    AnonymousBurst          %v", s.Api.AnonymousBurst))
+	out.WriteString(fmt.Sprintf("\n    MethodCosts             %v", s.Api.MethodCosts))
+	out.WriteString(fmt.Sprintf("\n    MaxBatchSize            %v", s.Api.MaxBatchSize))
+	out.WriteString(fmt.Sprintf("\n    BatchWorkers            %v", s.Api.BatchWorkers))
+	for name, k := range s.ApiKey {
+		out.WriteString(fmt.Sprintf("\n  ApiKey %q", name))
+		out.WriteString(fmt.Sprintf("\n    Rate                    %v", k.Rate))
+		out.WriteString(fmt.Sprintf("\n    Burst                   %v", k.Burst))
+		out.WriteString(fmt.Sprintf("\n    Methods                 %v", k.Methods))
+		out.WriteString(fmt.Sprintf("\n    ReadOnly                %v", k.ReadOnly))
+	}
+
+	out.WriteString(fmt.Sprintf("\n  Peer"))
+	out.WriteString(fmt.Sprintf("\n    BanDuration             %v", s.Peer.BanDuration))
+
 	out.WriteString(fmt.Sprintf("\n  Walletd"))
 	out.WriteString(fmt.Sprintf("\n    WalletRpcUser           %v", s.Walletd.WalletRpcUser))
 	out.WriteString(fmt.Sprintf("\n    WalletRpcPass           %v", s.Walletd.WalletRpcPass))
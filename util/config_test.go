@@ -1,6 +1,9 @@
 package util_test
 
 import (
+	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
 
 	. "github.com/FactomProject/factomd/util"
@@ -201,6 +204,54 @@ func TestReadConfig(t *testing.T) {
 	GetConfigFilename("")
 }
 
+func TestReadConfigApiKeys(t *testing.T) {
+	fconfig := ReadConfig("")
+	if fconfig.Api.RequireApiKey || fconfig.Api.MethodCosts == "" || len(fconfig.ApiKey) != 0 {
+		t.Errorf("Wrong default Api settings - %v", fconfig.Api)
+	}
+
+	f, err := ioutil.TempFile("", "factomd.conf")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`
+[Api]
+RequireApiKey = true
+AnonymousRate = 2.5
+
+[ApiKey "explorer"]
+Key      = "abc"
+Rate     = 20
+Burst    = 100
+ReadOnly = true
+
+[ApiKey "wallet"]
+Key     = "def"
+Methods = "factoid-submit, heights"
+`)
+	f.Close()
+
+	fconfig = ReadConfig(f.Name())
+	if !fconfig.Api.RequireApiKey || fconfig.Api.AnonymousRate != 2.5 {
+		t.Errorf("Wrong Api settings - %v", fconfig.Api)
+	}
+	if len(fconfig.ApiKey) != 2 {
+		t.Fatalf("Wrong number of api keys - %v", len(fconfig.ApiKey))
+	}
+	k := fconfig.ApiKey["explorer"]
+	if k == nil || k.Key != "abc" || k.Rate != 20 || k.Burst != 100 || !k.ReadOnly {
+		t.Errorf("Wrong explorer key - %v", k)
+	}
+	k = fconfig.ApiKey["wallet"]
+	if k == nil || k.Key != "def" || k.Methods != "factoid-submit, heights" || k.ReadOnly {
+		t.Errorf("Wrong wallet key - %v", k)
+	}
+	if !strings.Contains(fconfig.String(), `ApiKey "explorer"`) {
+		t.Errorf("Api keys missing from the config string")
+	}
+}
+
 // Check that the home directory is correctly prepended to bare files only
 func TestCheckConfigFileName(t *testing.T) {
 	checks := map[string]string{
@@ -0,0 +1,401 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/util"
+)
+
+// APIKeyHeader is the HTTP header a client presents its API key in
+const APIKeyHeader = "X-API-Key"
+
+// MaxAPIBuckets is the most rate limit buckets kept.  Past it the bucket of the client without
+// a key that was used longest ago is dropped.
+var MaxAPIBuckets = 10000
+
+// APIBucketTTL is how long the bucket of a client without a key is kept after its last request
+var APIBucketTTL = 10 * time.Minute
+
+// apiWriteMethods are the methods a read only API key may not call
+var apiWriteMethods = map[string]bool{
+	"commit-chain":     true,
+	"commit-entry":     true,
+	"reveal-chain":     true,
+	"reveal-entry":     true,
+	"factoid-submit":   true,
+	"send-raw-message": true,
+}
+
+// APIKey is a key clients can present to the V2 API, with what it may do
+type APIKey struct {
+	Name     string
+	Key      string
+	Rate     float64         // Tokens added to the key's bucket per second, 0 for no limit
+	Burst    int             // Size of the bucket
+	Methods  map[string]bool // The only methods the key may call, nil for all of them
+	ReadOnly bool
+}
+
+// Allowed tells if the key may call the method
+func (k *APIKey) Allowed(method string) bool {
+	if k.ReadOnly && apiWriteMethods[method] {
+		return false
+	}
+	if k.Methods != nil && !k.Methods[method] {
+		return false
+	}
+	return true
+}
+
+// tokenBucket is the rate limit state of a single client
+type tokenBucket struct {
+	tokens float64
+	last   time.Time // When the tokens were last added
+	used   time.Time // When the client last made a request
+}
+
+// burstSize is the size of the bucket for a rate, at least one second of tokens
+func burstSize(rate float64, burst int) float64 {
+	if float64(burst) < rate {
+		return rate
+	}
+	if burst < 1 {
+		return 1
+	}
+	return float64(burst)
+}
+
+// refill adds the tokens earned since the last call, and tells if the bucket is full
+func (b *tokenBucket) refill(rate, size float64, now time.Time) bool {
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens += elapsed * rate
+		b.last = now
+	}
+	if b.tokens >= size {
+		b.tokens = size
+		return true
+	}
+	return false
+}
+
+// take removes cost tokens from the bucket if it holds that many.  A cost larger than the
+// bucket needs the bucket to be full, and empties it.
+func (b *tokenBucket) take(rate, size, cost float64, now time.Time) bool {
+	b.used = now
+	b.refill(rate, size, now)
+	if cost > size {
+		cost = size
+	}
+	if b.tokens < cost {
+		return false
+	}
+	b.tokens -= cost
+	return true
+}
+
+// bucketSet holds the buckets of all the clients.  It is shared by the APIAccess a reload
+// replaces and the one replacing it.
+type bucketSet struct {
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+	pruned  time.Time // When the idle buckets were last dropped
+}
+
+func newBucketSet() *bucketSet {
+	s := new(bucketSet)
+	s.buckets = make(map[string]*tokenBucket)
+	return s
+}
+
+// APIAccess decides which V2 API requests are served, from the [Api] and [ApiKey] sections
+// of the config
+type APIAccess struct {
+	RequireKey     bool
+	AnonymousRate  float64
+	AnonymousBurst int
+	MethodCosts    map[string]float64
+	Keys           []*APIKey
+	MaxBatchSize   int
+	BatchWorkers   int
+
+	buckets *bucketSet
+}
+
+// splitList splits a comma separated config value, dropping empty items
+func splitList(s string) []string {
+	var list []string
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+// ParseMethodCosts reads a list of method:cost pairs such as "raw-data:10, receipt:5"
+func ParseMethodCosts(s string) (map[string]float64, error) {
+	costs := map[string]float64{}
+	for _, v := range splitList(s) {
+		i := strings.LastIndex(v, ":")
+		if i < 1 {
+			return nil, fmt.Errorf("Invalid method cost %q", v)
+		}
+		cost, err := strconv.ParseFloat(strings.TrimSpace(v[i+1:]), 64)
+		if err != nil || cost < 0 {
+			return nil, fmt.Errorf("Invalid method cost %q", v)
+		}
+		costs[strings.TrimSpace(v[:i])] = cost
+	}
+	return costs, nil
+}
+
+func NewAPIAccess(cfg *util.FactomdConfig) (*APIAccess, error) {
+	a := new(APIAccess)
+	a.buckets = newBucketSet()
+	a.MethodCosts = map[string]float64{}
+	a.MaxBatchSize = DefaultMaxBatchSize
+	a.BatchWorkers = DefaultBatchWorkers
+	if cfg == nil {
+		return a, nil
+	}
+
+	a.RequireKey = cfg.Api.RequireApiKey
+	a.AnonymousRate = cfg.Api.AnonymousRate
+	a.AnonymousBurst = cfg.Api.AnonymousBurst
+	if a.AnonymousRate < 0 {
+		return nil, errors.New("AnonymousRate cannot be negative")
+	}
+	costs, err := ParseMethodCosts(cfg.Api.MethodCosts)
+	if err != nil {
+		return nil, err
+	}
+	a.MethodCosts = costs
+	if cfg.Api.MaxBatchSize > 0 {
+		a.MaxBatchSize = cfg.Api.MaxBatchSize
+	}
+	if cfg.Api.BatchWorkers > 0 {
+		a.BatchWorkers = cfg.Api.BatchWorkers
+	}
+
+	seen := map[string]string{}
+	for name, kc := range cfg.ApiKey {
+		if kc == nil {
+			continue
+		}
+		if kc.Key == "" {
+			return nil, fmt.Errorf("ApiKey %q has no Key", name)
+		}
+		if other, ok := seen[kc.Key]; ok {
+			return nil, fmt.Errorf("ApiKey %q has the same Key as %q", name, other)
+		}
+		seen[kc.Key] = name
+		if kc.Rate < 0 {
+			return nil, fmt.Errorf("ApiKey %q has a negative Rate", name)
+		}
+
+		k := new(APIKey)
+		k.Name = name
+		k.Key = kc.Key
+		k.Rate = kc.Rate
+		k.Burst = kc.Burst
+		k.ReadOnly = kc.ReadOnly
+		if methods := splitList(kc.Methods); len(methods) > 0 {
+			k.Methods = map[string]bool{}
+			for _, m := range methods {
+				k.Methods[m] = true
+			}
+		}
+		a.Keys = append(a.Keys, k)
+	}
+	return a, nil
+}
+
+// Cost is the number of tokens a call to the method takes
+func (a *APIAccess) Cost(method string) float64 {
+	if cost, ok := a.MethodCosts[method]; ok {
+		return cost
+	}
+	return 1
+}
+
+// Authenticate finds the API key presented by a client.  A client without a key gets a nil
+// key, unless keys are required.
+func (a *APIAccess) Authenticate(key string) (*APIKey, error) {
+	if key == "" {
+		if a.RequireKey {
+			return nil, errors.New("no api key")
+		}
+		return nil, nil
+	}
+
+	// Compare hashes so every comparison takes the same time whatever the key presented
+	presented := sha256.Sum256([]byte(key))
+	var found *APIKey
+	for _, k := range a.Keys {
+		h := sha256.Sum256([]byte(k.Key))
+		if subtle.ConstantTimeCompare(presented[:], h[:]) == 1 {
+			found = k
+		}
+	}
+	if found == nil {
+		return nil, errors.New("bad api key")
+	}
+	return found, nil
+}
+
+// Authorize checks that a client may call the method, and takes the cost of the call from
+// its bucket.  Clients without a key are limited by their remote address.
+func (a *APIAccess) Authorize(key *APIKey, remoteIP string, method string) *primitives.JSONError {
+	id := "ip:" + remoteIP
+	rate, burst := a.AnonymousRate, a.AnonymousBurst
+	if key != nil {
+		if !key.Allowed(method) {
+			V2APIRequestsRejected.WithLabelValues("method").Inc()
+			return NewMethodNotAllowedError()
+		}
+		id = "key:" + key.Name
+		rate, burst = key.Rate, key.Burst
+	}
+	if rate == 0 {
+		return nil
+	}
+
+	a.buckets.mutex.Lock()
+	defer a.buckets.mutex.Unlock()
+
+	now := time.Now()
+	size := burstSize(rate, burst)
+	b, ok := a.buckets.buckets[id]
+	if !ok {
+		if len(a.buckets.buckets) >= MaxAPIBuckets || now.Sub(a.buckets.pruned) > APIBucketTTL {
+			a.prune(now)
+		}
+		if key == nil && len(a.buckets.buckets) >= MaxAPIBuckets {
+			a.evictOldest()
+		}
+		b = &tokenBucket{tokens: size, last: now}
+		a.buckets.buckets[id] = b
+	}
+	if !b.take(rate, size, a.Cost(method), now) {
+		V2APIRequestsRejected.WithLabelValues("rate").Inc()
+		return NewRateLimitExceededError()
+	}
+	return nil
+}
+
+// prune drops the buckets that have refilled, as a new bucket starts out full anyway, and
+// those of clients without a key that have been idle for APIBucketTTL.  The buckets must be
+// locked.
+func (a *APIAccess) prune(now time.Time) {
+	a.buckets.pruned = now
+	for id, b := range a.buckets.buckets {
+		if strings.HasPrefix(id, "ip:") && now.Sub(b.used) > APIBucketTTL {
+			delete(a.buckets.buckets, id)
+			continue
+		}
+		rate, burst := a.AnonymousRate, a.AnonymousBurst
+		for _, k := range a.Keys {
+			if id == "key:"+k.Name {
+				rate, burst = k.Rate, k.Burst
+			}
+		}
+		if b.refill(rate, burstSize(rate, burst), now) {
+			delete(a.buckets.buckets, id)
+		}
+	}
+}
+
+// evictOldest drops the bucket of the client without a key that was used longest ago.  The
+// buckets must be locked.
+func (a *APIAccess) evictOldest() {
+	oldest := ""
+	var used time.Time
+	for id, b := range a.buckets.buckets {
+		if strings.HasPrefix(id, "ip:") && (oldest == "" || b.used.Before(used)) {
+			oldest, used = id, b.used
+		}
+	}
+	if oldest != "" {
+		delete(a.buckets.buckets, oldest)
+	}
+}
+
+// BucketCount is the number of clients whose rate limit buckets are kept
+func (a *APIAccess) BucketCount() int {
+	a.buckets.mutex.Lock()
+	defer a.buckets.mutex.Unlock()
+	return len(a.buckets.buckets)
+}
+
+// remoteHost is the address of the client that sent the request, without the port
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+var apiAccess map[interfaces.IState]*APIAccess
+var apiAccessMutex sync.Mutex
+
+// GetAPIAccess returns the API access rules of the state, loading them from its config the
+// first time
+func GetAPIAccess(state interfaces.IState) *APIAccess {
+	apiAccessMutex.Lock()
+	a, ok := apiAccess[state]
+	apiAccessMutex.Unlock()
+	if ok {
+		return a
+	}
+	ReloadAPIAccess(state)
+
+	apiAccessMutex.Lock()
+	defer apiAccessMutex.Unlock()
+	return apiAccess[state]
+}
+
+// ReloadAPIAccess reads the API access rules from the config of the state.  If the config is
+// invalid the rules in use are kept, or when there are none every request is refused.  The
+// rate limit buckets of clients carry over, so a reload does not reset anyone's limit.
+func ReloadAPIAccess(state interfaces.IState) error {
+	cfg, _ := state.GetCfg().(*util.FactomdConfig)
+	a, err := NewAPIAccess(cfg)
+
+	apiAccessMutex.Lock()
+	defer apiAccessMutex.Unlock()
+
+	if apiAccess == nil {
+		apiAccess = make(map[interfaces.IState]*APIAccess)
+	}
+	old, ok := apiAccess[state]
+	if err != nil {
+		fmt.Printf("Invalid API access configuration: %v\n", err)
+		if !ok {
+			a = &APIAccess{RequireKey: true, MaxBatchSize: DefaultMaxBatchSize, BatchWorkers: DefaultBatchWorkers, buckets: newBucketSet()}
+			apiAccess[state] = a
+		}
+		return err
+	}
+	if ok {
+		a.buckets = old.buckets
+	}
+	apiAccess[state] = a
+	return nil
+}
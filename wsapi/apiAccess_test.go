@@ -0,0 +1,180 @@
+package wsapi_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/util"
+	. "github.com/FactomProject/factomd/wsapi"
+)
+
+func newTestAPIConfig() *util.FactomdConfig {
+	cfg := new(util.FactomdConfig)
+	cfg.Api.AnonymousRate = 0.001
+	cfg.Api.AnonymousBurst = 2
+	cfg.Api.MethodCosts = "raw-data:10, heights:0.5"
+	cfg.ApiKey = map[string]*util.ApiKeyConfig{
+		"explorer": {Key: "explorer-key", Rate: 0.001, Burst: 10, ReadOnly: true},
+		"wallet":   {Key: "wallet-key", Methods: "factoid-submit, heights"},
+	}
+	return cfg
+}
+
+func TestParseMethodCosts(t *testing.T) {
+	costs, err := ParseMethodCosts(" raw-data:10,receipt: 5 ,, heights:0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(costs) != 3 || costs["raw-data"] != 10 || costs["receipt"] != 5 || costs["heights"] != 0.5 {
+		t.Errorf("Wrong costs %v", costs)
+	}
+
+	for _, s := range []string{"raw-data", ":10", "raw-data:x", "raw-data:-1"} {
+		if _, err := ParseMethodCosts(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
+
+func TestNewAPIAccessErrors(t *testing.T) {
+	cfg := newTestAPIConfig()
+	cfg.ApiKey["copy"] = &util.ApiKeyConfig{Key: "wallet-key"}
+	if _, err := NewAPIAccess(cfg); err == nil {
+		t.Error("Expected an error for two keys with the same value")
+	}
+
+	cfg = newTestAPIConfig()
+	cfg.ApiKey["empty"] = &util.ApiKeyConfig{}
+	if _, err := NewAPIAccess(cfg); err == nil {
+		t.Error("Expected an error for a key without a value")
+	}
+
+	cfg = newTestAPIConfig()
+	cfg.Api.MethodCosts = "raw-data"
+	if _, err := NewAPIAccess(cfg); err == nil {
+		t.Error("Expected an error for an invalid method cost")
+	}
+}
+
+func TestAPIAccessAuthenticate(t *testing.T) {
+	cfg := newTestAPIConfig()
+	access, err := NewAPIAccess(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := access.Authenticate("")
+	if err != nil || key != nil {
+		t.Errorf("Anonymous clients should be let in - %v %v", key, err)
+	}
+	key, err = access.Authenticate("wallet-key")
+	if err != nil || key == nil || key.Name != "wallet" {
+		t.Errorf("Wrong key found - %v %v", key, err)
+	}
+	if _, err = access.Authenticate("nope"); err == nil {
+		t.Error("An unknown key should be refused")
+	}
+
+	cfg.Api.RequireApiKey = true
+	access, err = NewAPIAccess(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = access.Authenticate(""); err == nil {
+		t.Error("Anonymous clients should be refused when keys are required")
+	}
+}
+
+func TestAPIAccessAuthorize(t *testing.T) {
+	access, err := NewAPIAccess(newTestAPIConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	explorer, _ := access.Authenticate("explorer-key")
+	wallet, _ := access.Authenticate("wallet-key")
+
+	// Read only keys cannot submit anything
+	for _, m := range []string{"commit-chain", "commit-entry", "reveal-chain", "reveal-entry", "factoid-submit", "send-raw-message"} {
+		if jsonError := access.Authorize(explorer, "1.2.3.4", m); jsonError == nil || jsonError.Code != NewMethodNotAllowedError().Code {
+			t.Errorf("Read only key was allowed to call %v", m)
+		}
+	}
+
+	// Keys with an allow list can only call the methods on it, without limit
+	for i := 0; i < 100; i++ {
+		if jsonError := access.Authorize(wallet, "1.2.3.4", "factoid-submit"); jsonError != nil {
+			t.Fatalf("Call %v was refused - %v", i, jsonError)
+		}
+	}
+	if jsonError := access.Authorize(wallet, "1.2.3.4", "raw-data"); jsonError == nil {
+		t.Error("Key was allowed to call a method that is not on its list")
+	}
+
+	// The explorer can make a single raw-data call, which empties its bucket
+	if jsonError := access.Authorize(explorer, "1.2.3.4", "raw-data"); jsonError != nil {
+		t.Fatalf("First raw-data call was refused - %v", jsonError)
+	}
+	if jsonError := access.Authorize(explorer, "1.2.3.4", "heights"); jsonError == nil || jsonError.Code != NewRateLimitExceededError().Code {
+		t.Errorf("Expected the rate limit to be exceeded, got %v", jsonError)
+	}
+
+	// Anonymous clients are limited by address, and heights costs half a token
+	for i := 0; i < 4; i++ {
+		if jsonError := access.Authorize(nil, "1.2.3.4", "heights"); jsonError != nil {
+			t.Fatalf("Call %v was refused - %v", i, jsonError)
+		}
+	}
+	if jsonError := access.Authorize(nil, "1.2.3.4", "heights"); jsonError == nil {
+		t.Error("Expected the rate limit to be exceeded")
+	}
+	if jsonError := access.Authorize(nil, "5.6.7.8", "heights"); jsonError != nil {
+		t.Errorf("Another address shares the limit - %v", jsonError)
+	}
+}
+
+func TestAPIAccessBucketEviction(t *testing.T) {
+	defer func(max int, ttl time.Duration) { MaxAPIBuckets, APIBucketTTL = max, ttl }(MaxAPIBuckets, APIBucketTTL)
+	MaxAPIBuckets = 5
+	APIBucketTTL = 100 * time.Millisecond
+
+	access, err := NewAPIAccess(newTestAPIConfig())
+	if err != nil {
+		t.Fatal(err)
+	}
+	call := func(ip string) *primitives.JSONError {
+		time.Sleep(time.Millisecond) // so the buckets are used in order
+		return access.Authorize(nil, ip, "heights")
+	}
+
+	for i := 0; i < 4; i++ {
+		call(fmt.Sprintf("10.0.0.%d", i))
+	}
+	for i := 0; i < 4; i++ {
+		if jsonError := call("1.2.3.4"); jsonError != nil {
+			t.Fatalf("Call %v was refused - %v", i, jsonError)
+		}
+	}
+
+	// Past the most buckets kept, the one used longest ago goes, and a busy client keeps its limit
+	for i := 4; i < 20; i++ {
+		call(fmt.Sprintf("10.0.0.%d", i))
+		if access.BucketCount() > MaxAPIBuckets {
+			t.Fatalf("%v buckets kept", access.BucketCount())
+		}
+		if jsonError := call("1.2.3.4"); jsonError == nil {
+			t.Fatalf("The limit of a busy client was dropped after %v new clients", i)
+		}
+	}
+
+	// Idle buckets are dropped once they have outlived the TTL
+	time.Sleep(2 * APIBucketTTL)
+	call("10.0.1.1")
+	if access.BucketCount() != 1 {
+		t.Errorf("Expected the idle buckets to be dropped, %v are kept", access.BucketCount())
+	}
+	if jsonError := call("1.2.3.4"); jsonError != nil {
+		t.Errorf("An idle client is still limited - %v", jsonError)
+	}
+}
@@ -0,0 +1,107 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// Used when the config does not set the batch limits
+const (
+	DefaultMaxBatchSize = 100
+	DefaultBatchWorkers = 4
+)
+
+// IsV2Batch tells if a request body is a JSON-RPC 2.0 batch, that is a JSON array
+func IsV2Batch(body []byte) bool {
+	body = bytes.TrimLeft(body, " \t\r\n")
+	return len(body) > 0 && body[0] == '['
+}
+
+// HandleV2Batch answers every request of a JSON-RPC 2.0 batch with handle, running up to workers
+// of them at once.  The responses are in the order of the requests, and a request that fails
+// gets its own error response.  Notifications, requests without an id, are handled but get no
+// response, so a batch of only notifications returns none.  An error is only returned when the
+// batch itself is invalid: when it is not an array, is empty, or holds more than maxSize requests.
+func HandleV2Batch(body []byte, maxSize int, workers int, handle func(*primitives.JSON2Request) (*primitives.JSON2Response, *primitives.JSONError)) ([]*primitives.JSON2Response, *primitives.JSONError) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, NewParseError()
+	}
+	if len(raw) == 0 {
+		return nil, NewInvalidRequestError()
+	}
+	if len(raw) > maxSize {
+		return nil, NewCustomInvalidRequestError("Too many requests in the batch")
+	}
+	V2APIBatchSize.Observe(float64(len(raw)))
+
+	// answer returns nil for a notification
+	answer := func(r json.RawMessage) *primitives.JSON2Response {
+		resp := primitives.NewJSON2Response()
+		j, err := primitives.ParseJSON2Request(string(r))
+		if err != nil {
+			resp.Error = NewInvalidRequestError()
+			return resp
+		}
+		jsonResp, jsonError := handle(j)
+		if isNotification(r) {
+			return nil
+		}
+		if jsonError != nil {
+			resp.ID = j.ID
+			resp.Error = jsonError
+			return resp
+		}
+		return jsonResp
+	}
+
+	responses := make([]*primitives.JSON2Response, len(raw))
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(raw) {
+		workers = len(raw)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				responses[i] = answer(raw[i])
+			}
+		}()
+	}
+	for i := range raw {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	answered := responses[:0]
+	for _, r := range responses {
+		if r != nil {
+			answered = append(answered, r)
+		}
+	}
+	return answered, nil
+}
+
+// isNotification tells if a request has no id member.  An id of null still asks for a response.
+func isNotification(r json.RawMessage) bool {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(r, &members); err != nil {
+		return false
+	}
+	_, ok := members["id"]
+	return !ok
+}
@@ -0,0 +1,160 @@
+package wsapi_test
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/testHelper"
+	. "github.com/FactomProject/factomd/wsapi"
+)
+
+func TestIsV2Batch(t *testing.T) {
+	if !IsV2Batch([]byte(" \n[{}]")) {
+		t.Error("Array not seen as a batch")
+	}
+	if IsV2Batch([]byte(`{"jsonrpc":"2.0"}`)) || IsV2Batch([]byte("  ")) {
+		t.Error("Single request seen as a batch")
+	}
+}
+
+// echo answers every request with its own method, or an error for the method "fail"
+func echo(j *primitives.JSON2Request) (*primitives.JSON2Response, *primitives.JSONError) {
+	if j.Method == "fail" {
+		return nil, NewInvalidParamsError()
+	}
+	resp := primitives.NewJSON2Response()
+	resp.ID = j.ID
+	resp.Result = j.Method
+	return resp, nil
+}
+
+func TestHandleV2Batch(t *testing.T) {
+	body := `[
+		{"jsonrpc": "2.0", "id": 1, "method": "one"},
+		{"jsonrpc": "1.0", "id": 2, "method": "two"},
+		{"jsonrpc": "2.0", "id": 3, "method": "fail"},
+		5,
+		{"jsonrpc": "2.0", "id": 4, "method": "four"}
+	]`
+	responses, jsonError := HandleV2Batch([]byte(body), 10, 2, echo)
+	if jsonError != nil {
+		t.Fatal(jsonError)
+	}
+	if len(responses) != 5 {
+		t.Fatalf("Wrong number of responses - %v", len(responses))
+	}
+	if responses[0].Result != "one" || responses[0].Error != nil || responses[4].Result != "four" {
+		t.Errorf("Wrong responses %v, %v", responses[0], responses[4])
+	}
+	if responses[1].Error == nil || responses[1].Error.Code != NewInvalidRequestError().Code || responses[3].Error == nil {
+		t.Errorf("Invalid requests were not answered with an error - %v, %v", responses[1], responses[3])
+	}
+	if responses[2].Error == nil || responses[2].Error.Code != NewInvalidParamsError().Code || fmt.Sprint(responses[2].ID) != "3" {
+		t.Errorf("Failed request answered wrong - %v", responses[2])
+	}
+
+	invalid := map[string]int{
+		`[]`:           NewInvalidRequestError().Code,
+		`[{}, {}, {}]`: NewInvalidRequestError().Code,
+		`{"a": 1}`:     NewParseError().Code,
+		`[1, 2`:        NewParseError().Code,
+	}
+	for b, code := range invalid {
+		_, jsonError := HandleV2Batch([]byte(b), 2, 2, echo)
+		if jsonError == nil || jsonError.Code != code {
+			t.Errorf("Expected error %v for %v, got %v", code, b, jsonError)
+		}
+	}
+}
+
+func TestHandleV2BatchNotifications(t *testing.T) {
+	var mutex sync.Mutex
+	handled := []string{}
+	handle := func(j *primitives.JSON2Request) (*primitives.JSON2Response, *primitives.JSONError) {
+		mutex.Lock()
+		handled = append(handled, j.Method)
+		mutex.Unlock()
+		return echo(j)
+	}
+
+	// Requests without an id are handled but not answered, even when they fail
+	body := `[
+		{"jsonrpc": "2.0", "method": "one"},
+		{"jsonrpc": "2.0", "id": 2, "method": "two"},
+		{"jsonrpc": "2.0", "method": "fail"},
+		{"jsonrpc": "2.0", "id": null, "method": "four"}
+	]`
+	responses, jsonError := HandleV2Batch([]byte(body), 10, 2, handle)
+	if jsonError != nil {
+		t.Fatal(jsonError)
+	}
+	if len(handled) != 4 {
+		t.Errorf("Expected 4 requests to be handled, got %v", handled)
+	}
+	if len(responses) != 2 || responses[0].Result != "two" || responses[1].Result != "four" {
+		t.Fatalf("Wrong responses - %v", responses)
+	}
+
+	responses, jsonError = HandleV2Batch([]byte(`[{"jsonrpc": "2.0", "method": "one"}, {"jsonrpc": "2.0", "method": "fail"}]`), 10, 2, handle)
+	if jsonError != nil || len(responses) != 0 {
+		t.Errorf("A batch of notifications was answered - %v, %v", responses, jsonError)
+	}
+}
+
+func TestHandleV2BatchWorkers(t *testing.T) {
+	requests := []string{}
+	for i := 0; i < 20; i++ {
+		requests = append(requests, fmt.Sprintf(`{"jsonrpc": "2.0", "id": %d, "method": "m%d"}`, i, i))
+	}
+	body := "[" + strings.Join(requests, ",") + "]"
+
+	var mutex sync.Mutex
+	running, most := 0, 0
+	handle := func(j *primitives.JSON2Request) (*primitives.JSON2Response, *primitives.JSONError) {
+		mutex.Lock()
+		running++
+		if running > most {
+			most = running
+		}
+		mutex.Unlock()
+		time.Sleep(time.Millisecond)
+		mutex.Lock()
+		running--
+		mutex.Unlock()
+		return echo(j)
+	}
+
+	responses, jsonError := HandleV2Batch([]byte(body), 100, 3, handle)
+	if jsonError != nil {
+		t.Fatal(jsonError)
+	}
+	if most > 3 {
+		t.Errorf("%v requests were handled at once", most)
+	}
+	for i, r := range responses {
+		if r.Result != fmt.Sprintf("m%d", i) {
+			t.Errorf("Response %v is out of order - %v", i, r)
+		}
+	}
+}
+
+func TestHandleV2BatchRequests(t *testing.T) {
+	state := testHelper.CreateAndPopulateTestState()
+	body := `[{"jsonrpc": "2.0", "id": 1, "method": "heights"}, {"jsonrpc": "2.0", "id": 2, "method": "nope"}]`
+	responses, jsonError := HandleV2Batch([]byte(body), 10, 2, func(j *primitives.JSON2Request) (*primitives.JSON2Response, *primitives.JSONError) {
+		return HandleV2Request(state, j)
+	})
+	if jsonError != nil {
+		t.Fatal(jsonError)
+	}
+	if _, ok := responses[0].Result.(*HeightsResponse); !ok || responses[0].Error != nil {
+		t.Errorf("Wrong heights response - %v", responses[0])
+	}
+	if responses[1].Error == nil || responses[1].Error.Code != NewMethodNotFoundError().Code {
+		t.Errorf("Wrong response to an unknown method - %v", responses[1])
+	}
+}
@@ -0,0 +1,228 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+)
+
+// Default and largest page sizes of chain-entries
+const (
+	ChainEntriesDefaultLimit = 100
+	ChainEntriesMaxLimit     = 1000
+)
+
+// A chain-entries cursor is the sequence number of an entry block followed by the position in
+// the block of the next entry to return, hex encoded.
+
+func chainEntriesCursor(seq uint32, index int) string {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, seq)
+	binary.BigEndian.PutUint32(b[4:], uint32(index))
+	return hex.EncodeToString(b)
+}
+
+func parseChainEntriesCursor(cursor string) (uint32, int, error) {
+	b, err := hex.DecodeString(cursor)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(b) != 8 {
+		return 0, 0, hex.ErrLength
+	}
+	return binary.BigEndian.Uint32(b), int(binary.BigEndian.Uint32(b[4:])), nil
+}
+
+// chainSequenceAtHeight finds the first entry block of the chain at or above the height or,
+// if last is set, the last entry block at or below it.  ok is false if there is no such block.
+// On a pruning node the entry blocks that were pruned count as older than any height, so the
+// search lands on the blocks that are kept.
+func chainSequenceAtHeight(dbase interfaces.DBOverlaySimple, chainID interfaces.IHash, headSeq uint32, height uint32, last bool, pruning bool) (seq uint32, ok bool, jsonError *primitives.JSONError) {
+	n := int(headSeq) + 1
+	i := sort.Search(n, func(i int) bool {
+		if jsonError != nil {
+			return true
+		}
+		block, err := dbase.FetchEBlockBySequence(chainID, uint32(i))
+		if err == nil && block == nil && pruning {
+			return false
+		}
+		if err != nil {
+			jsonError = NewInternalDatabaseError()
+			return true
+		}
+		if block == nil {
+			jsonError = NewBlockNotFoundError()
+			return true
+		}
+		if last {
+			return block.GetDatabaseHeight() > height
+		}
+		return block.GetDatabaseHeight() >= height
+	})
+	if jsonError != nil {
+		return 0, false, jsonError
+	}
+	if last {
+		return uint32(i - 1), i > 0, nil
+	}
+	return uint32(i), i < n, nil
+}
+
+func HandleV2ChainEntries(state interfaces.IState, params interface{}) (interface{}, *primitives.JSONError) {
+	n := time.Now()
+	defer HandleV2APICallChainEntries.Observe(float64(time.Since(n).Nanoseconds()))
+
+	req := new(ChainEntriesRequest)
+	err := MapToObject(params, req)
+	if err != nil {
+		return nil, NewInvalidParamsError()
+	}
+
+	chainID, err := primitives.HexToHash(req.ChainID)
+	if err != nil {
+		return nil, NewInvalidHashError()
+	}
+
+	if req.Limit < 0 {
+		return nil, NewInvalidParamsError()
+	}
+	limit := int(req.Limit)
+	if limit == 0 {
+		limit = ChainEntriesDefaultLimit
+	}
+	if limit > ChainEntriesMaxLimit {
+		limit = ChainEntriesMaxLimit
+	}
+
+	var from, to uint32 = 0, math.MaxUint32
+	if req.FromHeight != nil {
+		if *req.FromHeight < 0 || *req.FromHeight > math.MaxUint32 {
+			return nil, NewInvalidParamsError()
+		}
+		from = uint32(*req.FromHeight)
+	}
+	if req.ToHeight != nil {
+		if *req.ToHeight < 0 || *req.ToHeight > math.MaxUint32 {
+			return nil, NewInvalidParamsError()
+		}
+		to = uint32(*req.ToHeight)
+	}
+	if from > to {
+		return nil, NewCustomInvalidParamsError("fromheight is above toheight")
+	}
+
+	dbase := state.GetDB()
+	head, err := dbase.FetchEBlockHead(chainID)
+	if err != nil {
+		return nil, NewInternalDatabaseError()
+	}
+	if head == nil {
+		return nil, NewMissingChainHeadError()
+	}
+	headSeq := head.GetHeader().GetEBSequence()
+
+	resp := new(ChainEntriesResponse)
+	resp.ChainID = chainID.String()
+	resp.Entries = []ChainEntry{}
+
+	// Find where to start.  An index of -1 stands for the last entry of the block.
+	var seq uint32
+	var index int
+	ok := true
+	var jsonError *primitives.JSONError
+	if req.Cursor != "" {
+		seq, index, err = parseChainEntriesCursor(req.Cursor)
+		if err != nil {
+			return nil, NewCustomInvalidParamsError("Invalid cursor")
+		}
+	} else if req.Reverse {
+		seq, ok, jsonError = chainSequenceAtHeight(dbase, chainID, headSeq, to, true, state.GetPruneDepth() > 0)
+		index = -1
+	} else {
+		seq, ok, jsonError = chainSequenceAtHeight(dbase, chainID, headSeq, from, false, state.GetPruneDepth() > 0)
+	}
+	if jsonError != nil {
+		return nil, jsonError
+	}
+
+	step := 1
+	if req.Reverse {
+		step = -1
+	}
+
+	for ok && seq <= headSeq {
+		block, err := dbase.FetchEBlockBySequence(chainID, seq)
+		if err == nil && block == nil && state.GetPruneDepth() > 0 {
+			// The range reaches back into blocks that were pruned
+			return nil, NewPrunedDataError()
+		}
+		if err != nil {
+			return nil, NewInternalDatabaseError()
+		}
+		if block == nil {
+			return nil, NewBlockNotFoundError()
+		}
+
+		height := block.GetDatabaseHeight()
+		if (!req.Reverse && height > to) || (req.Reverse && height < from) {
+			break
+		}
+
+		if height >= from && height <= to {
+			hashes := block.GetEntryHashes()
+			if index < 0 {
+				index = len(hashes) - 1
+			}
+			for ; index >= 0 && index < len(hashes); index += step {
+				h := hashes[index]
+				if h.IsMinuteMarker() {
+					continue
+				}
+				if len(resp.Entries) == limit {
+					resp.NextCursor = chainEntriesCursor(seq, index)
+					return resp, nil
+				}
+
+				e := ChainEntry{}
+				e.EntryHash = h.String()
+				e.EBlockKeyMR = block.DatabasePrimaryIndex().String()
+				e.EBSequence = int64(seq)
+				e.DBHeight = int64(height)
+				entry, err := dbase.FetchEntry(h)
+				if err != nil {
+					return nil, NewInternalDatabaseError()
+				}
+				if entry != nil {
+					e.Content = hex.EncodeToString(entry.GetContent())
+					for _, v := range entry.ExternalIDs() {
+						e.ExtIDs = append(e.ExtIDs, hex.EncodeToString(v))
+					}
+				}
+				resp.Entries = append(resp.Entries, e)
+			}
+		}
+
+		if req.Reverse {
+			if seq == 0 {
+				break
+			}
+			seq--
+			index = -1
+		} else {
+			seq++
+			index = 0
+		}
+	}
+
+	return resp, nil
+}
@@ -10,6 +10,7 @@ import (
 	"io/ioutil"
 	"net/http"
 	"os"
+	"sort"
 	"strings"
 
 	"github.com/FactomProject/factomd/common/globals"
@@ -117,6 +118,12 @@ func HandleDebugRequest(
 	case "network-info":
 		resp, jsonError = HandleNetworkInfo(state, params)
 		break
+	case "peer-bans":
+		resp, jsonError = HandlePeerBans(state, params)
+		break
+	case "clear-peer-bans":
+		resp, jsonError = HandleClearPeerBans(state, params)
+		break
 	case "summary":
 		resp, jsonError = HandleSummary(state, params)
 		break
@@ -131,6 +138,8 @@ func HandleDebugRequest(
 		break
 	case "sim-ctrl":
 		resp, jsonError = HandleSimControl(state, params)
+	case "snapshot-database":
+		resp, jsonError = HandleSnapshotDatabase(state, params)
 	default:
 		jsonError = NewMethodNotFoundError()
 		break
@@ -357,6 +366,52 @@ func HandleNetworkInfo(
 	return r, nil
 }
 
+// HandlePeerBans lists the banned peer addresses, with the unix time each ban ends
+func HandlePeerBans(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	type ban struct {
+		Address string `json:"address"`
+		Until   int64  `json:"until"`
+	}
+	type ret struct {
+		Bans []ban `json:"bans"`
+	}
+	r := new(ret)
+	r.Bans = []ban{}
+	for address, until := range state.GetPeerBans() {
+		r.Bans = append(r.Bans, ban{Address: address, Until: until.Unix()})
+	}
+	sort.Slice(r.Bans, func(i, j int) bool { return r.Bans[i].Address < r.Bans[j].Address })
+	return r, nil
+}
+
+// HandleClearPeerBans lifts the ban on the address given, or on every peer if there is none
+func HandleClearPeerBans(
+	state interfaces.IState,
+	params interface{},
+) (
+	interface{},
+	*primitives.JSONError,
+) {
+	req := new(ClearPeerBansRequest)
+	err := MapToObject(params, req)
+	if err != nil {
+		return nil, NewInvalidParamsError()
+	}
+
+	type ret struct {
+		Cleared int `json:"cleared"`
+	}
+	r := new(ret)
+	r.Cleared = state.UnbanPeer(req.Address)
+	return r, nil
+}
+
 func HandleSummary(
 	state interfaces.IState,
 	params interface{},
@@ -412,6 +467,9 @@ func HandleReloadConfig(
 ) {
 	// LoacConfig with "" strings should load the default location
 	state.LoadConfig(state.GetConfigPath(), state.GetNetworkName())
+	if err := ReloadAPIAccess(state); err != nil {
+		return nil, NewCustomInternalError(err.Error())
+	}
 
 	return state.GetCfg(), nil
 }
@@ -443,6 +501,30 @@ func HandleSimControl(state interfaces.IState, params interface{}) (interface{},
 	return r, nil
 }
 
+// HandleSnapshotDatabase copies the database into the directory given while the node keeps
+// running.  It returns once the copy is complete.
+func HandleSnapshotDatabase(state interfaces.IState, params interface{}) (interface{}, *primitives.JSONError) {
+	req := new(SnapshotDatabaseRequest)
+	err := MapToObject(params, req)
+	if err != nil || req.Path == "" {
+		return nil, NewInvalidParamsError()
+	}
+
+	height, err := state.SnapshotDatabase(req.Path)
+	if err != nil {
+		return nil, NewCustomInternalError(err.Error())
+	}
+
+	type ret struct {
+		Path   string `json:"path"`
+		Height uint32 `json:"height"`
+	}
+	r := new(ret)
+	r.Path = req.Path
+	r.Height = height
+	return r, nil
+}
+
 type SetDelayRequest struct {
 	Delay int64 `json:"delay"`
 }
@@ -451,6 +533,14 @@ type SetDropRateRequest struct {
 	DropRate int `json:"droprate"`
 }
 
+type SnapshotDatabaseRequest struct {
+	Path string `json:"path"`
+}
+
+type ClearPeerBansRequest struct {
+	Address string `json:"address"`
+}
+
 type GetCommands struct {
 	Commands []string `json:"commands"`
 }
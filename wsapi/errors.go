@@ -47,6 +47,9 @@ func NewCustomInternalError(data interface{}) *primitives.JSONError {
 func NewCustomInvalidParamsError(data interface{}) *primitives.JSONError {
 	return primitives.NewJSONError(-32602, "Invalid params", data)
 }
+func NewCustomInvalidRequestError(data interface{}) *primitives.JSONError {
+	return primitives.NewJSONError(-32600, "Invalid Request", data)
+}
 
 /*******************************************************************/
 
@@ -97,3 +100,24 @@ func NewReceiptError() *primitives.JSONError {
 func NewRepeatCommitError(data interface{}) *primitives.JSONError {
 	return primitives.NewJSONError(-32011, "Repeated Commit", data)
 }
+func NewSubscriptionNotFoundError() *primitives.JSONError {
+	return primitives.NewJSONError(-32012, "Subscription not found", nil)
+}
+func NewAddressIndexDisabledError() *primitives.JSONError {
+	return primitives.NewJSONError(-32013, "Address index is not enabled", nil)
+}
+func NewRateLimitExceededError() *primitives.JSONError {
+	return primitives.NewJSONError(-32014, "Rate limit exceeded", nil)
+}
+func NewMethodNotAllowedError() *primitives.JSONError {
+	return primitives.NewJSONError(-32015, "Method not allowed", nil)
+}
+func NewUnauthorizedError() *primitives.JSONError {
+	return primitives.NewJSONError(-32016, "Unauthorized", nil)
+}
+func NewPrunedDataError() *primitives.JSONError {
+	return primitives.NewJSONError(-32017, "Data pruned", nil)
+}
+func NewReadOnlyNodeError() *primitives.JSONError {
+	return primitives.NewJSONError(-32018, "Node is read only", nil)
+}
@@ -58,6 +58,11 @@ func TestErrors(t *testing.T) {
 		t.Error("Code or message is wrong for NewCustomInvalidParamsError")
 	}
 
+	je = NewCustomInvalidRequestError(nil)
+	if je.Code != -32600 || je.Message != "Invalid Request" {
+		t.Error("Code or message is wrong for NewCustomInvalidRequestError")
+	}
+
 	je = NewInvalidAddressError()
 	if je.Code != -32602 || je.Message != "Invalid params" {
 		t.Error("Code or message is wrong for NewInvalidAddressError")
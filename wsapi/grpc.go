@@ -0,0 +1,315 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/log"
+	"github.com/FactomProject/factomd/util"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// The gRPC API is described in factomd.proto.  Every call takes the params of the V2 method it
+// mirrors as a google.protobuf.Struct, is answered by the same handler as over JSON-RPC, and
+// returns the result as a google.protobuf.Value, so clients can use any protobuf library
+// without factomd shipping generated code.
+
+const GrpcServiceName = "factomd.api.v2.API"
+
+// GrpcMethods maps the unary gRPC methods to the V2 methods they mirror
+var GrpcMethods = map[string]string{
+	"Heights":             "heights",
+	"Properties":          "properties",
+	"CurrentMinute":       "current-minute",
+	"DirectoryBlockHead":  "directory-block-head",
+	"DirectoryBlock":      "directory-block",
+	"DBlockByHeight":      "dblock-by-height",
+	"ABlockByHeight":      "ablock-by-height",
+	"ECBlockByHeight":     "ecblock-by-height",
+	"FBlockByHeight":      "fblock-by-height",
+	"AdminBlock":          "admin-block",
+	"EntryCreditBlock":    "entrycredit-block",
+	"FactoidBlock":        "factoid-block",
+	"EntryBlock":          "entry-block",
+	"Entry":               "entry",
+	"ChainHead":           "chain-head",
+	"ChainEntries":        "chain-entries",
+	"RawData":             "raw-data",
+	"Receipt":             "receipt",
+	"Transaction":         "transaction",
+	"TransactionReceipt":  "transaction-receipt",
+	"AddressTransactions": "address-transactions",
+	"EntryCreditBalance":  "entry-credit-balance",
+	"FactoidBalance":      "factoid-balance",
+	"MultipleFCTBalances": "multiple-fct-balances",
+	"MultipleECBalances":  "multiple-ec-balances",
+	"EntryCreditRate":     "entry-credit-rate",
+	"Ack":                 "ack",
+	"EntryAck":            "entry-ack",
+	"FactoidAck":          "factoid-ack",
+	"PendingEntries":      "pending-entries",
+	"PendingTransactions": "pending-transactions",
+}
+
+var GrpcServers map[int]*grpc.Server
+var GrpcServersMutex sync.Mutex
+
+// grpcAPIServer is the handler type of the service description
+type grpcAPIServer interface {
+	call(ctx context.Context, method string, params *structpb.Struct) (*structpb.Value, error)
+	subscribe(params *structpb.Struct, stream grpc.ServerStream) error
+}
+
+type grpcAPI struct {
+	state interfaces.IState
+}
+
+var _ grpcAPIServer = (*grpcAPI)(nil)
+
+// StartGrpc starts the gRPC API of the state if it is enabled in the config
+func StartGrpc(state interfaces.IState) {
+	cfg, ok := state.GetCfg().(*util.FactomdConfig)
+	if !ok || !cfg.App.GrpcEnabled {
+		return
+	}
+
+	GrpcServersMutex.Lock()
+	defer GrpcServersMutex.Unlock()
+
+	if GrpcServers == nil {
+		GrpcServers = make(map[int]*grpc.Server)
+	}
+	port := cfg.App.GrpcPort
+	if GrpcServers[port] != nil {
+		return
+	}
+
+	var opts []grpc.ServerOption
+	tlsIsEnabled, tlsPrivate, tlsPublic := state.GetTlsInfo()
+	if tlsIsEnabled {
+		// The certificate is created by Start if it does not exist yet
+		keypair, err := tls.LoadX509KeyPair(tlsPublic, tlsPrivate)
+		if err != nil {
+			panic(fmt.Sprintf("could not create TLS keypair with error: %v", err))
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(&tls.Config{
+			Certificates: []tls.Certificate{keypair},
+			MinVersion:   tls.VersionTLS12,
+		})))
+	}
+
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+	if err != nil {
+		panic(fmt.Sprintf("could not start the gRPC API server with error: %v", err))
+	}
+
+	server := grpc.NewServer(opts...)
+	RegisterGrpcAPI(server, state)
+	GrpcServers[port] = server
+
+	log.Printf("Starting gRPC API server on port %d", port)
+	go server.Serve(listener)
+}
+
+// RegisterGrpcAPI adds the factomd API service of the state to a gRPC server
+func RegisterGrpcAPI(server *grpc.Server, state interfaces.IState) {
+	desc := grpcServiceDesc()
+	server.RegisterService(&desc, &grpcAPI{state: state})
+}
+
+func grpcServiceDesc() grpc.ServiceDesc {
+	desc := grpc.ServiceDesc{
+		ServiceName: GrpcServiceName,
+		HandlerType: (*grpcAPIServer)(nil),
+		Metadata:    "factomd.proto",
+	}
+
+	for name, method := range GrpcMethods {
+		fullMethod := "/" + GrpcServiceName + "/" + name
+		method := method
+		desc.Methods = append(desc.Methods, grpc.MethodDesc{
+			MethodName: name,
+			Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+				in := new(structpb.Struct)
+				if err := dec(in); err != nil {
+					return nil, err
+				}
+				handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+					return srv.(grpcAPIServer).call(ctx, method, req.(*structpb.Struct))
+				}
+				if interceptor == nil {
+					return handler(ctx, in)
+				}
+				return interceptor(ctx, in, &grpc.UnaryServerInfo{Server: srv, FullMethod: fullMethod}, handler)
+			},
+		})
+	}
+
+	// NewBlocks is Subscribe with the topic set to new directory blocks
+	streams := map[string]string{"Subscribe": "", "NewBlocks": TopicNewDBlock}
+	for name, topic := range streams {
+		topic := topic
+		desc.Streams = append(desc.Streams, grpc.StreamDesc{
+			StreamName:    name,
+			ServerStreams: true,
+			Handler: func(srv interface{}, stream grpc.ServerStream) error {
+				in := new(structpb.Struct)
+				if err := stream.RecvMsg(in); err != nil {
+					return err
+				}
+				if topic != "" {
+					if in.Fields == nil {
+						in.Fields = make(map[string]*structpb.Value)
+					}
+					in.Fields["topic"] = structpb.NewStringValue(topic)
+				}
+				return srv.(grpcAPIServer).subscribe(in, stream)
+			},
+		})
+	}
+	return desc
+}
+
+// authorize applies the RPC login and API key rules of the V2 API to a gRPC call
+func (g *grpcAPI) authorize(ctx context.Context, method string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	first := func(key string) string {
+		if v := md.Get(key); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	if g.state.GetRpcUser() != "" {
+		if err := checkAuthValue(g.state, first("authorization")); err != nil {
+			return status.Error(codes.Unauthenticated, "Unauthorized")
+		}
+	}
+
+	access := GetAPIAccess(g.state)
+	key, err := access.Authenticate(first(APIKeyHeader))
+	if err != nil {
+		V2APIRequestsRejected.WithLabelValues("auth").Inc()
+		return status.Error(codes.Unauthenticated, "Unauthorized")
+	}
+
+	remoteIP := ""
+	if p, ok := peer.FromContext(ctx); ok {
+		remoteIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(remoteIP); err == nil {
+			remoteIP = host
+		}
+	}
+	if jsonError := access.Authorize(key, remoteIP, method); jsonError != nil {
+		return grpcError(jsonError)
+	}
+	return nil
+}
+
+func (g *grpcAPI) call(ctx context.Context, method string, params *structpb.Struct) (*structpb.Value, error) {
+	if err := g.authorize(ctx, method); err != nil {
+		return nil, err
+	}
+
+	var p interface{}
+	if params != nil && len(params.Fields) > 0 {
+		p = params.AsMap()
+	}
+	resp, jsonError := HandleV2Request(g.state, primitives.NewJSON2Request(method, 0, p))
+	if jsonError != nil {
+		return nil, grpcError(jsonError)
+	}
+	return toGrpcValue(resp.Result)
+}
+
+func (g *grpcAPI) subscribe(params *structpb.Struct, stream grpc.ServerStream) error {
+	if err := g.authorize(stream.Context(), "subscribe"); err != nil {
+		return err
+	}
+	sub, jsonError := NewSubscription(params.AsMap())
+	if jsonError != nil {
+		return grpcError(jsonError)
+	}
+
+	c := newWSClient(nil)
+	c.subscriptions[sub.ID] = sub
+	hub := getSubscriptionHub(g.state)
+	hub.register(c)
+	defer hub.unregister(c)
+
+	for {
+		select {
+		case msg := <-c.send:
+			n, ok := msg.(*SubscriptionNotification)
+			if !ok {
+				continue
+			}
+			v, err := toGrpcValue(n.Params.Result)
+			if err != nil {
+				return err
+			}
+			if err := stream.SendMsg(v); err != nil {
+				return err
+			}
+		case <-stream.Context().Done():
+			return nil
+		}
+	}
+}
+
+// toGrpcValue converts a V2 result to the protobuf form of its JSON
+func toGrpcValue(result interface{}) (*structpb.Value, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	v := new(structpb.Value)
+	if err := protojson.Unmarshal(data, v); err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return v, nil
+}
+
+// grpcError converts a JSON-RPC error to the closest gRPC status.  The JSON-RPC code is kept in
+// the message so clients can tell apart the errors sharing a status.
+func grpcError(jsonError *primitives.JSONError) error {
+	code := codes.Internal
+	switch jsonError.Code {
+	case -32600, -32602, -32700:
+		code = codes.InvalidArgument
+	case -32601:
+		code = codes.Unimplemented
+	case -32008, -32009, -32012:
+		code = codes.NotFound
+	case -32013:
+		code = codes.FailedPrecondition
+	case -32014:
+		code = codes.ResourceExhausted
+	case -32015:
+		code = codes.PermissionDenied
+	case -32016:
+		code = codes.Unauthenticated
+	}
+	msg := fmt.Sprintf("%s (%d)", jsonError.Message, jsonError.Code)
+	if jsonError.Data != nil {
+		msg = fmt.Sprintf("%s: %v", msg, jsonError.Data)
+	}
+	return status.Error(code, msg)
+}
@@ -0,0 +1,150 @@
+package wsapi_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/state"
+	"github.com/FactomProject/factomd/testHelper"
+	. "github.com/FactomProject/factomd/wsapi"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func startTestGrpc(t *testing.T, s *state.State) (*grpc.ClientConn, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer()
+	RegisterGrpcAPI(server, s)
+	go server.Serve(listener)
+
+	conn, err := grpc.Dial(listener.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return conn, func() {
+		conn.Close()
+		server.Stop()
+	}
+}
+
+func TestGrpcUnaryMethods(t *testing.T) {
+	s := testHelper.CreateAndPopulateTestState()
+	conn, stop := startTestGrpc(t, s)
+	defer stop()
+	ctx := context.Background()
+
+	out := new(structpb.Value)
+	err := conn.Invoke(ctx, "/"+GrpcServiceName+"/Heights", new(structpb.Struct), out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	heights := out.GetStructValue().GetFields()
+	if heights["directoryblockheight"].GetNumberValue() != float64(s.GetHighestSavedBlk()) {
+		t.Errorf("Wrong heights - %v", out)
+	}
+
+	params, _ := structpb.NewStruct(map[string]interface{}{"height": 1})
+	err = conn.Invoke(ctx, "/"+GrpcServiceName+"/DBlockByHeight", params, out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dblock := out.GetStructValue().GetFields()["dblock"].GetStructValue()
+	header := dblock.GetFields()["header"].GetStructValue()
+	if header.GetFields()["dbheight"].GetNumberValue() != 1 {
+		t.Errorf("Wrong directory block - %v", dblock)
+	}
+
+	params, _ = structpb.NewStruct(map[string]interface{}{"height": 1000000})
+	err = conn.Invoke(ctx, "/"+GrpcServiceName+"/DBlockByHeight", params, out)
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound, got %v", err)
+	}
+
+	params, _ = structpb.NewStruct(map[string]interface{}{"hash": "abc"})
+	err = conn.Invoke(ctx, "/"+GrpcServiceName+"/Entry", params, out)
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+
+	// Only the read methods are served
+	err = conn.Invoke(ctx, "/"+GrpcServiceName+"/FactoidSubmit", new(structpb.Struct), out)
+	if status.Code(err) != codes.Unimplemented {
+		t.Errorf("Expected Unimplemented, got %v", err)
+	}
+}
+
+func TestGrpcSubscribe(t *testing.T) {
+	s := testHelper.CreateEmptyTestState()
+	conn, stop := startTestGrpc(t, s)
+	defer stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	desc := &grpc.StreamDesc{StreamName: "Subscribe", ServerStreams: true}
+	stream, err := conn.NewStream(ctx, desc, "/"+GrpcServiceName+"/Subscribe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := primitives.RandomHash()
+	params, _ := structpb.NewStruct(map[string]interface{}{"topic": TopicAck, "hash": hash.String()})
+	if err := stream.SendMsg(params); err != nil {
+		t.Fatal(err)
+	}
+	if err := stream.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Keep emitting the event until the subscription is in place
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			case <-time.After(20 * time.Millisecond):
+				s.EmitStateEvent(&interfaces.StateEvent{Type: interfaces.EVENT_ENTRY, Hash: hash, ChainID: primitives.RandomHash(), Status: constants.AckStatusACK})
+			}
+		}
+	}()
+
+	out := new(structpb.Value)
+	if err := stream.RecvMsg(out); err != nil {
+		t.Fatal(err)
+	}
+	fields := out.GetStructValue().GetFields()
+	if fields["entryhash"].GetStringValue() != hash.String() || fields["status"].GetStringValue() != constants.AckStatusACKString {
+		t.Errorf("Wrong notification - %v", out)
+	}
+}
+
+func TestGrpcSubscribeInvalid(t *testing.T) {
+	s := testHelper.CreateEmptyTestState()
+	conn, stop := startTestGrpc(t, s)
+	defer stop()
+
+	desc := &grpc.StreamDesc{StreamName: "Subscribe", ServerStreams: true}
+	stream, err := conn.NewStream(context.Background(), desc, "/"+GrpcServiceName+"/Subscribe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	params, _ := structpb.NewStruct(map[string]interface{}{"topic": "nope"})
+	stream.SendMsg(params)
+	stream.CloseSend()
+	err = stream.RecvMsg(new(structpb.Value))
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument, got %v", err)
+	}
+}
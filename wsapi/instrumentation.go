@@ -5,6 +5,44 @@ import (
 )
 
 var (
+	// Every V2 method dispatched by HandleV2Request, labeled by method and JSON-RPC error code
+	// ("0" when the call succeeds).  Methods that do not exist are counted as "unknown".
+	V2APIRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factomd_wsapi_v2_requests_total",
+		Help: "Number of V2 API requests by method and error code",
+	}, []string{"method", "code"})
+
+	V2APIRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "factomd_wsapi_v2_request_duration_seconds",
+		Help:    "Time it takes to complete a V2 API request by method",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"method"})
+
+	V2APIRequestsInFlight = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "factomd_wsapi_v2_requests_in_flight",
+		Help: "Number of V2 API requests being handled",
+	})
+
+	V2APIRequestSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "factomd_wsapi_v2_request_size_bytes",
+		Help:    "Size of the V2 API request bodies",
+		Buckets: prometheus.ExponentialBuckets(64, 4, 8),
+	})
+
+	// V2 requests turned away before they are dispatched, labeled by reason: "auth" for a
+	// missing or unknown API key, "method" for a method the key may not call and "rate" for a
+	// client over its rate limit.
+	V2APIRequestsRejected = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "factomd_wsapi_v2_requests_rejected_total",
+		Help: "Number of V2 API requests rejected by reason",
+	}, []string{"reason"})
+
+	V2APIBatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "factomd_wsapi_v2_batch_size",
+		Help:    "Number of requests in the V2 API batches",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 11),
+	})
+
 	GensisFblockCall = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: "factomd_wsapi_v2_gensis_fblock_count",
 		Help: "Number of times the gensis Fblock is asked for",
@@ -100,6 +138,11 @@ var (
 		Help: "Time it takes to compelete a ",
 	})
 
+	HandleV2APICallTransactionReceipt = prometheus.NewSummary(prometheus.SummaryOpts{
+		Name: "factomd_wsapi_v2_api_call_txreceipt_ns",
+		Help: "Time it takes to compelete a transaction-receipt",
+	})
+
 	HandleV2APICallRevealEntry = prometheus.NewSummary(prometheus.SummaryOpts{
 		Name: "factomd_wsapi_v2_api_call_reventry_ns",
 		Help: "Time it takes to compelete a revealentry",
@@ -179,6 +222,27 @@ var (
 		Name: "factomd_wsapi_v2_api_call_tpsrate_ns",
 		Help: "Time it takes to compelete a tpsrate",
 	})
+
+	HandleV2APICallAddressTxs = prometheus.NewSummary(prometheus.SummaryOpts{
+		Name: "factomd_wsapi_v2_api_call_addresstxs_ns",
+		Help: "Time it takes to compelete an address-transactions",
+	})
+
+	HandleV2APICallChainEntries = prometheus.NewSummary(prometheus.SummaryOpts{
+		Name: "factomd_wsapi_v2_api_call_chainentries_ns",
+		Help: "Time it takes to compelete a chain-entries",
+	})
+
+	// Websocket subscriptions
+	WebsocketClients = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "factomd_wsapi_v2_websocket_clients",
+		Help: "Number of websocket clients connected",
+	})
+
+	WebsocketNotificationsDropped = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "factomd_wsapi_v2_websocket_notifications_dropped_total",
+		Help: "Notifications dropped because a websocket client was too slow",
+	})
 )
 
 var registered = false
@@ -191,6 +255,12 @@ func RegisterPrometheus() {
 	}
 	registered = true
 
+	prometheus.MustRegister(V2APIRequests)
+	prometheus.MustRegister(V2APIRequestDuration)
+	prometheus.MustRegister(V2APIRequestsInFlight)
+	prometheus.MustRegister(V2APIRequestSize)
+	prometheus.MustRegister(V2APIRequestsRejected)
+	prometheus.MustRegister(V2APIBatchSize)
 	prometheus.MustRegister(GensisFblockCall)
 	prometheus.MustRegister(HandleV2APICallGeneral)
 	prometheus.MustRegister(HandleV2APICallChainHead)
@@ -208,6 +278,7 @@ func RegisterPrometheus() {
 	prometheus.MustRegister(HandleV2APICallProp)
 	prometheus.MustRegister(HandleV2APICallRawData)
 	prometheus.MustRegister(HandleV2APICallReceipt)
+	prometheus.MustRegister(HandleV2APICallTransactionReceipt)
 	prometheus.MustRegister(HandleV2APICallRevealEntry)
 	prometheus.MustRegister(HandleV2APICallFctAck)
 	prometheus.MustRegister(HandleV2APICallEntryAck)
@@ -225,4 +296,8 @@ func RegisterPrometheus() {
 	prometheus.MustRegister(HandleV2APICallTpsRate)
 	prometheus.MustRegister(HandleV2APICallAblock)
 	prometheus.MustRegister(HandleV2APICallFblock)
+	prometheus.MustRegister(HandleV2APICallAddressTxs)
+	prometheus.MustRegister(HandleV2APICallChainEntries)
+	prometheus.MustRegister(WebsocketClients)
+	prometheus.MustRegister(WebsocketNotificationsDropped)
 }
@@ -0,0 +1,468 @@
+// Copyright 2017 Factom Foundation
+// Use of this source code is governed by the MIT
+// license that can be found in the LICENSE file.
+
+package wsapi
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/web"
+	"golang.org/x/net/websocket"
+)
+
+// Topics a websocket client can subscribe to
+const (
+	TopicNewDBlock      = "new-dblock"      // Every directory block saved to the database
+	TopicChainEntries   = "chain-entries"   // Entries acknowledged or confirmed in a chain
+	TopicFactoidAddress = "factoid-address" // Factoid transactions with the address as an input or output
+	TopicAck            = "ack"             // Ack status changes of an entry, commit or transaction
+)
+
+const (
+	// MaxSubscriptionsPerClient bounds the number of topics a single websocket can follow
+	MaxSubscriptionsPerClient = 100
+	// Notifications queued for a client that does not read them fast enough are dropped
+	wsClientSendQueueSize = 256
+	// Events queued from the state before the hub dispatches them
+	wsHubEventQueueSize = 1000
+)
+
+var subscriptionCounter uint64
+
+// Subscription is a single topic followed by a websocket client
+type Subscription struct {
+	ID     string
+	Topic  string
+	Filter interfaces.IHash // ChainID, address or hash depending on the topic
+}
+
+// NewSubscription validates a subscribe request and returns the matching subscription
+func NewSubscription(params interface{}) (*Subscription, *primitives.JSONError) {
+	req := new(SubscribeRequest)
+	err := MapToObject(params, req)
+	if err != nil {
+		return nil, NewInvalidParamsError()
+	}
+
+	sub := new(Subscription)
+	sub.Topic = req.Topic
+	switch req.Topic {
+	case TopicNewDBlock:
+	case TopicChainEntries:
+		sub.Filter, err = primitives.HexToHash(req.ChainID)
+		if err != nil {
+			return nil, NewInvalidHashError()
+		}
+	case TopicFactoidAddress:
+		var adr []byte
+		if primitives.ValidateFUserStr(req.Address) || primitives.ValidateECUserStr(req.Address) {
+			adr = primitives.ConvertUserStrToAddress(req.Address)
+		} else {
+			adr, err = hex.DecodeString(req.Address)
+			if err != nil || len(adr) != constants.HASH_LENGTH {
+				return nil, NewInvalidAddressError()
+			}
+		}
+		sub.Filter = primitives.NewHash(adr)
+	case TopicAck:
+		sub.Filter, err = primitives.HexToHash(req.Hash)
+		if err != nil {
+			return nil, NewInvalidHashError()
+		}
+	default:
+		return nil, NewCustomInvalidParamsError("Unknown topic")
+	}
+
+	sub.ID = fmt.Sprintf("%x", atomic.AddUint64(&subscriptionCounter, 1))
+	return sub, nil
+}
+
+// Matches returns true if the event belongs to the topic of the subscription
+func (sub *Subscription) Matches(e *interfaces.StateEvent) bool {
+	switch sub.Topic {
+	case TopicNewDBlock:
+		return e.Type == interfaces.EVENT_DBLOCK_SAVED
+	case TopicChainEntries:
+		return e.Type == interfaces.EVENT_ENTRY && e.ChainID != nil && e.ChainID.IsSameAs(sub.Filter)
+	case TopicFactoidAddress:
+		if e.Type != interfaces.EVENT_FACTOID_TX {
+			return false
+		}
+		for _, a := range e.Addresses() {
+			if a != nil && a.IsSameAs(sub.Filter) {
+				return true
+			}
+		}
+		return false
+	case TopicAck:
+		return e.Type != interfaces.EVENT_DBLOCK_SAVED && e.Hash != nil && e.Hash.IsSameAs(sub.Filter)
+	}
+	return false
+}
+
+// EventToNotificationResult converts a state event into the V2 structure sent to the client
+func EventToNotificationResult(state interfaces.IState, e *interfaces.StateEvent) (interface{}, *primitives.JSONError) {
+	switch e.Type {
+	case interfaces.EVENT_DBLOCK_SAVED:
+		resp, jsonError := HandleV2DirectoryBlock(state, KeyMRRequest{KeyMR: e.Hash.String()})
+		if jsonError != nil {
+			return nil, jsonError
+		}
+		n := new(NewDBlockNotification)
+		n.KeyMR = e.Hash.String()
+		n.Height = int64(e.DBHeight)
+		n.DirectoryBlock = resp.(*DirectoryBlockResponse)
+		return n, nil
+	case interfaces.EVENT_ENTRY:
+		n := new(interfaces.IPendingEntry)
+		n.EntryHash = e.Hash
+		n.ChainID = e.ChainID
+		n.Status = constants.AckStatusString(e.Status)
+		return n, nil
+	case interfaces.EVENT_COMMIT, interfaces.EVENT_FACTOID_TX:
+		n := new(FactoidTxStatus)
+		n.TxID = e.Hash.String()
+		n.Status = constants.AckStatusString(e.Status)
+		return n, nil
+	}
+	return nil, NewInternalError()
+}
+
+// SubscriptionHub fans the events of one state out to all of its websocket clients
+type SubscriptionHub struct {
+	State interfaces.IState
+
+	mutex   sync.Mutex
+	clients map[*wsClient]struct{}
+	events  chan *interfaces.StateEvent
+	quit    chan struct{}
+}
+
+var hubs map[interfaces.IState]*SubscriptionHub
+var hubsMutex sync.Mutex
+
+func getSubscriptionHub(state interfaces.IState) *SubscriptionHub {
+	hubsMutex.Lock()
+	defer hubsMutex.Unlock()
+
+	if hubs == nil {
+		hubs = make(map[interfaces.IState]*SubscriptionHub)
+	}
+	h, ok := hubs[state]
+	if !ok {
+		h = new(SubscriptionHub)
+		h.State = state
+		h.clients = make(map[*wsClient]struct{})
+		hubs[state] = h
+	}
+	return h
+}
+
+// register adds a client, subscribing to the state when the first one arrives
+func (h *SubscriptionHub) register(c *wsClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if len(h.clients) == 0 {
+		h.events = make(chan *interfaces.StateEvent, wsHubEventQueueSize)
+		h.quit = make(chan struct{})
+		h.State.SubscribeStateEvents(h.events)
+		go h.run(h.events, h.quit)
+	}
+	h.clients[c] = struct{}{}
+	WebsocketClients.Inc()
+}
+
+// unregister removes a client, unsubscribing from the state when the last one leaves.  The
+// client's send channel is closed under the hub's mutex, so dispatch never sends on it after.
+func (h *SubscriptionHub) unregister(c *wsClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.clients[c]; !ok {
+		return
+	}
+	delete(h.clients, c)
+	close(c.send)
+	WebsocketClients.Dec()
+	if len(h.clients) == 0 {
+		h.State.UnsubscribeStateEvents(h.events)
+		close(h.quit)
+	}
+}
+
+func (h *SubscriptionHub) run(events chan *interfaces.StateEvent, quit chan struct{}) {
+	for {
+		select {
+		case e := <-events:
+			h.dispatch(e)
+		case <-quit:
+			return
+		}
+	}
+}
+
+// dispatch sends the event to the clients subscribed to it.  The event is converted, which may
+// read the database, without holding the mutex, which is only taken to hand out the notifications.
+func (h *SubscriptionHub) dispatch(e *interfaces.StateEvent) {
+	h.mutex.Lock()
+	clients := make([]*wsClient, 0, len(h.clients))
+	for c := range h.clients {
+		clients = append(clients, c)
+	}
+	h.mutex.Unlock()
+
+	var notifications []*SubscriptionNotification
+	var to []*wsClient
+	var result interface{}
+	for _, c := range clients {
+		for _, sub := range c.matching(e) {
+			if result == nil {
+				var jsonError *primitives.JSONError
+				result, jsonError = EventToNotificationResult(h.State, e)
+				if jsonError != nil {
+					// Skip this subscriber only, the next one tries again
+					h.State.LogPrintf("apilog", "subscription %s event error %v", sub.ID, jsonError)
+					result = nil
+					continue
+				}
+			}
+			n := new(SubscriptionNotification)
+			n.JSONRPC = "2.0"
+			n.Method = "subscription"
+			n.Params.Subscription = sub.ID
+			n.Params.Result = result
+			notifications = append(notifications, n)
+			to = append(to, c)
+		}
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for i, n := range notifications {
+		if _, ok := h.clients[to[i]]; ok { // still connected, so its channel is open
+			to[i].queue(n)
+		}
+	}
+}
+
+// wsClient is a single websocket connection, or gRPC stream, and the topics it follows.  A
+// gRPC stream has no conn and reads its notifications from send itself.
+type wsClient struct {
+	conn *websocket.Conn
+	send chan interface{}
+
+	// The API key and address the client connected with, checked again on every request so
+	// a reload of the config applies to open connections
+	apiKey   string
+	remoteIP string
+
+	mutex         sync.Mutex
+	subscriptions map[string]*Subscription
+}
+
+func newWSClient(conn *websocket.Conn) *wsClient {
+	c := new(wsClient)
+	c.conn = conn
+	c.send = make(chan interface{}, wsClientSendQueueSize)
+	c.subscriptions = make(map[string]*Subscription)
+	return c
+}
+
+func (c *wsClient) matching(e *interfaces.StateEvent) []*Subscription {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	var list []*Subscription
+	for _, sub := range c.subscriptions {
+		if sub.Matches(e) {
+			list = append(list, sub)
+		}
+	}
+	return list
+}
+
+// queue sends a message to the client without ever blocking the hub
+func (c *wsClient) queue(msg interface{}) {
+	select {
+	case c.send <- msg:
+	default:
+		WebsocketNotificationsDropped.Inc()
+	}
+}
+
+func (c *wsClient) writeLoop() {
+	for msg := range c.send {
+		if err := websocket.JSON.Send(c.conn, msg); err != nil {
+			c.conn.Close()
+			return
+		}
+	}
+}
+
+func (c *wsClient) handleRequest(state interfaces.IState, j *primitives.JSON2Request) (*primitives.JSON2Response, *primitives.JSONError) {
+	access := GetAPIAccess(state)
+	key, err := access.Authenticate(c.apiKey)
+	if err != nil {
+		V2APIRequestsRejected.WithLabelValues("auth").Inc()
+		return nil, NewUnauthorizedError()
+	}
+	if jsonError := access.Authorize(key, c.remoteIP, j.Method); jsonError != nil {
+		return nil, jsonError
+	}
+
+	switch j.Method {
+	case "subscribe":
+		sub, jsonError := NewSubscription(j.Params)
+		if jsonError != nil {
+			return nil, jsonError
+		}
+
+		c.mutex.Lock()
+		if len(c.subscriptions) >= MaxSubscriptionsPerClient {
+			c.mutex.Unlock()
+			return nil, NewCustomInvalidParamsError("Too many subscriptions")
+		}
+		c.subscriptions[sub.ID] = sub
+		c.mutex.Unlock()
+
+		resp := primitives.NewJSON2Response()
+		resp.ID = j.ID
+		resp.Result = &SubscribeResponse{Subscription: sub.ID}
+		return resp, nil
+	case "unsubscribe":
+		req := new(UnsubscribeRequest)
+		if err := MapToObject(j.Params, req); err != nil {
+			return nil, NewInvalidParamsError()
+		}
+
+		c.mutex.Lock()
+		_, ok := c.subscriptions[req.Subscription]
+		delete(c.subscriptions, req.Subscription)
+		c.mutex.Unlock()
+		if !ok {
+			return nil, NewSubscriptionNotFoundError()
+		}
+
+		resp := primitives.NewJSON2Response()
+		resp.ID = j.ID
+		resp.Result = &UnsubscribeResponse{Message: "Unsubscribed"}
+		return resp, nil
+	}
+
+	// Any other method is answered exactly as it would be over HTTP
+	return HandleV2Request(state, j)
+}
+
+// HandleV2Websocket upgrades the connection and serves JSON-RPC requests and subscriptions on it
+func HandleV2Websocket(ctx *web.Context) {
+	ServersMutex.Lock()
+	state := ctx.Server.Env["state"].(interfaces.IState)
+	ServersMutex.Unlock()
+
+	if err := checkAuthHeader(state, ctx.Request); err != nil {
+		remoteIP := ""
+		remoteIP += strings.Split(ctx.Request.RemoteAddr, ":")[0]
+		fmt.Printf("Unauthorized V2 websocket client connection attempt from %s\n", remoteIP)
+		ctx.ResponseWriter.Header().Add("WWW-Authenticate", `Basic realm="factomd RPC"`)
+		http.Error(ctx.ResponseWriter, "401 Unauthorized.", http.StatusUnauthorized)
+		return
+	}
+
+	apiKey := ctx.Request.Header.Get(APIKeyHeader)
+	if _, err := GetAPIAccess(state).Authenticate(apiKey); err != nil {
+		V2APIRequestsRejected.WithLabelValues("auth").Inc()
+		fmt.Printf("Unauthorized V2 websocket client connection attempt from %s: %v\n", remoteHost(ctx.Request), err)
+		http.Error(ctx.ResponseWriter, "401 Unauthorized.", http.StatusUnauthorized)
+		return
+	}
+	remoteIP := remoteHost(ctx.Request)
+
+	server := websocket.Server{
+		Handshake: func(config *websocket.Config, r *http.Request) error {
+			return checkWebsocketOrigin(state.GetCorsDomains(), r)
+		},
+		Handler: func(conn *websocket.Conn) {
+			serveWebsocket(state, conn, apiKey, remoteIP)
+		},
+	}
+	server.ServeHTTP(ctx.ResponseWriter, ctx.Request)
+}
+
+// checkWebsocketOrigin only lets browsers connect from the configured CORS domains.  Clients that
+// do not send an Origin are not browsers and are let through.
+func checkWebsocketOrigin(corsDomains []string, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(corsDomains) == 0 {
+		return nil
+	}
+	for _, d := range corsDomains {
+		d = strings.TrimSpace(d)
+		if d == "*" || d == origin {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %s not allowed", origin)
+}
+
+func serveWebsocket(state interfaces.IState, conn *websocket.Conn, apiKey string, remoteIP string) {
+	c := newWSClient(conn)
+	c.apiKey = apiKey
+	c.remoteIP = remoteIP
+
+	hub := getSubscriptionHub(state)
+	hub.register(c)
+	go c.writeLoop()
+	defer hub.unregister(c) // closes c.send, which ends the writeLoop
+
+	for {
+		var body string
+		if err := websocket.Message.Receive(conn, &body); err != nil {
+			return
+		}
+
+		if IsV2Batch([]byte(body)) {
+			access := GetAPIAccess(state)
+			responses, jsonError := HandleV2Batch([]byte(body), access.MaxBatchSize, access.BatchWorkers, func(j *primitives.JSON2Request) (*primitives.JSON2Response, *primitives.JSONError) {
+				return c.handleRequest(state, j)
+			})
+			if jsonError != nil {
+				resp := primitives.NewJSON2Response()
+				resp.Error = jsonError
+				c.queue(resp)
+				continue
+			}
+			if len(responses) > 0 {
+				c.queue(responses)
+			}
+			continue
+		}
+
+		j, err := primitives.ParseJSON2Request(body)
+		if err != nil {
+			resp := primitives.NewJSON2Response()
+			resp.Error = NewInvalidRequestError()
+			c.queue(resp)
+			continue
+		}
+
+		jsonResp, jsonError := c.handleRequest(state, j)
+		if jsonError != nil {
+			resp := primitives.NewJSON2Response()
+			resp.ID = j.ID
+			resp.Error = jsonError
+			c.queue(resp)
+			continue
+		}
+		c.queue(jsonResp)
+	}
+}
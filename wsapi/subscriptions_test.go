@@ -0,0 +1,117 @@
+package wsapi_test
+
+import (
+	"testing"
+
+	"github.com/FactomProject/factomd/common/constants"
+	"github.com/FactomProject/factomd/common/interfaces"
+	"github.com/FactomProject/factomd/common/primitives"
+	. "github.com/FactomProject/factomd/wsapi"
+)
+
+func TestNewSubscription(t *testing.T) {
+	chainID := primitives.RandomHash().String()
+
+	valid := []SubscribeRequest{
+		{Topic: TopicNewDBlock},
+		{Topic: TopicChainEntries, ChainID: chainID},
+		{Topic: TopicFactoidAddress, Address: "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q"},
+		{Topic: TopicFactoidAddress, Address: chainID},
+		{Topic: TopicAck, Hash: chainID},
+	}
+	ids := map[string]bool{}
+	for i, req := range valid {
+		sub, err := NewSubscription(req)
+		if err != nil {
+			t.Errorf("%v: unexpected error %v", i, err)
+			continue
+		}
+		if sub.Topic != req.Topic {
+			t.Errorf("%v: wrong topic %v", i, sub.Topic)
+		}
+		if ids[sub.ID] {
+			t.Errorf("%v: duplicate subscription id %v", i, sub.ID)
+		}
+		ids[sub.ID] = true
+	}
+
+	invalid := []SubscribeRequest{
+		{Topic: "nope"},
+		{Topic: TopicChainEntries, ChainID: "abc"},
+		{Topic: TopicFactoidAddress, Address: "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1R"},
+		{Topic: TopicAck},
+	}
+	for i, req := range invalid {
+		_, err := NewSubscription(req)
+		if err == nil {
+			t.Errorf("%v: expected an error for %v", i, req)
+		}
+	}
+}
+
+func TestSubscriptionMatches(t *testing.T) {
+	chainID := primitives.RandomHash()
+	hash := primitives.RandomHash()
+
+	entryEvent := &interfaces.StateEvent{Type: interfaces.EVENT_ENTRY, Hash: hash, ChainID: chainID, Status: constants.AckStatusACK}
+	otherEntryEvent := &interfaces.StateEvent{Type: interfaces.EVENT_ENTRY, Hash: primitives.RandomHash(), ChainID: primitives.RandomHash()}
+	dblockEvent := &interfaces.StateEvent{Type: interfaces.EVENT_DBLOCK_SAVED, Hash: hash}
+
+	chainSub, err := NewSubscription(SubscribeRequest{Topic: TopicChainEntries, ChainID: chainID.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !chainSub.Matches(entryEvent) {
+		t.Error("chain subscription should match its entry")
+	}
+	if chainSub.Matches(otherEntryEvent) || chainSub.Matches(dblockEvent) {
+		t.Error("chain subscription matched an unrelated event")
+	}
+
+	ackSub, err := NewSubscription(SubscribeRequest{Topic: TopicAck, Hash: hash.String()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ackSub.Matches(entryEvent) {
+		t.Error("ack subscription should match its entry")
+	}
+	if ackSub.Matches(dblockEvent) {
+		t.Error("ack subscription should not match a directory block with the same hash")
+	}
+
+	dblockSub, err := NewSubscription(SubscribeRequest{Topic: TopicNewDBlock})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !dblockSub.Matches(dblockEvent) || dblockSub.Matches(entryEvent) {
+		t.Error("dblock subscription matched the wrong events")
+	}
+}
+
+func TestEventToNotificationResult(t *testing.T) {
+	e := &interfaces.StateEvent{Type: interfaces.EVENT_ENTRY, Hash: primitives.RandomHash(), ChainID: primitives.RandomHash(), Status: constants.AckStatusDBlockConfirmed}
+	r, err := EventToNotificationResult(nil, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pe, ok := r.(*interfaces.IPendingEntry)
+	if !ok {
+		t.Fatalf("wrong result type %T", r)
+	}
+	if !pe.EntryHash.IsSameAs(e.Hash) || pe.Status != constants.AckStatusDBlockConfirmedString {
+		t.Errorf("wrong result %v", pe)
+	}
+
+	e = &interfaces.StateEvent{Type: interfaces.EVENT_FACTOID_TX, Hash: primitives.RandomHash(), Status: constants.AckStatusACK}
+	r, err = EventToNotificationResult(nil, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx, ok := r.(*FactoidTxStatus)
+	if !ok {
+		t.Fatalf("wrong result type %T", r)
+	}
+	if tx.TxID != e.Hash.String() || tx.Status != constants.AckStatusACKString {
+		t.Errorf("wrong result %v", tx)
+	}
+}
@@ -50,6 +50,7 @@ func Start(state interfaces.IState) {
 	h := sha256.New()
 	h.Write(httpBasicAuth(rpcUser, rpcPass))
 	state.SetRpcAuthHash(h.Sum(nil)) //set this in the beginning to prevent timing attacks
+	ReloadAPIAccess(state)
 
 	if Servers[state.GetPort()] == nil {
 		server = web.NewServer()
@@ -86,6 +87,7 @@ func Start(state interfaces.IState) {
 
 		server.Post("/v2", HandleV2)
 		server.Get("/v2", HandleV2)
+		server.Get("/v2/ws", HandleV2Websocket)
 
 		// start the debugging api if we are not on the main network
 		if state.GetNetworkName() != "MAIN" {
@@ -117,6 +119,8 @@ func Start(state interfaces.IState) {
 			go server.Run(fmt.Sprintf(":%d", state.GetPort()))
 		}
 	}
+
+	StartGrpc(state)
 }
 
 func SetState(state interfaces.IState) {
@@ -870,11 +874,15 @@ func checkAuthHeader(state interfaces.IState, r *http.Request) error {
 	if len(authhdr) == 0 {
 		return errors.New("no auth")
 	}
+	return checkAuthValue(state, authhdr[0])
+}
 
+// checkAuthValue checks the value of an Authorization header against the RPC login
+func checkAuthValue(state interfaces.IState, auth string) error {
 	correctAuth := state.GetRpcAuthHash()
 
 	h := sha256.New()
-	h.Write([]byte(authhdr[0]))
+	h.Write([]byte(auth))
 	presentedPassHash := h.Sum(nil)
 
 	cmp := subtle.ConstantTimeCompare(presentedPassHash, correctAuth) //compare hashes because ConstantTimeCompare takes a constant time based on the slice size.  hashing gives a constant slice size.
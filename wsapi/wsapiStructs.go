@@ -87,6 +87,10 @@ type ReceiptResponse struct {
 	Receipt *receipts.Receipt `json:"receipt"`
 }
 
+type TransactionReceiptResponse struct {
+	Receipt *receipts.TransactionReceipt `json:"receipt"`
+}
+
 type EntryBlockResponse struct {
 	Header struct {
 		BlockSequenceNumber int64  `json:"blocksequencenumber"`
@@ -134,6 +138,7 @@ type EntryCreditRateResponse struct {
 type PropertiesResponse struct {
 	FactomdVersion string `json:"factomdversion"`
 	ApiVersion     string `json:"factomdapiversion"`
+	PruneDepth     uint32 `json:"prunedepth,omitempty"`
 }
 
 type SendRawMessageResponse struct {
@@ -243,6 +248,21 @@ type HeightRequest struct {
 	Height int64 `json:"height"`
 }
 
+type AddressTransactionsRequest struct {
+	Address string `json:"address"`
+	Offset  int64  `json:"offset,omitempty"`
+	Limit   int64  `json:"limit,omitempty"`
+}
+
+type ChainEntriesRequest struct {
+	ChainID    string `json:"chainid"`
+	Reverse    bool   `json:"reverse,omitempty"`
+	Cursor     string `json:"cursor,omitempty"`
+	Limit      int64  `json:"limit,omitempty"`
+	FromHeight *int64 `json:"fromheight,omitempty"`
+	ToHeight   *int64 `json:"toheight,omitempty"`
+}
+
 type ChainIDRequest struct {
 	ChainID string `json:"chainid"`
 }
@@ -267,6 +287,20 @@ type MessageRequest struct {
 	Message string `json:"message"`
 }
 
+// PendingEntriesRequest filters the pending entries.  ChainID is the single chain older clients
+// ask for, and ECPubKey is either a hex public key or an EC address.
+type PendingEntriesRequest struct {
+	ChainID   string   `json:"chainid,omitempty"`
+	ChainIDs  []string `json:"chainids,omitempty"`
+	ECPubKey  string   `json:"ecpubkey,omitempty"`
+	MinMinute int      `json:"minminute,omitempty"`
+}
+
+type PendingTransactionsRequest struct {
+	Address   string `json:"address,omitempty"`
+	MinMinute int    `json:"minminute,omitempty"`
+}
+
 type PendingEntry struct {
 	EntryHash interfaces.IHash `json:"entryhash"`
 	ChainID   interfaces.IHash `json:"chainid"`
@@ -355,3 +389,64 @@ type AuditStatus struct {
 	ID     string `json:"id"`
 	Online bool   `json:"online"`
 }
+
+type SubscribeRequest struct {
+	Topic   string `json:"topic"`
+	ChainID string `json:"chainid,omitempty"`
+	Address string `json:"address,omitempty"`
+	Hash    string `json:"hash,omitempty"`
+}
+
+type SubscribeResponse struct {
+	Subscription string `json:"subscription"`
+}
+
+type UnsubscribeRequest struct {
+	Subscription string `json:"subscription"`
+}
+
+type UnsubscribeResponse struct {
+	Message string `json:"message"`
+}
+
+type SubscriptionNotification struct {
+	JSONRPC string `json:"jsonrpc"`
+	Method  string `json:"method"`
+	Params  struct {
+		Subscription string      `json:"subscription"`
+		Result       interface{} `json:"result"`
+	} `json:"params"`
+}
+
+type NewDBlockNotification struct {
+	KeyMR          string                  `json:"keymr"`
+	Height         int64                   `json:"height"`
+	DirectoryBlock *DirectoryBlockResponse `json:"dblock"`
+}
+
+type AddressTransaction struct {
+	TxID     string `json:"txid"`
+	DBHeight int64  `json:"dbheight"`
+}
+
+type AddressTransactionsResponse struct {
+	Address      string               `json:"address"`
+	Total        int64                `json:"total"`
+	Offset       int64                `json:"offset"`
+	Transactions []AddressTransaction `json:"transactions"`
+}
+
+type ChainEntry struct {
+	EntryHash   string   `json:"entryhash"`
+	EBlockKeyMR string   `json:"eblockkeymr"`
+	EBSequence  int64    `json:"ebsequence"`
+	DBHeight    int64    `json:"dbheight"`
+	ExtIDs      []string `json:"extids,omitempty"`
+	Content     string   `json:"content,omitempty"`
+}
+
+type ChainEntriesResponse struct {
+	ChainID    string       `json:"chainid"`
+	Entries    []ChainEntry `json:"entries"`
+	NextCursor string       `json:"nextcursor,omitempty"`
+}
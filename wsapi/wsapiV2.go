@@ -9,9 +9,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"os"
 	"reflect"
+	"strconv"
 	"strings"
 	"time"
 
@@ -46,18 +48,59 @@ func HandleV2(ctx *web.Context) {
 		return
 	}
 
+	access := GetAPIAccess(state)
+	key, err := access.Authenticate(ctx.Request.Header.Get(APIKeyHeader))
+	if err != nil {
+		V2APIRequestsRejected.WithLabelValues("auth").Inc()
+		fmt.Printf("Unauthorized V2 API client connection attempt from %s: %v\n", remoteHost(ctx.Request), err)
+		http.Error(ctx.ResponseWriter, "401 Unauthorized.", http.StatusUnauthorized)
+		return
+	}
+
 	body, err := ioutil.ReadAll(ctx.Request.Body)
 	if err != nil {
 		HandleV2Error(ctx, nil, NewInvalidRequestError())
 		return
 	}
 
+	V2APIRequestSize.Observe(float64(len(body)))
+
+	if IsV2Batch(body) {
+		remoteIP := remoteHost(ctx.Request)
+		responses, jsonError := HandleV2Batch(body, access.MaxBatchSize, access.BatchWorkers, func(j *primitives.JSON2Request) (*primitives.JSON2Response, *primitives.JSONError) {
+			if jsonError := access.Authorize(key, remoteIP, j.Method); jsonError != nil {
+				return nil, jsonError
+			}
+			return HandleV2Request(state, j)
+		})
+		if jsonError != nil {
+			HandleV2Error(ctx, nil, jsonError)
+			return
+		}
+		if len(responses) == 0 {
+			// Only notifications, which are not answered
+			return
+		}
+		data, err := json.Marshal(responses)
+		if err != nil {
+			HandleV2Error(ctx, nil, NewInternalError())
+			return
+		}
+		ctx.Write(data)
+		return
+	}
+
 	j, err := primitives.ParseJSON2Request(string(body))
 	if err != nil {
 		HandleV2Error(ctx, nil, NewInvalidRequestError())
 		return
 	}
 
+	if jsonError := access.Authorize(key, remoteHost(ctx.Request), j.Method); jsonError != nil {
+		HandleV2Error(ctx, j, jsonError)
+		return
+	}
+
 	jsonResp, jsonError := HandleV2Request(state, j)
 
 	if jsonError != nil {
@@ -68,11 +111,34 @@ func HandleV2(ctx *web.Context) {
 	ctx.Write([]byte(jsonResp.String()))
 }
 
+// observeV2APIRequest records the outcome of a V2 request once it has been handled.  The
+// method and error are passed by reference as they are only known after the dispatch.
+func observeV2APIRequest(method *string, start time.Time, jsonError **primitives.JSONError) {
+	code := "0"
+	if *jsonError != nil {
+		code = strconv.Itoa((*jsonError).Code)
+	}
+	V2APIRequests.WithLabelValues(*method, code).Inc()
+	V2APIRequestDuration.WithLabelValues(*method).Observe(time.Since(start).Seconds())
+}
+
 func HandleV2Request(state interfaces.IState, j *primitives.JSON2Request) (*primitives.JSON2Response, *primitives.JSONError) {
 	var resp interface{}
 	var jsonError *primitives.JSONError
 	params := j.Params
 	state.LogPrintf("apilog", "request %v", j.String())
+
+	method := j.Method
+	V2APIRequestsInFlight.Inc()
+	defer V2APIRequestsInFlight.Dec()
+	defer observeV2APIRequest(&method, time.Now(), &jsonError)
+
+	// A read only node takes in nothing, so whatever is sent to it would never be recorded
+	if apiWriteMethods[j.Method] && state.IsReadOnlyDB() {
+		jsonError = NewReadOnlyNodeError()
+		return nil, jsonError
+	}
+
 	switch j.Method {
 	case "chain-head":
 		resp, jsonError = HandleV2ChainHead(state, params)
@@ -155,6 +221,15 @@ func HandleV2Request(state interfaces.IState, j *primitives.JSON2Request) (*prim
 	case "transaction":
 		resp, jsonError = HandleV2GetTranasction(state, params)
 		break
+	case "transaction-receipt":
+		resp, jsonError = HandleV2TransactionReceipt(state, params)
+		break
+	case "address-transactions":
+		resp, jsonError = HandleV2AddressTransactions(state, params)
+		break
+	case "chain-entries":
+		resp, jsonError = HandleV2ChainEntries(state, params)
+		break
 	case "dblock-by-height":
 		resp, jsonError = HandleV2DBlockByHeight(state, params)
 		break
@@ -182,6 +257,7 @@ func HandleV2Request(state interfaces.IState, j *primitives.JSON2Request) (*prim
 	//case "factoid-accounts":
 	// resp, jsonError = HandleV2Accounts(state, params)
 	default:
+		method = "unknown"
 		jsonError = NewMethodNotFoundError()
 		break
 	}
@@ -727,7 +803,7 @@ func HandleV2RawData(state interfaces.IState, params interface{}) (interface{},
 		} else if block, _ = dbase.FetchEntry(h); block != nil {
 			b, _ = block.MarshalBinary()
 		} else {
-			return nil, NewObjectNotFoundError()
+			return nil, prunedOr(state, h, NewObjectNotFoundError())
 		}
 	}
 
@@ -755,7 +831,7 @@ func HandleV2Receipt(state interfaces.IState, params interface{}) (interface{},
 
 	receipt, err := receipts.CreateFullReceipt(dbase, h)
 	if err != nil {
-		return nil, NewReceiptError()
+		return nil, prunedOr(state, h, NewReceiptError())
 	}
 	resp := new(ReceiptResponse)
 	resp.Receipt = receipt
@@ -763,6 +839,31 @@ func HandleV2Receipt(state interfaces.IState, params interface{}) (interface{},
 	return resp, nil
 }
 
+func HandleV2TransactionReceipt(state interfaces.IState, params interface{}) (interface{}, *primitives.JSONError) {
+	n := time.Now()
+	defer HandleV2APICallTransactionReceipt.Observe(float64(time.Since(n).Nanoseconds()))
+
+	hashkey := new(HashRequest)
+	err := MapToObject(params, hashkey)
+	if err != nil {
+		return nil, NewInvalidParamsError()
+	}
+
+	h, err := primitives.HexToHash(hashkey.Hash)
+	if err != nil {
+		return nil, NewInvalidHashError()
+	}
+
+	receipt, err := receipts.CreateTransactionReceipt(state.GetDB(), h)
+	if err != nil {
+		return nil, NewReceiptError()
+	}
+	resp := new(TransactionReceiptResponse)
+	resp.Receipt = receipt
+
+	return resp, nil
+}
+
 func HandleV2DirectoryBlock(state interfaces.IState, params interface{}) (interface{}, *primitives.JSONError) {
 	n := time.Now()
 	defer HandleV2APICallDBlock.Observe(float64(time.Since(n).Nanoseconds()))
@@ -830,7 +931,7 @@ func HandleV2EntryBlock(state interfaces.IState, params interface{}) (interface{
 			return nil, NewInvalidHashError()
 		}
 		if block == nil {
-			return nil, NewBlockNotFoundError()
+			return nil, prunedOr(state, h, NewBlockNotFoundError())
 		}
 	}
 
@@ -901,7 +1002,7 @@ func HandleV2Entry(state interfaces.IState, params interface{}) (interface{}, *p
 			return nil, NewInvalidHashError()
 		}
 		if entry == nil {
-			return nil, NewEntryNotFoundError()
+			return nil, prunedOr(state, h, NewEntryNotFoundError())
 		}
 	}
 
@@ -914,6 +1015,15 @@ func HandleV2Entry(state interfaces.IState, params interface{}) (interface{}, *p
 	return e, nil
 }
 
+// prunedOr returns the pruned data error if a pruning node deleted the entry or entry block with
+// the hash, and notFound otherwise
+func prunedOr(state interfaces.IState, h interfaces.IHash, notFound *primitives.JSONError) *primitives.JSONError {
+	if pruned, _ := state.GetDB().IsPruned(h); pruned {
+		return NewPrunedDataError()
+	}
+	return notFound
+}
+
 func HandleV2ChainHead(state interfaces.IState, params interface{}) (interface{}, *primitives.JSONError) {
 	n := time.Now()
 	defer HandleV2APICallChainHead.Observe(float64(time.Since(n).Nanoseconds()))
@@ -1091,6 +1201,70 @@ func HandleV2FactoidBalance(state interfaces.IState, params interface{}) (interf
 	return resp, nil
 }
 
+// Default and largest page sizes of address-transactions
+const (
+	AddressTransactionsDefaultLimit = 100
+	AddressTransactionsMaxLimit     = 1000
+)
+
+func HandleV2AddressTransactions(state interfaces.IState, params interface{}) (interface{}, *primitives.JSONError) {
+	n := time.Now()
+	defer HandleV2APICallAddressTxs.Observe(float64(time.Since(n).Nanoseconds()))
+
+	req := new(AddressTransactionsRequest)
+	err := MapToObject(params, req)
+	if err != nil {
+		return nil, NewInvalidParamsError()
+	}
+
+	var adr []byte
+
+	if primitives.ValidateFUserStr(req.Address) || primitives.ValidateECUserStr(req.Address) {
+		adr = primitives.ConvertUserStrToAddress(req.Address)
+	} else {
+		adr, err = hex.DecodeString(req.Address)
+		if err != nil {
+			return nil, NewInvalidAddressError()
+		}
+	}
+
+	if len(adr) != constants.HASH_LENGTH {
+		return nil, NewInvalidAddressError()
+	}
+
+	// The index counts transactions in 32 bits, so a larger offset would wrap around
+	if req.Offset < 0 || req.Offset > math.MaxUint32 || req.Limit < 0 {
+		return nil, NewInvalidParamsError()
+	}
+	limit := req.Limit
+	if limit == 0 {
+		limit = AddressTransactionsDefaultLimit
+	}
+	if limit > AddressTransactionsMaxLimit {
+		limit = AddressTransactionsMaxLimit
+	}
+
+	dbase := state.GetDB()
+	if !dbase.HasAddressIndex() {
+		return nil, NewAddressIndexDisabledError()
+	}
+
+	txs, total, err := dbase.FetchAddressTransactions(primitives.NewHash(adr), uint32(req.Offset), uint32(limit))
+	if err != nil {
+		return nil, NewInternalDatabaseError()
+	}
+
+	resp := new(AddressTransactionsResponse)
+	resp.Address = req.Address
+	resp.Total = int64(total)
+	resp.Offset = req.Offset
+	resp.Transactions = make([]AddressTransaction, 0, len(txs))
+	for _, tx := range txs {
+		resp.Transactions = append(resp.Transactions, AddressTransaction{TxID: tx.TxID.String(), DBHeight: int64(tx.DBHeight)})
+	}
+	return resp, nil
+}
+
 func HandleV2Heights(state interfaces.IState, params interface{}) (interface{}, *primitives.JSONError) {
 	n := time.Now()
 	defer HandleV2APICallHeights.Observe(float64(time.Since(n).Nanoseconds()))
@@ -1113,12 +1287,43 @@ func HandleV2GetPendingEntries(state interfaces.IState, params interface{}) (int
 	n := time.Now()
 	defer HandleV2APICallPendingEntries.Observe(float64(time.Since(n).Nanoseconds()))
 
-	chainid := new(ChainIDRequest)
-	err := MapToObject(params, chainid)
+	req := new(PendingEntriesRequest)
+	err := MapToObject(params, req)
 	if err != nil {
 		return nil, NewInvalidParamsError()
 	}
-	pending := state.GetPendingEntries(chainid.ChainID)
+
+	filter := new(interfaces.PendingFilter)
+	if req.MinMinute < 0 || req.MinMinute > 10 {
+		return nil, NewCustomInvalidParamsError("minminute must be between 0 and 10")
+	}
+	filter.MinMinute = req.MinMinute
+
+	chainIDs := req.ChainIDs
+	if req.ChainID != "" {
+		chainIDs = append(chainIDs, req.ChainID)
+	}
+	for _, c := range chainIDs {
+		h, err := primitives.HexToHash(c)
+		if err != nil {
+			return nil, NewInvalidHashError()
+		}
+		filter.ChainIDs = append(filter.ChainIDs, h)
+	}
+
+	if req.ECPubKey != "" {
+		if primitives.ValidateECUserStr(req.ECPubKey) {
+			filter.ECPubKey = primitives.ConvertUserStrToAddress(req.ECPubKey)
+		} else {
+			key, err := hex.DecodeString(req.ECPubKey)
+			if err != nil || len(key) != 32 {
+				return nil, NewInvalidAddressError()
+			}
+			filter.ECPubKey = key
+		}
+	}
+
+	pending := state.GetPendingEntries(filter)
 
 	return pending, nil
 }
@@ -1127,13 +1332,20 @@ func HandleV2GetPendingTransactions(state interfaces.IState, params interface{})
 	n := time.Now()
 	defer HandleV2APICallPendingTxs.Observe(float64(time.Since(n).Nanoseconds()))
 
-	fadr := new(AddressRequest)
-	err := MapToObject(params, fadr)
+	req := new(PendingTransactionsRequest)
+	err := MapToObject(params, req)
 	if err != nil {
 		return nil, NewInvalidParamsError()
 	}
 
-	pending := state.GetPendingTransactions(fadr.Address)
+	if req.MinMinute < 0 || req.MinMinute > 10 {
+		return nil, NewCustomInvalidParamsError("minminute must be between 0 and 10")
+	}
+	filter := new(interfaces.PendingFilter)
+	filter.Address = req.Address
+	filter.MinMinute = req.MinMinute
+
+	pending := state.GetPendingTransactions(filter)
 
 	return pending, nil
 }
@@ -1145,6 +1357,7 @@ func HandleV2Properties(state interfaces.IState, params interface{}) (interface{
 	p := new(PropertiesResponse)
 	p.FactomdVersion = state.GetFactomdVersion()
 	p.ApiVersion = API_VERSION
+	p.PruneDepth = state.GetPruneDepth()
 	return p, nil
 }
 
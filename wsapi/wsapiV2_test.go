@@ -4,18 +4,24 @@ import (
 	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"reflect"
+	"strconv"
 	"strings"
 	"testing"
 
 	"time"
 
+	"github.com/FactomProject/factomd/common/entryBlock"
 	"github.com/FactomProject/factomd/common/interfaces"
 	"github.com/FactomProject/factomd/common/primitives"
+	"github.com/FactomProject/factomd/database/databaseOverlay"
 	"github.com/FactomProject/factomd/receipts"
 	"github.com/FactomProject/factomd/testHelper"
 	. "github.com/FactomProject/factomd/wsapi"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
 )
 
 func TestRegisterPrometheus(t *testing.T) {
@@ -274,6 +280,36 @@ func TestHandleV2GetReceipt(t *testing.T) {
 	}
 }
 
+func TestHandleV2TransactionReceipt(t *testing.T) {
+	state := testHelper.CreateAndPopulateTestState()
+	blocks := testHelper.CreateFullTestBlockSet()
+
+	hashkey := new(HashRequest)
+	hashkey.Hash = blocks[1].FBlock.GetTransactions()[0].GetSigHash().String()
+
+	resp, jErr := HandleV2TransactionReceipt(state, hashkey)
+	if jErr != nil {
+		t.Fatalf("%v", jErr)
+	}
+
+	marshalled, err := json.Marshal(resp.(*TransactionReceiptResponse).Receipt)
+	if err != nil {
+		t.Error(err)
+	}
+
+	err = receipts.VerifyFullTransactionReceipt(state.GetDB(), string(marshalled))
+	if err != nil {
+		t.Logf("receipt - %s", marshalled)
+		t.Error(err)
+	}
+
+	hashkey.Hash = primitives.RandomHash().String()
+	_, jErr = HandleV2TransactionReceipt(state, hashkey)
+	if jErr == nil || jErr.Code != NewReceiptError().Code {
+		t.Errorf("Expected a receipt error, got %v", jErr)
+	}
+}
+
 func TestHandleV2GetTranasction(t *testing.T) {
 	state := testHelper.CreateAndPopulateTestStateAndStartValidator()
 	blocks := testHelper.CreateFullTestBlockSet()
@@ -481,3 +517,320 @@ func Test_ecBlockToResp(t *testing.T) {
 		})
 	}
 }
+
+func TestHandleV2AddressTransactions(t *testing.T) {
+	state := testHelper.CreateAndPopulateTestState()
+
+	req := new(AddressTransactionsRequest)
+	req.Address = primitives.ConvertFctAddressToUserStr(testHelper.NewFactoidAddress(0))
+
+	_, jsonError := HandleV2AddressTransactions(state, req)
+	if jsonError == nil || jsonError.Code != NewAddressIndexDisabledError().Code {
+		t.Errorf("Expected the address index to be disabled, got %v", jsonError)
+	}
+
+	state.DB.SetAddressIndex(true)
+	_, err := state.DB.BackfillAddressIndex(0)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	resp, jsonError := HandleV2AddressTransactions(state, req)
+	if jsonError != nil {
+		t.Fatalf("%v", jsonError)
+	}
+	all := resp.(*AddressTransactionsResponse)
+	if all.Total == 0 || int64(len(all.Transactions)) != all.Total {
+		t.Fatalf("Wrong number of transactions - %v vs %v", len(all.Transactions), all.Total)
+	}
+
+	req.Offset = 1
+	req.Limit = 1
+	resp, jsonError = HandleV2AddressTransactions(state, req)
+	if jsonError != nil {
+		t.Fatalf("%v", jsonError)
+	}
+	page := resp.(*AddressTransactionsResponse)
+	if len(page.Transactions) != 1 || page.Transactions[0].TxID != all.Transactions[1].TxID {
+		t.Errorf("Wrong page returned - %v", page.Transactions)
+	}
+
+	// Offsets that do not fit the index are refused rather than wrapped around
+	for _, offset := range []int64{-1, math.MaxUint32 + 1, math.MaxUint32 + 2} {
+		req.Offset = offset
+		_, jsonError = HandleV2AddressTransactions(state, req)
+		if jsonError == nil || jsonError.Code != NewInvalidParamsError().Code {
+			t.Errorf("Expected an invalid params error for offset %d, got %v", offset, jsonError)
+		}
+	}
+	req.Offset = 0
+
+	req.Address = "abcd"
+	_, jsonError = HandleV2AddressTransactions(state, req)
+	if jsonError == nil {
+		t.Errorf("Expected an invalid address error")
+	}
+}
+
+func TestHandleV2ChainEntries(t *testing.T) {
+	state := testHelper.CreateEmptyTestState()
+
+	// The test blocks all have a sequence of 0, so number them before the next one links to them
+	blocks := []*entryBlock.EBlock{}
+	var prev *entryBlock.EBlock
+	for i := 0; i < 10; i++ {
+		prev, _ = testHelper.CreateTestEntryBlock(prev)
+		prev.GetHeader().SetEBSequence(uint32(i))
+		blocks = append(blocks, prev)
+		err := state.DB.ProcessEBlockBatch(prev, false)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+	}
+	chainID := blocks[0].GetChainID()
+
+	entriesOf := func(blocks []*entryBlock.EBlock) []string {
+		list := []string{}
+		for _, b := range blocks {
+			for _, h := range b.GetEntryHashes() {
+				if h.IsMinuteMarker() == false {
+					list = append(list, h.String())
+				}
+			}
+		}
+		return list
+	}
+	expected := entriesOf(blocks)
+
+	fetchAll := func(req *ChainEntriesRequest) []string {
+		list := []string{}
+		for {
+			resp, jsonError := HandleV2ChainEntries(state, req)
+			if jsonError != nil {
+				t.Fatalf("%v", jsonError)
+			}
+			page := resp.(*ChainEntriesResponse)
+			if len(page.Entries) > int(req.Limit) {
+				t.Fatalf("Page is larger than the limit - %v", len(page.Entries))
+			}
+			for _, e := range page.Entries {
+				list = append(list, e.EntryHash)
+			}
+			if page.NextCursor == "" {
+				return list
+			}
+			req.Cursor = page.NextCursor
+		}
+	}
+
+	forward := fetchAll(&ChainEntriesRequest{ChainID: chainID.String(), Limit: 3})
+	if reflect.DeepEqual(forward, expected) == false {
+		t.Errorf("Wrong entries returned going forward - %v vs %v", len(forward), len(expected))
+	}
+
+	reverse := fetchAll(&ChainEntriesRequest{ChainID: chainID.String(), Limit: 3, Reverse: true})
+	if len(reverse) != len(expected) {
+		t.Fatalf("Wrong number of entries returned going in reverse - %v vs %v", len(reverse), len(expected))
+	}
+	for i := range reverse {
+		if reverse[i] != expected[len(expected)-1-i] {
+			t.Errorf("Wrong entry at %v going in reverse", i)
+		}
+	}
+
+	// Only the entries of the blocks within the range
+	from, to := int64(blocks[2].GetDatabaseHeight()), int64(blocks[5].GetDatabaseHeight())
+	ranged := fetchAll(&ChainEntriesRequest{ChainID: chainID.String(), Limit: 2, FromHeight: &from, ToHeight: &to})
+	if reflect.DeepEqual(ranged, entriesOf(blocks[2:6])) == false {
+		t.Errorf("Wrong entries returned for the height range - %v vs %v", ranged, entriesOf(blocks[2:6]))
+	}
+
+	_, jsonError := HandleV2ChainEntries(state, &ChainEntriesRequest{ChainID: chainID.String(), Cursor: "nope"})
+	if jsonError == nil {
+		t.Errorf("Expected an invalid cursor error")
+	}
+	_, jsonError = HandleV2ChainEntries(state, &ChainEntriesRequest{ChainID: primitives.RandomHash().String()})
+	if jsonError == nil || jsonError.Code != NewMissingChainHeadError().Code {
+		t.Errorf("Expected a missing chain head error, got %v", jsonError)
+	}
+
+	// An entry block missing from the database is not found, rather than a database error
+	err := state.DB.(*databaseOverlay.Overlay).Delete(databaseOverlay.ENTRYBLOCK, blocks[4].DatabasePrimaryIndex().Bytes())
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+	missing := int64(blocks[4].GetDatabaseHeight())
+	for _, req := range []*ChainEntriesRequest{
+		{ChainID: chainID.String()},
+		{ChainID: chainID.String(), FromHeight: &missing},
+	} {
+		_, jsonError = HandleV2ChainEntries(state, req)
+		if jsonError == nil || jsonError.Code != NewBlockNotFoundError().Code {
+			t.Errorf("Expected a block not found error, got %v", jsonError)
+		}
+	}
+}
+
+func TestHandleV2PrunedData(t *testing.T) {
+	state := testHelper.CreateAndPopulateTestState()
+
+	resp, jsonError := HandleV2Properties(state, nil)
+	if jsonError != nil {
+		t.Fatalf("%v", jsonError)
+	}
+	if resp.(*PropertiesResponse).PruneDepth != 0 {
+		t.Errorf("A node that is not PRUNED should not advertise a pruning depth")
+	}
+
+	state.NodeMode = "PRUNED"
+	state.PruneDepth = 1000
+	resp, jsonError = HandleV2Properties(state, nil)
+	if jsonError != nil {
+		t.Fatalf("%v", jsonError)
+	}
+	if resp.(*PropertiesResponse).PruneDepth != 1000 {
+		t.Errorf("Got pruning depth %v, expected 1000", resp.(*PropertiesResponse).PruneDepth)
+	}
+
+	dblock, err := state.DB.FetchDBlockByHeight(3)
+	if err != nil || dblock == nil {
+		t.Fatalf("Missing directory block - %v", err)
+	}
+	keyMR := dblock.GetEBlockDBEntries()[0].GetKeyMR()
+	eblock, err := state.DB.FetchEBlock(keyMR)
+	if err != nil || eblock == nil {
+		t.Fatalf("Missing entry block - %v", err)
+	}
+	_, err = state.DB.PruneEBlock(keyMR)
+	if err != nil {
+		t.Fatalf("%v", err)
+	}
+
+	code := NewPrunedDataError().Code
+	_, jsonError = HandleV2EntryBlock(state, &KeyMRRequest{KeyMR: keyMR.String()})
+	if jsonError == nil || jsonError.Code != code {
+		t.Errorf("Expected a pruned data error for the entry block, got %v", jsonError)
+	}
+	_, jsonError = HandleV2Entry(state, &HashRequest{Hash: eblock.GetEntryHashes()[0].String()})
+	if jsonError == nil || jsonError.Code != code {
+		t.Errorf("Expected a pruned data error for the entry, got %v", jsonError)
+	}
+	_, jsonError = HandleV2RawData(state, &HashRequest{Hash: eblock.GetEntryHashes()[0].String()})
+	if jsonError == nil || jsonError.Code != code {
+		t.Errorf("Expected a pruned data error for the raw entry, got %v", jsonError)
+	}
+
+	// Data the node never had is still not found
+	_, jsonError = HandleV2Entry(state, &HashRequest{Hash: primitives.RandomHash().String()})
+	if jsonError == nil || jsonError.Code != NewEntryNotFoundError().Code {
+		t.Errorf("Expected an entry not found error, got %v", jsonError)
+	}
+}
+
+func TestV2APIRequestMetrics(t *testing.T) {
+	state := testHelper.CreateAndPopulateTestState()
+
+	count := func(method, code string) float64 {
+		m := new(dto.Metric)
+		err := V2APIRequests.WithLabelValues(method, code).Write(m)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		return m.GetCounter().GetValue()
+	}
+	observations := func(method string) uint64 {
+		m := new(dto.Metric)
+		err := V2APIRequestDuration.WithLabelValues(method).(prometheus.Histogram).Write(m)
+		if err != nil {
+			t.Fatalf("%v", err)
+		}
+		return m.GetHistogram().GetSampleCount()
+	}
+
+	heights, heightsCalls := count("heights", "0"), observations("heights")
+	invalid := count("entry", strconv.Itoa(NewInvalidParamsError().Code))
+	unknown := count("unknown", strconv.Itoa(NewMethodNotFoundError().Code))
+
+	_, jsonError := HandleV2Request(state, primitives.NewJSON2Request("heights", 1, nil))
+	if jsonError != nil {
+		t.Fatalf("%v", jsonError)
+	}
+	HandleV2Request(state, primitives.NewJSON2Request("entry", 2, "not a hash request"))
+	HandleV2Request(state, primitives.NewJSON2Request("no-such-method", 3, nil))
+
+	if count("heights", "0") != heights+1 || observations("heights") != heightsCalls+1 {
+		t.Errorf("Successful request was not counted")
+	}
+	if count("entry", strconv.Itoa(NewInvalidParamsError().Code)) != invalid+1 {
+		t.Errorf("Failed request was not counted with its error code")
+	}
+	if count("unknown", strconv.Itoa(NewMethodNotFoundError().Code)) != unknown+1 {
+		t.Errorf("Unknown method was not counted")
+	}
+
+	m := new(dto.Metric)
+	V2APIRequestsInFlight.Write(m)
+	if m.GetGauge().GetValue() != 0 {
+		t.Errorf("Requests are still counted as in flight - %v", m.GetGauge().GetValue())
+	}
+}
+
+func TestHandleV2ReadOnlyNode(t *testing.T) {
+	state := testHelper.CreateAndPopulateTestState()
+	state.ReadOnlyDB = true
+
+	code := NewReadOnlyNodeError().Code
+	for _, method := range []string{"commit-chain", "commit-entry", "reveal-chain", "reveal-entry", "factoid-submit", "send-raw-message"} {
+		_, jsonError := HandleV2Request(state, primitives.NewJSON2Request(method, 1, nil))
+		if jsonError == nil || jsonError.Code != code {
+			t.Errorf("Expected a read only error for %v, got %v", method, jsonError)
+		}
+	}
+
+	// Reads are still served
+	_, jsonError := HandleV2Request(state, primitives.NewJSON2Request("heights", 1, nil))
+	if jsonError != nil {
+		t.Errorf("%v", jsonError)
+	}
+}
+
+func TestHandleV2GetPendingFilters(t *testing.T) {
+	state := testHelper.CreateEmptyTestState()
+
+	valid := []interface{}{
+		nil,
+		map[string]interface{}{"chainid": primitives.RandomHash().String()},
+		map[string]interface{}{"chainids": []string{primitives.RandomHash().String()}, "minminute": 3},
+		map[string]interface{}{"ecpubkey": primitives.RandomHash().String()},
+		map[string]interface{}{"ecpubkey": "EC2DKSYyRcNWf7RS963VFYgMExoHRYLHVeCfQ9PGPmNzwrcmgm2r"},
+	}
+	for i, params := range valid {
+		r, jsonError := HandleV2GetPendingEntries(state, params)
+		if jsonError != nil {
+			t.Errorf("%v: %v", i, jsonError)
+			continue
+		}
+		if list, ok := r.([]interfaces.IPendingEntry); !ok || len(list) != 0 {
+			t.Errorf("%v: wrong result %v", i, r)
+		}
+	}
+
+	invalid := []interface{}{
+		map[string]interface{}{"chainids": []string{"abc"}},
+		map[string]interface{}{"ecpubkey": "1234"},
+		map[string]interface{}{"minminute": 11},
+		map[string]interface{}{"minminute": -1},
+	}
+	for i, params := range invalid {
+		if _, jsonError := HandleV2GetPendingEntries(state, params); jsonError == nil {
+			t.Errorf("%v: expected an error", i)
+		}
+	}
+
+	if _, jsonError := HandleV2GetPendingTransactions(state, map[string]interface{}{"address": "FA2jK2HcLnRdS94dEcU27rF3meoJfpUcZPSinpb7AwQvPRY6RL1Q", "minminute": 2}); jsonError != nil {
+		t.Errorf("%v", jsonError)
+	}
+	if _, jsonError := HandleV2GetPendingTransactions(state, map[string]interface{}{"minminute": 20}); jsonError == nil {
+		t.Error("expected an error")
+	}
+}